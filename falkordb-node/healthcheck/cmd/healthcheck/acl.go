@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// aclUser is one entry of VERIFY_ACL_USERS
+type aclUser struct {
+	name         string
+	passwordFile string
+	// reader users also run GRAPH.RO_QUERY on ACL_VERIFY_GRAPH
+	reader bool
+}
+
+// aclResult is whether one ACL user could authenticate and run its
+// commands
+type aclResult struct {
+	User  string `json:"user"`
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+// aclVerification is the last verification of VERIFY_ACL_USERS, reused for
+// ACL_VERIFY_INTERVAL_MS so the extra connections aren't opened per probe
+var aclVerification = struct {
+	mu      sync.Mutex
+	at      time.Time
+	reason  string
+	detail  string
+	results []aclResult
+}{}
+
+func aclUsersCheck(report *healthReport, cfg *Config) check {
+	return check{name: "acl_users", omitEmpty: true, run: func(ctx context.Context) (string, string, error) {
+		reason, detail, results := checkACLUsers(ctx, cfg)
+		report.ACLUsers = results
+		return reason, detail, nil
+	}}
+}
+
+// checkACLUsers verifies that every user of VERIFY_ACL_USERS can
+// authenticate with the password in its file and PING, readers also running
+// GRAPH.RO_QUERY, to validate a migration from requirepass to ACL users. The
+// users are verified one after the other, each over a single connection
+// closed right after, so at most one extra connection is open at a time. A
+// failing user is only a warning unless ACL_VERIFY_MODE=fail, which fails
+// readiness with ACL_USER_FAILED.
+func checkACLUsers(probeCtx context.Context, cfg *Config) (string, string, []aclResult) {
+	// The users are those of the local node
+	if len(cfg.ACLUsers) == 0 || probeTarget(probeCtx) != "" {
+		return "", "", nil
+	}
+
+	aclVerification.mu.Lock()
+	defer aclVerification.mu.Unlock()
+
+	if !aclVerification.at.IsZero() && time.Since(aclVerification.at) < cfg.ACLVerifyInterval {
+		return aclVerification.reason, aclVerification.detail, aclVerification.results
+	}
+
+	results := make([]aclResult, 0, len(cfg.ACLUsers))
+	var failed []string
+	for _, user := range cfg.ACLUsers {
+		result := aclResult{User: user.name, OK: true}
+		if err := verifyACLUser(probeCtx, cfg, user); err != nil {
+			result.OK, result.Error = false, err.Error()
+			failed = append(failed, user.name)
+			slog.Warn("ACL user failed verification", "user", user.name, "error", err)
+		}
+		updateACLUserMetric(user.name, result.OK)
+		results = append(results, result)
+	}
+
+	var reason string
+	detail := fmt.Sprintf("users=%d", len(results))
+	if len(failed) > 0 {
+		detail = "failed=" + strings.Join(failed, ",")
+		if cfg.ACLVerifyMode == "fail" {
+			reason = "ACL_USER_FAILED " + detail
+		} else {
+			detail = "warning: ACL_USER_FAILED " + detail
+		}
+	}
+
+	// An evaluation cut short says nothing about the users, the next one
+	// verifies them again
+	if probeCtx.Err() == nil {
+		aclVerification.at, aclVerification.reason, aclVerification.detail, aclVerification.results = time.Now(), reason, detail, results
+	}
+	return reason, detail, results
+}
+
+// verifyACLUser authenticates as user over a new connection and runs the
+// commands it must be allowed, within ACL_VERIFY_TIMEOUT_MS
+func verifyACLUser(probeCtx context.Context, cfg *Config, user aclUser) error {
+	data, err := os.ReadFile(user.passwordFile)
+	if err != nil {
+		return err
+	}
+
+	options := *probesOf(probeCtx).targets.base
+	options.CredentialsProvider = nil
+	options.Username, options.Password = user.name, strings.TrimSpace(string(data))
+	// The user may not be allowed CLIENT SETNAME
+	options.ClientName = ""
+	options.PoolSize = 1
+	options.MinIdleConns = 0
+	options.DialTimeout = cfg.ACLVerifyTimeout
+	options.ReadTimeout = cfg.ACLVerifyTimeout
+	options.WriteTimeout = cfg.ACLVerifyTimeout
+	client := redis.NewClient(&options)
+	defer client.Close()
+
+	userCtx, cancel := context.WithTimeout(probeCtx, cfg.ACLVerifyTimeout)
+	defer cancel()
+
+	if err := client.Ping(userCtx).Err(); err != nil {
+		return err
+	}
+	if !user.reader {
+		return nil
+	}
+
+	err = client.Do(userCtx, "GRAPH.RO_QUERY", cfg.ACLVerifyGraph, "MATCH (n) RETURN n LIMIT 1").Err()
+	// A graph that doesn't exist yet still proves the user may query it
+	if err != nil && isRedisReply(err) && strings.Contains(err.Error(), "empty key") {
+		return nil
+	}
+	if err != nil {
+		return errors.New("GRAPH.RO_QUERY: " + err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rejectedLogInterval bounds how often rejected sources are logged, a
+// scanner hitting the port must not flood the logs
+const rejectedLogInterval = 10 * time.Second
+
+var rejectedLog = struct {
+	mu         sync.Mutex
+	last       time.Time
+	suppressed int
+}{}
+
+// allowCIDRs answers 403 FORBIDDEN to clients outside HEALTH_ALLOWED_CIDRS
+// before anything else runs. The client is the TCP peer, or with
+// TRUST_PROXY_HEADERS the last X-Forwarded-For entry, the one added by the
+// proxy in front. The unix socket is guarded by its file mode instead.
+func allowCIDRs(cfg *Config, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Not a TCP peer, the unix socket
+		if net.ParseIP(requestSource(r)) == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		source := clientIP(r, cfg.TrustProxyHeaders)
+		if ip := net.ParseIP(source); ip == nil || !containsAddr(cfg.AllowedCIDRs, ip) {
+			logRejected(r, source)
+			writeError(w, r, http.StatusForbidden, "FORBIDDEN", "")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// clientIP returns the address of the client, from X-Forwarded-For when the
+// proxy headers are trusted
+func clientIP(r *http.Request, trustProxy bool) string {
+	if forwarded := r.Header.Values("X-Forwarded-For"); trustProxy && len(forwarded) > 0 {
+		entries := strings.Split(forwarded[len(forwarded)-1], ",")
+		return strings.TrimSpace(entries[len(entries)-1])
+	}
+	return requestSource(r)
+}
+
+func containsAddr(nets []*net.IPNet, ip net.IP) bool {
+	for _, ipNet := range nets {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// logRejected logs a rejected source at most once per rejectedLogInterval,
+// with the number of rejections left out since
+func logRejected(r *http.Request, source string) {
+	rejectedLog.mu.Lock()
+	defer rejectedLog.mu.Unlock()
+
+	if time.Since(rejectedLog.last) < rejectedLogInterval {
+		rejectedLog.suppressed++
+		return
+	}
+	slog.Warn("rejected request from outside HEALTH_ALLOWED_CIDRS", "source", source, "path", r.URL.Path, "remote_addr", r.RemoteAddr, "suppressed", rejectedLog.suppressed)
+	rejectedLog.last, rejectedLog.suppressed = time.Now(), 0
+}
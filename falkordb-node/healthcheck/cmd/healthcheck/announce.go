@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+)
+
+// podIP returns the address the node should announce, from POD_IP or the
+// first non-loopback interface address.
+func podIP(cfg *Config) (string, error) {
+	if cfg.PodIP != "" {
+		return cfg.PodIP, nil
+	}
+
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return "", err
+	}
+
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok && !ipNet.IP.IsLoopback() && !ipNet.IP.IsLinkLocalUnicast() {
+			return ipNet.IP.String(), nil
+		}
+	}
+	return "", errors.New("no non-loopback interface address found")
+}
+
+// checkAnnounceAddress verifies cluster-announce-ip still points at this pod.
+// A stale value left after rescheduling sends MOVED redirects to a dead
+// address while the node itself looks healthy. Hostnames are resolved before
+// comparing. With ANNOUNCE_MISMATCH_WARN_ONLY, for topologies announcing a
+// service VIP on purpose, a mismatch is only logged.
+func checkAnnounceAddress(probeCtx context.Context, cfg *Config) (string, string, error) {
+	// The pod address is only known for the local node
+	if probeTarget(probeCtx) != "" {
+		return "", "", nil
+	}
+
+	reply, err := nodeClient(probeCtx).ConfigGet(probeCtx, "cluster-announce-*").Result()
+	if err != nil {
+		return "", "", err
+	}
+
+	announced := reply["cluster-announce-ip"]
+	if announced == "" {
+		return "", "cluster-announce-ip unset", nil
+	}
+
+	actual, err := podIP(cfg)
+	if err != nil {
+		return "", "", err
+	}
+
+	addrs := []string{announced}
+	if net.ParseIP(announced) == nil {
+		if addrs, err = net.DefaultResolver.LookupHost(probeCtx, announced); err != nil {
+			return "", "", err
+		}
+	}
+
+	mismatch := fmt.Sprintf("announced=%s actual=%s", announced, actual)
+	if !containsIP(addrs, actual) {
+		return announceMismatch(cfg, "ANNOUNCE_MISMATCH "+mismatch, mismatch)
+	}
+
+	// A zero announce port means the node announces the port it listens on
+	if port := reply["cluster-announce-port"]; port != "" && port != "0" && cfg.NodePort != "" && port != cfg.NodePort {
+		mismatch = fmt.Sprintf("announced_port=%s actual_port=%s", port, cfg.NodePort)
+		return announceMismatch(cfg, "ANNOUNCE_MISMATCH "+mismatch, mismatch)
+	}
+
+	return "", "announced=" + announced, nil
+}
+
+func announceMismatch(cfg *Config, reason string, detail string) (string, string, error) {
+	if cfg.AnnounceMismatchWarnOnly {
+		slog.Warn("cluster announce address doesn't match the pod", "detail", detail)
+		return "", detail + " (ignored)", nil
+	}
+	return reason, detail, nil
+}
+
+func containsIP(addrs []string, ip string) bool {
+	want := net.ParseIP(ip)
+	for _, addr := range addrs {
+		if parsed := net.ParseIP(addr); parsed != nil && parsed.Equal(want) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"crypto/subtle"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// maxAuthFailures failed attempts within authFailureWindow block a source
+	// until the window ends
+	maxAuthFailures   = 5
+	authFailureWindow = time.Minute
+	// maxAuthSources bounds the sources tracked at once
+	maxAuthSources = 1024
+)
+
+type authFailure struct {
+	count int
+	since time.Time
+}
+
+// authFailures counts the failed attempts of each source, by IP address
+var authFailures = struct {
+	mu      sync.Mutex
+	sources map[string]*authFailure
+}{sources: map[string]*authFailure{}}
+
+// requireAdmin only lets requests carrying HEALTH_ADMIN_TOKEN as a bearer
+// token through. Others get a bare 401, and a source failing too often gets
+// 429 until authFailureWindow passed.
+func requireAdmin(cfg *Config, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		source := requestSource(r)
+		if retryAfter, blocked := authBlocked(source); blocked {
+			w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Seconds())+1))
+			writeError(w, r, http.StatusTooManyRequests, "TOO_MANY_REQUESTS", "")
+			return
+		}
+
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || cfg.AdminToken == "" || subtle.ConstantTimeCompare([]byte(token), []byte(cfg.AdminToken)) != 1 {
+			failures := recordAuthFailure(source)
+			slog.Warn("unauthorized request", "request_id", requestID(r), "path", r.URL.Path, "remote_addr", r.RemoteAddr, "failures", failures)
+			if failures == maxAuthFailures {
+				slog.Warn("too many unauthorized requests, blocking source", "remote_addr", r.RemoteAddr, "for", authFailureWindow)
+			}
+
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, r, http.StatusUnauthorized, "UNAUTHORIZED", "")
+			return
+		}
+
+		clearAuthFailures(source)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requestSource is the IP address of the caller, the connection itself over
+// the unix socket
+func requestSource(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// authBlocked reports whether source failed maxAuthFailures times within the
+// current window, and how long until the window ends
+func authBlocked(source string) (time.Duration, bool) {
+	authFailures.mu.Lock()
+	defer authFailures.mu.Unlock()
+
+	failure, ok := authFailures.sources[source]
+	if !ok || failure.count < maxAuthFailures {
+		return 0, false
+	}
+	left := authFailureWindow - time.Since(failure.since)
+	if left <= 0 {
+		delete(authFailures.sources, source)
+		return 0, false
+	}
+	return left, true
+}
+
+// recordAuthFailure counts a failed attempt of source and returns the count
+// within the current window
+func recordAuthFailure(source string) int {
+	authFailures.mu.Lock()
+	defer authFailures.mu.Unlock()
+
+	now := time.Now()
+	if len(authFailures.sources) >= maxAuthSources {
+		for key, failure := range authFailures.sources {
+			if now.Sub(failure.since) > authFailureWindow {
+				delete(authFailures.sources, key)
+			}
+		}
+	}
+
+	failure, ok := authFailures.sources[source]
+	if !ok || now.Sub(failure.since) > authFailureWindow {
+		failure = &authFailure{since: now}
+		// Past the bound a flood of sources is only logged, not tracked
+		if len(authFailures.sources) >= maxAuthSources {
+			return 1
+		}
+		authFailures.sources[source] = failure
+	}
+	failure.count++
+	return failure.count
+}
+
+func clearAuthFailures(source string) {
+	authFailures.mu.Lock()
+	defer authFailures.mu.Unlock()
+
+	delete(authFailures.sources, source)
+}
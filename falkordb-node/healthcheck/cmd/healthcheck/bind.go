@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"net"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// checkNetworkExposure fails a node other pods can't reach while the
+// localhost probe still passes: one only bound to loopback addresses, or one
+// with protected-mode on although the deployment relies on a password for
+// access control. ALLOW_LOOPBACK_ONLY skips it for nodes meant to only be
+// reached locally.
+func checkNetworkExposure(probeCtx context.Context, cfg *Config) (string, string, error) {
+	if cfg.AllowLoopbackOnly {
+		return "", "skipped, ALLOW_LOOPBACK_ONLY", nil
+	}
+
+	var protectedMode, bind *redis.MapStringStringCmd
+	_, err := nodeClient(probeCtx).Pipelined(probeCtx, func(pipe redis.Pipeliner) error {
+		protectedMode = pipe.ConfigGet(probeCtx, "protected-mode")
+		bind = pipe.ConfigGet(probeCtx, "bind")
+		return nil
+	})
+	if err != nil {
+		return "", "", err
+	}
+
+	addrs := bind.Val()["bind"]
+	if loopbackOnly(addrs) {
+		detail := "bind=" + addrs
+		return "BIND_LOOPBACK_ONLY " + detail, detail, nil
+	}
+
+	mode := protectedMode.Val()["protected-mode"]
+	if mode == "yes" && passwordExpected(cfg) {
+		detail := "protected-mode=yes"
+		return "PROTECTED_MODE_ENABLED " + detail, detail, nil
+	}
+	return "", "protected-mode=" + mode + " bind=" + addrs, nil
+}
+
+// loopbackOnly reports whether every address of the bind directive is a
+// loopback one. Without any the node listens on every interface.
+func loopbackOnly(bind string) bool {
+	addrs := strings.Fields(bind)
+	for _, addr := range addrs {
+		// A leading - makes the address optional
+		addr = strings.TrimPrefix(addr, "-")
+		if addr == "localhost" {
+			continue
+		}
+		if ip := net.ParseIP(addr); ip == nil || !ip.IsLoopback() {
+			return false
+		}
+	}
+	return len(addrs) > 0
+}
+
+// passwordExpected reports whether the probes authenticate, which means the
+// deployment controls access with a password rather than the network
+func passwordExpected(cfg *Config) bool {
+	return cfg.Password != "" || cfg.AdminPassword != "" || cfg.AdminPasswordFile != ""
+}
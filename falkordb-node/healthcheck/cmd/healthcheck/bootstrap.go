@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func (c *nodeCredentials) inBootstrap() bool {
+	return time.Now().Before(c.bootstrapUntil)
+}
+
+// bootstrapPhase is reported with probes when BOOTSTRAP_GRACE_SECONDS is set
+func (c *nodeCredentials) bootstrapPhase() string {
+	if c.inBootstrap() {
+		return "bootstrap"
+	}
+	return "steady-state"
+}
+
+// isNoPasswordSetError reports whether the node rejected AUTH because it has
+// no password configured yet
+func isNoPasswordSetError(err error) bool {
+	if !isRedisReply(err) {
+		return false
+	}
+
+	msg := err.Error()
+	return strings.Contains(msg, "no password is set") || strings.Contains(msg, "without any password configured")
+}
+
+// skipAuthForBootstrap makes new connections skip AUTH after the node said
+// it has no password, during bootstrap only. It returns true the first time
+// so the failed command is retried right away.
+func skipAuthForBootstrap(probeCtx context.Context, err error) bool {
+	credentials := probesOf(probeCtx).credentials
+	if !isNoPasswordSetError(err) || !credentials.inBootstrap() || credentials.unauthenticated.Swap(true) {
+		return false
+	}
+
+	slog.Warn("node has no password set yet, connecting without auth", "phase", credentials.bootstrapPhase(), "bootstrap_until", credentials.bootstrapUntil)
+	return true
+}
+
+// checkBootstrapAuth reports a node probed without auth: ready with a
+// warning during bootstrap, failing with AUTH_NOT_CONFIGURED after it. The
+// connections opened without auth stay in the pool once the password is
+// applied, so the node is asked to AUTH on one of them, which ends the
+// bootstrap as soon as it succeeds.
+func checkBootstrapAuth(probeCtx context.Context, report *healthReport) {
+	p := probesOf(probeCtx)
+	if probeTarget(probeCtx) != "" || !p.credentials.unauthenticated.Load() {
+		return
+	}
+
+	err := authenticate(probeCtx, p.client, p.credentials)
+	switch {
+	case err == nil:
+		p.credentials.unauthenticated.Store(false)
+		slog.Info("node password applied, authenticating again", "phase", p.credentials.bootstrapPhase())
+		report.pass("auth", "")
+	case !isNoPasswordSetError(err):
+		report.failErr(probeCtx, "auth", err)
+	case p.credentials.inBootstrap():
+		report.pass("auth", "warning: node has no password set yet")
+	default:
+		report.fail(http.StatusServiceUnavailable, "AUTH_NOT_CONFIGURED", "auth", "node still has no password set after BOOTSTRAP_GRACE_SECONDS")
+	}
+}
+
+// authenticate sends AUTH with the configured credentials over a connection
+// of client
+func authenticate(probeCtx context.Context, client redis.UniversalClient, credentials *nodeCredentials) error {
+	user, password := credentials.configured()
+	if user != "" {
+		return client.Do(probeCtx, "AUTH", user, password).Err()
+	}
+	return client.Do(probeCtx, "AUTH", password).Err()
+}
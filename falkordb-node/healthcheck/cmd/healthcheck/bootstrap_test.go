@@ -0,0 +1,65 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// bootstrappingNode returns a node with no password until the returned flag
+// is set, after which it only accepts secret
+func bootstrappingNode() (*fakeNode, *atomic.Bool) {
+	node := newFakeNode(masterInfo)
+	applied := &atomic.Bool{}
+	node.reply("AUTH", func(args []string) any {
+		switch {
+		case !applied.Load():
+			return replyError("ERR AUTH <password> called without any password configured for the default user. Are you sure your configuration is correct?")
+		case args[len(args)-1] != "secret":
+			return replyError("WRONGPASS invalid username-password pair or user is disabled.")
+		}
+		return status("OK")
+	})
+	return node, applied
+}
+
+func TestBootstrapPasswordApplied(t *testing.T) {
+	node, applied := bootstrappingNode()
+	cfg := testConfig(t, map[string]string{"ADMIN_PASSWORD": "secret", "BOOTSTRAP_GRACE_SECONDS": "60"})
+	p := newTestProbes(t, cfg, node)
+
+	checks := readinessChecks(t, cfg, p, http.StatusOK)
+	if !strings.HasPrefix(checks["auth"].Detail, "warning") || !p.credentials.unauthenticated.Load() {
+		t.Fatalf("auth check = %+v, want a warning while the node has no password", checks["auth"])
+	}
+
+	// The pooled connections opened without auth keep working
+	applied.Store(true)
+	if checks := readinessChecks(t, cfg, p, http.StatusOK); !checks["auth"].OK || checks["auth"].Detail != "" {
+		t.Errorf("auth check = %+v, want a pass once the password is applied", checks["auth"])
+	}
+	if p.credentials.unauthenticated.Load() {
+		t.Error("still connecting without auth after the node accepted AUTH")
+	}
+
+	p.credentials.bootstrapUntil = time.Now()
+	if checks := readinessChecks(t, cfg, p, http.StatusOK); checks["auth"].Name != "" {
+		t.Errorf("auth check = %+v after BOOTSTRAP_GRACE_SECONDS, want none", checks["auth"])
+	}
+}
+
+func TestBootstrapPasswordNeverApplied(t *testing.T) {
+	node, _ := bootstrappingNode()
+	cfg := testConfig(t, map[string]string{"ADMIN_PASSWORD": "secret", "BOOTSTRAP_GRACE_SECONDS": "60"})
+	p := newTestProbes(t, cfg, node)
+
+	readinessChecks(t, cfg, p, http.StatusOK)
+	p.credentials.bootstrapUntil = time.Now()
+
+	w := serve(t, cfg, p, http.MethodGet, "/readyz?nocache=1", nil)
+	if w.Code != http.StatusServiceUnavailable || !strings.HasPrefix(w.Body.String(), "AUTH_NOT_CONFIGURED") {
+		t.Errorf("GET /readyz = %d %q, want 503 AUTH_NOT_CONFIGURED", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"falkordb.cloud/main/internal/redisinfo"
+)
+
+// infoCache keeps the last parsed INFO reply for a short TTL so bursts of
+// probes from several callers only cost a single round trip.
+type infoCache struct {
+	ttl time.Duration
+
+	mu        sync.Mutex
+	info      *redisinfo.Info
+	fetchedAt time.Time
+}
+
+func (c *infoCache) get() (*redisinfo.Info, time.Time, bool) {
+	if c.ttl <= 0 {
+		return nil, time.Time{}, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.info == nil || time.Since(c.fetchedAt) > c.ttl {
+		return nil, time.Time{}, false
+	}
+	return c.info, c.fetchedAt, true
+}
+
+func (c *infoCache) set(info *redisinfo.Info, fetchedAt time.Time) {
+	if c.ttl <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.info = info
+	c.fetchedAt = fetchedAt
+}
+
+// invalidate drops the cached reply so the next probe sees fresh data
+func (c *infoCache) invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.info = nil
+}
+
+type noCacheKey struct{}
+
+// withNoCache marks a probe context as requiring a fresh INFO fetch
+func withNoCache(probeCtx context.Context) context.Context {
+	return context.WithValue(probeCtx, noCacheKey{}, true)
+}
+
+func noCache(probeCtx context.Context) bool {
+	v, _ := probeCtx.Value(noCacheKey{}).(bool)
+	return v
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// capabilityRecheckInterval is how often commands found unavailable are
+// tried again, so a fixed ACL or rename is picked up
+const capabilityRecheckInterval = 30 * time.Second
+
+// capabilityProbes are the commands checks depend on that hardened
+// deployments rename or disable, each with a harmless invocation
+var capabilityProbes = map[string][]interface{}{
+	"CONFIG":  {"CONFIG", "GET", "maxclients"},
+	"SLOWLOG": {"SLOWLOG", "LEN"},
+	"LATENCY": {"LATENCY", "LATEST"},
+	"CLUSTER": {"CLUSTER", "INFO"},
+	"MODULE":  {"MODULE", "LIST"},
+}
+
+// checkCommands are the commands each check can't run without. Other checks
+// using them only in some configurations are skipped when they hit one.
+var checkCommands = map[string][]string{
+	"clients":          {"CONFIG"},
+	"network":          {"CONFIG"},
+	"persistence_mode": {"CONFIG"},
+	"replica_config":   {"CONFIG"},
+	"standby":          {"CONFIG"},
+	"announce":         {"CONFIG"},
+	"latency_events":   {"LATENCY", "CONFIG"},
+	"slowlog":          {"SLOWLOG"},
+	"module":           {"MODULE"},
+	"module_version":   {"MODULE"},
+	"cluster":          {"CLUSTER"},
+	"cluster_nodes":    {"CLUSTER"},
+	"cluster_master":   {"CLUSTER"},
+	"slot_migrations":  {"CLUSTER"},
+	"slots":            {"CLUSTER"},
+}
+
+// capability is whether the local node runs a command for the healthcheck
+type capability struct {
+	Available bool      `json:"available"`
+	Error     string    `json:"error,omitempty"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// capabilities caches the capabilities of the local node. A command not
+// probed yet, or whose probe didn't get an answer, is assumed available.
+type capabilities struct {
+	mu       sync.Mutex
+	commands map[string]capability
+}
+
+// unavailableCommand returns the command the node refused as unknown, renamed
+// or disabled, or not allowed by the ACL of our user, when checks depend on
+// it. The command is the quoted one of "ERR unknown command 'CONFIG'" and
+// "NOPERM ... run the 'config|get' command", or the one named by
+// "ERR unknown subcommand 'x'. Try CONFIG HELP."
+func unavailableCommand(err error) string {
+	if !isRedisReply(err) {
+		return ""
+	}
+	msg := err.Error()
+	switch {
+	case strings.HasPrefix(msg, "ERR unknown subcommand"):
+		for _, word := range strings.Fields(msg) {
+			if _, ok := capabilityProbes[word]; ok {
+				return word
+			}
+		}
+		return ""
+	case !strings.HasPrefix(msg, "ERR unknown command") && !isNoPermError(err):
+		return ""
+	}
+
+	_, quoted, ok := strings.Cut(msg, "'")
+	if !ok {
+		return ""
+	}
+	quoted, _, _ = strings.Cut(quoted, "'")
+	command, _, _ := strings.Cut(strings.ToUpper(quoted), "|")
+	if _, ok := capabilityProbes[command]; !ok {
+		return ""
+	}
+	return command
+}
+
+// probe issues the commands once and records which are unavailable
+func (c *capabilities) probe(probeCtx context.Context, commands ...string) {
+	for _, command := range commands {
+		args, ok := capabilityProbes[command]
+		if !ok {
+			continue
+		}
+
+		err := nodeClient(probeCtx).Do(probeCtx, args...).Err()
+		// Only the node refusing the command tells anything
+		if err != nil && !isRedisReply(err) {
+			continue
+		}
+
+		result := capability{Available: unavailableCommand(err) != command, CheckedAt: time.Now().UTC()}
+		if !result.Available {
+			result.Error = err.Error()
+		}
+
+		c.mu.Lock()
+		previous, seen := c.commands[command]
+		c.commands[command] = result
+		c.mu.Unlock()
+
+		switch {
+		case !result.Available && (!seen || previous.Available):
+			slog.Warn("command unavailable, the checks depending on it are skipped", "command", command, "error", err)
+		case result.Available && seen && !previous.Available:
+			slog.Info("command available again", "command", command)
+		}
+	}
+}
+
+// recheck tries the unavailable commands again once
+// capabilityRecheckInterval has passed
+func (c *capabilities) recheck(probeCtx context.Context) {
+	var stale []string
+	c.mu.Lock()
+	for command, result := range c.commands {
+		if !result.Available && time.Since(result.CheckedAt) >= capabilityRecheckInterval {
+			stale = append(stale, command)
+		}
+	}
+	c.mu.Unlock()
+
+	c.probe(probeCtx, stale...)
+}
+
+// missing returns the first unavailable command the check depends on
+func (c *capabilities) missing(name string) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, command := range checkCommands[name] {
+		if result, ok := c.commands[command]; ok && !result.Available {
+			return command
+		}
+	}
+	return ""
+}
+
+// detectCapabilities probes every command once at startup, within parent.
+// Sentinels don't serve the data node commands.
+func detectCapabilities(parent context.Context, cfg *Config, p *probes) {
+	if cfg.SentinelMode {
+		return
+	}
+
+	probeCtx, cancel := context.WithTimeout(withProbes(parent, p), p.probeTimeout)
+	defer cancel()
+
+	commands := make([]string, 0, len(capabilityProbes))
+	for command := range capabilityProbes {
+		commands = append(commands, command)
+	}
+	sort.Strings(commands)
+	p.capabilities.probe(probeCtx, commands...)
+}
+
+// debugCapabilities is the body of /debug/capabilities
+type debugCapabilities struct {
+	Commands map[string]capability `json:"commands"`
+	// Skipped are the checks skipped for an unavailable command
+	Skipped map[string]string `json:"skipped"`
+}
+
+// debugCapabilitiesHandler returns the capability map, probed again first
+// with refresh=1
+func debugCapabilitiesHandler(cfg *Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		p := probesOf(r.Context())
+		if r.URL.Query().Get("refresh") == "1" {
+			detectCapabilities(r.Context(), cfg, p)
+		}
+
+		body := debugCapabilities{Commands: map[string]capability{}, Skipped: map[string]string{}}
+		p.capabilities.mu.Lock()
+		for command, result := range p.capabilities.commands {
+			body.Commands[command] = result
+		}
+		p.capabilities.mu.Unlock()
+
+		for name := range checkCommands {
+			if command := p.capabilities.missing(name); command != "" {
+				body.Skipped[name] = command
+			}
+		}
+		writeJSON(w, r, http.StatusOK, body)
+	}
+}
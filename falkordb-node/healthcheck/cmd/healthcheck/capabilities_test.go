@@ -0,0 +1,164 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// configGet answers CONFIG GET like a node binding every interface, with no
+// latency monitor
+func configGet(args []string) any {
+	values := map[string]string{"bind": "0.0.0.0", "protected-mode": "no", "latency-monitor-threshold": "0"}
+	return []string{args[2], values[args[2]]}
+}
+
+func TestUnavailableCommand(t *testing.T) {
+	for _, tt := range []struct {
+		err  error
+		want string
+	}{
+		{redisReply("ERR unknown command 'CONFIG', with args beginning with: 'GET' 'bind'"), "CONFIG"},
+		{redisReply("ERR unknown command 'slowlog', with args beginning with: 'LEN'"), "SLOWLOG"},
+		{redisReply("NOPERM User healthcheck has no permissions to run the 'config|get' command"), "CONFIG"},
+		{redisReply("ERR unknown subcommand 'LATEST'. Try LATENCY HELP."), "LATENCY"},
+		{redisReply("ERR unknown command 'GRAPH.QUERY', with args beginning with: "), ""},
+		{redisReply("ERR syntax error"), ""},
+		{errors.New("ERR unknown command 'CONFIG'"), ""},
+		{nil, ""},
+	} {
+		if got := unavailableCommand(tt.err); got != tt.want {
+			t.Errorf("unavailableCommand(%v) = %q, want %q", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestChecksSkippedForUnavailableCommand(t *testing.T) {
+	for _, refusal := range []string{
+		"ERR unknown command 'CONFIG', with args beginning with: 'GET' 'bind'",
+		"NOPERM User healthcheck has no permissions to run the 'config|get' command",
+		"ERR unknown subcommand 'GET'. Try CONFIG HELP.",
+	} {
+		t.Run(refusal, func(t *testing.T) {
+			cfg := testConfig(t, map[string]string{"CHECK_LATENCY_EVENTS": "true"})
+			node := newFakeNode(masterInfo)
+			node.reply("LATENCY LATEST", []any{})
+			node.reply("CONFIG", replyError(refusal))
+			p := newTestProbes(t, cfg, node)
+
+			checks := readinessChecks(t, cfg, p, http.StatusOK)
+			for _, name := range []string{"network", "latency_events"} {
+				if got := checks[name]; !got.OK || got.Detail != "skipped: command unavailable (CONFIG)" {
+					t.Errorf("%s = %+v, want skipped for CONFIG", name, got)
+				}
+			}
+
+			// The next evaluation skips them without asking the node again
+			configs, latencies := node.called("CONFIG"), node.called("LATENCY")
+			checks = readinessChecks(t, cfg, p, http.StatusOK)
+			if got := checks["network"]; got.Detail != "skipped: command unavailable (CONFIG)" {
+				t.Errorf("network = %+v on the second evaluation, want skipped", got)
+			}
+			if node.called("CONFIG") != configs || node.called("LATENCY") != latencies {
+				t.Errorf("the second evaluation sent CONFIG %d and LATENCY %d times, want none", node.called("CONFIG")-configs, node.called("LATENCY")-latencies)
+			}
+		})
+	}
+}
+
+func TestCapabilityRecheck(t *testing.T) {
+	cfg := testConfig(t, map[string]string{"CHECK_LATENCY_EVENTS": "true"})
+	node := newFakeNode(masterInfo)
+	node.reply("LATENCY LATEST", []any{})
+	node.reply("CONFIG", replyError("ERR unknown command 'CONFIG', with args beginning with: 'GET' 'bind'"))
+	p := newTestProbes(t, cfg, node)
+
+	readinessChecks(t, cfg, p, http.StatusOK)
+	if command := p.capabilities.missing("network"); command != "CONFIG" {
+		t.Fatalf("missing(network) = %q, want CONFIG", command)
+	}
+
+	// CONFIG renamed back, it is only tried again once the recheck is due
+	node.reply("CONFIG GET", configGet)
+	if got := readinessChecks(t, cfg, p, http.StatusOK)["network"]; got.Detail != "skipped: command unavailable (CONFIG)" {
+		t.Errorf("network = %+v before the recheck, want skipped", got)
+	}
+
+	p.capabilities.mu.Lock()
+	result := p.capabilities.commands["CONFIG"]
+	result.CheckedAt = result.CheckedAt.Add(-capabilityRecheckInterval)
+	p.capabilities.commands["CONFIG"] = result
+	p.capabilities.mu.Unlock()
+
+	checks := readinessChecks(t, cfg, p, http.StatusOK)
+	if got := checks["network"]; !got.OK || got.Detail != "protected-mode=no bind=0.0.0.0" {
+		t.Errorf("network = %+v after the recheck, want it run", got)
+	}
+	if got := checks["latency_events"]; got.Detail != "skipped, latency monitor disabled" {
+		t.Errorf("latency_events = %+v after the recheck, want it run", got)
+	}
+}
+
+func TestDebugCapabilities(t *testing.T) {
+	cfg := testConfig(t, map[string]string{"ENABLE_DEBUG_ENDPOINTS": "true", "HEALTH_ADMIN_TOKEN": "secret"})
+	node := newFakeNode(masterInfo)
+	node.reply("SLOWLOG", replyError("ERR unknown command 'SLOWLOG', with args beginning with: 'LEN'"))
+	p := newTestProbes(t, cfg, node)
+	admin := http.Header{"Authorization": {"Bearer secret"}}
+
+	capabilities := func(path string) debugCapabilities {
+		t.Helper()
+
+		w := serve(t, cfg, p, http.MethodGet, path, admin)
+		var body debugCapabilities
+		if err := json.Unmarshal(w.Body.Bytes(), &body); w.Code != http.StatusOK || err != nil {
+			t.Fatalf("GET %s = %d %q", path, w.Code, w.Body.String())
+		}
+		return body
+	}
+
+	body := capabilities("/debug/capabilities?refresh=1")
+	if got := body.Commands["SLOWLOG"]; got.Available || got.Error == "" || got.CheckedAt.IsZero() {
+		t.Errorf("SLOWLOG = %+v, want unavailable with the error", got)
+	}
+	if got := body.Commands["MODULE"]; !got.Available {
+		t.Errorf("MODULE = %+v, want available", got)
+	}
+	if body.Skipped["slowlog"] != "SLOWLOG" || body.Skipped["module"] != "" {
+		t.Errorf("skipped = %v, want slowlog only among SLOWLOG and MODULE", body.Skipped)
+	}
+
+	node.reply("SLOWLOG", int64(0))
+	if body := capabilities("/debug/capabilities"); body.Skipped["slowlog"] != "SLOWLOG" {
+		t.Errorf("skipped = %v without refresh, want the cached SLOWLOG refusal", body.Skipped)
+	}
+	before := time.Now().UTC()
+	body = capabilities("/debug/capabilities?refresh=1")
+	if got := body.Commands["SLOWLOG"]; !got.Available || got.CheckedAt.Before(before) {
+		t.Errorf("SLOWLOG = %+v after refresh, want available", got)
+	}
+	if _, ok := body.Skipped["slowlog"]; ok {
+		t.Errorf("skipped = %v after refresh, want slowlog run again", body.Skipped)
+	}
+}
+
+// readinessChecks returns the checks of the /readyz JSON report by name,
+// failing the test unless it answered with code
+func readinessChecks(t *testing.T, cfg *Config, p *probes, code int) map[string]checkResult {
+	t.Helper()
+
+	w := serve(t, cfg, p, http.MethodGet, "/readyz?nocache=1", http.Header{"Accept": {"application/json"}})
+	var report struct {
+		Checks []checkResult `json:"checks"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil || w.Code != code {
+		t.Fatalf("GET /readyz = %d %q, want %d", w.Code, w.Body.String(), code)
+	}
+	checks := map[string]checkResult{}
+	for _, result := range report.Checks {
+		checks[result.Name] = result
+	}
+	return checks
+}
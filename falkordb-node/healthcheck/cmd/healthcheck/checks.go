@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"falkordb.cloud/main/internal/checks"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/sync/errgroup"
+)
+
+// maxConcurrentChecks bounds how many checks talk to the node at once
+const maxConcurrentChecks = 4
+
+// check is a named readiness check. run returns a non-empty reason when the
+// check fails, and a detail describing what was observed.
+type check struct {
+	name string
+	run  func(ctx context.Context) (reason string, detail string, err error)
+	// omitEmpty leaves a passing check without detail out of the report, for
+	// thresholds that aren't configured
+	omitEmpty bool
+}
+
+// checkEnabled reports whether the named readiness check runs. Without
+// HEALTH_CHECKS every check of the topology runs except the opt-in ones.
+func (c *Config) checkEnabled(name string) bool {
+	if !checks.InTopology(c.Topology, name) {
+		return false
+	}
+	if c.Checks != nil {
+		return c.Checks[name]
+	}
+
+	switch name {
+	case "module":
+		return !c.SkipModuleCheck
+	case "persistence":
+		return c.CheckPersistence
+	case "graph_query":
+		return c.DeepCheck
+	case "write_probe":
+		return c.WriteProbe
+	case "replica_config":
+		return c.CheckReplicaConfig
+	case "latency_events":
+		return c.CheckLatencyEvents
+	}
+	return true
+}
+
+// activeChecks lists the readiness checks that can run with this config
+func (c *Config) activeChecks() []string {
+	var names []string
+	for _, name := range checks.ForTopology(c.Topology) {
+		if c.checkEnabled(name) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// enabledChecks drops the checks the config doesn't select
+func enabledChecks(cfg *Config, checks []check) []check {
+	var enabled []check
+	for _, c := range checks {
+		if cfg.checkEnabled(c.name) {
+			enabled = append(enabled, c)
+		}
+	}
+	return enabled
+}
+
+type checkOutcome struct {
+	reason  string
+	detail  string
+	err     error
+	elapsed time.Duration
+	timing  *checkTiming
+}
+
+// runCheck runs one check, its panic failing the check rather than crashing
+// the process from the errgroup goroutine
+func runCheck(checkCtx context.Context, c check) (outcome checkOutcome) {
+	defer func() {
+		if v := recover(); v != nil {
+			slog.Error("panic running check", "check", c.name, "panic", v, "stack", string(debug.Stack()))
+			panicCounter.WithLabelValues("check").Inc()
+			probesOf(checkCtx).internal.record(fmt.Sprintf("panic in %s: %v", c.name, v))
+			outcome = checkOutcome{err: &checkPanic{value: v}}
+		}
+	}()
+
+	reason, detail, err := c.run(checkCtx)
+	return checkOutcome{reason: reason, detail: detail, err: err}
+}
+
+// runChecks runs the checks concurrently and records their outcomes in the
+// order they were given, so the report and its body don't depend on which
+// check finished first. On the local node a check whose command is renamed or
+// disabled passes as skipped rather than failing every probe.
+func runChecks(probeCtx context.Context, report *healthReport, checks []check) {
+	outcomes := make([]checkOutcome, len(checks))
+	unavailable := make([]string, len(checks))
+
+	capabilities := probesOf(probeCtx).capabilities
+	local := probeTarget(probeCtx) == ""
+	if local {
+		capabilities.recheck(probeCtx)
+	}
+
+	group, groupCtx := errgroup.WithContext(probeCtx)
+	group.SetLimit(maxConcurrentChecks)
+	for i, c := range checks {
+		i, c := i, c
+		if local {
+			if unavailable[i] = capabilities.missing(c.name); unavailable[i] != "" {
+				continue
+			}
+		}
+		group.Go(func() error {
+			checkCtx, cancel := context.WithTimeout(groupCtx, probesOf(groupCtx).checkTimeout)
+			defer cancel()
+
+			checkCtx, span := tracer.Start(checkCtx, "check "+c.name, trace.WithAttributes(attribute.String("healthcheck.check", c.name)))
+			checkCtx, timing := withCheckTiming(checkCtx)
+			start := time.Now()
+			outcomes[i] = runCheck(checkCtx, c)
+			outcomes[i].elapsed, outcomes[i].timing = time.Since(start), timing
+			endCheckSpan(span, report.Role, outcomes[i])
+			if command := unavailableCommand(outcomes[i].err); local && command != "" {
+				capabilities.probe(checkCtx, command)
+			}
+			// A failing check must not cancel the others, all of them are reported
+			return nil
+		})
+	}
+	group.Wait()
+
+	for i, c := range checks {
+		outcome := outcomes[i]
+		recorded := len(report.Checks)
+		var panicked *checkPanic
+		switch {
+		case unavailable[i] != "":
+			report.pass(c.name, "skipped: command unavailable ("+unavailable[i]+")")
+		case errors.As(outcome.err, &panicked):
+			report.fail(http.StatusInternalServerError, "INTERNAL_PANIC", c.name, panicked.Error())
+		case unavailableCommand(outcome.err) != "":
+			report.pass(c.name, "skipped: command unavailable ("+unavailableCommand(outcome.err)+")")
+		case outcome.err != nil:
+			report.failErr(probeCtx, c.name, outcome.err)
+		case outcome.reason != "":
+			detail := outcome.detail
+			if detail == "" {
+				detail = outcome.reason
+			}
+			report.fail(http.StatusServiceUnavailable, outcome.reason, c.name, detail)
+		case outcome.detail == "" && c.omitEmpty:
+		default:
+			report.pass(c.name, outcome.detail)
+		}
+
+		if outcome.timing == nil {
+			continue
+		}
+		if len(report.Checks) > recorded {
+			report.timeLastCheck(outcome.elapsed, outcome.timing)
+		}
+		if local {
+			observeCheckDuration(c.name, outcome.elapsed, outcome.timing.redisTime())
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestTopologyConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		env      map[string]string
+		topology string
+		cluster  bool
+		sentinel bool
+	}{
+		{name: "default", topology: "replication"},
+		{name: "replication", env: map[string]string{"TOPOLOGY": "replication"}, topology: "replication"},
+		{name: "cluster mode", env: map[string]string{"CLUSTER_MODE": "true"}, topology: "cluster", cluster: true},
+		{name: "sentinel mode", env: map[string]string{"SENTINEL_MODE": "true", "MASTER_NAME": "mymaster"}, topology: "sentinel", sentinel: true},
+		{name: "cluster", env: map[string]string{"TOPOLOGY": "Cluster"}, topology: "cluster", cluster: true},
+		{name: "sentinel", env: map[string]string{"TOPOLOGY": "sentinel", "MASTER_NAME": "mymaster"}, topology: "sentinel", sentinel: true},
+		{name: "standalone", env: map[string]string{"TOPOLOGY": "standalone"}, topology: "standalone"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig(t, tt.env)
+			if cfg.Topology != tt.topology || cfg.ClusterMode != tt.cluster || cfg.SentinelMode != tt.sentinel {
+				t.Errorf("topology = %s cluster=%v sentinel=%v, want %s cluster=%v sentinel=%v", cfg.Topology, cfg.ClusterMode, cfg.SentinelMode, tt.topology, tt.cluster, tt.sentinel)
+			}
+		})
+	}
+}
+
+func TestTopologyConfigInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		want string
+	}{
+		{name: "unknown", env: map[string]string{"TOPOLOGY": "mesh"}, want: `TOPOLOGY="mesh" must be one of standalone, replication, cluster, sentinel`},
+		{name: "cluster mode conflict", env: map[string]string{"TOPOLOGY": "replication", "CLUSTER_MODE": "true"}, want: "TOPOLOGY=replication conflicts with CLUSTER_MODE=true"},
+		{name: "sentinel mode conflict", env: map[string]string{"TOPOLOGY": "sentinel", "SENTINEL_MODE": "false"}, want: "TOPOLOGY=sentinel conflicts with SENTINEL_MODE=false"},
+		{name: "unknown check", env: map[string]string{"HEALTH_CHECKS": "loading,nope"}, want: `HEALTH_CHECKS="nope" must be one of`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("NODE_PORT", "6379")
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+			_, err := loadConfig(nil)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("loadConfig() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestActiveChecks(t *testing.T) {
+	replication := testConfig(t, map[string]string{"TOPOLOGY": "replication"}).activeChecks()
+	// Unset, the node keeps running the checks it ran before TOPOLOGY
+	if unset := testConfig(t, nil).activeChecks(); !slices.Equal(unset, replication) {
+		t.Errorf("activeChecks() = %v without TOPOLOGY, want %v", unset, replication)
+	}
+
+	tests := []struct {
+		name     string
+		env      map[string]string
+		includes []string
+		excludes []string
+	}{
+		{
+			name:     "replication",
+			env:      map[string]string{"TOPOLOGY": "replication"},
+			includes: []string{"loading", "role", "module", "sync", "master_link", "connected_replicas"},
+			// Opt-in and cluster checks
+			excludes: []string{"persistence", "graph_query", "replica_config", "cluster", "slots"},
+		},
+		{
+			name:     "cluster",
+			env:      map[string]string{"TOPOLOGY": "cluster"},
+			includes: []string{"sync", "cluster", "cluster_nodes", "slot_migrations", "slots"},
+		},
+		{
+			name:     "standalone",
+			env:      map[string]string{"TOPOLOGY": "standalone"},
+			includes: []string{"loading", "module", "memory"},
+			excludes: []string{"sync", "master_link", "connected_replicas", "cluster", "quorum"},
+		},
+		{
+			name:     "sentinel",
+			env:      map[string]string{"TOPOLOGY": "sentinel", "MASTER_NAME": "mymaster"},
+			includes: []string{"loading", "sentinel", "quorum", "sentinel_peers"},
+			excludes: []string{"module", "memory", "sync"},
+		},
+		{
+			name:     "opt-in",
+			env:      map[string]string{"CHECK_PERSISTENCE": "true", "DEEP_CHECK": "true", "SKIP_MODULE_CHECK": "true"},
+			includes: []string{"persistence", "graph_query"},
+			excludes: []string{"module"},
+		},
+		{
+			name:     "health checks",
+			env:      map[string]string{"HEALTH_CHECKS": "loading,sync,cluster"},
+			includes: []string{"loading", "sync"},
+			excludes: []string{"role", "module", "cluster"},
+		},
+		{
+			name:     "health checks outside the topology",
+			env:      map[string]string{"TOPOLOGY": "standalone", "HEALTH_CHECKS": "loading,sync"},
+			includes: []string{"loading"},
+			excludes: []string{"sync"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			active := testConfig(t, tt.env).activeChecks()
+			for _, name := range tt.includes {
+				if !slices.Contains(active, name) {
+					t.Errorf("activeChecks() = %v, leaves out %s", active, name)
+				}
+			}
+			for _, name := range tt.excludes {
+				if slices.Contains(active, name) {
+					t.Errorf("activeChecks() = %v, selects %s", active, name)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	circuitClosed   = "closed"
+	circuitOpen     = "open"
+	circuitHalfOpen = "half-open"
+)
+
+// circuitBreaker stops talking to a node that is hard down. After threshold
+// consecutive failed commands it opens and every command fails at once with
+// the last error, so probes don't each wait for the dial timeout. Once
+// openFor has passed a single trial command is let through, half-open: its
+// success closes the circuit and its failure opens it again. Recovery is
+// thus seen at most openFor late.
+type circuitBreaker struct {
+	threshold int64
+	openFor   time.Duration
+
+	mu       sync.Mutex
+	state    string
+	failures int64
+	openedAt time.Time
+	lastErr  error
+	trialing bool
+}
+
+// circuitOpenError is the cached failure returned while the circuit is
+// open, classified like the error it wraps
+type circuitOpenError struct {
+	err error
+}
+
+func (e *circuitOpenError) Error() string {
+	return e.err.Error() + " (circuit open)"
+}
+
+func (e *circuitOpenError) Unwrap() error {
+	return e.err
+}
+
+func newCircuitBreaker(cfg *Config) *circuitBreaker {
+	breaker := &circuitBreaker{threshold: cfg.CircuitThreshold, openFor: cfg.CircuitOpen, state: circuitClosed}
+	updateCircuitMetric(circuitClosed)
+	return breaker
+}
+
+func (b *circuitBreaker) currentState() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// allow tells whether a command may go ahead, and whether it is the trial of
+// a half-open circuit
+func (b *circuitBreaker) allow() (bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case circuitOpen:
+		if time.Since(b.openedAt) < b.openFor {
+			return false, &circuitOpenError{err: b.lastErr}
+		}
+		b.setState(circuitHalfOpen)
+		fallthrough
+	case circuitHalfOpen:
+		if b.trialing {
+			return false, &circuitOpenError{err: b.lastErr}
+		}
+		b.trialing = true
+		return true, nil
+	}
+	return false, nil
+}
+
+// done records the outcome of a command. A command its caller gave up on
+// says nothing about the node.
+func (b *circuitBreaker) done(trial bool, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if trial {
+		b.trialing = false
+	}
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+
+	if err == nil {
+		b.failures = 0
+		if b.state != circuitClosed {
+			b.setState(circuitClosed)
+		}
+		return
+	}
+
+	b.lastErr = err
+	b.failures++
+	if (b.state == circuitClosed && b.failures >= b.threshold) || (trial && b.state == circuitHalfOpen) {
+		b.openedAt = time.Now()
+		b.setState(circuitOpen)
+	}
+}
+
+func (b *circuitBreaker) setState(state string) {
+	if state == circuitOpen {
+		slog.Warn("node unreachable, circuit open", "failures", b.failures, "open_for", b.openFor, "error", b.lastErr)
+	} else {
+		slog.Info("circuit "+state, "failures", b.failures)
+	}
+	b.state = state
+	updateCircuitMetric(state)
+}
+
+// circuitHook puts the breaker in front of the commands of a client. Only
+// failures reaching the node count, a reply, even an error, closes the
+// circuit. Counting commands rather than dials also catches a proxy in front
+// of the node accepting connections and resetting them.
+type circuitHook struct {
+	breaker *circuitBreaker
+}
+
+func (circuitHook) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (h circuitHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(cmdCtx context.Context, cmd redis.Cmder) error {
+		trial, err := h.breaker.allow()
+		if err != nil {
+			cmd.SetErr(err)
+			return err
+		}
+
+		err = next(cmdCtx, cmd)
+		h.breaker.done(trial, connectionError(err))
+		return err
+	}
+}
+
+func (h circuitHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(cmdCtx context.Context, cmds []redis.Cmder) error {
+		trial, err := h.breaker.allow()
+		if err != nil {
+			for _, cmd := range cmds {
+				cmd.SetErr(err)
+			}
+			return err
+		}
+
+		err = next(cmdCtx, cmds)
+		h.breaker.done(trial, connectionError(err))
+		return err
+	}
+}
+
+// connectionError returns err unless the node answered it
+func connectionError(err error) error {
+	if err == nil || err == redis.Nil || isRedisReply(err) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"falkordb.cloud/main/internal/redisinfo"
+)
+
+// rejectedConnections remembers the last rejected_connections counter seen
+// per probed node, so a node that started refusing clients since the previous
+// probe is caught even though INFO still works over our own connection.
+var rejectedConnections = struct {
+	mu   sync.Mutex
+	last map[string]int64
+}{last: map[string]int64{}}
+
+// rejectedSinceLastProbe returns how many connections the node rejected since
+// the previous probe. The first observation never counts as an increase.
+func rejectedSinceLastProbe(node string, rejected int64) int64 {
+	rejectedConnections.mu.Lock()
+	defer rejectedConnections.mu.Unlock()
+
+	last, seen := rejectedConnections.last[node]
+	rejectedConnections.last[node] = rejected
+	// The counter resets when the node restarts
+	if !seen || rejected < last {
+		return 0
+	}
+	return rejected - last
+}
+
+// maxClients reads maxclients from INFO, only reported by Redis 7 and later,
+// or from CONFIG GET otherwise.
+func maxClients(probeCtx context.Context, info *redisinfo.Info) (int64, error) {
+	if max, err := info.Int("maxclients"); err == nil {
+		return max, nil
+	}
+
+	reply, err := nodeClient(probeCtx).ConfigGet(probeCtx, "maxclients").Result()
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(reply["maxclients"], 10, 64)
+}
+
+// checkClientSaturation fails a node close to maxclients, by more than
+// MAX_CLIENTS_USED_PERCENT, or that rejected connections since the previous
+// probe when CHECK_REJECTED_CONNECTIONS is set. Blocked clients above
+// MAX_BLOCKED_CLIENTS are only reported.
+func checkClientSaturation(probeCtx context.Context, info *redisinfo.Info, cfg *Config) (string, string, error) {
+	if cfg.MaxClientsUsedPercent <= 0 && !cfg.CheckRejectedConnections {
+		return "", "", nil
+	}
+
+	var details []string
+
+	if cfg.CheckRejectedConnections {
+		if rejected, err := info.Int("rejected_connections"); err == nil {
+			if delta := rejectedSinceLastProbe(probeTarget(probeCtx), rejected); delta > 0 {
+				detail := fmt.Sprintf("rejected_connections=+%d", delta)
+				return "CLIENTS_REJECTED " + detail, detail, nil
+			}
+		}
+	}
+
+	if cfg.MaxClientsUsedPercent > 0 {
+		connected, err := info.Int("connected_clients")
+		if err != nil {
+			return "", "connected_clients not found", nil
+		}
+
+		max, err := maxClients(probeCtx, info)
+		if err != nil {
+			return "", "", err
+		}
+
+		if max > 0 {
+			pct := float64(connected) * 100 / float64(max)
+			detail := fmt.Sprintf("clients=%d/%d used=%.1f%%", connected, max, pct)
+			if pct > float64(cfg.MaxClientsUsedPercent) {
+				return fmt.Sprintf("CLIENTS_SATURATED used=%.1f%%", pct), detail, nil
+			}
+			details = append(details, detail)
+		}
+	}
+
+	if cfg.MaxBlockedClients > 0 {
+		if blocked, err := info.Int("blocked_clients"); err == nil && blocked > cfg.MaxBlockedClients {
+			details = append(details, fmt.Sprintf("blocked_clients=%d above %d", blocked, cfg.MaxBlockedClients))
+		}
+	}
+
+	return "", strings.Join(details, " "), nil
+}
@@ -0,0 +1,365 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"falkordb.cloud/main/internal/redisinfo"
+)
+
+// checkCluster runs CLUSTER INFO and verifies the cluster is up from this
+// node's point of view. It returns an empty string when healthy, or the
+// failing field otherwise.
+func checkCluster(probeCtx context.Context) (string, error) {
+	raw, err := nodeClient(probeCtx).ClusterInfo(probeCtx).Result()
+	if err != nil {
+		return "", err
+	}
+	clusterInfo := redisinfo.Parse(raw)
+
+	state, err := clusterInfo.String("cluster_state")
+	if err != nil {
+		return "CLUSTER_NOT_OK cluster_state=unknown", nil
+	}
+	if state != "ok" {
+		return fmt.Sprintf("CLUSTER_NOT_OK cluster_state=%s", state), nil
+	}
+
+	knownNodes, err := clusterInfo.Int("cluster_known_nodes")
+	if err != nil || knownNodes <= 1 {
+		return fmt.Sprintf("CLUSTER_NOT_OK cluster_known_nodes=%d", knownNodes), nil
+	}
+
+	// Once the cluster has been bootstrapped the current epoch moves past 0,
+	// a node still at epoch 0 never joined the configuration
+	currentEpoch, _ := clusterInfo.Int("cluster_current_epoch")
+	myEpoch, _ := clusterInfo.Int("cluster_my_epoch")
+	if currentEpoch > 0 && myEpoch == 0 {
+		return "CLUSTER_NOT_OK cluster_my_epoch=0", nil
+	}
+
+	return "", nil
+}
+
+const clusterSlotCount = 16384
+
+// checkSlotCoverage verifies a master owns at least one slot and, when
+// CHECK_FULL_SLOT_COVERAGE is set, that every slot is served by the cluster.
+// Replicas are never expected to own slots themselves.
+func checkSlotCoverage(probeCtx context.Context, role string, fullCoverage bool) (string, error) {
+	if role != "master" && !fullCoverage {
+		return "", nil
+	}
+
+	slots, err := nodeClient(probeCtx).ClusterSlots(probeCtx).Result()
+	if err != nil {
+		return "", err
+	}
+
+	if role == "master" {
+		myID, err := nodeClient(probeCtx).Do(probeCtx, "CLUSTER", "MYID").Text()
+		if err != nil {
+			return "", err
+		}
+
+		owned := 0
+		for _, slot := range slots {
+			if len(slot.Nodes) > 0 && slot.Nodes[0].ID == myID {
+				owned += slot.End - slot.Start + 1
+			}
+		}
+
+		if owned == 0 {
+			return "NO_SLOTS_ASSIGNED", nil
+		}
+	}
+
+	if fullCoverage {
+		covered := 0
+		for _, slot := range slots {
+			covered += slot.End - slot.Start + 1
+		}
+
+		if covered < clusterSlotCount {
+			return fmt.Sprintf("SLOTS_NOT_COVERED covered=%d/%d", covered, clusterSlotCount), nil
+		}
+	}
+
+	return "", nil
+}
+
+// clusterNode is one line of CLUSTER NODES
+type clusterNode struct {
+	ID       string
+	Addr     string
+	Hostname string
+	Flags    []string
+	RawFlags string
+	// MasterID is the master a replica replicates from, empty for masters
+	MasterID   string
+	Migrations []slotMigration
+}
+
+// slotMigration is an open [slot->-node] or [slot-<-node] entry of CLUSTER
+// NODES, a slot being migrated to or imported from another node.
+type slotMigration struct {
+	Slot      int
+	Importing bool
+	Node      string
+}
+
+func (m slotMigration) String() string {
+	if m.Importing {
+		return fmt.Sprintf("%d<-%s", m.Slot, m.Node)
+	}
+	return fmt.Sprintf("%d->%s", m.Slot, m.Node)
+}
+
+// parseSlotMigration parses a bracketed slot field, returning false for
+// plain slots and ranges.
+func parseSlotMigration(field string) (slotMigration, bool) {
+	if !strings.HasPrefix(field, "[") || !strings.HasSuffix(field, "]") {
+		return slotMigration{}, false
+	}
+	field = field[1 : len(field)-1]
+
+	migration := slotMigration{}
+	slot, node, ok := strings.Cut(field, "->-")
+	if !ok {
+		slot, node, ok = strings.Cut(field, "-<-")
+		migration.Importing = true
+	}
+	if !ok || node == "" {
+		return slotMigration{}, false
+	}
+
+	n, err := strconv.Atoi(slot)
+	if err != nil {
+		return slotMigration{}, false
+	}
+	migration.Slot = n
+	migration.Node = node
+	return migration, true
+}
+
+func (n clusterNode) hasFlag(flags ...string) (string, bool) {
+	for _, flag := range n.Flags {
+		for _, wanted := range flags {
+			if flag == wanted {
+				return flag, true
+			}
+		}
+	}
+	return "", false
+}
+
+// parseClusterNodes parses the CLUSTER NODES reply. The address field reads
+// ip:port@cport, followed since Redis 7 by ,hostname when one is announced.
+// The fourth field is the master ID of a replica, - for masters. Slot fields
+// in brackets are open migrations. Lines with too few fields are
+// skipped.
+func parseClusterNodes(raw string) []clusterNode {
+	var nodes []clusterNode
+	for _, line := range strings.Split(strings.ReplaceAll(raw, "\r\n", "\n"), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 8 {
+			continue
+		}
+
+		node := clusterNode{ID: fields[0], RawFlags: fields[2], Flags: strings.Split(fields[2], ",")}
+		if fields[3] != "-" {
+			node.MasterID = fields[3]
+		}
+
+		addr := fields[1]
+		if i := strings.IndexByte(addr, ','); i >= 0 {
+			node.Hostname = addr[i+1:]
+			addr = addr[:i]
+		}
+		if i := strings.IndexByte(addr, '@'); i >= 0 {
+			addr = addr[:i]
+		}
+		node.Addr = addr
+
+		for _, field := range fields[8:] {
+			if migration, ok := parseSlotMigration(field); ok {
+				node.Migrations = append(node.Migrations, migration)
+			}
+		}
+
+		nodes = append(nodes, node)
+	}
+	return nodes
+}
+
+// checkClusterNodes fails a node the rest of the cluster flagged as failing,
+// or that it still handshakes with or has no address for, while it may look
+// fine locally. A partition is only logged, when more than maxFailedPercent
+// of the peers are flagged fail.
+func checkClusterNodes(probeCtx context.Context, maxFailedPercent int64) (string, string, error) {
+	raw, err := nodeClient(probeCtx).ClusterNodes(probeCtx).Result()
+	if err != nil {
+		return "", "", err
+	}
+
+	nodes := parseClusterNodes(raw)
+
+	var myself *clusterNode
+	peers, failedPeers := 0, 0
+	for i, node := range nodes {
+		if _, ok := node.hasFlag("myself"); ok {
+			myself = &nodes[i]
+			continue
+		}
+
+		peers++
+		if _, ok := node.hasFlag("fail", "fail?"); ok {
+			failedPeers++
+		}
+	}
+
+	if myself == nil {
+		return "CLUSTER_NODE_NOT_FOUND", "no myself entry in CLUSTER NODES", nil
+	}
+
+	if _, ok := myself.hasFlag("fail", "fail?", "handshake", "noaddr"); ok {
+		return "CLUSTER_NODE_FLAGGED flags=" + myself.RawFlags, "flags=" + myself.RawFlags, nil
+	}
+
+	detail := fmt.Sprintf("flags=%s failed_peers=%d/%d", myself.RawFlags, failedPeers, peers)
+	if peers > 0 && maxFailedPercent > 0 && int64(failedPeers*100/peers) > maxFailedPercent {
+		slog.Warn("many cluster peers flagged fail, possible partition", "failed", failedPeers, "peers", peers)
+	}
+
+	return "", detail, nil
+}
+
+// failedMaster remembers since when the master of the local replica has been
+// flagged fail, since CLUSTER NODES doesn't say.
+var failedMaster = struct {
+	mu    sync.Mutex
+	id    string
+	since time.Time
+}{}
+
+// checkClusterMaster fails a replica whose master the cluster flagged fail
+// with MASTER_FAILED once no failover promoted it within
+// FAILOVER_GRACE_SECONDS, the replica would serve stale reads indefinitely
+// otherwise. Within the window FAILOVER_PENDING is only reported.
+func checkClusterMaster(probeCtx context.Context, role string, graceSeconds int64) (string, string, error) {
+	// The failure time describes the local node only
+	if role != "slave" || probeTarget(probeCtx) != "" {
+		return "", "", nil
+	}
+
+	raw, err := nodeClient(probeCtx).ClusterNodes(probeCtx).Result()
+	if err != nil {
+		return "", "", err
+	}
+
+	nodes := parseClusterNodes(raw)
+	var masterID string
+	for _, node := range nodes {
+		if _, ok := node.hasFlag("myself"); ok {
+			masterID = node.MasterID
+		}
+	}
+	// Promoted in the meantime, the role check reports it
+	if masterID == "" {
+		return "", "", nil
+	}
+
+	failed := false
+	for _, node := range nodes {
+		if node.ID == masterID {
+			_, failed = node.hasFlag("fail")
+		}
+	}
+
+	failedMaster.mu.Lock()
+	defer failedMaster.mu.Unlock()
+
+	if !failed {
+		failedMaster.id = ""
+		return "", "master_id=" + masterID, nil
+	}
+
+	now := time.Now()
+	if failedMaster.id != masterID {
+		failedMaster.id, failedMaster.since = masterID, now
+	}
+	age := now.Sub(failedMaster.since)
+	detail := fmt.Sprintf("master_id=%s failed_for=%ds", masterID, int64(age.Seconds()))
+	if age > time.Duration(graceSeconds)*time.Second {
+		return "MASTER_FAILED master_id=" + masterID, detail, nil
+	}
+	return "", "warning: FAILOVER_PENDING " + detail, nil
+}
+
+// slotMigrations remembers when each open migration of the local node was
+// first seen, since CLUSTER NODES doesn't say when it started.
+var slotMigrations = struct {
+	mu        sync.Mutex
+	firstSeen map[slotMigration]time.Time
+}{firstSeen: map[slotMigration]time.Time{}}
+
+// checkSlotMigrations fails a node with slots left MIGRATING or IMPORTING
+// for longer than MAX_SLOT_MIGRATION_SECONDS, e.g. by a reshard that died
+// midway, which breaks multi-key operations on those slots. Migrations
+// within the window are only reported.
+func checkSlotMigrations(probeCtx context.Context, maxSeconds int64) (string, string, error) {
+	// The first seen times describe the local node only
+	if probeTarget(probeCtx) != "" {
+		return "", "", nil
+	}
+
+	raw, err := nodeClient(probeCtx).ClusterNodes(probeCtx).Result()
+	if err != nil {
+		return "", "", err
+	}
+
+	var open []slotMigration
+	for _, node := range parseClusterNodes(raw) {
+		if _, ok := node.hasFlag("myself"); ok {
+			open = node.Migrations
+		}
+	}
+
+	slotMigrations.mu.Lock()
+	defer slotMigrations.mu.Unlock()
+
+	now := time.Now()
+	firstSeen := make(map[slotMigration]time.Time, len(open))
+	var stuck, pending []string
+	for _, migration := range open {
+		seen, ok := slotMigrations.firstSeen[migration]
+		if !ok {
+			seen = now
+		}
+		firstSeen[migration] = seen
+
+		age := now.Sub(seen)
+		entry := fmt.Sprintf("%s age=%ds", migration, int64(age.Seconds()))
+		if maxSeconds > 0 && age > time.Duration(maxSeconds)*time.Second {
+			stuck = append(stuck, entry)
+		} else {
+			pending = append(pending, entry)
+		}
+	}
+	// Finished migrations are forgotten
+	slotMigrations.firstSeen = firstSeen
+
+	if len(stuck) > 0 {
+		detail := strings.Join(stuck, ",")
+		return "SLOT_MIGRATION_STUCK slots=" + detail, "stuck=" + detail, nil
+	}
+	if len(pending) > 0 {
+		return "", "warning: migrating=" + strings.Join(pending, ","), nil
+	}
+	return "", "", nil
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// clusterNodes is the CLUSTER NODES reply of a Redis 7 node announcing
+// hostnames, with a failed master and a replica
+const clusterNodes = "e7d1eecce10fd6bb5eb35b9f99a514335d9ba9ca 10.0.0.1:6379@16379,node-1.falkordb myself,master - 0 0 1 connected 0-5460\r\n" +
+	"67ed2db8d677e59ec4a4cefb06858cf2a1a89fa1 10.0.0.2:6379@16379,node-2.falkordb master - 0 1426238316232 2 connected 5461-10922\r\n" +
+	"292f8b365bb7edb5e285caf0b7e6ddc7265d2f4f 10.0.0.3:6379@16379 master,fail - 1426238316232 1426238316232 3 disconnected 10923-16383\r\n" +
+	"6ec23923021cf3ffec47632106199cb7f496ce01 10.0.0.4:6379@16379,node-4.falkordb slave 67ed2db8d677e59ec4a4cefb06858cf2a1a89fa1 0 1426238316232 2 connected\r\n"
+
+func TestParseClusterNodes(t *testing.T) {
+	nodes := parseClusterNodes(clusterNodes)
+	want := []clusterNode{
+		{ID: "e7d1eecce10fd6bb5eb35b9f99a514335d9ba9ca", Addr: "10.0.0.1:6379", Hostname: "node-1.falkordb", Flags: []string{"myself", "master"}, RawFlags: "myself,master"},
+		{ID: "67ed2db8d677e59ec4a4cefb06858cf2a1a89fa1", Addr: "10.0.0.2:6379", Hostname: "node-2.falkordb", Flags: []string{"master"}, RawFlags: "master"},
+		{ID: "292f8b365bb7edb5e285caf0b7e6ddc7265d2f4f", Addr: "10.0.0.3:6379", Flags: []string{"master", "fail"}, RawFlags: "master,fail"},
+		{ID: "6ec23923021cf3ffec47632106199cb7f496ce01", Addr: "10.0.0.4:6379", Hostname: "node-4.falkordb", Flags: []string{"slave"}, RawFlags: "slave", MasterID: "67ed2db8d677e59ec4a4cefb06858cf2a1a89fa1"},
+	}
+	if !reflect.DeepEqual(nodes, want) {
+		t.Errorf("parseClusterNodes =\n%+v\nwant\n%+v", nodes, want)
+	}
+}
+
+func TestParseClusterNodesAddresses(t *testing.T) {
+	tests := []struct {
+		name     string
+		line     string
+		addr     string
+		hostname string
+	}{
+		{name: "before Redis 4", line: "a1 10.0.0.1:6379 myself,master - 0 0 1 connected", addr: "10.0.0.1:6379"},
+		{name: "cluster bus port", line: "a1 10.0.0.1:6379@16379 myself,master - 0 0 1 connected", addr: "10.0.0.1:6379"},
+		{name: "hostname", line: "a1 10.0.0.1:6379@16379,falkordb-0.falkordb.svc myself,master - 0 0 1 connected", addr: "10.0.0.1:6379", hostname: "falkordb-0.falkordb.svc"},
+		{name: "empty hostname", line: "a1 10.0.0.1:6379@16379, myself,master - 0 0 1 connected", addr: "10.0.0.1:6379"},
+		{name: "ipv6", line: "a1 [fd00::1]:6379@16379 myself,master - 0 0 1 connected", addr: "[fd00::1]:6379"},
+		{name: "no address", line: "a1 :0@0 myself,noaddr - 0 0 1 disconnected", addr: ":0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nodes := parseClusterNodes(tt.line)
+			if len(nodes) != 1 || nodes[0].Addr != tt.addr || nodes[0].Hostname != tt.hostname {
+				t.Errorf("parseClusterNodes(%q) = %+v, want addr %q hostname %q", tt.line, nodes, tt.addr, tt.hostname)
+			}
+		})
+	}
+}
+
+func TestParseClusterNodesSkipsShortLines(t *testing.T) {
+	raw := "\n" + "a1 10.0.0.1:6379@16379 myself,master -\n" + "b2 10.0.0.2:6379@16379 master - 0 0 2 connected\n\n"
+	nodes := parseClusterNodes(raw)
+	if len(nodes) != 1 || nodes[0].ID != "b2" {
+		t.Errorf("parseClusterNodes = %+v, want only b2", nodes)
+	}
+}
+
+func TestCheckClusterNodes(t *testing.T) {
+	peer := "b2 10.0.0.2:6379@16379 master - 0 0 2 connected 5461-16383\n"
+	tests := []struct {
+		name   string
+		flags  string
+		reason string
+		detail string
+	}{
+		{name: "healthy", flags: "myself,master", detail: "flags=myself,master failed_peers=0/1"},
+		{name: "suspected", flags: "myself,master,fail?", reason: "CLUSTER_NODE_FLAGGED flags=myself,master,fail?", detail: "flags=myself,master,fail?"},
+		{name: "failed", flags: "myself,slave,fail", reason: "CLUSTER_NODE_FLAGGED flags=myself,slave,fail", detail: "flags=myself,slave,fail"},
+		{name: "handshake", flags: "myself,handshake", reason: "CLUSTER_NODE_FLAGGED flags=myself,handshake", detail: "flags=myself,handshake"},
+		{name: "no address", flags: "myself,master,noaddr", reason: "CLUSTER_NODE_FLAGGED flags=myself,master,noaddr", detail: "flags=myself,master,noaddr"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := newFakeNode(masterInfo)
+			node.reply("CLUSTER NODES", "a1 10.0.0.1:6379@16379 "+tt.flags+" - 0 0 1 connected 0-5460\n"+peer)
+			cfg := testConfig(t, map[string]string{"CLUSTER_MODE": "true"})
+			p := newTestProbes(t, cfg, node)
+
+			reason, detail, err := checkClusterNodes(withProbes(context.Background(), p), 0)
+			if err != nil || reason != tt.reason || detail != tt.detail {
+				t.Errorf("checkClusterNodes = %q, %q, %v, want %q, %q", reason, detail, err, tt.reason, tt.detail)
+			}
+		})
+	}
+}
+
+func TestCheckClusterNodesWithoutMyself(t *testing.T) {
+	node := newFakeNode(masterInfo)
+	node.reply("CLUSTER NODES", "b2 10.0.0.2:6379@16379 master - 0 0 2 connected 0-16383\n")
+	cfg := testConfig(t, map[string]string{"CLUSTER_MODE": "true"})
+	p := newTestProbes(t, cfg, node)
+
+	reason, _, err := checkClusterNodes(withProbes(context.Background(), p), 0)
+	if err != nil || reason != "CLUSTER_NODE_NOT_FOUND" {
+		t.Errorf("checkClusterNodes = %q, %v, want CLUSTER_NODE_NOT_FOUND", reason, err)
+	}
+}
+
+func TestCheckClusterNodesPartition(t *testing.T) {
+	var logs bytes.Buffer
+	defer slog.SetDefault(slog.Default())
+	slog.SetDefault(slog.New(slog.NewTextHandler(&logs, nil)))
+
+	node := newFakeNode(masterInfo)
+	node.reply("CLUSTER NODES", clusterNodes)
+	cfg := testConfig(t, map[string]string{"CLUSTER_MODE": "true"})
+	p := newTestProbes(t, cfg, node)
+	probeCtx := withProbes(context.Background(), p)
+
+	// One of the three peers failed is within 50%
+	reason, detail, err := checkClusterNodes(probeCtx, 50)
+	if err != nil || reason != "" || detail != "flags=myself,master failed_peers=1/3" {
+		t.Errorf("checkClusterNodes = %q, %q, %v, want it passing", reason, detail, err)
+	}
+	if strings.Contains(logs.String(), "possible partition") {
+		t.Errorf("warned of a partition within the threshold: %s", logs.String())
+	}
+
+	// and beyond 20%, which only warns
+	reason, _, err = checkClusterNodes(probeCtx, 20)
+	if err != nil || reason != "" {
+		t.Errorf("checkClusterNodes = %q, %v, want a partition to only warn", reason, err)
+	}
+	if !strings.Contains(logs.String(), "possible partition") {
+		t.Errorf("no partition warning in %s", logs.String())
+	}
+}
+
+func TestParseSlotMigration(t *testing.T) {
+	tests := []struct {
+		field string
+		want  slotMigration
+		ok    bool
+	}{
+		{field: "[93->-e7d1eecce10fd6bb5eb35b9f99a514335d9ba9ca]", want: slotMigration{Slot: 93, Node: "e7d1eecce10fd6bb5eb35b9f99a514335d9ba9ca"}, ok: true},
+		{field: "[1002-<-67ed2db8d677e59ec4a4cefb06858cf2a1a89fa1]", want: slotMigration{Slot: 1002, Importing: true, Node: "67ed2db8d677e59ec4a4cefb06858cf2a1a89fa1"}, ok: true},
+		{field: "0-5460"},
+		{field: "5461"},
+		{field: "[93->-]"},
+		{field: "[x->-e7d1]"},
+		{field: "[93]"},
+		{field: "[93->-e7d1"},
+	}
+	for _, tt := range tests {
+		got, ok := parseSlotMigration(tt.field)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("parseSlotMigration(%q) = %+v, %t, want %+v, %t", tt.field, got, ok, tt.want, tt.ok)
+		}
+	}
+	if got := (slotMigration{Slot: 93, Node: "b2"}).String(); got != "93->b2" {
+		t.Errorf("migrating String() = %q", got)
+	}
+	if got := (slotMigration{Slot: 93, Importing: true, Node: "b2"}).String(); got != "93<-b2" {
+		t.Errorf("importing String() = %q", got)
+	}
+}
+
+func TestParseClusterNodesMigrations(t *testing.T) {
+	raw := "a1 10.0.0.1:6379@16379 myself,master - 0 0 1 connected 0-92 [93->-b2] 94-5460 [6000-<-c3]\n"
+	nodes := parseClusterNodes(raw)
+	want := []slotMigration{{Slot: 93, Node: "b2"}, {Slot: 6000, Importing: true, Node: "c3"}}
+	if len(nodes) != 1 || !reflect.DeepEqual(nodes[0].Migrations, want) {
+		t.Errorf("parseClusterNodes(%q) = %+v, want migrations %+v", raw, nodes, want)
+	}
+}
+
+func TestCheckSlotMigrations(t *testing.T) {
+	slotMigrations.mu.Lock()
+	slotMigrations.firstSeen = map[slotMigration]time.Time{}
+	slotMigrations.mu.Unlock()
+
+	node := newFakeNode(masterInfo)
+	peer := "b2 10.0.0.2:6379@16379 master - 0 0 2 connected 5461-16383 [93-<-a1]\n"
+	node.reply("CLUSTER NODES", "a1 10.0.0.1:6379@16379 myself,master - 0 0 1 connected 0-5460 [93->-b2]\n"+peer)
+	cfg := testConfig(t, map[string]string{"CLUSTER_MODE": "true"})
+	p := newTestProbes(t, cfg, node)
+	probeCtx := withProbes(context.Background(), p)
+
+	// A fresh migration is only reported, the peer's side isn't ours
+	reason, detail, err := checkSlotMigrations(probeCtx, 60)
+	if err != nil || reason != "" || detail != "warning: migrating=93->b2 age=0s" {
+		t.Errorf("fresh migration = %q, %q, %v, want the warning", reason, detail, err)
+	}
+
+	// still open past MAX_SLOT_MIGRATION_SECONDS
+	slotMigrations.mu.Lock()
+	slotMigrations.firstSeen[slotMigration{Slot: 93, Node: "b2"}] = time.Now().Add(-90 * time.Second)
+	slotMigrations.mu.Unlock()
+	reason, detail, err = checkSlotMigrations(probeCtx, 60)
+	if err != nil || reason != "SLOT_MIGRATION_STUCK slots=93->b2 age=90s" || detail != "stuck=93->b2 age=90s" {
+		t.Errorf("stuck migration = %q, %q, %v, want SLOT_MIGRATION_STUCK", reason, detail, err)
+	}
+
+	// Finished, it is forgotten and a new one on the slot starts over
+	node.reply("CLUSTER NODES", "a1 10.0.0.1:6379@16379 myself,master - 0 0 1 connected 0-92 94-5460\n"+peer)
+	if reason, detail, err = checkSlotMigrations(probeCtx, 60); err != nil || reason != "" || detail != "" {
+		t.Errorf("finished migration = %q, %q, %v, want nothing", reason, detail, err)
+	}
+	node.reply("CLUSTER NODES", "a1 10.0.0.1:6379@16379 myself,master - 0 0 1 connected 0-5460 [93->-b2]\n"+peer)
+	if reason, _, err = checkSlotMigrations(probeCtx, 60); err != nil || reason != "" {
+		t.Errorf("restarted migration = %q, %v, want only a warning", reason, err)
+	}
+}
+
+func TestCheckClusterMaster(t *testing.T) {
+	failedMaster.mu.Lock()
+	failedMaster.id = ""
+	failedMaster.mu.Unlock()
+
+	replica := "c3 10.0.0.3:6379@16379 myself,slave b2 0 0 2 connected\n"
+	node := newFakeNode(masterInfo)
+	node.reply("CLUSTER NODES", replica+"b2 10.0.0.2:6379@16379 master - 0 0 2 connected 0-16383\n")
+	cfg := testConfig(t, map[string]string{"CLUSTER_MODE": "true"})
+	p := newTestProbes(t, cfg, node)
+	probeCtx := withProbes(context.Background(), p)
+
+	if reason, detail, err := checkClusterMaster(probeCtx, "slave", 30); err != nil || reason != "" || detail != "master_id=b2" {
+		t.Errorf("healthy master = %q, %q, %v, want master_id=b2", reason, detail, err)
+	}
+	// Masters have no master to check
+	if reason, detail, err := checkClusterMaster(probeCtx, "master", 30); err != nil || reason != "" || detail != "" {
+		t.Errorf("master = %q, %q, %v, want nothing", reason, detail, err)
+	}
+
+	// A suspected master may still be reachable, only fail counts
+	node.reply("CLUSTER NODES", replica+"b2 10.0.0.2:6379@16379 master,fail? - 0 0 2 connected 0-16383\n")
+	if reason, _, err := checkClusterMaster(probeCtx, "slave", 30); err != nil || reason != "" {
+		t.Errorf("suspected master = %q, %v, want it passing", reason, err)
+	}
+
+	node.reply("CLUSTER NODES", replica+"b2 10.0.0.2:6379@16379 master,fail - 0 0 2 disconnected 0-16383\n")
+	reason, detail, err := checkClusterMaster(probeCtx, "slave", 30)
+	if err != nil || reason != "" || detail != "warning: FAILOVER_PENDING master_id=b2 failed_for=0s" {
+		t.Errorf("failed master within the grace = %q, %q, %v, want FAILOVER_PENDING", reason, detail, err)
+	}
+
+	failedMaster.mu.Lock()
+	failedMaster.since = failedMaster.since.Add(-45 * time.Second)
+	failedMaster.mu.Unlock()
+	reason, detail, err = checkClusterMaster(probeCtx, "slave", 30)
+	if err != nil || reason != "MASTER_FAILED master_id=b2" || detail != "master_id=b2 failed_for=45s" {
+		t.Errorf("failed master past the grace = %q, %q, %v, want MASTER_FAILED", reason, detail, err)
+	}
+
+	// Promoted by the failover, the replica has no master anymore
+	node.reply("CLUSTER NODES", "c3 10.0.0.3:6379@16379 myself,master - 0 0 3 connected 0-16383\n"+"b2 10.0.0.2:6379@16379 master,fail - 0 0 2 disconnected\n")
+	if reason, detail, err := checkClusterMaster(probeCtx, "slave", 30); err != nil || reason != "" || detail != "" {
+		t.Errorf("promoted replica = %q, %q, %v, want nothing", reason, detail, err)
+	}
+
+	// Recovered, a later failure starts the grace over
+	node.reply("CLUSTER NODES", replica+"b2 10.0.0.2:6379@16379 master - 0 0 2 connected 0-16383\n")
+	checkClusterMaster(probeCtx, "slave", 30)
+	node.reply("CLUSTER NODES", replica+"b2 10.0.0.2:6379@16379 master,fail - 0 0 2 disconnected 0-16383\n")
+	if reason, _, err := checkClusterMaster(probeCtx, "slave", 30); err != nil || reason != "" {
+		t.Errorf("failed again = %q, %v, want the grace started over", reason, err)
+	}
+}
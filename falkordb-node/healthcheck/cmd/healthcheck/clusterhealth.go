@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"falkordb.cloud/main/internal/server"
+	"golang.org/x/sync/errgroup"
+)
+
+// clusterHealth is the body of /clusterhealth
+type clusterHealth struct {
+	Status   string              `json:"status"`
+	Problems []string            `json:"problems"`
+	Nodes    []clusterHealthNode `json:"nodes"`
+	// Only known in cluster mode
+	CoveredSlots *int `json:"covered_slots,omitempty"`
+	server.Truncation
+}
+
+// clusterHealthNode is what one node of CLUSTER_HEALTH_NODES reported. A node
+// that couldn't be probed carries its error and no role.
+type clusterHealthNode struct {
+	Addr     string   `json:"addr"`
+	Role     string   `json:"role,omitempty"`
+	Health   string   `json:"health"`
+	Error    string   `json:"error,omitempty"`
+	NodeID   string   `json:"node_id,omitempty"`
+	MasterID string   `json:"master_id,omitempty"`
+	Slots    []string `json:"slots,omitempty"`
+	Replicas int64    `json:"connected_replicas"`
+
+	slots int
+}
+
+// clusterHealthHandler probes every node of CLUSTER_HEALTH_NODES and sums up
+// the health of the deployment, so the rebalancer doesn't have to connect to
+// each node itself. A node failing to answer is reported in its entry, the
+// response is only an error when the nodes can't be resolved. The problems
+// cover every node, the nodes themselves are listed by address and paged
+// with ?limit= and ?cursor= past MAX_LIST_ITEMS.
+func clusterHealthHandler(cfg *Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		page, ok := parseListPage(w, r)
+		if !ok {
+			return
+		}
+
+		addrs, err := clusterHealthAddrs(r.Context(), cfg)
+		if err != nil {
+			writeError(w, r, http.StatusBadGateway, "NODES_UNRESOLVED", err.Error())
+			return
+		}
+
+		nodes := make([]clusterHealthNode, len(addrs))
+		group := errgroup.Group{}
+		group.SetLimit(maxConcurrentChecks)
+		for i, addr := range addrs {
+			i, addr := i, addr
+			group.Go(func() error {
+				nodeCtx, cancel := context.WithTimeout(r.Context(), cfg.ClusterHealthNodeTimeout)
+				defer cancel()
+
+				nodes[i] = probeClusterHealthNode(context.WithValue(withNoCache(nodeCtx), targetKey{}, addr), cfg, addr)
+				return nil
+			})
+		}
+		group.Wait()
+
+		health := summarizeClusterHealth(cfg, nodes)
+		sort.Slice(health.Nodes, func(i, j int) bool { return health.Nodes[i].Addr < health.Nodes[j].Addr })
+		health.Nodes, health.Truncation = server.PageOf(health.Nodes, func(node clusterHealthNode) string { return node.Addr }, page)
+		writeJSON(w, r, http.StatusOK, health)
+	}
+}
+
+// clusterHealthAddrs returns the nodes to probe. A single entry without an
+// IP, e.g. the headless service of the deployment, is resolved to every
+// address behind it, entries without a port use NODE_PORT.
+func clusterHealthAddrs(ctx context.Context, cfg *Config) ([]string, error) {
+	var addrs []string
+	for _, entry := range cfg.ClusterHealthNodes {
+		host, port, err := net.SplitHostPort(entry)
+		if err != nil {
+			host, port = entry, cfg.NodePort
+		}
+
+		if len(cfg.ClusterHealthNodes) > 1 || net.ParseIP(host) != nil {
+			addrs = append(addrs, net.JoinHostPort(host, port))
+			continue
+		}
+
+		ips, err := net.DefaultResolver.LookupHost(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(ips)
+		for _, ip := range ips {
+			addrs = append(addrs, net.JoinHostPort(ip, port))
+		}
+	}
+	return addrs, nil
+}
+
+// probeClusterHealthNode reads the role and replication state of one node
+// and, in cluster mode, its ID and slots from CLUSTER NODES
+func probeClusterHealthNode(nodeCtx context.Context, cfg *Config, addr string) clusterHealthNode {
+	node := clusterHealthNode{Addr: addr, Health: "ok"}
+	info, err := fetchInfo(nodeCtx)
+	if err != nil {
+		node.Health, node.Error = "unreachable", err.Error()
+		return node
+	}
+
+	node.Role, _ = info.Role()
+	node.Replicas, _ = info.Int("connected_slaves")
+	if loading, _ := info.Loading(); loading {
+		node.Health = "loading"
+	} else if syncing, _ := info.MasterSyncInProgress(); syncing {
+		node.Health = "syncing"
+	} else if node.Role == "slave" && checkMasterLink(info) != "" {
+		node.Health = "master_link_down"
+	}
+
+	if !cfg.ClusterMode {
+		return node
+	}
+
+	raw, err := nodeClient(nodeCtx).ClusterNodes(nodeCtx).Result()
+	if err != nil {
+		node.Health, node.Error = "error", err.Error()
+		return node
+	}
+	for _, line := range strings.Split(strings.ReplaceAll(raw, "\r\n", "\n"), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 8 || !strings.Contains(fields[2], "myself") {
+			continue
+		}
+
+		node.NodeID = fields[0]
+		if fields[3] != "-" {
+			node.MasterID = fields[3]
+		}
+		if strings.Contains(fields[2], "fail") {
+			node.Health = "fail"
+		}
+		for _, field := range fields[8:] {
+			if count, ok := slotRangeSize(field); ok {
+				node.Slots = append(node.Slots, field)
+				node.slots += count
+			}
+		}
+	}
+	return node
+}
+
+// slotRangeSize returns the number of slots of a CLUSTER NODES slot field,
+// e.g. 0-5460 or 5461. Open migrations aren't ranges.
+func slotRangeSize(field string) (int, bool) {
+	first, last, isRange := strings.Cut(field, "-")
+	start, err := strconv.Atoi(first)
+	if err != nil {
+		return 0, false
+	}
+	end := start
+	if isRange {
+		if end, err = strconv.Atoi(last); err != nil || end < start {
+			return 0, false
+		}
+	}
+	return end - start + 1, true
+}
+
+// summarizeClusterHealth lists the problems of the deployment: unhealthy
+// nodes, masters without replicas, more than one master outside cluster
+// mode, and slots no reachable master serves.
+func summarizeClusterHealth(cfg *Config, nodes []clusterHealthNode) *clusterHealth {
+	health := &clusterHealth{Status: "pass", Problems: []string{}, Nodes: nodes}
+
+	masters, covered := 0, 0
+	for _, node := range nodes {
+		if node.Health != "ok" {
+			health.Problems = append(health.Problems, fmt.Sprintf("NODE_UNHEALTHY addr=%s health=%s", node.Addr, node.Health))
+		}
+		if node.Role != "master" {
+			continue
+		}
+
+		masters++
+		covered += node.slots
+		if node.Replicas == 0 {
+			health.Problems = append(health.Problems, "MASTER_WITHOUT_REPLICAS addr="+node.Addr)
+		}
+	}
+
+	if cfg.ClusterMode {
+		health.CoveredSlots = &covered
+		if covered < clusterSlotCount {
+			health.Problems = append(health.Problems, fmt.Sprintf("SLOTS_NOT_COVERED covered=%d/%d", covered, clusterSlotCount))
+		}
+	} else if masters > 1 {
+		health.Problems = append(health.Problems, fmt.Sprintf("MULTIPLE_MASTERS count=%d", masters))
+	}
+
+	if len(health.Problems) > 0 {
+		health.Status = "fail"
+	}
+	return health
+}
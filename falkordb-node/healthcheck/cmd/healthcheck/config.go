@@ -0,0 +1,1078 @@
+package main
+
+import (
+	"crypto/tls"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"falkordb.cloud/main/internal/checks"
+)
+
+// Config is the healthcheck configuration, loaded once at startup from the
+// environment and the command line flags.
+type Config struct {
+	// HTTP server
+	Port                  string
+	HTTPEnabled           bool // plaintext on Port, see ServerTLSPort
+	BindAddrs             []string
+	SocketPath            string
+	SocketMode            os.FileMode
+	SocketOnly            bool    // SocketPath without a port set, no TCP listener
+	RateLimitRPS          float64 // per remote IP, disabled when not positive
+	RateLimitBurst        int64
+	RateLimitExempt       []*net.IPNet
+	AllowedCIDRs          []*net.IPNet // every client allowed when empty
+	TrustProxyHeaders     bool
+	ShutdownGrace         time.Duration
+	DebugEndpoints        bool
+	HistorySize           int
+	GzipMinBytes          int64 // JSON bodies are never compressed when 0
+	MaxListItems          int
+	MaxHeaderBytes        int // of a request, URL and headers included
+	DebugPort             string
+	ServerTLS             bool
+	ServerTLSPort         string // TLS is served on Port when empty
+	ServerTLSCertFile     string
+	ServerTLSKeyFile      string
+	ServerTLSClientCAFile string
+	AdminToken            string // protects the admin and debug endpoints, off without it
+	DrainFile             string
+	StatusFile            string   // readiness report for exec probes, see -check-file
+	TerminationLog        string   // last unhealthy report, read by Kubernetes on restarts
+	Targets               []Target // TARGETS, the first one is the local node
+	FaultInjection        bool     // enables /fault/*, test environments only
+	GRPCPort              string
+	GRPCPollInterval      time.Duration
+
+	// CLUSTER_HEALTH_NODES, host:port entries or one name to resolve
+	ClusterHealthNodes       []string
+	ClusterHealthNodeTimeout time.Duration
+
+	// Checked once before serving
+	PreflightMode          string // abort, wait or warn
+	PreflightRetries       int64
+	PreflightRetryInterval time.Duration
+
+	// Events on the pod for readiness changes, in a cluster only
+	EmitK8sEvents  bool
+	EventsInterval time.Duration
+	PodNamespace   string // the service account namespace when unset
+	PodUID         string // read from the API server when unset
+
+	// Notifications
+	WebhookURL         string
+	WebhookInterval    time.Duration
+	WebhookMinInterval time.Duration
+	PodName            string
+	NodeIndex          string
+	StreamInterval     time.Duration
+	HeartbeatURL       string
+	HeartbeatInterval  time.Duration
+	HeartbeatSecret    string // signs heartbeats when set
+	StatusKey          string // written on masters, __falkordb: is reserved
+	StatusKeyInterval  time.Duration
+
+	// Connection to the probed node
+	NodeHost                   string
+	NodePort                   string
+	NodeSocket                 string
+	NodeTLSPort                string // with CheckBothListeners
+	CheckBothListeners         bool
+	TLS                        bool
+	RedisTLSServerName         string
+	RedisTLSInsecureSkipVerify bool
+	RedisTLSCAFile             string
+	RedisTLSCertFile           string
+	RedisTLSKeyFile            string
+	RedisProtocol              int64 // 2 or 3, go-redis negotiates when 0
+	// Apply to the healthcheck listener too
+	TLSMinVersion   uint16
+	TLSCipherSuites []uint16 // Go's defaults when empty
+
+	User                  string
+	Password              string
+	AdminPassword         string
+	AdminPasswordFile     string
+	AdminPasswordPrevious string // accepted during rotations
+	FailOpenOnAuthError   bool   // liveness only
+	BootstrapGrace        time.Duration
+	StartupGrace          time.Duration
+	AllowRemoteTargets    bool
+	RemoteTargetPattern   *regexp.Regexp
+
+	// Probe behaviour
+	ProbeTimeout     time.Duration
+	CheckTimeout     time.Duration
+	DeepCheckTimeout time.Duration
+	ReadProbeTimeout time.Duration
+	ListenerTimeout  time.Duration // each listener with CHECK_BOTH_LISTENERS
+	Retries          int
+	MaxInternalFails int64
+	CircuitThreshold int64 // consecutive failed commands, disabled when 0
+	CircuitOpen      time.Duration
+	CacheTTL         time.Duration
+	GraphCacheTTL    time.Duration
+	PollInterval     time.Duration // probes are evaluated per request when 0
+	SlowEvalFraction float64       // of the probe timeout, never warned about when 0
+	PollMaxAge       time.Duration
+
+	// Topology
+	Topology     string // TOPOLOGY, or derived from the modes below
+	SentinelMode bool
+	MasterName   string
+	// Sentinel queried to cross-check masters, optional
+	SentinelHost    string
+	SentinelPort    string
+	SentinelTimeout time.Duration
+	SentinelAddrs   []string // host:port, queried in order for the registration
+	SentinelAuth    string   // SENTINEL_PASSWORD
+	ClusterMode     bool
+	PodIP           string
+	ExpectedRole    string
+
+	// Optional checks
+	Checks                   map[string]bool // HEALTH_CHECKS, the defaults run when nil
+	SkipModuleCheck          bool
+	DeepCheck                bool
+	WriteProbe               bool
+	ReadProbeGraph           string // replicas only unless ReadProbeMasters
+	ReadProbeMasters         bool
+	ReadProbeInterval        time.Duration
+	CheckPersistence         bool
+	ExpectedPersistence      string // aof, rdb, both or none, unchecked when empty
+	CheckFullSlotCoverage    bool
+	CheckRejectedConnections bool
+	CheckReplicaConfig       bool
+	CheckLatencyEvents       bool // LATENCY needs the @admin ACL category
+	ExpectFailoverEligible   bool
+	StandbyReady             bool // replica-priority 0 replicas pass readiness
+	DrainDuringPersistence   bool // fails readiness during AOF rewrites and BGSAVEs
+	AnnounceMismatchWarnOnly bool
+	SentinelRegistration     bool // requires SENTINEL_ADDRS
+	CheckExternalAddress     bool
+	E2ESentinelCheck         bool   // requires SENTINEL_ADDRS
+	E2EGraph                 string // queried read-only by the end to end check
+	ExternalCheckMode        string // warn or fail
+	ExternalHost             string // the announced address when empty
+	ExternalPort             string
+	ExternalTimeout          time.Duration
+	ACLUsers                 []aclUser
+	ACLVerifyMode            string        // warn or fail
+	ACLVerifyTimeout         time.Duration // per user
+	ACLVerifyGraph           string        // queried by the readers
+	ACLVerifyInterval        time.Duration
+	AllowLoopbackOnly        bool // skips the network check
+	ExpectedGraphConfig      map[string]string
+	ExpectedGraphs           []string // glob patterns
+	GraphConfigWarnOnly      bool
+	DataDir                  string
+	RequireDiskForBgsave     bool
+	RDBStalenessWarnOnly     bool
+	FailOnHighCPU            bool
+	FalkorDBVersion          string // FALKORDB_VERSION baked into the image
+	VersionMismatchWarnOnly  bool
+
+	// Thresholds, disabled when not positive unless noted
+	MaxPingLatencyMs          int64
+	MaxMemoryUsedPercent      int64
+	MaxCPUPercent             int64
+	CPUSustainedProbes        int64
+	FragWarnRatio             float64 // only reported
+	FragFailRatio             float64
+	MaxSecondsSinceLastSave   int64
+	MaxRDBAgeSeconds          int64
+	MaxKeys                   int64 // over every database
+	MinReplBacklogBytes       int64
+	MaxReplicaLagBytes        int64 // disabled when negative
+	MaxSyncStallSeconds       int64
+	MaxMasterLastIOSeconds    int64
+	FailoverMaxLagBytes       int64 // for /failover-ready
+	MaxDrainSeconds           int64 // with DrainDuringPersistence, required
+	SyncStallWarnSeconds      int64
+	MinConnectedReplicas      int64
+	MaxClientsUsedPercent     int64
+	MaxBlockedClients         int64
+	SentinelMinOtherSentinels int64
+	ExpectedReplicas          int64
+	MaxFailedPeersPercent     int64
+	MaxSlotMigrationSeconds   int64
+	FailoverGraceSeconds      int64
+	SlowlogGrowthPerMinute    int64 // only reported
+	SlowlogFailThreshold      int64 // per minute too
+	LatencyEventWindowSeconds int64
+	LatencyFailMs             int64
+	LatencyFailEvents         []string
+	MinDiskFreePercent        int64
+	MinDiskFreeBytes          int64
+
+	// Exported over OTLP when OTEL_EXPORTER_OTLP_ENDPOINT is set
+	Telemetry bool
+
+	// Logging
+	LogLevel  string
+	LogFormat string
+}
+
+// Target is one node probed when TARGETS lists several, with a configuration
+// of its own.
+type Target struct {
+	Name   string
+	Config *Config
+}
+
+// configFlags maps the command line flags to the environment variable they
+// override.
+var configFlags = map[string]string{
+	"port":             "HEALTH_CHECK_PORT",
+	"node-port":        "NODE_PORT",
+	"node-socket":      "NODE_SOCKET",
+	"timeout-ms":       "HEALTH_CHECK_TIMEOUT_MS",
+	"log-level":        "LOG_LEVEL",
+	"max-header-bytes": "HEALTH_CHECK_MAX_HEADER_BYTES",
+}
+
+func registerConfigFlags(flags *flag.FlagSet) {
+	for name, key := range configFlags {
+		flags.String(name, "", "overrides "+key)
+	}
+}
+
+// configLoader reads settings with flag > env > default precedence and
+// collects every invalid value so they can be reported at once.
+type configLoader struct {
+	flags  map[string]string
+	lookup func(string) (string, bool)
+	errs   []error
+}
+
+func (l *configLoader) get(key string) string {
+	if value, ok := l.flags[key]; ok {
+		return value
+	}
+	value, _ := l.lookup(key)
+	return value
+}
+
+func (l *configLoader) invalid(key string, value string, expected string) {
+	l.errs = append(l.errs, fmt.Errorf("%s=%q must be %s", key, value, expected))
+}
+
+func (l *configLoader) str(key string, fallback string) string {
+	if value := l.get(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+func (l *configLoader) boolean(key string) bool {
+	return l.booleanOr(key, false)
+}
+
+func (l *configLoader) booleanOr(key string, fallback bool) bool {
+	value := l.get(key)
+	if value == "" {
+		return fallback
+	}
+
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		l.invalid(key, value, "true or false")
+	}
+	return b
+}
+
+func (l *configLoader) integer(key string, fallback int64) int64 {
+	value := l.get(key)
+	if value == "" {
+		return fallback
+	}
+
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		l.invalid(key, value, "an integer")
+		return fallback
+	}
+	return n
+}
+
+func (l *configLoader) float(key string, fallback float64) float64 {
+	value := l.get(key)
+	if value == "" {
+		return fallback
+	}
+
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		l.invalid(key, value, "a number")
+		return fallback
+	}
+	return f
+}
+
+// durationMs reads a positive duration expressed in milliseconds
+func (l *configLoader) durationMs(key string, fallback time.Duration) time.Duration {
+	value := l.get(key)
+	if value == "" {
+		return fallback
+	}
+
+	ms, err := strconv.Atoi(value)
+	if err != nil || ms <= 0 {
+		l.invalid(key, value, "a positive number of milliseconds")
+		return fallback
+	}
+	return time.Duration(ms) * time.Millisecond
+}
+
+func (l *configLoader) port(key string, value string) {
+	if n, err := strconv.Atoi(value); err != nil || n <= 0 || n > 65535 {
+		l.invalid(key, value, "a port number")
+	}
+}
+
+// list reads a comma separated list, dropping empty entries
+func (l *configLoader) list(key string, fallback string) []string {
+	var entries []string
+	for _, entry := range strings.Split(l.str(key, fallback), ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// cidrs reads a comma separated list of CIDR ranges
+func (l *configLoader) cidrs(key string, fallback string) []*net.IPNet {
+	var nets []*net.IPNet
+	for _, entry := range l.list(key, fallback) {
+		_, ipNet, err := net.ParseCIDR(entry)
+		if err != nil {
+			l.invalid(key, entry, "a comma separated list of CIDR ranges")
+			continue
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets
+}
+
+// bindAddrs reads a comma separated list of IP addresses to listen on
+func (l *configLoader) bindAddrs(key string) []string {
+	var addrs []string
+	for _, addr := range strings.Split(l.get(key), ",") {
+		addr = strings.TrimSpace(addr)
+		if addr == "" {
+			continue
+		}
+
+		// Accept the bracketed form commonly used for IPv6 literals
+		if net.ParseIP(strings.Trim(addr, "[]")) == nil {
+			l.invalid(key, addr, "a comma separated list of IP addresses")
+			continue
+		}
+		addrs = append(addrs, strings.Trim(addr, "[]"))
+	}
+	return addrs
+}
+
+// graphConfig parses a comma separated list of GRAPH.CONFIG NAME=value pairs
+func (l *configLoader) graphConfig(key string) map[string]string {
+	known := map[string]bool{}
+	for _, name := range graphConfigKeys {
+		known[name] = true
+	}
+
+	expected := map[string]string{}
+	for _, pair := range strings.Split(l.get(key), ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		name, value, ok := strings.Cut(pair, "=")
+		name = strings.ToUpper(strings.TrimSpace(name))
+		if !ok || strings.TrimSpace(value) == "" {
+			l.invalid(key, pair, "a list of NAME=value pairs")
+			continue
+		}
+		if !known[name] {
+			l.invalid(key, name, "one of "+strings.Join(graphConfigKeys, ", "))
+			continue
+		}
+		expected[name] = strings.TrimSpace(value)
+	}
+	return expected
+}
+
+// aclUsers parses a comma separated list of user:password-file entries,
+// readers ending with :ro
+func (l *configLoader) aclUsers(key string) []aclUser {
+	var users []aclUser
+	for _, entry := range strings.Split(l.get(key), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		user := aclUser{}
+		entry, user.reader = strings.CutSuffix(entry, ":ro")
+		name, passwordFile, ok := strings.Cut(entry, ":")
+		user.name, user.passwordFile = strings.TrimSpace(name), strings.TrimSpace(passwordFile)
+		if !ok || user.name == "" || user.passwordFile == "" {
+			l.invalid(key, entry, "a comma separated list of user:password-file entries, readers ending with :ro")
+			continue
+		}
+		users = append(users, user)
+	}
+	return users
+}
+
+// tlsVersion parses a minimum TLS version, 1.2 unless set
+func (l *configLoader) tlsVersion(key string) uint16 {
+	switch value := l.str(key, "1.2"); value {
+	case "1.2":
+		return tls.VersionTLS12
+	case "1.3":
+		return tls.VersionTLS13
+	default:
+		l.invalid(key, value, "1.2 or 1.3")
+		return tls.VersionTLS12
+	}
+}
+
+// cipherSuites parses a comma separated list of TLS 1.2 cipher suite names.
+// TLS 1.3 suites aren't configurable in Go.
+func (l *configLoader) cipherSuites(key string) []uint16 {
+	known := map[string]uint16{}
+	var names []string
+	for _, suite := range tls.CipherSuites() {
+		for _, version := range suite.SupportedVersions {
+			if version == tls.VersionTLS12 {
+				known[suite.Name] = suite.ID
+				names = append(names, suite.Name)
+				break
+			}
+		}
+	}
+
+	var suites []uint16
+	for _, name := range strings.Split(l.get(key), ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		id, ok := known[name]
+		if !ok {
+			l.invalid(key, name, "one of "+strings.Join(names, ", "))
+			continue
+		}
+		suites = append(suites, id)
+	}
+	return suites
+}
+
+// topology reads the deployment topology selecting the readiness checks. It
+// sets CLUSTER_MODE or SENTINEL_MODE accordingly, and is derived from them
+// when unset, replication being the default.
+func (l *configLoader) topology(key string, cfg *Config) string {
+	topology := strings.ToLower(l.get(key))
+	switch topology {
+	case "":
+		if cfg.ClusterMode {
+			return "cluster"
+		}
+		if cfg.SentinelMode {
+			return "sentinel"
+		}
+		return "replication"
+	case "standalone", "replication", "cluster", "sentinel":
+	default:
+		l.invalid(key, topology, "one of "+strings.Join(checks.Topologies, ", "))
+		return topology
+	}
+
+	if l.get("CLUSTER_MODE") != "" && cfg.ClusterMode != (topology == "cluster") {
+		l.errs = append(l.errs, fmt.Errorf("%s=%s conflicts with CLUSTER_MODE=%t", key, topology, cfg.ClusterMode))
+	}
+	if l.get("SENTINEL_MODE") != "" && cfg.SentinelMode != (topology == "sentinel") {
+		l.errs = append(l.errs, fmt.Errorf("%s=%s conflicts with SENTINEL_MODE=%t", key, topology, cfg.SentinelMode))
+	}
+	cfg.ClusterMode = topology == "cluster"
+	cfg.SentinelMode = topology == "sentinel"
+	return topology
+}
+
+// checks parses a comma separated list of readiness check names, nil when
+// the variable is unset.
+func (l *configLoader) checks(key string) map[string]bool {
+	if l.get(key) == "" {
+		return nil
+	}
+
+	selected := map[string]bool{}
+	for _, name := range strings.Split(l.get(key), ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if !checks.Known(name) {
+			l.invalid(key, name, "one of "+strings.Join(checks.Readiness, ", "))
+			continue
+		}
+		selected[name] = true
+	}
+	return selected
+}
+
+// loadConfig loads and validates the configuration. Flags explicitly set on
+// the command line take precedence over the environment. The returned error
+// lists every invalid or missing setting.
+func loadConfig(flags *flag.FlagSet) (*Config, error) {
+	l := &configLoader{flags: map[string]string{}, lookup: os.LookupEnv}
+	if flags != nil {
+		flags.Visit(func(f *flag.Flag) {
+			if key, ok := configFlags[f.Name]; ok {
+				l.flags[key] = f.Value.String()
+			}
+		})
+	}
+
+	if l.get("TARGETS") != "" {
+		return l.loadTargets()
+	}
+	return l.load()
+}
+
+// loadTargets loads one configuration per entry of TARGETS, each reading
+// TARGET_<NAME>_<VARIABLE> before the shared environment, e.g.
+// TARGET_SENTINEL_HEALTH_CHECK_PASSWORD. The first target is the local node
+// served by /readyz and the other endpoints, its configuration is returned
+// with Targets set.
+func (l *configLoader) loadTargets() (*Config, error) {
+	specs, err := parseTargets(l.get("TARGETS"), l.str("NODE_HOST", "localhost"))
+	if err != nil {
+		return nil, err
+	}
+
+	targets := make([]Target, 0, len(specs))
+	var errs []string
+	for _, spec := range specs {
+		cfg, err := l.forTarget(spec).load()
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("target %s: %s", spec.name, err))
+			continue
+		}
+		targets = append(targets, Target{Name: spec.name, Config: cfg})
+	}
+	if len(errs) > 0 {
+		return nil, errors.New(strings.Join(errs, "; "))
+	}
+
+	cfg := targets[0].Config
+	cfg.Targets = targets
+	return cfg, nil
+}
+
+// forTarget returns a loader reading the overrides of one target. The target
+// always connects over TCP to the address given in TARGETS, in sentinel mode
+// by default when named sentinel.
+func (l *configLoader) forTarget(spec targetSpec) *configLoader {
+	prefix := "TARGET_" + strings.ToUpper(strings.ReplaceAll(spec.name, "-", "_")) + "_"
+
+	flags := map[string]string{}
+	for key, value := range l.flags {
+		flags[key] = value
+	}
+	flags["NODE_HOST"] = spec.host
+	flags["NODE_PORT"] = spec.port
+	flags["NODE_SOCKET"] = ""
+
+	return &configLoader{flags: flags, lookup: func(key string) (string, bool) {
+		if value, ok := l.lookup(prefix + key); ok {
+			return value, true
+		}
+		// The shared topology describes the data node
+		if spec.name == "sentinel" && key == "TOPOLOGY" {
+			return "sentinel", true
+		}
+		if spec.name == "sentinel" && key == "SENTINEL_MODE" {
+			return "true", true
+		}
+		return l.lookup(key)
+	}}
+}
+
+type targetSpec struct {
+	name string
+	host string
+	port string
+}
+
+// targetName keeps names usable in paths and environment variables
+var targetName = regexp.MustCompile(`^[a-z][a-z0-9-]*$`)
+
+// parseTargets parses a comma separated list of name:port or name:host:port
+// entries, the host defaulting to NODE_HOST.
+func parseTargets(value string, defaultHost string) ([]targetSpec, error) {
+	var specs []targetSpec
+	var errs []string
+	seen := map[string]bool{}
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		name, addr, _ := strings.Cut(entry, ":")
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			host, port = defaultHost, addr
+		}
+
+		switch n, err := strconv.Atoi(port); {
+		case !targetName.MatchString(name):
+			errs = append(errs, fmt.Sprintf("target name %q must be lowercase letters, digits and dashes", name))
+		case err != nil || n <= 0 || n > 65535:
+			errs = append(errs, fmt.Sprintf("target %s must be name:port or name:host:port, got %q", name, entry))
+		case seen[name]:
+			errs = append(errs, fmt.Sprintf("target name %s is used more than once", name))
+		default:
+			seen[name] = true
+			specs = append(specs, targetSpec{name: name, host: host, port: port})
+		}
+	}
+
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("invalid configuration: TARGETS: %s", strings.Join(errs, "; "))
+	}
+	if len(specs) == 0 {
+		return nil, errors.New("invalid configuration: TARGETS has no entries")
+	}
+	return specs, nil
+}
+
+// load reads and validates one configuration
+func (l *configLoader) load() (*Config, error) {
+	cfg := &Config{
+		Port:                  l.str("HEALTH_CHECK_PORT", "8081"),
+		ShutdownGrace:         l.durationMs("HEALTH_CHECK_SHUTDOWN_GRACE_MS", 5000*time.Millisecond),
+		DebugEndpoints:        l.boolean("ENABLE_DEBUG_ENDPOINTS"),
+		HistorySize:           int(l.integer("HEALTH_HISTORY_SIZE", 100)),
+		GzipMinBytes:          l.integer("JSON_GZIP_MIN_BYTES", 1024),
+		MaxListItems:          int(l.integer("MAX_LIST_ITEMS", 1000)),
+		MaxHeaderBytes:        int(l.integer("HEALTH_CHECK_MAX_HEADER_BYTES", 16<<10)),
+		DebugPort:             l.get("DEBUG_PORT"),
+		HTTPEnabled:           l.booleanOr("HEALTH_CHECK_HTTP", true),
+		ServerTLS:             l.boolean("HEALTH_CHECK_TLS"),
+		ServerTLSPort:         l.get("HEALTH_CHECK_TLS_PORT"),
+		SocketPath:            l.get("HEALTH_CHECK_SOCKET_PATH"),
+		AllowedCIDRs:          l.cidrs("HEALTH_ALLOWED_CIDRS", ""),
+		TrustProxyHeaders:     l.boolean("TRUST_PROXY_HEADERS"),
+		ServerTLSCertFile:     l.get("HEALTH_CHECK_TLS_CERT_FILE"),
+		ServerTLSKeyFile:      l.get("HEALTH_CHECK_TLS_KEY_FILE"),
+		ServerTLSClientCAFile: l.get("HEALTH_CHECK_TLS_CLIENT_CA_FILE"),
+		AdminToken:            l.get("HEALTH_ADMIN_TOKEN"),
+		DrainFile:             l.get("DRAIN_FILE"),
+		StatusFile:            l.get("HEALTH_STATUS_FILE"),
+		TerminationLog:        l.str("TERMINATION_LOG_PATH", "/dev/termination-log"),
+		FaultInjection:        l.boolean("ENABLE_FAULT_INJECTION"),
+		GRPCPort:              l.get("GRPC_HEALTH_PORT"),
+		GRPCPollInterval:      l.durationMs("GRPC_HEALTH_POLL_INTERVAL_MS", 5000*time.Millisecond),
+
+		ClusterHealthNodes:       l.list("CLUSTER_HEALTH_NODES", ""),
+		ClusterHealthNodeTimeout: l.durationMs("CLUSTER_HEALTH_NODE_TIMEOUT_MS", 1000*time.Millisecond),
+
+		PreflightMode:          strings.ToLower(l.str("PREFLIGHT_MODE", "warn")),
+		PreflightRetries:       l.integer("PREFLIGHT_RETRIES", 2),
+		PreflightRetryInterval: l.durationMs("PREFLIGHT_RETRY_INTERVAL_MS", 2000*time.Millisecond),
+
+		EmitK8sEvents:  l.boolean("EMIT_K8S_EVENTS"),
+		EventsInterval: l.durationMs("K8S_EVENTS_INTERVAL_MS", 10000*time.Millisecond),
+		PodNamespace:   l.get("POD_NAMESPACE"),
+		PodUID:         l.get("POD_UID"),
+
+		WebhookURL:         l.get("HEALTH_WEBHOOK_URL"),
+		WebhookInterval:    l.durationMs("HEALTH_WEBHOOK_INTERVAL_MS", 10000*time.Millisecond),
+		WebhookMinInterval: l.durationMs("HEALTH_WEBHOOK_MIN_INTERVAL_MS", 30000*time.Millisecond),
+		PodName:            l.get("POD_NAME"),
+		NodeIndex:          l.get("NODE_INDEX"),
+		StreamInterval:     l.durationMs("HEALTH_STREAM_INTERVAL_MS", 1000*time.Millisecond),
+		HeartbeatURL:       l.get("HEARTBEAT_URL"),
+		HeartbeatInterval:  l.durationMs("HEARTBEAT_INTERVAL_MS", 15000*time.Millisecond),
+		HeartbeatSecret:    l.get("HEARTBEAT_SECRET"),
+		StatusKey:          l.get("PUBLISH_STATUS_KEY"),
+		StatusKeyInterval:  l.durationMs("PUBLISH_STATUS_INTERVAL_MS", 5000*time.Millisecond),
+
+		NodeHost:                   l.str("NODE_HOST", "localhost"),
+		NodePort:                   l.get("NODE_PORT"),
+		NodeSocket:                 l.get("NODE_SOCKET"),
+		NodeTLSPort:                l.get("NODE_TLS_PORT"),
+		CheckBothListeners:         l.boolean("CHECK_BOTH_LISTENERS"),
+		TLS:                        l.boolean("TLS"),
+		RedisTLSServerName:         l.get("REDIS_TLS_SERVER_NAME"),
+		RedisTLSInsecureSkipVerify: l.boolean("REDIS_TLS_INSECURE_SKIP_VERIFY"),
+		RedisTLSCAFile:             l.get("REDIS_TLS_CA_FILE"),
+		RedisTLSCertFile:           l.get("REDIS_TLS_CERT_FILE"),
+		RedisTLSKeyFile:            l.get("REDIS_TLS_KEY_FILE"),
+		RedisProtocol:              l.integer("REDIS_PROTOCOL", 0),
+		User:                       l.get("HEALTH_CHECK_USER"),
+		Password:                   l.get("HEALTH_CHECK_PASSWORD"),
+		AdminPassword:              l.get("ADMIN_PASSWORD"),
+		AdminPasswordFile:          l.get("ADMIN_PASSWORD_FILE"),
+		AdminPasswordPrevious:      l.get("ADMIN_PASSWORD_PREVIOUS"),
+		FailOpenOnAuthError:        l.boolean("FAIL_OPEN_ON_AUTH_ERROR"),
+		BootstrapGrace:             time.Duration(l.integer("BOOTSTRAP_GRACE_SECONDS", 0)) * time.Second,
+		StartupGrace:               time.Duration(l.integer("STARTUP_GRACE_SECONDS", 60)) * time.Second,
+		AllowRemoteTargets:         l.boolean("ALLOW_REMOTE_TARGETS"),
+
+		ProbeTimeout:     l.durationMs("HEALTH_CHECK_TIMEOUT_MS", 2000*time.Millisecond),
+		DeepCheckTimeout: l.durationMs("DEEP_CHECK_TIMEOUT_MS", 500*time.Millisecond),
+		ReadProbeTimeout: l.durationMs("READ_PROBE_TIMEOUT_MS", 500*time.Millisecond),
+		ListenerTimeout:  l.durationMs("LISTENER_TIMEOUT_MS", 500*time.Millisecond),
+		Retries:          int(l.integer("HEALTH_CHECK_RETRIES", 2)),
+		MaxInternalFails: l.integer("MAX_CONSECUTIVE_INTERNAL_FAILURES", 0),
+		CircuitThreshold: l.integer("CIRCUIT_FAILURE_THRESHOLD", 0),
+		CircuitOpen:      l.durationMs("CIRCUIT_OPEN_MS", 5000*time.Millisecond),
+		CacheTTL:         time.Duration(l.integer("HEALTH_CHECK_CACHE_MS", 0)) * time.Millisecond,
+		GraphCacheTTL:    l.durationMs("GRAPH_INVENTORY_CACHE_MS", 5000*time.Millisecond),
+		SlowEvalFraction: l.float("SLOW_EVALUATION_WARN_FRACTION", 0.8),
+
+		SentinelMode:    l.boolean("SENTINEL_MODE"),
+		MasterName:      l.str("MASTER_NAME", "master"),
+		SentinelHost:    l.get("SENTINEL_HOST"),
+		SentinelPort:    l.str("SENTINEL_PORT", "26379"),
+		SentinelTimeout: l.durationMs("SENTINEL_TIMEOUT_MS", 500*time.Millisecond),
+		SentinelAddrs:   l.list("SENTINEL_ADDRS", ""),
+		SentinelAuth:    l.get("SENTINEL_PASSWORD"),
+		ClusterMode:     l.boolean("CLUSTER_MODE"),
+		PodIP:           l.get("POD_IP"),
+		ExpectedRole:    l.get("EXPECTED_ROLE"),
+
+		SkipModuleCheck:          l.boolean("SKIP_MODULE_CHECK"),
+		DeepCheck:                l.boolean("DEEP_CHECK"),
+		WriteProbe:               l.boolean("WRITE_PROBE"),
+		ReadProbeGraph:           l.get("READ_PROBE_GRAPH"),
+		ReadProbeMasters:         l.boolean("READ_PROBE_MASTERS"),
+		ReadProbeInterval:        l.durationMs("READ_PROBE_INTERVAL_MS", 30000*time.Millisecond),
+		CheckPersistence:         l.boolean("CHECK_PERSISTENCE"),
+		ExpectedPersistence:      strings.ToLower(l.get("EXPECTED_PERSISTENCE")),
+		CheckFullSlotCoverage:    l.boolean("CHECK_FULL_SLOT_COVERAGE"),
+		CheckRejectedConnections: l.boolean("CHECK_REJECTED_CONNECTIONS"),
+		CheckReplicaConfig:       l.boolean("CHECK_REPLICA_CONFIG"),
+		CheckLatencyEvents:       l.boolean("CHECK_LATENCY_EVENTS"),
+		ExpectFailoverEligible:   l.boolean("EXPECT_FAILOVER_ELIGIBLE"),
+		StandbyReady:             l.booleanOr("STANDBY_READY", true),
+		DrainDuringPersistence:   l.boolean("DRAIN_DURING_PERSISTENCE"),
+		AnnounceMismatchWarnOnly: l.boolean("ANNOUNCE_MISMATCH_WARN_ONLY"),
+		SentinelRegistration:     l.boolean("REQUIRE_SENTINEL_REGISTRATION"),
+		CheckExternalAddress:     l.boolean("CHECK_EXTERNAL_ADDRESS"),
+		E2ESentinelCheck:         l.boolean("E2E_SENTINEL_CHECK"),
+		E2EGraph:                 l.get("E2E_GRAPH"),
+		ExternalCheckMode:        l.str("EXTERNAL_CHECK_MODE", "warn"),
+		ExternalHost:             l.get("EXTERNAL_HOST"),
+		ExternalPort:             l.get("EXTERNAL_PORT"),
+		ExternalTimeout:          l.durationMs("EXTERNAL_CHECK_TIMEOUT_MS", 1000*time.Millisecond),
+		ACLUsers:                 l.aclUsers("VERIFY_ACL_USERS"),
+		ACLVerifyMode:            l.str("ACL_VERIFY_MODE", "warn"),
+		ACLVerifyInterval:        l.durationMs("ACL_VERIFY_INTERVAL_MS", 60000*time.Millisecond),
+		ACLVerifyTimeout:         l.durationMs("ACL_VERIFY_TIMEOUT_MS", 500*time.Millisecond),
+		ACLVerifyGraph:           l.get("ACL_VERIFY_GRAPH"),
+		AllowLoopbackOnly:        l.boolean("ALLOW_LOOPBACK_ONLY"),
+		GraphConfigWarnOnly:      l.boolean("GRAPH_CONFIG_MISMATCH_WARN_ONLY"),
+		ExpectedGraphs:           l.list("EXPECTED_GRAPHS", ""),
+		FalkorDBVersion:          l.get("FALKORDB_VERSION"),
+		DataDir:                  l.str("DATA_DIR", "/data"),
+		RequireDiskForBgsave:     l.boolean("REQUIRE_DISK_FOR_BGSAVE"),
+
+		MaxPingLatencyMs:          l.integer("MAX_PING_LATENCY_MS", 0),
+		MaxMemoryUsedPercent:      l.integer("MAX_MEMORY_USED_PERCENT", 0),
+		MaxCPUPercent:             l.integer("MAX_CPU_PERCENT", 0),
+		CPUSustainedProbes:        l.integer("CPU_SUSTAINED_PROBES", 3),
+		FragWarnRatio:             l.float("FRAG_WARN_RATIO", 0),
+		FragFailRatio:             l.float("FRAG_FAIL_RATIO", 0),
+		MaxSecondsSinceLastSave:   l.integer("MAX_SECONDS_SINCE_LAST_SAVE", 0),
+		MaxRDBAgeSeconds:          l.integer("MAX_RDB_AGE_SECONDS", 0),
+		MaxKeys:                   l.integer("MAX_KEYS", 0),
+		MinReplBacklogBytes:       l.integer("MIN_REPL_BACKLOG_BYTES", 0),
+		MaxReplicaLagBytes:        l.integer("MAX_REPLICA_LAG_BYTES", -1),
+		MaxSyncStallSeconds:       l.integer("MAX_SYNC_STALL_SECONDS", 0),
+		MaxMasterLastIOSeconds:    l.integer("MAX_MASTER_LAST_IO_SECONDS", 0),
+		FailoverMaxLagBytes:       l.integer("FAILOVER_READY_MAX_LAG_BYTES", 1<<20),
+		MaxDrainSeconds:           l.integer("MAX_PERSISTENCE_DRAIN_SECONDS", 600),
+		SyncStallWarnSeconds:      l.integer("SYNC_STALL_WARN_SECONDS", 60),
+		MinConnectedReplicas:      l.integer("MIN_CONNECTED_REPLICAS", 0),
+		MaxClientsUsedPercent:     l.integer("MAX_CLIENTS_USED_PERCENT", 0),
+		MaxBlockedClients:         l.integer("MAX_BLOCKED_CLIENTS", 0),
+		SentinelMinOtherSentinels: l.integer("SENTINEL_MIN_OTHER_SENTINELS", 0),
+		ExpectedReplicas:          l.integer("EXPECTED_REPLICAS", 0),
+		MaxFailedPeersPercent:     l.integer("CLUSTER_MAX_FAILED_PEERS_PERCENT", 50),
+		MaxSlotMigrationSeconds:   l.integer("MAX_SLOT_MIGRATION_SECONDS", 300),
+		FailoverGraceSeconds:      l.integer("FAILOVER_GRACE_SECONDS", 30),
+		SlowlogGrowthPerMinute:    l.integer("SLOWLOG_GROWTH_PER_MINUTE", 0),
+		SlowlogFailThreshold:      l.integer("SLOWLOG_FAIL_THRESHOLD", 0),
+		LatencyEventWindowSeconds: l.integer("LATENCY_EVENT_WINDOW_SECONDS", 300),
+		LatencyFailMs:             l.integer("LATENCY_FAIL_MS", 0),
+		LatencyFailEvents:         l.list("LATENCY_FAIL_EVENTS", "fork"),
+		MinDiskFreePercent:        l.integer("MIN_DISK_FREE_PERCENT", 0),
+		MinDiskFreeBytes:          l.integer("MIN_DISK_FREE_BYTES", 0),
+
+		Telemetry: l.get("OTEL_EXPORTER_OTLP_ENDPOINT") != "",
+
+		LogLevel:  strings.ToLower(l.str("LOG_LEVEL", "info")),
+		LogFormat: strings.ToLower(l.str("LOG_FORMAT", "text")),
+	}
+	// Each check may use the whole probe budget unless told otherwise
+	cfg.CheckTimeout = l.durationMs("CHECK_TIMEOUT_MS", cfg.ProbeTimeout)
+
+	// EXPECTED_SENTINELS counts this sentinel too
+	if expected := l.integer("EXPECTED_SENTINELS", 0); expected-1 > cfg.SentinelMinOtherSentinels {
+		cfg.SentinelMinOtherSentinels = expected - 1
+	}
+
+	cfg.PollInterval = l.durationMs("HEALTH_POLL_INTERVAL_MS", 0)
+	// Allow a couple of slow rounds before declaring the poller wedged
+	cfg.PollMaxAge = l.durationMs("HEALTH_POLL_MAX_AGE_MS", 3*cfg.PollInterval+cfg.ProbeTimeout)
+
+	l.port("HEALTH_CHECK_PORT", cfg.Port)
+	if cfg.ServerTLSPort != "" {
+		l.port("HEALTH_CHECK_TLS_PORT", cfg.ServerTLSPort)
+		if !cfg.ServerTLS {
+			l.errs = append(l.errs, errors.New("HEALTH_CHECK_TLS_PORT requires HEALTH_CHECK_TLS=true"))
+		}
+		if cfg.HTTPEnabled && cfg.ServerTLSPort == cfg.Port {
+			l.errs = append(l.errs, errors.New("HEALTH_CHECK_TLS_PORT must differ from HEALTH_CHECK_PORT"))
+		}
+	}
+	if !cfg.HTTPEnabled && !cfg.ServerTLS {
+		l.errs = append(l.errs, errors.New("HEALTH_CHECK_HTTP=false requires HEALTH_CHECK_TLS=true"))
+	}
+	cfg.BindAddrs = l.bindAddrs("HEALTH_CHECK_BIND_ADDR")
+	cfg.RateLimitRPS = l.float("HEALTH_RATE_LIMIT_RPS", 0)
+	if cfg.RateLimitRPS > 0 {
+		cfg.RateLimitBurst = l.integer("HEALTH_RATE_LIMIT_BURST", int64(max(cfg.RateLimitRPS, 1)))
+		if cfg.RateLimitBurst <= 0 {
+			l.invalid("HEALTH_RATE_LIMIT_BURST", l.get("HEALTH_RATE_LIMIT_BURST"), "a positive integer")
+		}
+		cfg.RateLimitExempt = l.cidrs("HEALTH_RATE_LIMIT_EXEMPT_CIDRS", "127.0.0.0/8,::1/128")
+	}
+	if path := l.get("HEALTH_ADMIN_TOKEN_FILE"); path != "" && cfg.AdminToken == "" {
+		token, err := os.ReadFile(path)
+		cfg.AdminToken = strings.TrimSpace(string(token))
+		if err != nil || cfg.AdminToken == "" {
+			l.invalid("HEALTH_ADMIN_TOKEN_FILE", path, "a readable file holding the token")
+		}
+	}
+	if cfg.SocketPath != "" {
+		cfg.SocketOnly = l.get("HEALTH_CHECK_PORT") == "" && cfg.ServerTLSPort == ""
+		mode, err := strconv.ParseUint(l.str("HEALTH_CHECK_SOCKET_MODE", "0660"), 8, 32)
+		if err != nil || mode > 0o777 {
+			l.invalid("HEALTH_CHECK_SOCKET_MODE", l.get("HEALTH_CHECK_SOCKET_MODE"), "octal permissions such as 0660")
+		}
+		cfg.SocketMode = os.FileMode(mode)
+	}
+	if cfg.WebhookURL != "" {
+		if u, err := url.Parse(cfg.WebhookURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			l.invalid("HEALTH_WEBHOOK_URL", cfg.WebhookURL, "an http or https URL")
+		}
+	}
+	if cfg.HeartbeatURL != "" {
+		if u, err := url.Parse(cfg.HeartbeatURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			l.invalid("HEARTBEAT_URL", cfg.HeartbeatURL, "an http or https URL")
+		}
+	}
+
+	cfg.Checks = l.checks("HEALTH_CHECKS")
+	cfg.ExpectedGraphConfig = l.graphConfig("EXPECTED_GRAPH_CONFIG")
+	cfg.TLSMinVersion = l.tlsVersion("REDIS_TLS_MIN_VERSION")
+	cfg.TLSCipherSuites = l.cipherSuites("REDIS_TLS_CIPHER_SUITES")
+
+	if cfg.PodIP != "" && net.ParseIP(cfg.PodIP) == nil {
+		l.invalid("POD_IP", cfg.PodIP, "an IP address")
+	}
+	if cfg.DebugPort != "" {
+		l.port("DEBUG_PORT", cfg.DebugPort)
+	}
+	if cfg.SentinelHost != "" {
+		l.port("SENTINEL_PORT", cfg.SentinelPort)
+	}
+	for _, addr := range cfg.SentinelAddrs {
+		if _, port, err := net.SplitHostPort(addr); err != nil {
+			l.invalid("SENTINEL_ADDRS", addr, "a comma separated list of host:port")
+		} else {
+			l.port("SENTINEL_ADDRS", port)
+		}
+	}
+	if cfg.ExternalPort != "" {
+		l.port("EXTERNAL_PORT", cfg.ExternalPort)
+	}
+	if cfg.ExternalCheckMode != "warn" && cfg.ExternalCheckMode != "fail" {
+		l.invalid("EXTERNAL_CHECK_MODE", cfg.ExternalCheckMode, "warn or fail")
+	}
+	if cfg.ACLVerifyMode != "warn" && cfg.ACLVerifyMode != "fail" {
+		l.invalid("ACL_VERIFY_MODE", cfg.ACLVerifyMode, "warn or fail")
+	}
+	if cfg.ACLVerifyGraph == "" && slices.ContainsFunc(cfg.ACLUsers, func(user aclUser) bool { return user.reader }) {
+		l.errs = append(l.errs, errors.New("ACL_VERIFY_GRAPH is required when VERIFY_ACL_USERS has readers"))
+	}
+	if cfg.SentinelRegistration && len(cfg.SentinelAddrs) == 0 {
+		l.errs = append(l.errs, errors.New("SENTINEL_ADDRS is required when REQUIRE_SENTINEL_REGISTRATION=true"))
+	}
+	if cfg.E2ESentinelCheck && len(cfg.SentinelAddrs) == 0 {
+		l.errs = append(l.errs, errors.New("SENTINEL_ADDRS is required when E2E_SENTINEL_CHECK=true"))
+	}
+	if cfg.GRPCPort != "" {
+		l.port("GRPC_HEALTH_PORT", cfg.GRPCPort)
+	}
+
+	if cfg.NodeSocket != "" {
+		if cfg.TLS || cfg.RedisTLSCAFile != "" || cfg.RedisTLSCertFile != "" {
+			l.errs = append(l.errs, errors.New("TLS settings are not supported when connecting over NODE_SOCKET"))
+		}
+	} else if cfg.NodePort == "" {
+		l.errs = append(l.errs, errors.New("NODE_PORT is required unless NODE_SOCKET is set"))
+	} else {
+		l.port("NODE_PORT", cfg.NodePort)
+	}
+	if cfg.CheckBothListeners {
+		if cfg.NodeSocket != "" || cfg.NodeTLSPort == "" {
+			l.errs = append(l.errs, errors.New("CHECK_BOTH_LISTENERS needs NODE_PORT and NODE_TLS_PORT, not NODE_SOCKET"))
+		} else {
+			l.port("NODE_TLS_PORT", cfg.NodeTLSPort)
+		}
+	}
+
+	// The drain must end even if the rewrite never does
+	if cfg.DrainDuringPersistence && cfg.MaxDrainSeconds <= 0 {
+		l.invalid("MAX_PERSISTENCE_DRAIN_SECONDS", strconv.FormatInt(cfg.MaxDrainSeconds, 10), "a positive number with DRAIN_DURING_PERSISTENCE=true")
+	}
+
+	if pattern := l.get("REMOTE_TARGET_PATTERN"); pattern != "" {
+		// Anchored so the whole host:port has to match
+		re, err := regexp.Compile("^(?:" + pattern + ")$")
+		if err != nil {
+			l.invalid("REMOTE_TARGET_PATTERN", pattern, "a valid regular expression")
+		}
+		cfg.RemoteTargetPattern = re
+	} else if cfg.AllowRemoteTargets {
+		l.errs = append(l.errs, errors.New("REMOTE_TARGET_PATTERN is required when ALLOW_REMOTE_TARGETS=true"))
+	}
+
+	if (cfg.RedisTLSCertFile == "") != (cfg.RedisTLSKeyFile == "") {
+		l.errs = append(l.errs, errors.New("REDIS_TLS_CERT_FILE and REDIS_TLS_KEY_FILE must be set together"))
+	}
+
+	if cfg.ServerTLS && (cfg.ServerTLSCertFile == "" || cfg.ServerTLSKeyFile == "") {
+		l.errs = append(l.errs, errors.New("HEALTH_CHECK_TLS_CERT_FILE and HEALTH_CHECK_TLS_KEY_FILE are required when HEALTH_CHECK_TLS=true"))
+	}
+
+	if cfg.HistorySize < 0 {
+		l.invalid("HEALTH_HISTORY_SIZE", strconv.Itoa(cfg.HistorySize), "zero or more")
+	}
+	if cfg.SlowEvalFraction < 0 {
+		l.invalid("SLOW_EVALUATION_WARN_FRACTION", l.get("SLOW_EVALUATION_WARN_FRACTION"), "zero or more")
+	}
+	if cfg.GzipMinBytes < 0 {
+		l.invalid("JSON_GZIP_MIN_BYTES", l.get("JSON_GZIP_MIN_BYTES"), "zero or more")
+	}
+	if cfg.MaxListItems <= 0 {
+		l.invalid("MAX_LIST_ITEMS", strconv.Itoa(cfg.MaxListItems), "a positive integer")
+	}
+	if cfg.MaxHeaderBytes <= 0 {
+		l.invalid("HEALTH_CHECK_MAX_HEADER_BYTES", strconv.Itoa(cfg.MaxHeaderBytes), "a positive integer")
+	}
+	if cfg.Retries < 0 {
+		l.invalid("HEALTH_CHECK_RETRIES", strconv.Itoa(cfg.Retries), "zero or more")
+	}
+	if cfg.CacheTTL < 0 {
+		l.invalid("HEALTH_CHECK_CACHE_MS", l.get("HEALTH_CHECK_CACHE_MS"), "zero or more")
+	}
+
+	cfg.Topology = l.topology("TOPOLOGY", cfg)
+	for _, pattern := range cfg.ExpectedGraphs {
+		if _, err := path.Match(pattern, ""); err != nil {
+			l.invalid("EXPECTED_GRAPHS", pattern, "a glob pattern")
+		}
+	}
+	switch cfg.ExpectedPersistence {
+	case "", "aof", "rdb", "both", "none":
+	default:
+		l.invalid("EXPECTED_PERSISTENCE", cfg.ExpectedPersistence, "aof, rdb, both or none")
+	}
+	if cfg.RedisProtocol != 0 && cfg.RedisProtocol != 2 && cfg.RedisProtocol != 3 {
+		l.invalid("REDIS_PROTOCOL", strconv.FormatInt(cfg.RedisProtocol, 10), "2 or 3")
+	}
+	switch cfg.PreflightMode {
+	case "abort", "wait", "warn":
+	default:
+		l.invalid("PREFLIGHT_MODE", cfg.PreflightMode, "abort, wait or warn")
+	}
+	if cfg.PreflightRetries < 0 {
+		l.invalid("PREFLIGHT_RETRIES", strconv.FormatInt(cfg.PreflightRetries, 10), "a non-negative number")
+	}
+	switch mode := strings.ToLower(l.str("CPU_CHECK_MODE", "warn")); mode {
+	case "warn":
+	case "fail":
+		cfg.FailOnHighCPU = true
+	default:
+		l.invalid("CPU_CHECK_MODE", mode, "warn or fail")
+	}
+	switch mode := strings.ToLower(l.str("VERSION_CHECK_MODE", "fail")); mode {
+	case "fail":
+	case "warn":
+		cfg.VersionMismatchWarnOnly = true
+	default:
+		l.invalid("VERSION_CHECK_MODE", mode, "fail or warn")
+	}
+	switch mode := strings.ToLower(l.str("RDB_STALENESS_MODE", "fail")); mode {
+	case "fail":
+	case "warn":
+		cfg.RDBStalenessWarnOnly = true
+	default:
+		l.invalid("RDB_STALENESS_MODE", mode, "fail or warn")
+	}
+
+	if cfg.Topology == "standalone" && cfg.ExpectedRole == "" {
+		// Nothing else could be serving the data
+		cfg.ExpectedRole = "master"
+	}
+
+	// Redis reports no lag in seconds a replica could be held to
+	if l.get("MAX_REPLICA_LAG_SECONDS") != "" {
+		l.errs = append(l.errs, errors.New("MAX_REPLICA_LAG_SECONDS is not supported, use MAX_REPLICA_LAG_BYTES, or MAX_MASTER_LAST_IO_SECONDS for a silent master"))
+	}
+
+	if cfg.SentinelMode && l.get("MASTER_NAME") == "" {
+		l.errs = append(l.errs, errors.New("MASTER_NAME is required when SENTINEL_MODE=true"))
+	}
+
+	if cfg.ExpectedRole != "" {
+		if _, ok := normalizeRole(cfg.ExpectedRole); !ok {
+			l.invalid("EXPECTED_ROLE", cfg.ExpectedRole, "one of "+strings.Join(acceptedRoles, ", "))
+		}
+	}
+
+	if _, ok := logLevels[cfg.LogLevel]; !ok {
+		l.invalid("LOG_LEVEL", cfg.LogLevel, "one of debug, info, warn or error")
+	}
+	if cfg.LogFormat != "text" && cfg.LogFormat != "json" {
+		l.invalid("LOG_FORMAT", cfg.LogFormat, "text or json")
+	}
+
+	if len(l.errs) > 0 {
+		messages := make([]string, len(l.errs))
+		for i, err := range l.errs {
+			messages[i] = err.Error()
+		}
+		return nil, fmt.Errorf("invalid configuration: %s", strings.Join(messages, "; "))
+	}
+	return cfg, nil
+}
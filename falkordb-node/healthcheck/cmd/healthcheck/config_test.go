@@ -0,0 +1,143 @@
+package main
+
+import (
+	"flag"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConfigPrecedence(t *testing.T) {
+	tests := []struct {
+		name        string
+		env         string
+		args        []string
+		wantTimeout time.Duration
+	}{
+		{name: "default", wantTimeout: 2000 * time.Millisecond},
+		{name: "env", env: "300", wantTimeout: 300 * time.Millisecond},
+		{name: "flag", args: []string{"-timeout-ms=400"}, wantTimeout: 400 * time.Millisecond},
+		{name: "flag over env", env: "300", args: []string{"-timeout-ms=400"}, wantTimeout: 400 * time.Millisecond},
+		{name: "other flag leaves env", env: "300", args: []string{"-log-level=debug"}, wantTimeout: 300 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("NODE_PORT", "6379")
+			t.Setenv("HEALTH_CHECK_TIMEOUT_MS", tt.env)
+			flags := flag.NewFlagSet("healthcheck", flag.ContinueOnError)
+			registerConfigFlags(flags)
+			if err := flags.Parse(tt.args); err != nil {
+				t.Fatal(err)
+			}
+
+			cfg, err := loadConfig(flags)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.ProbeTimeout != tt.wantTimeout {
+				t.Errorf("ProbeTimeout = %v, want %v", cfg.ProbeTimeout, tt.wantTimeout)
+			}
+		})
+	}
+}
+
+func TestConfigFlagOverridesInvalidEnv(t *testing.T) {
+	t.Setenv("NODE_PORT", "not-a-port")
+	flags := flag.NewFlagSet("healthcheck", flag.ContinueOnError)
+	registerConfigFlags(flags)
+	if err := flags.Parse([]string{"-node-port=6380"}); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := loadConfig(flags)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.NodePort != "6380" {
+		t.Errorf("NodePort = %q, want the flag's 6380", cfg.NodePort)
+	}
+}
+
+func TestConfigValidation(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		want []string
+	}{
+		{
+			name: "missing port",
+			env:  map[string]string{"NODE_PORT": ""},
+			want: []string{"NODE_PORT is required unless NODE_SOCKET is set"},
+		},
+		{
+			name: "bad port",
+			env:  map[string]string{"NODE_PORT": "70000"},
+			want: []string{`NODE_PORT="70000" must be a port number`},
+		},
+		{
+			name: "bad boolean",
+			env:  map[string]string{"TLS": "yes please"},
+			want: []string{`TLS="yes please" must be true or false`},
+		},
+		{
+			name: "bad timeout",
+			env:  map[string]string{"HEALTH_CHECK_TIMEOUT_MS": "0"},
+			want: []string{`HEALTH_CHECK_TIMEOUT_MS="0" must be a positive number of milliseconds`},
+		},
+		{
+			name: "every error at once",
+			env:  map[string]string{"NODE_PORT": "", "TLS": "maybe", "HEALTH_CHECK_TIMEOUT_MS": "fast", "LOG_FORMAT": "xml"},
+			want: []string{
+				`TLS="maybe" must be true or false`,
+				`HEALTH_CHECK_TIMEOUT_MS="fast" must be a positive number of milliseconds`,
+				`LOG_FORMAT="xml" must be text or json`,
+				"NODE_PORT is required unless NODE_SOCKET is set",
+			},
+		},
+		{
+			name: "header limit",
+			env:  map[string]string{"HEALTH_CHECK_MAX_HEADER_BYTES": "0"},
+			want: []string{`HEALTH_CHECK_MAX_HEADER_BYTES="0" must be a positive integer`},
+		},
+		{
+			name: "replica lag in seconds",
+			env:  map[string]string{"MAX_REPLICA_LAG_SECONDS": "10"},
+			want: []string{"MAX_REPLICA_LAG_SECONDS is not supported, use MAX_REPLICA_LAG_BYTES"},
+		},
+		{
+			name: "dependent settings",
+			env:  map[string]string{"REDIS_TLS_CERT_FILE": "/tls/client.crt", "SENTINEL_MODE": "true"},
+			want: []string{
+				"REDIS_TLS_CERT_FILE and REDIS_TLS_KEY_FILE must be set together",
+				"MASTER_NAME is required when SENTINEL_MODE=true",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("NODE_PORT", "6379")
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+			cfg, err := loadConfig(nil)
+			if err == nil {
+				t.Fatalf("loadConfig() = %+v, want an error", cfg)
+			}
+			if !strings.HasPrefix(err.Error(), "invalid configuration: ") {
+				t.Errorf("loadConfig() error = %q, want it to open with invalid configuration", err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("loadConfig() error = %q, want it to list %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestConfigDefaults(t *testing.T) {
+	cfg := testConfig(t, nil)
+	if cfg.Port != "8081" || cfg.ProbeTimeout != 2000*time.Millisecond || cfg.CheckTimeout != cfg.ProbeTimeout || cfg.LogLevel != "info" || cfg.TLS {
+		t.Errorf("defaults = port %s, timeout %v, check timeout %v, log level %s, TLS %t", cfg.Port, cfg.ProbeTimeout, cfg.CheckTimeout, cfg.LogLevel, cfg.TLS)
+	}
+}
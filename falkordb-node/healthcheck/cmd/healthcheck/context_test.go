@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestProbeAbandonedByTheCaller(t *testing.T) {
+	cfg := testConfig(t, map[string]string{"HEALTH_CHECK_TIMEOUT_MS": "200"})
+	node := newFakeNode(masterInfo)
+	p := newTestProbes(t, cfg, node)
+	handler := newHealthCheckHandler(cfg, p)
+
+	// The connection of the client pool is up before counting
+	if w := serve(t, cfg, p, http.MethodGet, "/readyz", nil); w.Code != http.StatusOK {
+		t.Fatalf("GET /readyz = %d %q", w.Code, w.Body.String())
+	}
+	baseline := runtime.NumGoroutine()
+
+	node.delay("INFO", 400*time.Millisecond)
+	reqCtx, cancel := context.WithCancel(context.Background())
+	r := httptest.NewRequest(http.MethodGet, "/readyz", nil).WithContext(reqCtx)
+	w := httptest.NewRecorder()
+	returned := make(chan struct{})
+	go func() {
+		defer close(returned)
+		handler.ServeHTTP(w, r)
+	}()
+
+	eventually(t, "the INFO call", func() bool { return node.called("INFO") == 2 })
+	cancel()
+	select {
+	case <-returned:
+	case <-time.After(100 * time.Millisecond):
+		t.Fatal("handler still running after the caller left")
+	}
+	if w.Body.Len() != 0 {
+		t.Errorf("abandoned probe answered %d %q", w.Code, w.Body.String())
+	}
+
+	// The shared evaluation ends by the probe deadline, aborting the INFO
+	// call, and leaves nothing running behind
+	eventually(t, "the goroutines of the probe to end", func() bool { return runtime.NumGoroutine() <= baseline })
+	if got := node.called("INFO"); got != 2 {
+		t.Errorf("INFO called %d times, want the abandoned call not retried", got)
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"falkordb.cloud/main/internal/redisinfo"
+)
+
+// cpuWindow is the shortest interval utilization is measured over, so probes
+// answered from the same cached INFO don't measure 0%
+const cpuWindow = time.Second
+
+// cpuSample is the used_cpu_sys + used_cpu_user total that started the
+// current window, the utilization measured over the previous one and how
+// many measurements in a row were above MAX_CPU_PERCENT
+var cpuSample = struct {
+	mu       sync.Mutex
+	seconds  float64
+	at       time.Time
+	percent  float64
+	measured bool
+	over     int64
+}{}
+
+// checkCPU flags a node pegging a core while it still answers probes
+// quickly. Utilization above MAX_CPU_PERCENT for CPU_SUSTAINED_PROBES
+// measurements in a row is reported, and fails readiness with
+// CPU_CHECK_MODE=fail. 100% is one busy core. Disabled unless MAX_CPU_PERCENT
+// is set.
+func checkCPU(probeCtx context.Context, info *redisinfo.Info, cfg *Config) (string, string) {
+	// The previous sample describes the local node only
+	if cfg.MaxCPUPercent <= 0 || probeTarget(probeCtx) != "" {
+		return "", ""
+	}
+
+	sys, errSys := info.Float("used_cpu_sys")
+	user, errUser := info.Float("used_cpu_user")
+	if errSys != nil || errUser != nil {
+		return "", "unknown"
+	}
+
+	percent, over, ok := cpuPercent(sys+user, cfg.MaxCPUPercent)
+	if !ok {
+		return "", "unknown"
+	}
+	cpuPercentGauge.Set(percent)
+
+	detail := fmt.Sprintf("cpu=%.1f%%", percent)
+	if over < max(cfg.CPUSustainedProbes, 1) {
+		return "", detail
+	}
+
+	detail = fmt.Sprintf("%s max=%d%% probes=%d", detail, cfg.MaxCPUPercent, over)
+	if cfg.FailOnHighCPU {
+		return fmt.Sprintf("HIGH_CPU cpu=%.1f%%", percent), detail
+	}
+	return "", "warning: " + detail
+}
+
+// cpuPercent returns the utilization over the last full window, starting a
+// new one with seconds once cpuWindow passed, and how many windows in a row
+// were above maxPercent. It returns false until a first window completed,
+// the first probe after startup has no baseline.
+func cpuPercent(seconds float64, maxPercent int64) (float64, int64, bool) {
+	cpuSample.mu.Lock()
+	defer cpuSample.mu.Unlock()
+
+	now := time.Now()
+	// The counters restart with the node
+	if cpuSample.at.IsZero() || seconds < cpuSample.seconds {
+		cpuSample.seconds, cpuSample.at, cpuSample.measured, cpuSample.over = seconds, now, false, 0
+		return 0, 0, false
+	}
+
+	if elapsed := now.Sub(cpuSample.at); elapsed >= cpuWindow {
+		cpuSample.percent = (seconds - cpuSample.seconds) / elapsed.Seconds() * 100
+		cpuSample.measured = true
+		if cpuSample.percent > float64(maxPercent) {
+			cpuSample.over++
+		} else {
+			cpuSample.over = 0
+		}
+		cpuSample.seconds, cpuSample.at = seconds, now
+	}
+	return cpuSample.percent, cpuSample.over, cpuSample.measured
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// debugInfoFields are the INFO fields consumed by the checks. Only these are
+// exposed so nothing sensitive ever leaks through the debug endpoint.
+var debugInfoFields = []string{
+	"redis_version",
+	"redis_mode",
+	"role",
+	"master_sync_in_progress",
+	"master_link_status",
+	"master_last_io_seconds_ago",
+	"master_link_down_since_seconds",
+	"master_repl_offset",
+	"slave_repl_offset",
+	"connected_slaves",
+	"loading",
+	"loading_loaded_perc",
+	"used_memory",
+	"maxmemory",
+	"rdb_last_bgsave_status",
+	"rdb_last_save_time",
+	"aof_enabled",
+	"aof_last_write_status",
+	"aof_last_bgrewrite_status",
+}
+
+type debugInfo struct {
+	FetchedAt time.Time         `json:"fetched_at"`
+	Fields    map[string]string `json:"fields"`
+	Keyspace  *keyspaceInfo     `json:"keyspace,omitempty"`
+}
+
+// debugInfoHandler returns the parsed INFO fields the checks evaluate,
+// through the same fetch path, so it shows exactly what the probe sees.
+func debugInfoHandler(w http.ResponseWriter, r *http.Request) {
+	probeCtx, cancel := probeContext(r)
+	defer cancel()
+
+	info, fetchedAt, err := fetchInfoAt(probeCtx)
+	if err != nil {
+		writeRedisError(w, r, err)
+		return
+	}
+
+	body := debugInfo{FetchedAt: fetchedAt, Fields: map[string]string{}}
+	for _, key := range debugInfoFields {
+		if value, err := info.String(key); err == nil {
+			body.Fields[key] = value
+		}
+	}
+	for _, key := range info.Keys() {
+		if strings.HasPrefix(key, "slave") && strings.TrimLeft(key[len("slave"):], "0123456789") == "" {
+			body.Fields[key], _ = info.String(key)
+		}
+	}
+	if info.Section("keyspace") != nil {
+		body.Keyspace = nodeKeyspace(info)
+	}
+
+	writeJSON(w, r, http.StatusOK, body)
+}
+
+// debugConfig is the part of the configuration that decides what the probes
+// check. Credentials and file paths are left out on purpose.
+type debugConfig struct {
+	Checks       []string `json:"checks"`
+	Topology     string   `json:"topology"`
+	SentinelMode bool     `json:"sentinel_mode"`
+	ClusterMode  bool     `json:"cluster_mode"`
+	MasterName   string   `json:"master_name,omitempty"`
+	ExpectedRole string   `json:"expected_role,omitempty"`
+	ProbeTimeout string   `json:"probe_timeout"`
+	CheckTimeout string   `json:"check_timeout"`
+	PollInterval string   `json:"poll_interval,omitempty"`
+}
+
+func debugConfigHandler(cfg *Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body := debugConfig{
+			Checks:       cfg.activeChecks(),
+			Topology:     cfg.Topology,
+			SentinelMode: cfg.SentinelMode,
+			ClusterMode:  cfg.ClusterMode,
+			MasterName:   cfg.MasterName,
+			ExpectedRole: cfg.ExpectedRole,
+			ProbeTimeout: cfg.ProbeTimeout.String(),
+			CheckTimeout: cfg.CheckTimeout.String(),
+		}
+		if cfg.PollInterval > 0 {
+			body.PollInterval = cfg.PollInterval.String()
+		}
+
+		writeJSON(w, r, http.StatusOK, body)
+	}
+}
+
+// healthCheckClientName is set on every connection the probes open
+const healthCheckClientName = "falkordb-healthcheck"
+
+type debugConnection struct {
+	ID          string `json:"id"`
+	Addr        string `json:"addr"`
+	AgeSeconds  int64  `json:"age_seconds"`
+	IdleSeconds int64  `json:"idle_seconds"`
+	LastCommand string `json:"last_command,omitempty"`
+}
+
+type debugConnections struct {
+	Count       int               `json:"count"`
+	Connections []debugConnection `json:"connections"`
+	Pool        debugPoolStats    `json:"pool"`
+}
+
+type debugPoolStats struct {
+	Hits       uint32 `json:"hits"`
+	Misses     uint32 `json:"misses"`
+	Timeouts   uint32 `json:"timeouts"`
+	TotalConns uint32 `json:"total_conns"`
+	IdleConns  uint32 `json:"idle_conns"`
+	StaleConns uint32 `json:"stale_conns"`
+}
+
+// debugConnectionsHandler lists the connections named healthCheckClientName
+// from CLIENT LIST, with the pool stats of our client, to check pooling and
+// spot leaks. Healthchecks of other pods probing this node show up too.
+func debugConnectionsHandler(w http.ResponseWriter, r *http.Request) {
+	probeCtx, cancel := probeContext(r)
+	defer cancel()
+
+	client := probesOf(probeCtx).client
+	list, err := client.ClientList(probeCtx).Result()
+	if err != nil {
+		writeRedisError(w, r, err)
+		return
+	}
+
+	stats := client.PoolStats()
+	body := debugConnections{Connections: []debugConnection{}, Pool: debugPoolStats{
+		Hits:       stats.Hits,
+		Misses:     stats.Misses,
+		Timeouts:   stats.Timeouts,
+		TotalConns: stats.TotalConns,
+		IdleConns:  stats.IdleConns,
+		StaleConns: stats.StaleConns,
+	}}
+	for _, line := range strings.Split(list, "\n") {
+		fields := map[string]string{}
+		for _, field := range strings.Fields(line) {
+			if key, value, ok := strings.Cut(field, "="); ok {
+				fields[key] = value
+			}
+		}
+		if fields["name"] != healthCheckClientName {
+			continue
+		}
+
+		age, _ := strconv.ParseInt(fields["age"], 10, 64)
+		idle, _ := strconv.ParseInt(fields["idle"], 10, 64)
+		body.Connections = append(body.Connections, debugConnection{
+			ID:          fields["id"],
+			Addr:        fields["addr"],
+			AgeSeconds:  age,
+			IdleSeconds: idle,
+			LastCommand: fields["cmd"],
+		})
+	}
+	body.Count = len(body.Connections)
+
+	writeJSON(w, r, http.StatusOK, body)
+}
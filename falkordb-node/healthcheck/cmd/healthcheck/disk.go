@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"falkordb.cloud/main/internal/redisinfo"
+)
+
+// errDiskUsageUnsupported is returned by diskUsage where statfs isn't available
+var errDiskUsageUnsupported = errors.New("disk usage is not supported on this platform")
+
+// checkDiskSpace fails a node whose DATA_DIR filesystem runs low, before
+// BGSAVE and AOF writes start failing. Free space is checked against
+// MIN_DISK_FREE_PERCENT and MIN_DISK_FREE_BYTES, and with
+// REQUIRE_DISK_FOR_BGSAVE against used_memory, about what a snapshot needs.
+// Disabled unless one of them is set.
+func checkDiskSpace(probeCtx context.Context, info *redisinfo.Info, cfg *Config) (string, string) {
+	if cfg.MinDiskFreePercent <= 0 && cfg.MinDiskFreeBytes <= 0 && !cfg.RequireDiskForBgsave {
+		return "", ""
+	}
+	// The data directory is on this pod, not on remote targets
+	if probeTarget(probeCtx) != "" {
+		return "", ""
+	}
+
+	free, total, err := diskUsage(cfg.DataDir)
+	if errors.Is(err, errDiskUsageUnsupported) {
+		return "", "skipped"
+	}
+	if err != nil {
+		return "DISK_STAT_FAILED", err.Error()
+	}
+
+	var pct int64
+	if total > 0 {
+		pct = int64(free * 100 / total)
+	}
+	detail := fmt.Sprintf("free=%d pct=%d", free, pct)
+	low := "LOW_DISK " + detail
+
+	switch {
+	case cfg.MinDiskFreePercent > 0 && pct < cfg.MinDiskFreePercent:
+		return low, fmt.Sprintf("%s min_pct=%d", detail, cfg.MinDiskFreePercent)
+	case cfg.MinDiskFreeBytes > 0 && free < uint64(cfg.MinDiskFreeBytes):
+		return low, fmt.Sprintf("%s min_bytes=%d", detail, cfg.MinDiskFreeBytes)
+	}
+
+	if cfg.RequireDiskForBgsave {
+		if used, err := info.Int("used_memory"); err == nil && free < uint64(used) {
+			return low, fmt.Sprintf("%s used_memory=%d", detail, used)
+		}
+	}
+	return "", detail
+}
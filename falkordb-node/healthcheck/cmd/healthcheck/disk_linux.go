@@ -0,0 +1,13 @@
+package main
+
+import "syscall"
+
+// diskUsage returns the bytes available to unprivileged users and the size
+// of the filesystem containing path
+func diskUsage(path string) (uint64, uint64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, 0, err
+	}
+	return stat.Bavail * uint64(stat.Bsize), stat.Blocks * uint64(stat.Bsize), nil
+}
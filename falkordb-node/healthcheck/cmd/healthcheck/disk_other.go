@@ -0,0 +1,7 @@
+//go:build !linux
+
+package main
+
+func diskUsage(string) (uint64, uint64, error) {
+	return 0, 0, errDiskUsageUnsupported
+}
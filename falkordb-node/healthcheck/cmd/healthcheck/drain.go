@@ -0,0 +1,60 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+)
+
+// drainEnabled reports whether draining is possible at all, through
+// HEALTH_ADMIN_TOKEN or DRAIN_FILE. The drain check is only reported then.
+func drainEnabled(cfg *Config) bool {
+	return cfg.AdminToken != "" || cfg.DrainFile != ""
+}
+
+// loadDrainState drains the node of p at startup when DRAIN_FILE exists, so
+// the state survives healthcheck restarts during long maintenance.
+func loadDrainState(cfg *Config, p *probes) {
+	if cfg.DrainFile == "" {
+		return
+	}
+
+	if _, err := os.Stat(cfg.DrainFile); err == nil {
+		p.draining.Store(true)
+		slog.Warn("node is draining", "drain_file", cfg.DrainFile)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		slog.Warn("error reading drain file", "drain_file", cfg.DrainFile, "error", err)
+	}
+}
+
+// drainHandler sets the drain state, persisting it to DRAIN_FILE when set.
+// A draining node fails readiness with DRAINING while liveness stays green,
+// so it can be taken out of rotation ahead of planned maintenance.
+func drainHandler(cfg *Config, drain bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := persistDrainState(cfg, drain); err != nil {
+			slog.Error("error persisting drain state", "request_id", requestID(r), "drain_file", cfg.DrainFile, "error", err)
+			writeError(w, r, http.StatusInternalServerError, "INTERNAL_ERROR", "")
+			return
+		}
+
+		probesOf(r.Context()).draining.Store(drain)
+		slog.Warn("drain state changed", "request_id", requestID(r), "draining", drain, "remote_addr", r.RemoteAddr)
+		w.Write([]byte("OK"))
+	}
+}
+
+func persistDrainState(cfg *Config, drain bool) error {
+	if cfg.DrainFile == "" {
+		return nil
+	}
+
+	if drain {
+		return os.WriteFile(cfg.DrainFile, nil, 0o644)
+	}
+	if err := os.Remove(cfg.DrainFile); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// e2eSentinelCheck records the master the sentinels resolved in the report.
+// Only this check writes E2EMaster.
+func e2eSentinelCheck(report *healthReport, cfg *Config) check {
+	return check{name: "e2e_sentinel", omitEmpty: true, run: func(ctx context.Context) (string, string, error) {
+		reason, detail, master := checkE2ESentinel(ctx, cfg)
+		report.E2EMaster = master
+		return reason, detail, nil
+	}}
+}
+
+// checkE2ESentinel checks the service the way its clients reach it: a
+// failover client resolves the master of MASTER_NAME through SENTINEL_ADDRS,
+// then PINGs it and, with E2E_GRAPH, runs a read-only query. A new client
+// is used every time so the discovery is exercised too. The reason tells the
+// stage that broke: E2E_SENTINEL_UNREACHABLE, E2E_MASTER_RESOLUTION_FAILED,
+// E2E_MASTER_UNREACHABLE, E2E_MASTER_AUTH_FAILED or E2E_COMMAND_FAILED. It
+// doesn't depend on the local node, any node or a dedicated deployment can
+// run it, and liveness never does.
+func checkE2ESentinel(probeCtx context.Context, cfg *Config) (string, string, string) {
+	if !cfg.E2ESentinelCheck || probeTarget(probeCtx) != "" {
+		return "", "", ""
+	}
+
+	var resolved struct {
+		mu   sync.Mutex
+		addr string
+	}
+	sentinels := map[string]bool{}
+	for _, addr := range cfg.SentinelAddrs {
+		sentinels[addr] = true
+	}
+	var dialer net.Dialer
+
+	user, password := probesOf(probeCtx).credentials.get()
+	client := redis.NewFailoverClient(&redis.FailoverOptions{
+		MasterName:       cfg.MasterName,
+		SentinelAddrs:    cfg.SentinelAddrs,
+		SentinelPassword: cfg.SentinelAuth,
+		ClientName:       healthCheckClientName,
+		// Sentinels are dialed through it too, the master is the other address
+		Dialer: func(dialCtx context.Context, network string, addr string) (net.Conn, error) {
+			if !sentinels[addr] {
+				resolved.mu.Lock()
+				resolved.addr = addr
+				resolved.mu.Unlock()
+			}
+			return dialer.DialContext(dialCtx, network, addr)
+		},
+		Protocol:              int(cfg.RedisProtocol),
+		Username:              user,
+		Password:              password,
+		MaxRetries:            -1,
+		DialTimeout:           cfg.SentinelTimeout,
+		ReadTimeout:           cfg.SentinelTimeout,
+		WriteTimeout:          cfg.SentinelTimeout,
+		ContextTimeoutEnabled: true,
+		PoolSize:              1,
+	})
+	defer client.Close()
+
+	err := client.Ping(probeCtx).Err()
+	resolved.mu.Lock()
+	master := resolved.addr
+	resolved.mu.Unlock()
+
+	if err == nil && cfg.E2EGraph != "" {
+		err = client.Do(probeCtx, "GRAPH.RO_QUERY", cfg.E2EGraph, "MATCH (n) RETURN n LIMIT 1").Err()
+		// A graph that doesn't exist yet still proves the query path
+		if err != nil && isRedisReply(err) && strings.Contains(err.Error(), "empty key") {
+			err = nil
+		}
+		if err != nil {
+			return "E2E_COMMAND_FAILED master=" + master, err.Error(), master
+		}
+	}
+	if err == nil {
+		return "", "master=" + master, master
+	}
+
+	switch {
+	case master == "":
+		// The failover client reports every sentinel failure alike
+		stage := resolutionStage(probeCtx, cfg)
+		return stage + " master_name=" + cfg.MasterName, err.Error(), ""
+	case isAuthError(err):
+		return "E2E_MASTER_AUTH_FAILED master=" + master, err.Error(), master
+	case isRedisReply(err):
+		return "E2E_COMMAND_FAILED master=" + master, err.Error(), master
+	}
+	return "E2E_MASTER_UNREACHABLE master=" + master, err.Error(), master
+}
+
+// resolutionStage tells a master no sentinel knows, some sentinel answering,
+// from sentinels that can't be reached or authenticated with
+func resolutionStage(probeCtx context.Context, cfg *Config) string {
+	for _, addr := range cfg.SentinelAddrs {
+		sentinel := sentinelClientFor(cfg, addr)
+		sentinelCtx, cancel := context.WithTimeout(probeCtx, cfg.SentinelTimeout)
+		err := sentinel.GetMasterAddrByName(sentinelCtx, cfg.MasterName).Err()
+		cancel()
+		sentinel.Close()
+
+		if errors.Is(err, redis.Nil) || (err != nil && isRedisReply(err) && !isAuthError(err)) {
+			return "E2E_MASTER_RESOLUTION_FAILED"
+		}
+	}
+	return "E2E_SENTINEL_UNREACHABLE"
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// checkExternalAddress dials the node at the address clients use rather than
+// localhost, to catch a broken Service, NetworkPolicy or announce address
+// while the node itself is healthy. The address is EXTERNAL_HOST and
+// EXTERNAL_PORT, each falling back to the announced one. Every probe opens a
+// new connection, authenticates and PINGs within EXTERNAL_CHECK_TIMEOUT_MS.
+// Pods can't always hairpin to their own Service, so a failure is only a
+// warning unless EXTERNAL_CHECK_MODE=fail, which fails readiness with
+// EXTERNAL_UNREACHABLE.
+func checkExternalAddress(probeCtx context.Context, cfg *Config) (string, string, error) {
+	// The external address is only known for the local node
+	if !cfg.CheckExternalAddress || probeTarget(probeCtx) != "" {
+		return "", "", nil
+	}
+
+	addr, err := externalAddr(probeCtx, cfg)
+	if err != nil {
+		return "", "", err
+	}
+
+	options := *probesOf(probeCtx).targets.base
+	options.Network = "tcp"
+	options.Addr = addr
+	options.PoolSize = 1
+	options.MinIdleConns = 0
+	options.DialTimeout = cfg.ExternalTimeout
+	options.ReadTimeout = cfg.ExternalTimeout
+	options.WriteTimeout = cfg.ExternalTimeout
+	if options.TLSConfig != nil {
+		host, _, _ := net.SplitHostPort(addr)
+		options.TLSConfig = options.TLSConfig.Clone()
+		options.TLSConfig.ServerName = host
+	}
+	client := redis.NewClient(&options)
+	defer client.Close()
+
+	externalCtx, cancel := context.WithTimeout(probeCtx, cfg.ExternalTimeout)
+	defer cancel()
+
+	if err := client.Ping(externalCtx).Err(); err != nil {
+		detail := "addr=" + addr + " " + err.Error()
+		if cfg.ExternalCheckMode == "fail" {
+			return "EXTERNAL_UNREACHABLE " + err.Error(), detail, nil
+		}
+		slog.Warn("node unreachable at its external address", "addr", addr, "error", err)
+		return "", "warning: EXTERNAL_UNREACHABLE " + detail, nil
+	}
+	return "", "addr=" + addr, nil
+}
+
+// externalAddr returns EXTERNAL_HOST:EXTERNAL_PORT, completed from the
+// announced address
+func externalAddr(probeCtx context.Context, cfg *Config) (string, error) {
+	host, port := cfg.ExternalHost, cfg.ExternalPort
+	if host == "" || port == "" {
+		announcedHost, announcedPort, err := nodeAnnouncedAddr(probeCtx, cfg)
+		if err != nil {
+			return "", err
+		}
+		if host == "" {
+			host = announcedHost
+		}
+		if port == "" {
+			port = announcedPort
+		}
+	}
+	return net.JoinHostPort(host, port), nil
+}
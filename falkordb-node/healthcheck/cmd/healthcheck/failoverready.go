@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+
+	"falkordb.cloud/main/internal/redisinfo"
+)
+
+// failoverReadiness is the body of /failover-ready
+type failoverReadiness struct {
+	Ready    bool     `json:"ready"`
+	Blocking []string `json:"blocking"`
+	// Candidates are the online replicas within FAILOVER_READY_MAX_LAG_BYTES
+	Candidates []string `json:"candidates"`
+}
+
+// failoverReadyHandler tells whether the master can be taken down right
+// now: 200 once a replica can take over and nothing would be lost or block
+// the failover, 503 with the blocking reasons otherwise. Replicas and
+// sentinels get a 400.
+func failoverReadyHandler(cfg *Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		probeCtx, cancel := probeContext(r)
+		defer cancel()
+
+		info, err := fetchInfo(withNoCache(probeCtx))
+		if err != nil {
+			writeRedisError(w, r, err)
+			return
+		}
+
+		role, _ := info.Role()
+		if isSentinel(info, cfg.SentinelMode) {
+			role = "sentinel"
+		}
+		if role != "master" {
+			writeError(w, r, http.StatusBadRequest, "NOT_A_MASTER", "failover readiness only applies to masters, role="+role)
+			return
+		}
+
+		readiness := evaluateFailoverReadiness(probeCtx, cfg, info)
+		code := http.StatusOK
+		if !readiness.Ready {
+			code = http.StatusServiceUnavailable
+		}
+		writeJSON(w, r, code, readiness)
+	}
+}
+
+// evaluateFailoverReadiness lists what keeps a planned failover from being
+// safe: no online replica close enough to take over, a BGSAVE or AOF rewrite
+// in progress, and with SENTINEL_HOST set a sentinel quorum that can't
+// authorize it.
+func evaluateFailoverReadiness(probeCtx context.Context, cfg *Config, info *redisinfo.Info) *failoverReadiness {
+	readiness := &failoverReadiness{Blocking: []string{}, Candidates: []string{}}
+
+	masterOffset, _ := info.Int("master_repl_offset")
+	for _, replica := range info.Replicas() {
+		lag := masterOffset - replica.Offset
+		if replica.State == "online" && lag <= cfg.FailoverMaxLagBytes {
+			readiness.Candidates = append(readiness.Candidates, net.JoinHostPort(replica.IP, strconv.FormatInt(replica.Port, 10)))
+		}
+	}
+	if len(readiness.Candidates) == 0 {
+		readiness.Blocking = append(readiness.Blocking, fmt.Sprintf("NO_CANDIDATE_REPLICA replicas=%d max_lag=%d", len(info.Replicas()), cfg.FailoverMaxLagBytes))
+	}
+
+	for _, field := range []string{"rdb_bgsave_in_progress", "aof_rewrite_in_progress"} {
+		if running, _ := info.Bool(field); running {
+			readiness.Blocking = append(readiness.Blocking, "PERSISTENCE_IN_PROGRESS "+field+"=1")
+		}
+	}
+
+	if sentinel := probesOf(probeCtx).sentinel; sentinel != nil {
+		sentinelCtx, cancel := context.WithTimeout(probeCtx, cfg.SentinelTimeout)
+		defer cancel()
+
+		if err := sentinel.CkQuorum(sentinelCtx, cfg.MasterName).Err(); err != nil {
+			readiness.Blocking = append(readiness.Blocking, "NO_QUORUM "+err.Error())
+		}
+	}
+
+	readiness.Ready = len(readiness.Blocking) == 0
+	return readiness
+}
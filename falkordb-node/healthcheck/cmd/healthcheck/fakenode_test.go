@@ -0,0 +1,305 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// status is a simple string reply, as opposed to a bulk string
+type status string
+
+// replyError is an error reply
+type replyError string
+
+// fakeNode is an in-memory Redis node the clients of the tests dial instead
+// of a real one. It speaks RESP2 and answers each command with the reply of
+// the longest command prefix registered, e.g. "CONFIG GET MAXMEMORY", INFO
+// with info, CONFIG GET with no matching parameter and anything else with an
+// unknown command error, like Redis.
+type fakeNode struct {
+	mu       sync.Mutex
+	info     string
+	replies  map[string]any
+	delays   map[string]time.Duration
+	password string
+	dialErr  error
+	calls    map[string]int
+}
+
+// masterInfo is the INFO reply of a healthy master with one replica
+const masterInfo = `# Server
+redis_version:7.2.4
+redis_mode:standalone
+uptime_in_seconds:100
+
+# Replication
+role:master
+connected_slaves:1
+slave0:ip=10.0.0.2,port=6379,state=online,offset=100,lag=0
+master_repl_offset:100
+
+# Persistence
+loading:0
+`
+
+func newFakeNode(info string) *fakeNode {
+	return &fakeNode{
+		info: info,
+		replies: map[string]any{
+			"PING":        status("PONG"),
+			"MODULE LIST": []any{[]any{"name", "graph", "ver", int64(41411), "path", "/falkordb.so", "args", []any{}}},
+		},
+		delays: map[string]time.Duration{},
+		calls:  map[string]int{},
+	}
+}
+
+// reply sets the reply to the commands starting with prefix. A func(args
+// []string) any reply is called with the arguments of each command.
+func (n *fakeNode) reply(prefix string, reply any) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.replies[strings.ToUpper(prefix)] = reply
+}
+
+// delay holds the replies to the commands starting with prefix
+func (n *fakeNode) delay(prefix string, d time.Duration) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.delays[strings.ToUpper(prefix)] = d
+}
+
+func (n *fakeNode) setInfo(info string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.info = info
+}
+
+// called returns how many commands named name the node answered
+func (n *fakeNode) called(name string) int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.calls[strings.ToUpper(name)]
+}
+
+func (n *fakeNode) dial(ctx context.Context, network string, addr string) (net.Conn, error) {
+	n.mu.Lock()
+	err := n.dialErr
+	n.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	server, client := net.Pipe()
+	go n.serve(server)
+	return client, nil
+}
+
+func (n *fakeNode) serve(conn net.Conn) {
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+
+		reply, delay := n.answer(args)
+		time.Sleep(delay)
+		if _, err := conn.Write(encodeReply(nil, reply)); err != nil {
+			return
+		}
+	}
+}
+
+func (n *fakeNode) answer(args []string) (any, time.Duration) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	name := strings.ToUpper(args[0])
+	n.calls[name]++
+	command := strings.ToUpper(strings.Join(args, " "))
+
+	var delay time.Duration
+	if prefix := longestPrefix(n.delays, command); prefix != "" {
+		delay = n.delays[prefix]
+	}
+
+	if prefix := longestPrefix(n.replies, command); prefix != "" {
+		reply := n.replies[prefix]
+		if fn, ok := reply.(func(args []string) any); ok {
+			reply = fn(args)
+		}
+		return reply, delay
+	}
+
+	switch name {
+	case "HELLO":
+		return replyError("ERR unknown command 'HELLO'"), delay
+	case "AUTH":
+		if n.password != "" && args[len(args)-1] != n.password {
+			return replyError("WRONGPASS invalid username-password pair or user is disabled."), delay
+		}
+		return status("OK"), delay
+	case "CLIENT", "SELECT":
+		return status("OK"), delay
+	case "INFO":
+		return strings.ReplaceAll(n.info, "\n", "\r\n"), delay
+	case "CONFIG":
+		return []any{}, delay
+	}
+	return replyError(fmt.Sprintf("ERR unknown command '%s'", args[0])), delay
+}
+
+func longestPrefix[V any](m map[string]V, command string) string {
+	prefixes := make([]string, 0, len(m))
+	for prefix := range m {
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Slice(prefixes, func(i, j int) bool { return len(prefixes[i]) > len(prefixes[j]) })
+
+	for _, prefix := range prefixes {
+		if command == prefix || strings.HasPrefix(command, prefix+" ") {
+			return prefix
+		}
+	}
+	return ""
+}
+
+// readCommand reads a command sent by go-redis, an array of bulk strings
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(line, "*") {
+		return nil, fmt.Errorf("unexpected command line %q", line)
+	}
+	count, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+	if err != nil {
+		return nil, err
+	}
+
+	args := make([]string, count)
+	for i := range args {
+		if line, err = r.ReadString('\n'); err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+		if err != nil {
+			return nil, err
+		}
+		arg := make([]byte, size+2)
+		if _, err := io.ReadFull(r, arg); err != nil {
+			return nil, err
+		}
+		args[i] = string(arg[:size])
+	}
+	return args, nil
+}
+
+func encodeReply(b []byte, reply any) []byte {
+	switch v := reply.(type) {
+	case nil:
+		return append(b, "$-1\r\n"...)
+	case status:
+		return append(b, "+"+string(v)+"\r\n"...)
+	case replyError:
+		return append(b, "-"+string(v)+"\r\n"...)
+	case int:
+		return append(b, ":"+strconv.Itoa(v)+"\r\n"...)
+	case int64:
+		return append(b, ":"+strconv.FormatInt(v, 10)+"\r\n"...)
+	case string:
+		return append(b, "$"+strconv.Itoa(len(v))+"\r\n"+v+"\r\n"...)
+	case []string:
+		b = append(b, "*"+strconv.Itoa(len(v))+"\r\n"...)
+		for _, item := range v {
+			b = encodeReply(b, item)
+		}
+		return b
+	case []any:
+		b = append(b, "*"+strconv.Itoa(len(v))+"\r\n"...)
+		for _, item := range v {
+			b = encodeReply(b, item)
+		}
+		return b
+	}
+	panic(fmt.Sprintf("unsupported reply %T", reply))
+}
+
+// testConfig loads the configuration from the environment with env set on
+// top of a reachable node port and no retries
+func testConfig(t *testing.T, env map[string]string) *Config {
+	t.Helper()
+
+	t.Setenv("NODE_PORT", "6379")
+	t.Setenv("HEALTH_CHECK_RETRIES", "0")
+	for key, value := range env {
+		t.Setenv(key, value)
+	}
+	cfg, err := loadConfig(nil)
+	if err != nil {
+		t.Fatalf("invalid test configuration: %v", err)
+	}
+	return cfg
+}
+
+// newTestProbes returns the probes of cfg talking to node through a client
+// configured like the real one
+func newTestProbes(t *testing.T, cfg *Config, node *fakeNode) *probes {
+	t.Helper()
+
+	credentials := newNodeCredentials(cfg)
+	options, err := clientOptions(cfg, credentials)
+	if err != nil {
+		t.Fatal(err)
+	}
+	options.Dialer = node.dial
+	client := redis.NewClient(options)
+	client.AddHook(timingHook{})
+
+	p := newProbes(cfg, credentials, client)
+	t.Cleanup(p.close)
+	return p
+}
+
+// eventually fails the test unless condition holds within a second
+func eventually(t *testing.T, what string, condition func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// serve answers a request of method to path with the handler of cfg and p
+func serve(t *testing.T, cfg *Config, p *probes, method string, path string, header http.Header) *httptest.ResponseRecorder {
+	t.Helper()
+
+	r := httptest.NewRequest(method, path, nil)
+	for key, values := range header {
+		r.Header[key] = values
+	}
+	w := httptest.NewRecorder()
+	newHealthCheckHandler(cfg, p).ServeHTTP(w, r)
+	return w
+}
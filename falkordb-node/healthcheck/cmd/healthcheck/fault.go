@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+type faultMode int
+
+const (
+	faultNone faultMode = iota
+	faultUnhealthy
+	faultTimeout
+)
+
+const (
+	defaultFaultDuration = 30 * time.Second
+	defaultFaultDelay    = 5 * time.Second
+	// Faults always expire, a forgotten one can't keep a node out of rotation
+	maxFaultDuration = time.Hour
+)
+
+type fault struct {
+	mode  faultMode
+	delay time.Duration
+	until time.Time
+}
+
+// injectedFault is the fault set through /fault/*, only reachable with
+// ENABLE_FAULT_INJECTION so e2e tests can fail a node without killing Redis
+type injectedFault struct {
+	mu    sync.Mutex
+	fault fault
+}
+
+// active returns the injected fault, or faultNone once it expired
+func (f *injectedFault) active() fault {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.fault.mode != faultNone && time.Now().After(f.fault.until) {
+		slog.Info("injected fault expired")
+		f.fault = fault{}
+	}
+	return f.fault
+}
+
+func (f *injectedFault) set(injected fault) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.fault = injected
+}
+
+// injectFault applies the active fault to a readiness report. An unhealthy
+// fault fails it with FAULT_INJECTED, a timeout fault holds the probe for the
+// delay before the real checks run, so a genuine failure still shows. It
+// returns false when the report is final.
+func injectFault(probeCtx context.Context, report *healthReport) bool {
+	active := probesOf(probeCtx).fault.active()
+	switch active.mode {
+	case faultUnhealthy:
+		report.FaultInjected = true
+		report.fail(http.StatusServiceUnavailable, "FAULT_INJECTED", "fault", "unhealthy")
+		return false
+	case faultTimeout:
+		report.FaultInjected = true
+		select {
+		case <-time.After(active.delay):
+		case <-probeCtx.Done():
+			report.failErr(probeCtx, "fault", probeCtx.Err())
+			return false
+		}
+		report.pass("fault", fmt.Sprintf("delay_ms=%d", active.delay.Milliseconds()))
+	}
+	return true
+}
+
+// faultHandler sets or clears the injected fault. ?duration= bounds it, up
+// to maxFaultDuration, and ?delay= sets how long timeout faults hold probes.
+func faultHandler(cfg *Config, mode faultMode) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		injected := fault{mode: mode}
+		if mode != faultNone {
+			duration, err := faultDuration(r, "duration", defaultFaultDuration)
+			if err == nil && duration > maxFaultDuration {
+				err = fmt.Errorf("duration must not exceed %s", maxFaultDuration)
+			}
+			if err == nil && mode == faultTimeout {
+				injected.delay, err = faultDuration(r, "delay", defaultFaultDelay)
+			}
+			if err != nil {
+				writeError(w, r, http.StatusBadRequest, "INVALID_FAULT", err.Error())
+				return
+			}
+			injected.until = time.Now().Add(duration)
+		}
+
+		probesOf(r.Context()).fault.set(injected)
+
+		slog.Warn("injected fault changed", "request_id", requestID(r), "fault", r.URL.Path, "until", injected.until, "delay", injected.delay, "remote_addr", r.RemoteAddr)
+		w.Write([]byte("OK"))
+	}
+}
+
+func faultDuration(r *http.Request, name string, fallback time.Duration) (time.Duration, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return fallback, nil
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration <= 0 {
+		return 0, fmt.Errorf("%s must be a positive duration like 30s, got %q", name, value)
+	}
+	return duration, nil
+}
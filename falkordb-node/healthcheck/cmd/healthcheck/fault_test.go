@@ -0,0 +1,153 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// faultReport is the JSON readiness report of p
+func faultReport(t *testing.T, cfg *Config, p *probes) (int, healthReport) {
+	t.Helper()
+
+	w := serve(t, cfg, p, http.MethodGet, "/readyz", http.Header{"Accept": {"application/json"}})
+	var report healthReport
+	if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil {
+		t.Fatalf("invalid report %q: %v", w.Body.String(), err)
+	}
+	return w.Code, report
+}
+
+func TestFaultEndpointsOff(t *testing.T) {
+	cfg := testConfig(t, map[string]string{"HEALTH_ADMIN_TOKEN": "secret"})
+	p := newTestProbes(t, cfg, newFakeNode(masterInfo))
+	admin := http.Header{"Authorization": {"Bearer secret"}}
+
+	for _, path := range []string{"/fault/unhealthy", "/fault/timeout", "/fault/clear"} {
+		if w := serve(t, cfg, p, http.MethodPost, path, admin); w.Code != http.StatusNotFound {
+			t.Errorf("POST %s = %d %q, want 404 without ENABLE_FAULT_INJECTION", path, w.Code, w.Body.String())
+		}
+	}
+	if code, report := faultReport(t, cfg, p); code != http.StatusOK || report.FaultInjected {
+		t.Errorf("GET /readyz = %d %+v, want 200 without a fault", code, report)
+	}
+}
+
+func TestFaultUnhealthy(t *testing.T) {
+	cfg := testConfig(t, map[string]string{"ENABLE_FAULT_INJECTION": "true", "HEALTH_ADMIN_TOKEN": "secret"})
+	node := newFakeNode(masterInfo)
+	p := newTestProbes(t, cfg, node)
+	admin := http.Header{"Authorization": {"Bearer secret"}}
+
+	if w := serve(t, cfg, p, http.MethodPost, "/fault/unhealthy", nil); w.Code != http.StatusUnauthorized {
+		t.Errorf("POST /fault/unhealthy without the token = %d, want 401", w.Code)
+	}
+	if w := serve(t, cfg, p, http.MethodPost, "/fault/unhealthy?duration=30s", admin); w.Code != http.StatusOK {
+		t.Fatalf("POST /fault/unhealthy = %d %q", w.Code, w.Body.String())
+	}
+
+	if code, report := faultReport(t, cfg, p); code != http.StatusServiceUnavailable || !report.FaultInjected {
+		t.Errorf("GET /readyz = %d %+v, want 503 with the fault", code, report)
+	}
+	if w := serve(t, cfg, p, http.MethodGet, "/readyz", nil); !strings.HasPrefix(w.Body.String(), "FAULT_INJECTED") {
+		t.Errorf("GET /readyz = %d %q, want FAULT_INJECTED", w.Code, w.Body.String())
+	}
+	if node.called("INFO") != 0 {
+		t.Errorf("INFO sent %d times under an unhealthy fault, want none", node.called("INFO"))
+	}
+	if w := serve(t, cfg, p, http.MethodGet, "/livez", nil); w.Code != http.StatusOK {
+		t.Errorf("GET /livez = %d %q, the fault only fails readiness", w.Code, w.Body.String())
+	}
+
+	if w := serve(t, cfg, p, http.MethodPost, "/fault/clear", admin); w.Code != http.StatusOK {
+		t.Fatalf("POST /fault/clear = %d %q", w.Code, w.Body.String())
+	}
+	if code, report := faultReport(t, cfg, p); code != http.StatusOK || report.FaultInjected {
+		t.Errorf("GET /readyz after the clear = %d %+v, want 200", code, report)
+	}
+}
+
+func TestFaultExpiry(t *testing.T) {
+	cfg := testConfig(t, map[string]string{"ENABLE_FAULT_INJECTION": "true", "HEALTH_ADMIN_TOKEN": "secret"})
+	p := newTestProbes(t, cfg, newFakeNode(masterInfo))
+	admin := http.Header{"Authorization": {"Bearer secret"}}
+
+	if w := serve(t, cfg, p, http.MethodPost, "/fault/unhealthy?duration=1m", admin); w.Code != http.StatusOK {
+		t.Fatalf("POST /fault/unhealthy = %d %q", w.Code, w.Body.String())
+	}
+	p.fault.mu.Lock()
+	until := p.fault.fault.until
+	p.fault.fault.until = time.Now().Add(-time.Second)
+	p.fault.mu.Unlock()
+	if left := time.Until(until); left < 50*time.Second || left > time.Minute {
+		t.Errorf("fault expires in %s, want the requested minute", left)
+	}
+
+	if code, report := faultReport(t, cfg, p); code != http.StatusOK || report.FaultInjected {
+		t.Errorf("GET /readyz after the expiry = %d %+v, want 200", code, report)
+	}
+	if mode := p.fault.active().mode; mode != faultNone {
+		t.Errorf("fault = %d after the expiry, want it cleared", mode)
+	}
+}
+
+func TestFaultTimeout(t *testing.T) {
+	cfg := testConfig(t, map[string]string{"ENABLE_FAULT_INJECTION": "true", "HEALTH_ADMIN_TOKEN": "secret", "HEALTH_CHECK_TIMEOUT_MS": "500"})
+	node := newFakeNode(masterInfo)
+	p := newTestProbes(t, cfg, node)
+	admin := http.Header{"Authorization": {"Bearer secret"}}
+
+	if w := serve(t, cfg, p, http.MethodPost, "/fault/timeout?delay=50ms", admin); w.Code != http.StatusOK {
+		t.Fatalf("POST /fault/timeout = %d %q", w.Code, w.Body.String())
+	}
+	start := time.Now()
+	code, report := faultReport(t, cfg, p)
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("GET /readyz answered in %s, want it held for the delay", elapsed)
+	}
+	if code != http.StatusOK || !report.FaultInjected {
+		t.Errorf("GET /readyz = %d %+v, want 200 after the delay", code, report)
+	}
+	if i := slices.IndexFunc(report.Checks, func(c checkResult) bool { return c.Name == "fault" }); i < 0 || report.Checks[i].Detail != "delay_ms=50" {
+		t.Errorf("checks = %+v, want the fault delay", report.Checks)
+	}
+
+	// The real checks still run after the delay, a genuine failure shows
+	node.setInfo(strings.Replace(masterInfo, "loading:0", "loading:1", 1))
+	if code, report := faultReport(t, cfg, p); code != http.StatusServiceUnavailable || !report.FaultInjected {
+		t.Errorf("GET /readyz while loading = %d %+v, want 503 with the fault", code, report)
+	}
+	if w := serve(t, cfg, p, http.MethodGet, "/readyz", nil); !strings.HasPrefix(w.Body.String(), "LOADING") {
+		t.Errorf("GET /readyz while loading = %d %q, want LOADING", w.Code, w.Body.String())
+	}
+
+	// A delay past the probe timeout times the probe out
+	if w := serve(t, cfg, p, http.MethodPost, "/fault/timeout?delay=5s", admin); w.Code != http.StatusOK {
+		t.Fatalf("POST /fault/timeout = %d %q", w.Code, w.Body.String())
+	}
+	if w := serve(t, cfg, p, http.MethodGet, "/readyz", nil); w.Code == http.StatusOK || !strings.HasPrefix(w.Body.String(), "TIMEOUT") {
+		t.Errorf("GET /readyz = %d %q, want a TIMEOUT", w.Code, w.Body.String())
+	}
+}
+
+func TestFaultInvalid(t *testing.T) {
+	cfg := testConfig(t, map[string]string{"ENABLE_FAULT_INJECTION": "true", "HEALTH_ADMIN_TOKEN": "secret"})
+	p := newTestProbes(t, cfg, newFakeNode(masterInfo))
+	admin := http.Header{"Authorization": {"Bearer secret"}}
+
+	for _, path := range []string{"/fault/unhealthy?duration=2h", "/fault/unhealthy?duration=-1s", "/fault/timeout?delay=soon"} {
+		w := serve(t, cfg, p, http.MethodPost, path, admin)
+		if w.Code != http.StatusBadRequest || !strings.HasPrefix(w.Body.String(), "INVALID_FAULT") {
+			t.Errorf("POST %s = %d %q, want 400 INVALID_FAULT", path, w.Code, w.Body.String())
+		}
+	}
+	if w := serve(t, cfg, p, http.MethodGet, "/fault/unhealthy", admin); w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET /fault/unhealthy = %d, want 405", w.Code)
+	}
+	if mode := p.fault.active().mode; mode != faultNone {
+		t.Errorf("fault = %d after invalid requests, want none", mode)
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+)
+
+// healthCheckGraph is the key queried by the deep check. It is deleted right
+// after the query on masters so it never shows up in GRAPH.LIST.
+const healthCheckGraph = "__healthcheck__"
+
+// checkGraphQuery proves the graph engine can execute a query. It is only
+// run on the readiness path, never on liveness, and is off by default.
+// Masters run GRAPH.QUERY while replicas, being read-only, use GRAPH.RO_QUERY.
+func checkGraphQuery(probeCtx context.Context, role string) string {
+	queryCtx, cancel := context.WithTimeout(probeCtx, probesOf(probeCtx).deepCheckTimeout)
+	defer cancel()
+
+	command := "GRAPH.QUERY"
+	if role != "master" {
+		command = "GRAPH.RO_QUERY"
+	}
+
+	err := nodeClient(queryCtx).Do(queryCtx, command, healthCheckGraph, "RETURN 1").Err()
+
+	// The key only exists for a moment on masters, so replicas querying it
+	// get an empty key error which still proves the engine answered
+	if err != nil && role != "master" && strings.Contains(err.Error(), "empty key") {
+		err = nil
+	}
+
+	if err != nil {
+		return fmt.Sprintf("GRAPH_QUERY_FAILED: %s", err)
+	}
+
+	if role == "master" {
+		if err := nodeClient(queryCtx).Do(queryCtx, "GRAPH.DELETE", healthCheckGraph).Err(); err != nil {
+			return fmt.Sprintf("GRAPH_QUERY_FAILED: %s", err)
+		}
+	}
+
+	return ""
+}
+
+// readProbeResult is the last outcome of the read probe on the local node,
+// reused for READ_PROBE_INTERVAL_MS so frequent probes don't add query load
+var readProbeResult = struct {
+	mu     sync.Mutex
+	at     time.Time
+	reason string
+	detail string
+}{}
+
+// checkReadProbe proves the node can serve reads by running GRAPH.RO_QUERY
+// against READ_PROBE_GRAPH, on replicas and, with READ_PROBE_MASTERS, on
+// masters. A graph that doesn't exist yet only warns. The result is reused
+// for READ_PROBE_INTERVAL_MS, errors reaching the node are not.
+func checkReadProbe(probeCtx context.Context, cfg *Config, role string) (string, string, error) {
+	if cfg.ReadProbeGraph == "" || (role == "master" && !cfg.ReadProbeMasters) {
+		return "", "", nil
+	}
+
+	// The cached result describes the local node only
+	local := probeTarget(probeCtx) == ""
+	if local {
+		readProbeResult.mu.Lock()
+		defer readProbeResult.mu.Unlock()
+
+		if !readProbeResult.at.IsZero() && time.Since(readProbeResult.at) < cfg.ReadProbeInterval {
+			return readProbeResult.reason, readProbeResult.detail, nil
+		}
+	}
+
+	queryCtx, cancel := context.WithTimeout(probeCtx, cfg.ReadProbeTimeout)
+	defer cancel()
+
+	start := time.Now()
+	err := nodeClient(queryCtx).Do(queryCtx, "GRAPH.RO_QUERY", cfg.ReadProbeGraph, "MATCH (n) RETURN n LIMIT 1").Err()
+	elapsed := time.Since(start)
+
+	var reason, detail string
+	switch {
+	case err != nil && isRedisReply(err) && strings.Contains(err.Error(), "empty key"):
+		slog.Warn("read probe graph doesn't exist yet", "graph", cfg.ReadProbeGraph)
+		detail = fmt.Sprintf("warning: graph %s doesn't exist", cfg.ReadProbeGraph)
+	case err != nil && (isRedisReply(err) || isTimeout(err)):
+		reason = fmt.Sprintf("READ_PROBE_FAILED: %s", err)
+		detail = err.Error()
+	case err != nil:
+		return "", "", err
+	default:
+		detail = fmt.Sprintf("graph=%s latency_ms=%.2f", cfg.ReadProbeGraph, float64(elapsed.Microseconds())/1000)
+	}
+
+	if local {
+		readProbeResult.at, readProbeResult.reason, readProbeResult.detail = time.Now(), reason, detail
+	}
+	return reason, detail, nil
+}
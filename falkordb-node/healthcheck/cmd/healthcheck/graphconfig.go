@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// graphConfigKeys are the GRAPH.CONFIG parameters EXPECTED_GRAPH_CONFIG may
+// name, so a typo fails startup instead of never matching.
+var graphConfigKeys = []string{
+	"ASYNC_DELETE",
+	"BOLT_PORT",
+	"CACHE_SIZE",
+	"CMD_INFO",
+	"DELAY_INDEXING",
+	"DELTA_MAX_PENDING_CHANGES",
+	"EFFECTS_THRESHOLD",
+	"IMPORT_FOLDER",
+	"MAX_INFO_QUERIES",
+	"MAX_QUEUED_QUERIES",
+	"NODE_CREATION_BUFFER",
+	"OMP_THREAD_COUNT",
+	"QUERY_MEM_CAPACITY",
+	"RESULTSET_SIZE",
+	"THREAD_COUNT",
+	"TIMEOUT",
+	"TIMEOUT_DEFAULT",
+	"TIMEOUT_MAX",
+	"VKEY_MAX_ENTITY_COUNT",
+}
+
+// checkGraphConfig fails readiness when GRAPH.CONFIG values differ from
+// EXPECTED_GRAPH_CONFIG, e.g. after an image silently reset THREAD_COUNT.
+// With GRAPH_CONFIG_MISMATCH_WARN_ONLY a mismatch is only logged.
+func checkGraphConfig(probeCtx context.Context, cfg *Config) (string, string, error) {
+	if len(cfg.ExpectedGraphConfig) == 0 {
+		return "", "", nil
+	}
+
+	reply, err := nodeClient(probeCtx).Do(probeCtx, "GRAPH.CONFIG", "GET", "*").Result()
+	if err != nil {
+		return "", "", err
+	}
+
+	// A list of name/value pairs
+	actual := map[string]string{}
+	for _, entry := range replyEntries(reply) {
+		for key, value := range entry {
+			actual[strings.ToUpper(key)] = value
+		}
+	}
+
+	keys := make([]string, 0, len(cfg.ExpectedGraphConfig))
+	for key := range cfg.ExpectedGraphConfig {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var mismatches []string
+	for _, key := range keys {
+		want := cfg.ExpectedGraphConfig[key]
+		have, ok := actual[key]
+		if !ok {
+			have = "<unset>"
+		}
+		if !ok || !graphConfigEqual(want, have) {
+			mismatches = append(mismatches, fmt.Sprintf("%s want=%s have=%s", key, want, have))
+		}
+	}
+
+	if len(mismatches) == 0 {
+		return "", fmt.Sprintf("matched=%d", len(keys)), nil
+	}
+
+	detail := strings.Join(mismatches, ", ")
+	if cfg.GraphConfigWarnOnly {
+		slog.Warn("graph configuration doesn't match EXPECTED_GRAPH_CONFIG", "detail", detail)
+		return "", detail + " (ignored)", nil
+	}
+	return "GRAPH_CONFIG_MISMATCH " + detail, detail, nil
+}
+
+// graphConfigEqual compares numbers by value, since replies may format them
+// differently than the expectation, and anything else case-insensitively.
+func graphConfigEqual(want string, have string) bool {
+	wantNum, errWant := strconv.ParseFloat(want, 64)
+	haveNum, errHave := strconv.ParseFloat(have, 64)
+	if errWant == nil && errHave == nil {
+		return wantNum == haveNum
+	}
+	return strings.EqualFold(want, have)
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"falkordb.cloud/main/internal/redisinfo"
+	"falkordb.cloud/main/internal/server"
+	"golang.org/x/sync/errgroup"
+)
+
+type graphEntry struct {
+	Name   string         `json:"name"`
+	Memory map[string]any `json:"memory,omitempty"`
+	Error  string         `json:"error,omitempty"`
+}
+
+type graphInventory struct {
+	FetchedAt time.Time    `json:"fetched_at"`
+	Count     int          `json:"count"`
+	Graphs    []graphEntry `json:"graphs"`
+	server.Truncation
+}
+
+// graphInventoryCache keeps the last inventory, with and without memory
+// usage, for GRAPH_INVENTORY_CACHE_MS so repeated calls don't list the node
+// every time.
+type graphInventoryCache struct {
+	mu          sync.Mutex
+	ttl         time.Duration
+	inventories map[bool]*graphInventory
+}
+
+func newGraphInventoryCache(ttl time.Duration) *graphInventoryCache {
+	return &graphInventoryCache{ttl: ttl, inventories: map[bool]*graphInventory{}}
+}
+
+// graphsHandler lists the graphs on the node by name, with their
+// GRAPH.MEMORY USAGE when ?memory=1 is passed. Past MAX_LIST_ITEMS graphs or
+// ?limit=, the rest is paged with ?cursor=.
+func graphsHandler(cfg *Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		page, ok := parseListPage(w, r)
+		if !ok {
+			return
+		}
+
+		probeCtx, cancel := probeContext(r)
+		defer cancel()
+
+		inventory, err := cachedGraphInventory(probeCtx, r.URL.Query().Get("memory") == "1")
+		if err != nil {
+			writeRedisError(w, r, err)
+			return
+		}
+
+		// The cached inventory is shared, the page is a copy
+		paged := *inventory
+		paged.Graphs, paged.Truncation = server.PageOf(inventory.Graphs, func(entry graphEntry) string { return entry.Name }, page)
+		writeJSON(w, r, http.StatusOK, &paged)
+	}
+}
+
+func cachedGraphInventory(probeCtx context.Context, withMemory bool) (*graphInventory, error) {
+	cache := probesOf(probeCtx).graphs
+	cache.mu.Lock()
+	cached := cache.inventories[withMemory]
+	cache.mu.Unlock()
+
+	if cached != nil && !noCache(probeCtx) && time.Since(cached.FetchedAt) <= cache.ttl {
+		return cached, nil
+	}
+
+	inventory, err := fetchGraphInventory(probeCtx, withMemory)
+	if err != nil {
+		return nil, err
+	}
+
+	cache.mu.Lock()
+	cache.inventories[withMemory] = inventory
+	cache.mu.Unlock()
+	return inventory, nil
+}
+
+// fetchGraphInventory runs GRAPH.LIST and, when asked, GRAPH.MEMORY USAGE
+// for every graph, at most maxConcurrentChecks at a time. A graph whose usage
+// can't be read carries its error instead of failing the inventory.
+func fetchGraphInventory(probeCtx context.Context, withMemory bool) (*graphInventory, error) {
+	fetchedAt := time.Now()
+	names, err := nodeClient(probeCtx).Do(probeCtx, "GRAPH.LIST").StringSlice()
+	if err != nil {
+		return nil, err
+	}
+
+	slices.Sort(names)
+	graphs := make([]graphEntry, 0, len(names))
+	for _, name := range names {
+		// Only exists for a moment while the deep check runs
+		if name != healthCheckGraph {
+			graphs = append(graphs, graphEntry{Name: name})
+		}
+	}
+
+	if withMemory {
+		group := errgroup.Group{}
+		group.SetLimit(maxConcurrentChecks)
+		for i := range graphs {
+			entry := &graphs[i]
+			group.Go(func() error {
+				memory, err := graphMemoryUsage(probeCtx, entry.Name)
+				if err != nil {
+					entry.Error = err.Error()
+				}
+				entry.Memory = memory
+				return nil
+			})
+		}
+		group.Wait()
+	}
+
+	return &graphInventory{FetchedAt: fetchedAt, Count: len(graphs), Graphs: graphs}, nil
+}
+
+// graphMemoryUsage returns the fields of GRAPH.MEMORY USAGE, a flat list of
+// names and values over RESP2.
+func graphMemoryUsage(probeCtx context.Context, name string) (map[string]any, error) {
+	reply, err := nodeClient(probeCtx).Do(probeCtx, "GRAPH.MEMORY", "USAGE", name).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	switch reply := reply.(type) {
+	case map[any]any:
+		memory := map[string]any{}
+		for key, value := range reply {
+			memory[fmt.Sprint(key)] = value
+		}
+		return memory, nil
+	case []any:
+		memory := map[string]any{}
+		for i := 0; i+1 < len(reply); i += 2 {
+			memory[fmt.Sprint(reply[i])] = reply[i+1]
+		}
+		return memory, nil
+	}
+	return nil, fmt.Errorf("unexpected GRAPH.MEMORY USAGE reply %v", reply)
+}
+
+// checkExpectedGraphs fails a node missing any of EXPECTED_GRAPHS, e.g. system
+// graphs a restore dropped. Entries are glob patterns, matched against
+// GRAPH.LIST, so tenant graphs can be expected as tenant_*. It's skipped
+// while the node loads its dataset or a replica syncs, when graphs are
+// still missing.
+func checkExpectedGraphs(probeCtx context.Context, info *redisinfo.Info, expected []string) (string, string, error) {
+	if len(expected) == 0 {
+		return "", "", nil
+	}
+	if loading, _ := info.Loading(); loading {
+		return "", "skipped, loading", nil
+	}
+	if syncing, _ := info.MasterSyncInProgress(); syncing {
+		return "", "skipped, sync in progress", nil
+	}
+
+	names, err := nodeClient(probeCtx).Do(probeCtx, "GRAPH.LIST").StringSlice()
+	if err != nil {
+		return "", "", err
+	}
+
+	var missing []string
+	for _, pattern := range expected {
+		if !slices.ContainsFunc(names, func(name string) bool {
+			matched, _ := path.Match(pattern, name)
+			return matched
+		}) {
+			missing = append(missing, pattern)
+		}
+	}
+
+	if len(missing) > 0 {
+		detail := "missing=" + strings.Join(missing, ",")
+		return "GRAPHS_MISSING " + detail, detail, nil
+	}
+	return "", fmt.Sprintf("expected=%d graphs=%d", len(expected), len(names)), nil
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// grpcReadinessServices are the grpc.health.v1 service names following the
+// readiness broadcaster. The empty name is the overall server health.
+var grpcReadinessServices = []string{"", "readiness"}
+
+// grpcServices maps the other service names to the evaluation they expose
+var grpcServices = map[string]func(context.Context, *Config) *healthReport{
+	"liveness": evaluateLiveness,
+	"startup":  evaluateStartup,
+}
+
+// startGRPCHealthServer serves grpc.health.v1.Health on GRPC_HEALTH_PORT when
+// set. Readiness comes from the broadcaster shared with the streams and the
+// other services are refreshed by a background poller, so watchers never
+// trigger checks themselves. It returns a function stopping the server.
+func startGRPCHealthServer(serverCtx context.Context, cfg *Config, p *probes) (func(), error) {
+	port := cfg.GRPCPort
+	if port == "" {
+		return func() {}, nil
+	}
+
+	listener, err := net.Listen("tcp", ":"+port)
+	if err != nil {
+		return nil, fmt.Errorf("error listening on GRPC_HEALTH_PORT: %w", err)
+	}
+
+	healthServer := health.NewServer()
+	server := grpc.NewServer()
+	healthpb.RegisterHealthServer(server, healthServer)
+
+	pollCtx, cancel := context.WithCancel(withProbes(serverCtx, p))
+	go pollGRPCHealth(pollCtx, cfg, healthServer)
+
+	go func() {
+		slog.Info("starting grpc health server", "port", port)
+		if err := server.Serve(listener); err != nil {
+			slog.Error("grpc health server stopped", "error", err)
+		}
+	}()
+
+	return func() {
+		cancel()
+		healthServer.Shutdown()
+		server.GracefulStop()
+	}, nil
+}
+
+func pollGRPCHealth(pollCtx context.Context, cfg *Config, healthServer *health.Server) {
+	// Readiness is unknown until the first report
+	for _, service := range grpcReadinessServices {
+		healthServer.SetServingStatus(service, healthpb.HealthCheckResponse_NOT_SERVING)
+	}
+
+	streams := probesOf(pollCtx).streams
+	readiness, ok := streams.subscribe(pollCtx, cfg, cfg.GRPCPollInterval)
+	if ok {
+		defer streams.unsubscribe(readiness)
+	}
+
+	refresh := func() {
+		for service, evaluate := range grpcServices {
+			probeCtx, cancel := context.WithTimeout(withConfiguredRole(pollCtx, cfg), probesOf(pollCtx).probeTimeout)
+			healthServer.SetServingStatus(service, grpcStatus(evaluateRecorded("grpc/"+service, evaluate, probeCtx, cfg)))
+			cancel()
+		}
+	}
+
+	ticker := time.NewTicker(cfg.GRPCPollInterval)
+	defer ticker.Stop()
+
+	refresh()
+	for {
+		select {
+		case <-pollCtx.Done():
+			return
+		case report, ok := <-readiness:
+			if !ok {
+				// Closed on shutdown, a nil channel is never ready
+				readiness = nil
+				continue
+			}
+			for _, service := range grpcReadinessServices {
+				healthServer.SetServingStatus(service, grpcStatus(report))
+			}
+		case <-ticker.C:
+			refresh()
+		}
+	}
+}
+
+func grpcStatus(report *healthReport) healthpb.HealthCheckResponse_ServingStatus {
+	if report.ok() {
+		return healthpb.HealthCheckResponse_SERVING
+	}
+	return healthpb.HealthCheckResponse_NOT_SERVING
+}
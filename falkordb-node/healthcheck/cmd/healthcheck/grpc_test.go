@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestPollGRPCHealth(t *testing.T) {
+	cfg := testConfig(t, map[string]string{"GRPC_HEALTH_POLL_INTERVAL_MS": "10"})
+	node := newFakeNode(masterInfo)
+	p := newTestProbes(t, cfg, node)
+
+	healthServer := health.NewServer()
+	pollCtx, cancel := context.WithCancel(withProbes(context.Background(), p))
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		pollGRPCHealth(pollCtx, cfg, healthServer)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	status := func(service string) healthpb.HealthCheckResponse_ServingStatus {
+		resp, err := healthServer.Check(context.Background(), &healthpb.HealthCheckRequest{Service: service})
+		if err != nil {
+			// Not set yet
+			return healthpb.HealthCheckResponse_SERVICE_UNKNOWN
+		}
+		return resp.Status
+	}
+	serving := func(want healthpb.HealthCheckResponse_ServingStatus, services ...string) func() bool {
+		return func() bool {
+			for _, service := range services {
+				if status(service) != want {
+					return false
+				}
+			}
+			return true
+		}
+	}
+
+	eventually(t, "every service serving", serving(healthpb.HealthCheckResponse_SERVING, "", "readiness", "liveness", "startup"))
+
+	// The readiness subscription is the one the streams share
+	p.streams.mu.Lock()
+	subscribers := len(p.streams.subscribers)
+	p.streams.mu.Unlock()
+	if subscribers != 1 {
+		t.Errorf("%d broadcaster subscribers, want the gRPC server only", subscribers)
+	}
+
+	node.setInfo(strings.Replace(masterInfo, "loading:0", "loading:1", 1))
+	eventually(t, "readiness not serving", serving(healthpb.HealthCheckResponse_NOT_SERVING, "", "readiness"))
+
+	// Closing the broadcaster on shutdown leaves the other services polled
+	p.streams.closeAll()
+	node.setInfo(masterInfo)
+	before := node.called("INFO")
+	eventually(t, "liveness polled after the broadcaster closed", func() bool { return node.called("INFO") > before })
+	if got := status("readiness"); got != healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Errorf("readiness = %v after the broadcaster closed, want the last status", got)
+	}
+}
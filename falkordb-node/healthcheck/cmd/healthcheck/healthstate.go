@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+// healthStates is the last status evaluated for each endpoint. Evaluations
+// finish concurrently, the last one to finish wins.
+type healthStates struct {
+	mu       sync.Mutex
+	statuses map[string]string
+}
+
+// ownEvaluation reports whether an evaluation is the node's own: of the
+// local node, through the INFO cache, without the expect_role of a request.
+// Only those move the health state, a nocache probe or a role asserted while
+// confirming a failover says nothing about the state of the endpoint.
+func ownEvaluation(probeCtx context.Context) bool {
+	return probeTarget(probeCtx) == "" && !noCache(probeCtx) && !requestedRole(probeCtx)
+}
+
+// stateEndpoint returns the probe endpoint an evaluation source stands for,
+// the broadcaster evaluating readiness like /readyz
+func stateEndpoint(source string) string {
+	source = strings.TrimPrefix(source, "poller/")
+	switch source {
+	case "stream":
+		return "readyz"
+	case "grpc/liveness":
+		return "livez"
+	case "grpc/startup":
+		return "startupz"
+	}
+	return source
+}
+
+// record updates the state metrics of the endpoint with the status of an
+// evaluation, and tells whether the status changed. The first evaluation
+// after a restart only sets the baseline, it isn't counted as a transition.
+func (s *healthStates) record(source string, status string) bool {
+	endpoint := stateEndpoint(source)
+	ok := 0.0
+	if status == "pass" {
+		ok = 1
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	previous, seen := s.statuses[endpoint]
+	s.statuses[endpoint] = status
+	healthStatusGauge.WithLabelValues(endpoint).Set(ok)
+	if seen && previous == status {
+		return false
+	}
+	if seen {
+		healthTransitionCounter.WithLabelValues(endpoint, previous, status).Inc()
+	}
+	healthLastTransitionGauge.WithLabelValues(endpoint).Set(float64(time.Now().UnixNano()) / 1e9)
+	return true
+}
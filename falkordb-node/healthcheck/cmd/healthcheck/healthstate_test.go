@@ -0,0 +1,85 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestRecordHealthState(t *testing.T) {
+	states := &healthStates{statuses: map[string]string{}}
+	transitions := func(from, to string) float64 {
+		return testutil.ToFloat64(healthTransitionCounter.WithLabelValues("startupz", from, to))
+	}
+	passToFail, failToPass := transitions("pass", "fail"), transitions("fail", "pass")
+
+	steps := []struct {
+		status  string
+		changed bool
+		up      float64
+	}{
+		// The first evaluation only sets the baseline
+		{status: "pass", changed: true, up: 1},
+		{status: "pass", changed: false, up: 1},
+		{status: "fail", changed: true, up: 0},
+		{status: "fail", changed: false, up: 0},
+		{status: "pass", changed: true, up: 1},
+	}
+	for i, step := range steps {
+		if changed := states.record("poller/startupz", step.status); changed != step.changed {
+			t.Errorf("step %d: record(%s) = %v, want %v", i, step.status, changed, step.changed)
+		}
+		if up := testutil.ToFloat64(healthStatusGauge.WithLabelValues("startupz")); up != step.up {
+			t.Errorf("step %d: status gauge = %v, want %v", i, up, step.up)
+		}
+	}
+
+	if got := transitions("pass", "fail") - passToFail; got != 1 {
+		t.Errorf("pass to fail transitions = %v, want 1", got)
+	}
+	if got := transitions("fail", "pass") - failToPass; got != 1 {
+		t.Errorf("fail to pass transitions = %v, want 1", got)
+	}
+}
+
+func TestStateEndpoint(t *testing.T) {
+	tests := map[string]string{
+		"readyz": "readyz", "poller/readyz": "readyz", "stream": "readyz",
+		"grpc/liveness": "livez", "poller/livez": "livez", "grpc/startup": "startupz",
+	}
+	for source, want := range tests {
+		if got := stateEndpoint(source); got != want {
+			t.Errorf("stateEndpoint(%q) = %q, want %q", source, got, want)
+		}
+	}
+}
+
+func TestParameterizedEvaluationsKeepTheState(t *testing.T) {
+	cfg := testConfig(t, nil)
+	p := newTestProbes(t, cfg, newFakeNode(masterInfo))
+
+	if w := serve(t, cfg, p, http.MethodGet, "/readyz", nil); w.Code != http.StatusOK {
+		t.Fatalf("GET /readyz = %d %q", w.Code, w.Body.String())
+	}
+	failing := testutil.ToFloat64(healthTransitionCounter.WithLabelValues("readyz", "pass", "fail"))
+
+	// Confirming a failover asserts a role the master doesn't have
+	for _, path := range []string{"/readyz?expect_role=slave", "/readyz?expect_role=slave&nocache=1"} {
+		if w := serve(t, cfg, p, http.MethodGet, path, nil); w.Code != http.StatusServiceUnavailable {
+			t.Fatalf("GET %s = %d %q, want 503", path, w.Code, w.Body.String())
+		}
+	}
+
+	if up := testutil.ToFloat64(healthStatusGauge.WithLabelValues("readyz")); up != 1 {
+		t.Errorf("readyz status gauge = %v after expect_role probes, want 1", up)
+	}
+	if got := testutil.ToFloat64(healthTransitionCounter.WithLabelValues("readyz", "pass", "fail")); got != failing {
+		t.Errorf("expect_role probes counted %v transitions", got-failing)
+	}
+	p.states.mu.Lock()
+	defer p.states.mu.Unlock()
+	if status := p.states.statuses["readyz"]; status != "pass" {
+		t.Errorf("readyz state = %q after expect_role probes, want pass", status)
+	}
+}
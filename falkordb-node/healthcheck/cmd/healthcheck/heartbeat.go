@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+// finalHeartbeatTimeout bounds the terminating heartbeat sent on shutdown
+const finalHeartbeatTimeout = 2 * time.Second
+
+// heartbeat is POSTed to HEARTBEAT_URL every HEARTBEAT_INTERVAL_MS, for
+// control planes that can't reach the pod to probe it
+type heartbeat struct {
+	Node      string        `json:"node"`
+	NodeIndex string        `json:"node_index,omitempty"`
+	Status    string        `json:"status"` // the readiness status, or terminating on shutdown
+	Timestamp time.Time     `json:"timestamp"`
+	Report    *healthReport `json:"report,omitempty"`
+}
+
+// startHeartbeat pushes the readiness report to HEARTBEAT_URL on the
+// interval. Deliveries run on their own goroutine so they never hold up the
+// HTTP probes. The returned function stops it and sends a last heartbeat
+// with the terminating status.
+func startHeartbeat(serverCtx context.Context, cfg *Config, p *probes) func() {
+	if cfg.HeartbeatURL == "" {
+		return func() {}
+	}
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	heartbeatCtx, cancel := context.WithCancel(withProbes(serverCtx, p))
+	last := make(chan *healthReport, 1)
+	go func() {
+		defer close(last)
+		last <- pushHeartbeats(heartbeatCtx, client, cfg)
+	}()
+
+	slog.Info("starting heartbeat", "interval", cfg.HeartbeatInterval)
+	return func() {
+		cancel()
+		final := newHeartbeat(cfg, <-last)
+		final.Status = "terminating"
+
+		finalCtx, cancelFinal := context.WithTimeout(context.WithoutCancel(serverCtx), finalHeartbeatTimeout)
+		defer cancelFinal()
+		if err := deliverHeartbeat(finalCtx, client, cfg, final); err != nil {
+			slog.Warn("error delivering final heartbeat", "error", err)
+		}
+	}
+}
+
+// pushHeartbeats sends a heartbeat per interval with the latest report of
+// the readiness broadcaster until heartbeatCtx is done, returning the last
+// report
+func pushHeartbeats(heartbeatCtx context.Context, client *http.Client, cfg *Config) *healthReport {
+	streams := probesOf(heartbeatCtx).streams
+	reports, ok := streams.subscribe(heartbeatCtx, cfg, cfg.HeartbeatInterval)
+	if !ok {
+		return nil
+	}
+	defer streams.unsubscribe(reports)
+
+	ticker := time.NewTicker(cfg.HeartbeatInterval)
+	defer ticker.Stop()
+
+	var report *healthReport
+	for {
+		select {
+		case <-heartbeatCtx.Done():
+			return report
+		case latest, ok := <-reports:
+			if !ok {
+				// Closed on shutdown, the last report is repeated until the
+				// heartbeat stops
+				reports = nil
+				continue
+			}
+			first := report == nil
+			report = latest
+			if !first {
+				continue
+			}
+		case <-ticker.C:
+			if report == nil {
+				continue
+			}
+		}
+
+		// Retries stop once the next heartbeat is due
+		deliverCtx, cancelDeliver := context.WithTimeout(heartbeatCtx, cfg.HeartbeatInterval)
+		if err := deliverHeartbeat(deliverCtx, client, cfg, newHeartbeat(cfg, report)); err != nil && heartbeatCtx.Err() == nil {
+			slog.Warn("error delivering heartbeat", "error", err)
+		}
+		cancelDeliver()
+	}
+}
+
+func newHeartbeat(cfg *Config, report *healthReport) heartbeat {
+	beat := heartbeat{
+		Node:      nodeName(cfg),
+		NodeIndex: cfg.NodeIndex,
+		Timestamp: time.Now().UTC(),
+		Report:    report,
+	}
+	if report != nil {
+		beat.Status = report.Status
+	}
+	return beat
+}
+
+// deliverHeartbeat POSTs the heartbeat, retrying with jittered backoff until
+// it is accepted or deliverCtx is done
+func deliverHeartbeat(deliverCtx context.Context, client *http.Client, cfg *Config, beat heartbeat) error {
+	body, err := json.Marshal(beat)
+	if err != nil {
+		return err
+	}
+
+	backoff := time.Second
+	for attempt := 1; ; attempt++ {
+		err = postHeartbeat(deliverCtx, client, cfg, body)
+		if err == nil {
+			slog.Debug("delivered heartbeat", "status", beat.Status, "attempts", attempt)
+			return nil
+		}
+
+		// Spread the retries of many pods after a control plane outage
+		wait := backoff/2 + time.Duration(rand.Int63n(int64(backoff)))
+		select {
+		case <-deliverCtx.Done():
+			return fmt.Errorf("%w (after %d attempts)", err, attempt)
+		case <-time.After(wait):
+		}
+		backoff = min(2*backoff, 30*time.Second)
+	}
+}
+
+// postHeartbeat sends one heartbeat. With HEARTBEAT_SECRET the body is
+// signed with HMAC-SHA256 in X-Heartbeat-Signature, as sha256=<hex>.
+func postHeartbeat(deliverCtx context.Context, client *http.Client, cfg *Config, body []byte) error {
+	req, err := http.NewRequestWithContext(deliverCtx, http.MethodPost, cfg.HeartbeatURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if cfg.HeartbeatSecret != "" {
+		mac := hmac.New(sha256.New, []byte(cfg.HeartbeatSecret))
+		mac.Write(body)
+		req.Header.Set("X-Heartbeat-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("heartbeat endpoint answered %s", resp.Status)
+	}
+	return nil
+}
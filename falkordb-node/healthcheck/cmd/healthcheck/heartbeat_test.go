@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestHeartbeat(t *testing.T) {
+	var mu sync.Mutex
+	var all []heartbeat
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mac := hmac.New(sha256.New, []byte("secret"))
+		mac.Write(body)
+		if got, want := r.Header.Get("X-Heartbeat-Signature"), "sha256="+hex.EncodeToString(mac.Sum(nil)); got != want {
+			t.Errorf("X-Heartbeat-Signature = %q, want %q", got, want)
+		}
+
+		var beat heartbeat
+		if err := json.Unmarshal(body, &beat); err != nil {
+			t.Errorf("invalid heartbeat %q: %v", body, err)
+		}
+		mu.Lock()
+		all = append(all, beat)
+		mu.Unlock()
+	}))
+	defer receiver.Close()
+	received := func() []heartbeat {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]heartbeat(nil), all...)
+	}
+
+	cfg := testConfig(t, map[string]string{
+		"HEARTBEAT_URL":         receiver.URL,
+		"HEARTBEAT_INTERVAL_MS": "10",
+		"HEARTBEAT_SECRET":      "secret",
+		"POD_NAME":              "node-0",
+		"NODE_INDEX":            "0",
+	})
+	p := newTestProbes(t, cfg, newFakeNode(masterInfo))
+
+	// The broadcaster only publishes the first report of a passing node,
+	// the heartbeats repeat it on their interval
+	stop := startHeartbeat(context.Background(), cfg, p)
+	eventually(t, "three heartbeats", func() bool { return len(received()) >= 3 })
+	stop()
+
+	beats := received()
+	for _, beat := range beats[:len(beats)-1] {
+		if beat.Node != "node-0" || beat.NodeIndex != "0" || beat.Status != "pass" || beat.Report == nil || beat.Report.Status != "pass" {
+			t.Errorf("heartbeat = %+v, want node-0 passing", beat)
+		}
+	}
+	if final := beats[len(beats)-1]; final.Status != "terminating" || final.Report == nil || final.Report.Status != "pass" {
+		t.Errorf("final heartbeat = %+v, want terminating with the last report", final)
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// historyEntry is one evaluation kept in the health history
+type historyEntry struct {
+	Time       time.Time     `json:"time"`
+	Source     string        `json:"source"`
+	Status     string        `json:"status"`
+	Body       string        `json:"body"`
+	DurationMs float64       `json:"duration_ms"`
+	Checks     []checkResult `json:"checks"`
+}
+
+// healthHistory is a fixed size ring buffer of the latest evaluations
+type healthHistory struct {
+	mu      sync.Mutex
+	entries []historyEntry
+	next    int
+	full    bool
+}
+
+func newHealthHistory(size int) *healthHistory {
+	return &healthHistory{entries: make([]historyEntry, size)}
+}
+
+func (h *healthHistory) add(entry historyEntry) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if len(h.entries) == 0 {
+		return
+	}
+
+	h.entries[h.next] = entry
+	h.next = (h.next + 1) % len(h.entries)
+	if h.next == 0 {
+		h.full = true
+	}
+}
+
+// since returns the entries recorded after t, oldest first
+func (h *healthHistory) since(t time.Time) []historyEntry {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	ordered := h.entries[:h.next]
+	if h.full {
+		ordered = append(append([]historyEntry{}, h.entries[h.next:]...), h.entries[:h.next]...)
+	}
+
+	entries := []historyEntry{}
+	for _, entry := range ordered {
+		if entry.Time.After(t) {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// evaluateRecorded runs evaluate and records the outcome in the history
+func evaluateRecorded(source string, evaluate func(context.Context, *Config) *healthReport, probeCtx context.Context, cfg *Config) *healthReport {
+	start := time.Now()
+	probeCtx, span := tracer.Start(probeCtx, "evaluate "+source)
+	report := func() (report *healthReport) {
+		defer recoverEvaluation(probeCtx, source, &report)
+		return evaluate(probeCtx, cfg)
+	}()
+	elapsed := time.Since(start)
+	report.DurationMs = milliseconds(elapsed)
+	endReportSpan(span, report)
+	warnSlowEvaluation(cfg, source, report, elapsed)
+	p := probesOf(probeCtx)
+	if p.circuit != nil && probeTarget(probeCtx) == "" {
+		report.Circuit = p.circuit.currentState()
+	}
+	// An evaluation aborted by its caller says nothing about the node
+	if errors.Is(probeCtx.Err(), context.Canceled) {
+		return report
+	}
+	// An evaluation asked with parameters doesn't tell the state of the
+	// node, nor why it was restarted, and a remote node has no state of its
+	// own here
+	if ownEvaluation(probeCtx) {
+		changed := p.states.record(source, report.Status)
+		rememberTerminationReport(cfg, stateEndpoint(source), report, changed)
+	}
+
+	p.history.add(historyEntry{
+		Time:       start,
+		Source:     source,
+		Status:     report.Status,
+		Body:       report.body,
+		DurationMs: report.DurationMs,
+		Checks:     report.Checks,
+	})
+	return report
+}
+
+// historyHandler serves the recorded evaluations as JSON, optionally only
+// those since the RFC 3339 time given in ?since=.
+func historyHandler(w http.ResponseWriter, r *http.Request) {
+	var since time.Time
+	if value := r.URL.Query().Get("since"); value != "" {
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, "INVALID_SINCE", "expected an RFC 3339 time")
+			return
+		}
+		since = t
+	}
+
+	writeJSON(w, r, http.StatusOK, probesOf(r.Context()).history.since(since))
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"falkordb.cloud/main/internal/redisinfo"
+	"github.com/redis/go-redis/v9"
+)
+
+// infoMode is how a node answers INFO with several sections
+type infoMode int
+
+const (
+	infoModeUnknown infoMode = iota
+	// infoModeBatched sends every section in one INFO call, Redis 7+
+	infoModeBatched
+	// infoModeSingle pipelines one INFO call per section, older servers
+	// answer several sections with a syntax error
+	infoModeSingle
+)
+
+// infoModes remembers the infoMode of each node, keyed by probe target
+type infoModes struct {
+	mu    sync.Mutex
+	modes map[string]infoMode
+}
+
+// infoPayloadLogged is set once the size of the sectioned INFO reply was
+// compared with the full one
+var infoPayloadLogged atomic.Bool
+
+// neededInfoSections lists the INFO sections the checks of cfg and of its
+// targets read. The commandstats and the other large sections are left out.
+func neededInfoSections(cfg *Config) []string {
+	needed := map[string]bool{"server": true, "replication": true, "persistence": true, "memory": true}
+	configs := []*Config{cfg}
+	for _, target := range cfg.Targets {
+		configs = append(configs, target.Config)
+	}
+	for _, c := range configs {
+		if c.checkEnabled("clients") {
+			needed["clients"], needed["stats"] = true, true
+		}
+		if c.checkEnabled("cpu") && c.MaxCPUPercent > 0 {
+			needed["cpu"] = true
+		}
+		if c.checkEnabled("keyspace") {
+			needed["keyspace"] = true
+		}
+		if c.SentinelMode {
+			needed["sentinel"] = true
+		}
+	}
+
+	var sections []string
+	for _, section := range []string{"server", "clients", "memory", "persistence", "stats", "replication", "cpu", "keyspace", "sentinel"} {
+		if needed[section] {
+			sections = append(sections, section)
+		}
+	}
+	return sections
+}
+
+func (m *infoModes) get(node string) infoMode {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.modes[node]
+}
+
+func (m *infoModes) set(node string, mode infoMode) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.modes[node] != mode {
+		slog.Debug("detected INFO section support", "target", node, "batched", mode == infoModeBatched)
+	}
+	m.modes[node] = mode
+}
+
+// infoModeForVersion returns the infoMode of a node from its redis_version
+func infoModeForVersion(info *redisinfo.Info) infoMode {
+	version, err := info.String("redis_version")
+	if err != nil {
+		return infoModeUnknown
+	}
+	major, _, _ := strings.Cut(version, ".")
+	if n, err := strconv.Atoi(major); err == nil && n >= 7 {
+		return infoModeBatched
+	}
+	return infoModeSingle
+}
+
+// isSyntaxError reports whether Redis rejected the command arguments
+func isSyntaxError(err error) bool {
+	return isRedisReply(err) && strings.HasPrefix(err.Error(), "ERR syntax error")
+}
+
+// fetchRawInfo sends INFO for the sections the probes need, nil asking for
+// the whole reply, to the node probed within
+// probeCtx: in one call on Redis 7+, one pipelined call per section on older
+// servers, and the whole INFO reply when the server refuses those too.
+func fetchRawInfo(probeCtx context.Context) (string, error) {
+	client := nodeClient(probeCtx)
+	p := probesOf(probeCtx)
+	infoSections, modes := p.infoSections, p.infoModes
+	if len(infoSections) == 0 {
+		return client.Info(probeCtx).Result()
+	}
+
+	node := probeTarget(probeCtx)
+	if modes.get(node) != infoModeSingle {
+		raw, err := client.Info(probeCtx, infoSections...).Result()
+		switch {
+		case isSyntaxError(err):
+			modes.set(node, infoModeSingle)
+		case err != nil:
+			return "", err
+		case modes.get(node) == infoModeBatched:
+			return raw, nil
+		// Some servers ignore the extra sections instead of failing
+		case infoModeForVersion(redisinfo.Parse(raw)) == infoModeSingle:
+			modes.set(node, infoModeSingle)
+		default:
+			modes.set(node, infoModeBatched)
+			return raw, nil
+		}
+	}
+
+	pipe := client.Pipeline()
+	cmds := make([]*redis.StringCmd, len(infoSections))
+	for i, section := range infoSections {
+		cmds[i] = pipe.Info(probeCtx, section)
+	}
+	if _, err := pipe.Exec(probeCtx); err != nil {
+		if isRedisReply(err) {
+			return client.Info(probeCtx).Result()
+		}
+		return "", err
+	}
+
+	var b strings.Builder
+	for _, cmd := range cmds {
+		b.WriteString(cmd.Val())
+		b.WriteString("\r\n")
+	}
+	return b.String(), nil
+}
+
+// logInfoPayload logs at debug level how much smaller the sectioned INFO
+// reply of the local node is than the full one, once
+func logInfoPayload(probeCtx context.Context, size int) {
+	infoSections := probesOf(probeCtx).infoSections
+	if len(infoSections) == 0 || !slog.Default().Enabled(probeCtx, slog.LevelDebug) || infoPayloadLogged.Swap(true) {
+		return
+	}
+
+	full, err := probesOf(probeCtx).client.Info(probeCtx).Result()
+	if err != nil || len(full) == 0 {
+		infoPayloadLogged.Store(false)
+		return
+	}
+	slog.Debug("INFO payload", "sections", infoSections, "bytes", size, "full_bytes", len(full),
+		"reduction_pct", 100-100*size/len(full))
+}
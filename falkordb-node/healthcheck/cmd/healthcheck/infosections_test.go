@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"falkordb.cloud/main/internal/redisinfo"
+)
+
+// infoServer answers INFO like a server of version, recording the sections
+// of each call. batched tells whether it accepts several sections at once,
+// ignores whether it answers them with the first one only and sectioned
+// whether it accepts a section at all.
+type infoServer struct {
+	mu        sync.Mutex
+	version   string
+	batched   bool
+	sectioned bool
+	ignores   bool
+	calls     [][]string
+}
+
+func (s *infoServer) reply(args []string) any {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sections := args[1:]
+	s.calls = append(s.calls, sections)
+	switch {
+	case len(sections) > 1 && s.ignores:
+		sections = sections[:1]
+	case len(sections) > 1 && !s.batched, len(sections) == 1 && !s.sectioned:
+		return replyError("ERR syntax error")
+	}
+
+	var b strings.Builder
+	for _, section := range sections {
+		b.WriteString("# " + section + "\r\n" + section + "_field:1\r\n")
+		if section == "server" {
+			b.WriteString("redis_version:" + s.version + "\r\n")
+		}
+	}
+	if len(sections) == 0 {
+		b.WriteString("# Server\r\nredis_version:" + s.version + "\r\n# Commandstats\r\ncmdstat_ping:calls=1\r\n")
+	}
+	return b.String()
+}
+
+func (s *infoServer) takeCalls() [][]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	calls := s.calls
+	s.calls = nil
+	return calls
+}
+
+func TestFetchRawInfo(t *testing.T) {
+	sections := []string{"server", "replication"}
+	tests := []struct {
+		name   string
+		server *infoServer
+		mode   infoMode
+		// calls are the INFO calls of the first and of the next fetch
+		first, next [][]string
+		field       string
+	}{
+		{
+			name:   "batched on Redis 7",
+			server: &infoServer{version: "7.2.4", batched: true, sectioned: true},
+			mode:   infoModeBatched,
+			first:  [][]string{sections},
+			next:   [][]string{sections},
+			field:  "replication_field",
+		},
+		{
+			name:   "one call per section when several are refused",
+			server: &infoServer{version: "6.2.14", sectioned: true},
+			mode:   infoModeSingle,
+			first:  [][]string{sections, {"server"}, {"replication"}},
+			next:   [][]string{{"server"}, {"replication"}},
+			field:  "replication_field",
+		},
+		{
+			name:   "one call per section when the extra ones are ignored",
+			server: &infoServer{version: "6.0.20", sectioned: true, ignores: true},
+			mode:   infoModeSingle,
+			first:  [][]string{sections, {"server"}, {"replication"}},
+			next:   [][]string{{"server"}, {"replication"}},
+			field:  "replication_field",
+		},
+		{
+			name:   "whole INFO when sections are refused",
+			server: &infoServer{version: "2.8.24"},
+			mode:   infoModeSingle,
+			first:  [][]string{sections, {"server"}, {"replication"}, {}},
+			next:   [][]string{{"server"}, {"replication"}, {}},
+			field:  "cmdstat_ping",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig(t, nil)
+			node := newFakeNode(masterInfo)
+			node.reply("INFO", tt.server.reply)
+			p := newTestProbes(t, cfg, node)
+			p.infoSections = sections
+			probeCtx := withProbes(context.Background(), p)
+
+			for i, want := range [][][]string{tt.first, tt.next} {
+				raw, err := fetchRawInfo(probeCtx)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if calls := tt.server.takeCalls(); !reflect.DeepEqual(calls, want) {
+					t.Errorf("fetch %d sent INFO %q, want %q", i+1, calls, want)
+				}
+				info := redisinfo.Parse(raw)
+				if version, _ := info.String("redis_version"); version != tt.server.version || !info.Has(tt.field) {
+					t.Errorf("fetch %d = %q, want version %s and %s", i+1, raw, tt.server.version, tt.field)
+				}
+			}
+			if mode := p.infoModes.get(""); mode != tt.mode {
+				t.Errorf("infoMode = %d, want %d", mode, tt.mode)
+			}
+		})
+	}
+}
+
+func TestInfoModeForVersion(t *testing.T) {
+	tests := []struct {
+		info string
+		want infoMode
+	}{
+		{info: "redis_version:7.0.0", want: infoModeBatched},
+		{info: "redis_version:7.2.4", want: infoModeBatched},
+		{info: "redis_version:8.0.1", want: infoModeBatched},
+		{info: "redis_version:6.2.14", want: infoModeSingle},
+		{info: "redis_version:unstable", want: infoModeSingle},
+		{info: "uptime_in_seconds:1", want: infoModeUnknown},
+	}
+	for _, tt := range tests {
+		if got := infoModeForVersion(redisinfo.Parse(tt.info)); got != tt.want {
+			t.Errorf("infoModeForVersion(%q) = %d, want %d", tt.info, got, tt.want)
+		}
+	}
+}
+
+func TestNeededInfoSections(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		want []string
+	}{
+		{name: "readiness checks", env: map[string]string{"HEALTH_CHECKS": "loading,role"}, want: []string{"server", "memory", "persistence", "replication"}},
+		{name: "keyspace", env: map[string]string{"HEALTH_CHECKS": "keyspace"}, want: []string{"server", "memory", "persistence", "replication", "keyspace"}},
+		{name: "clients", env: map[string]string{"HEALTH_CHECKS": "clients"}, want: []string{"server", "clients", "memory", "persistence", "stats", "replication"}},
+		{name: "cpu without a threshold", env: map[string]string{"HEALTH_CHECKS": "cpu"}, want: []string{"server", "memory", "persistence", "replication"}},
+		{name: "cpu", env: map[string]string{"HEALTH_CHECKS": "cpu", "MAX_CPU_PERCENT": "90"}, want: []string{"server", "memory", "persistence", "replication", "cpu"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := neededInfoSections(testConfig(t, tt.env)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("neededInfoSections = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLogInfoPayload(t *testing.T) {
+	var logs bytes.Buffer
+	defer slog.SetDefault(slog.Default())
+	slog.SetDefault(slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	infoPayloadLogged.Store(false)
+
+	cfg := testConfig(t, nil)
+	node := newFakeNode(strings.Repeat("cmdstat_ping:calls=1\n", 100))
+	p := newTestProbes(t, cfg, node)
+	probeCtx := withProbes(context.Background(), p)
+
+	logInfoPayload(probeCtx, 100)
+	logInfoPayload(probeCtx, 100)
+	if got := strings.Count(logs.String(), "INFO payload"); got != 1 {
+		t.Fatalf("logged the payload %d times, want once: %s", got, logs.String())
+	}
+	if !strings.Contains(logs.String(), "bytes=100 full_bytes=2200 reduction_pct=96") {
+		t.Errorf("payload log = %s, want the reduction from the full reply", logs.String())
+	}
+}
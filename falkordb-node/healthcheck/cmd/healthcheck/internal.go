@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// internalFailures counts consecutive failures of the healthcheck itself,
+// as opposed to an unhealthy node. Past MAX_CONSECUTIVE_INTERNAL_FAILURES the
+// process exits so its supervisor restarts it, which is the only fix for a
+// wedged client since kubelet restarts the main container instead.
+type internalFailures struct {
+	mu      sync.Mutex
+	max     int64
+	history []string
+}
+
+// isInternalError reports whether err comes from our own client rather than
+// the node, e.g. a closed client or an exhausted connection pool.
+func isInternalError(err error) bool {
+	// go-redis doesn't export its pool errors
+	return errors.Is(err, redis.ErrClosed) || strings.Contains(err.Error(), "connection pool timeout") || strings.Contains(err.Error(), "connection pool exhausted")
+}
+
+func (f *internalFailures) record(reason string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.max <= 0 {
+		return
+	}
+
+	f.history = append(f.history, time.Now().UTC().Format(time.RFC3339)+" "+reason)
+	if int64(len(f.history)) >= f.max {
+		slog.Error("too many consecutive internal failures, exiting", "failures", f.history)
+		os.Exit(exitInternalFailure)
+	}
+}
+
+func (f *internalFailures) reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.history = nil
+}
+
+// recoverPanics answers 500 INTERNAL_PANIC when a handler panics instead of
+// dropping the connection, and counts the panic as an internal failure.
+func recoverPanics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			if v := recover(); v != nil {
+				if v == http.ErrAbortHandler {
+					panic(v)
+				}
+
+				slog.Error("panic serving request", "request_id", requestID(r), "path", r.URL.Path, "panic", v, "stack", string(debug.Stack()))
+				panicCounter.WithLabelValues("handler").Inc()
+				writeError(w, r, http.StatusInternalServerError, "INTERNAL_PANIC", "")
+				probesOf(r.Context()).internal.record(fmt.Sprintf("panic: %v", v))
+			}
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// recoverEvaluation turns a panic of an evaluation into a failed report. The
+// evaluations run on the poller and on singleflight goroutines, where a panic
+// would crash the process rather than reach recoverPanics.
+func recoverEvaluation(probeCtx context.Context, source string, report **healthReport) {
+	v := recover()
+	if v == nil {
+		return
+	}
+
+	slog.Error("panic evaluating health", "source", source, "panic", v, "stack", string(debug.Stack()))
+	panicCounter.WithLabelValues("evaluation").Inc()
+	*report = newHealthReport()
+	(*report).fail(http.StatusInternalServerError, "INTERNAL_PANIC", "evaluation", fmt.Sprint(v))
+	probesOf(probeCtx).internal.record(fmt.Sprintf("panic: %v", v))
+}
+
+// checkPanic is the error of a check that panicked
+type checkPanic struct {
+	value any
+}
+
+func (p *checkPanic) Error() string {
+	return fmt.Sprintf("panic: %v", p.value)
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestRunChecksPanic(t *testing.T) {
+	cfg := testConfig(t, nil)
+	p := newTestProbes(t, cfg, newFakeNode(masterInfo))
+	panics := testutil.ToFloat64(panicCounter.WithLabelValues("check"))
+
+	report := newHealthReport()
+	runChecks(withProbes(context.Background(), p), report, []check{
+		{name: "before", run: func(ctx context.Context) (string, string, error) {
+			return "", "fine", nil
+		}},
+		{name: "broken", run: func(ctx context.Context) (string, string, error) {
+			var info map[string]string
+			info["role"] = "master"
+			return "", "", nil
+		}},
+		{name: "after", run: func(ctx context.Context) (string, string, error) {
+			return "", "fine", nil
+		}},
+	})
+
+	if len(report.Checks) != 3 || !report.Checks[0].OK || !report.Checks[2].OK {
+		t.Fatalf("checks = %+v, want the others reported around the panic", report.Checks)
+	}
+	broken := report.Checks[1]
+	if broken.OK || broken.ReasonCode != "INTERNAL_PANIC" || !strings.HasPrefix(broken.Detail, "panic: assignment to entry in nil map") {
+		t.Errorf("broken = %+v, want INTERNAL_PANIC with the panic", broken)
+	}
+	if report.code != http.StatusInternalServerError || report.ReasonCode != "INTERNAL_PANIC" {
+		t.Errorf("report = %d %s, want 500 INTERNAL_PANIC", report.code, report.ReasonCode)
+	}
+	if got := testutil.ToFloat64(panicCounter.WithLabelValues("check")); got != panics+1 {
+		t.Errorf("check panics = %v, want %v", got, panics+1)
+	}
+}
+
+func TestRecoverEvaluation(t *testing.T) {
+	cfg := testConfig(t, nil)
+	p := newTestProbes(t, cfg, newFakeNode(masterInfo))
+	panics := testutil.ToFloat64(panicCounter.WithLabelValues("evaluation"))
+
+	evaluate := func() (report *healthReport) {
+		defer recoverEvaluation(withProbes(context.Background(), p), "poller", &report)
+		report = newHealthReport()
+		report.pass("loading", "")
+		panic("evaluation gone wrong")
+	}
+
+	report := evaluate()
+	if report == nil || report.code != http.StatusInternalServerError || report.ReasonCode != "INTERNAL_PANIC" {
+		t.Fatalf("report = %+v, want 500 INTERNAL_PANIC", report)
+	}
+	if len(report.Checks) != 1 || report.Checks[0].Name != "evaluation" || report.Checks[0].Detail != "evaluation gone wrong" {
+		t.Errorf("checks = %+v, want only the evaluation failure", report.Checks)
+	}
+	if got := testutil.ToFloat64(panicCounter.WithLabelValues("evaluation")); got != panics+1 {
+		t.Errorf("evaluation panics = %v, want %v", got, panics+1)
+	}
+}
+
+func TestRecoverPanics(t *testing.T) {
+	cfg := testConfig(t, nil)
+	p := newTestProbes(t, cfg, newFakeNode(masterInfo))
+	handler := withProbesHandler(p, recoverPanics(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/abort" {
+			panic(http.ErrAbortHandler)
+		}
+		if r.URL.Path == "/panic" {
+			panic("handler gone wrong")
+		}
+		w.Write([]byte("OK\n"))
+	})))
+
+	for _, path := range []string{"/panic", "/ok"} {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		switch {
+		case path == "/panic" && (w.Code != http.StatusInternalServerError || !strings.HasPrefix(w.Body.String(), "INTERNAL_PANIC")):
+			t.Errorf("GET /panic = %d %q, want 500 INTERNAL_PANIC", w.Code, w.Body.String())
+		case path == "/ok" && w.Code != http.StatusOK:
+			t.Errorf("GET /ok after a panic = %d %q, want 200", w.Code, w.Body.String())
+		}
+	}
+
+	// An aborted handler keeps aborting the connection
+	defer func() {
+		if v := recover(); v != http.ErrAbortHandler {
+			t.Errorf("recovered %v, want http.ErrAbortHandler", v)
+		}
+	}()
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/abort", nil))
+}
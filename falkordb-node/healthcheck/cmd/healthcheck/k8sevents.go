@@ -0,0 +1,425 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+	eventComponent    = "falkordb-healthcheck"
+	// The API server rejects longer messages
+	maxEventMessage = 1024
+)
+
+// The defaults of client-go's EventCorrelator
+const (
+	// eventCacheWindow is how long an event is remembered for correlation
+	eventCacheWindow = 10 * time.Minute
+	// eventAggregateThreshold distinct messages of one reason are combined
+	// into a single event past it
+	eventAggregateThreshold = 10
+	eventSpamBurst          = 25
+	eventSpamRefill         = 5 * time.Minute
+)
+
+// kubeAPI is the in-cluster API server, authenticated with the service
+// account of the pod
+type kubeAPI struct {
+	url       string
+	client    *http.Client
+	tokenFile string
+}
+
+type kubeAPIError struct {
+	code   int
+	status string
+}
+
+func (e *kubeAPIError) Error() string {
+	if e.code == http.StatusForbidden {
+		return "kubernetes API answered " + e.status + ", the service account needs to create and patch events"
+	}
+	return "kubernetes API answered " + e.status
+}
+
+type kubeObjectMeta struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	UID       string `json:"uid,omitempty"`
+}
+
+type kubeObjectRef struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Namespace  string `json:"namespace"`
+	Name       string `json:"name"`
+	UID        string `json:"uid,omitempty"`
+}
+
+// kubeEvent is a core/v1 Event
+type kubeEvent struct {
+	APIVersion         string         `json:"apiVersion"`
+	Kind               string         `json:"kind"`
+	Metadata           kubeObjectMeta `json:"metadata"`
+	InvolvedObject     kubeObjectRef  `json:"involvedObject"`
+	Reason             string         `json:"reason"`
+	Message            string         `json:"message"`
+	Type               string         `json:"type"`
+	Source             eventSource    `json:"source"`
+	FirstTimestamp     time.Time      `json:"firstTimestamp"`
+	LastTimestamp      time.Time      `json:"lastTimestamp"`
+	Count              int            `json:"count"`
+	ReportingComponent string         `json:"reportingComponent"`
+	ReportingInstance  string         `json:"reportingInstance"`
+}
+
+type eventSource struct {
+	Component string `json:"component"`
+	Host      string `json:"host,omitempty"`
+}
+
+// inClusterAPI returns the API server of the cluster the pod runs in, from
+// the environment and service account Kubernetes provides
+func inClusterAPI() (*kubeAPI, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, errors.New("KUBERNETES_SERVICE_HOST and KUBERNETES_SERVICE_PORT are unset, not running in a cluster")
+	}
+	if _, err := os.Stat(filepath.Join(serviceAccountDir, "token")); err != nil {
+		return nil, fmt.Errorf("no service account token: %w", err)
+	}
+
+	ca, err := os.ReadFile(filepath.Join(serviceAccountDir, "ca.crt"))
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, errors.New("no certificate in the service account ca.crt")
+	}
+
+	return &kubeAPI{
+		url: "https://" + net.JoinHostPort(host, port),
+		client: &http.Client{
+			Timeout:   5 * time.Second,
+			Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}},
+		},
+		tokenFile: filepath.Join(serviceAccountDir, "token"),
+	}, nil
+}
+
+// do sends a request to the API server and decodes the reply into out when
+// given. The token is read for every request, bound service account tokens
+// are rotated under the pod.
+func (k *kubeAPI) do(reqCtx context.Context, method string, path string, contentType string, body any, out any) error {
+	token, err := os.ReadFile(k.tokenFile)
+	if err != nil {
+		return err
+	}
+
+	var payload []byte
+	if body != nil {
+		if payload, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+	req, err := http.NewRequestWithContext(reqCtx, method, k.url+path, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := k.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return &kubeAPIError{code: resp.StatusCode, status: resp.Status}
+	}
+	if out != nil {
+		return json.NewDecoder(resp.Body).Decode(out)
+	}
+	return nil
+}
+
+// eventEmitter creates Events on the pod, correlated the way client-go
+// does: an event identical to one sent within eventCacheWindow bumps the
+// count of the existing one, distinct messages of a reason past
+// eventAggregateThreshold are combined into a single event, and past a
+// burst of eventSpamBurst events one more is allowed every eventSpamRefill.
+// It is only used by the watcher goroutine.
+type eventEmitter struct {
+	api  *kubeAPI
+	pod  kubeObjectRef
+	host string
+
+	// emitted is keyed by type, reason and message, or only type and reason
+	// once combined
+	emitted  map[string]*emittedEvent
+	similar  map[string]*similarEvents // by type and reason
+	tokens   float64
+	refilled time.Time
+}
+
+type emittedEvent struct {
+	name  string
+	count int
+	last  time.Time
+}
+
+type similarEvents struct {
+	messages map[string]bool
+	since    time.Time
+}
+
+// newEventEmitter finds the API server and the pod the events are about.
+// Without the UID of the pod, see resolvePodUID, the events still list under
+// the pod name.
+func newEventEmitter(cfg *Config) (*eventEmitter, error) {
+	api, err := inClusterAPI()
+	if err != nil {
+		return nil, err
+	}
+
+	namespace := cfg.PodNamespace
+	if namespace == "" {
+		ns, err := os.ReadFile(filepath.Join(serviceAccountDir, "namespace"))
+		if err != nil {
+			return nil, fmt.Errorf("POD_NAMESPACE unset and %w", err)
+		}
+		namespace = strings.TrimSpace(string(ns))
+	}
+
+	emitter := &eventEmitter{
+		api:      api,
+		pod:      kubeObjectRef{APIVersion: "v1", Kind: "Pod", Namespace: namespace, Name: nodeName(cfg), UID: cfg.PodUID},
+		host:     os.Getenv("NODE_NAME"),
+		emitted:  map[string]*emittedEvent{},
+		similar:  map[string]*similarEvents{},
+		tokens:   eventSpamBurst,
+		refilled: time.Now(),
+	}
+	return emitter, nil
+}
+
+// resolvePodUID reads the UID of the pod from the API server unless POD_UID
+// gave it
+func (e *eventEmitter) resolvePodUID(emitCtx context.Context) {
+	if e.pod.UID != "" {
+		return
+	}
+
+	var pod struct {
+		Metadata kubeObjectMeta `json:"metadata"`
+	}
+	if err := e.api.do(emitCtx, http.MethodGet, "/api/v1/namespaces/"+e.pod.Namespace+"/pods/"+e.pod.Name, "", nil, &pod); err != nil {
+		slog.Warn("error reading the pod UID, set POD_UID", "error", err)
+	}
+	e.pod.UID = pod.Metadata.UID
+}
+
+// emit creates the event, or updates the one it correlates with
+func (e *eventEmitter) emit(emitCtx context.Context, eventType string, reason string, message string) error {
+	now := time.Now().UTC().Truncate(time.Second)
+	e.forget(now)
+	if !e.allow(now) {
+		slog.Debug("dropping kubernetes event, too many in a row", "reason", reason, "message", message)
+		return nil
+	}
+
+	if len(message) > maxEventMessage {
+		message = message[:maxEventMessage]
+	}
+	key, message := e.aggregate(now, eventType, reason, message)
+
+	if previous, ok := e.emitted[key]; ok {
+		patch := map[string]any{"count": previous.count + 1, "lastTimestamp": now, "message": message}
+		err := e.api.do(emitCtx, http.MethodPatch, e.eventsPath()+"/"+previous.name, "application/strategic-merge-patch+json", patch, nil)
+		var apiErr *kubeAPIError
+		if err == nil {
+			previous.count++
+			previous.last = now
+			return nil
+		} else if !errors.As(err, &apiErr) || apiErr.code != http.StatusNotFound {
+			return err
+		}
+		// Expired on the API server, created again
+	}
+
+	// Named like client-go does
+	name := fmt.Sprintf("%s.%x", e.pod.Name, time.Now().UnixNano())
+	event := kubeEvent{
+		APIVersion:         "v1",
+		Kind:               "Event",
+		Metadata:           kubeObjectMeta{Name: name, Namespace: e.pod.Namespace},
+		InvolvedObject:     e.pod,
+		Reason:             reason,
+		Message:            message,
+		Type:               eventType,
+		Source:             eventSource{Component: eventComponent, Host: e.host},
+		FirstTimestamp:     now,
+		LastTimestamp:      now,
+		Count:              1,
+		ReportingComponent: eventComponent,
+		ReportingInstance:  e.pod.Name,
+	}
+	if err := e.api.do(emitCtx, http.MethodPost, e.eventsPath(), "application/json", event, nil); err != nil {
+		return err
+	}
+	e.emitted[key] = &emittedEvent{name: name, count: 1, last: now}
+	return nil
+}
+
+func (e *eventEmitter) eventsPath() string {
+	return "/api/v1/namespaces/" + e.pod.Namespace + "/events"
+}
+
+// forget drops the events past eventCacheWindow, the next identical one is
+// a new event
+func (e *eventEmitter) forget(now time.Time) {
+	for key, event := range e.emitted {
+		if now.Sub(event.last) > eventCacheWindow {
+			delete(e.emitted, key)
+		}
+	}
+	for key, similar := range e.similar {
+		if now.Sub(similar.since) > eventCacheWindow {
+			delete(e.similar, key)
+		}
+	}
+}
+
+// allow takes a token from the spam filter
+func (e *eventEmitter) allow(now time.Time) bool {
+	e.tokens = min(eventSpamBurst, e.tokens+float64(now.Sub(e.refilled))/float64(eventSpamRefill))
+	e.refilled = now
+	if e.tokens < 1 {
+		return false
+	}
+	e.tokens--
+	return true
+}
+
+// aggregate returns the correlation key of the event and its message,
+// combined with the similar ones once there are too many of them
+func (e *eventEmitter) aggregate(now time.Time, eventType string, reason string, message string) (string, string) {
+	group := eventType + "\x00" + reason
+	similar, ok := e.similar[group]
+	if !ok {
+		similar = &similarEvents{messages: map[string]bool{}, since: now}
+		e.similar[group] = similar
+	}
+	similar.messages[message] = true
+
+	if len(similar.messages) > eventAggregateThreshold {
+		return group, "(combined from similar events): " + message
+	}
+	return group + "\x00" + message, message
+}
+
+// readinessEvent returns the event telling a change of the readiness
+// report: FalkorDBSyncStarted and FalkorDBSyncCompleted around a full sync,
+// FalkorDBUnhealthy with the failing check otherwise and FalkorDBHealthy
+// once it passes again
+func readinessEvent(previous *healthReport, report *healthReport) (eventType string, reason string, message string, changed bool) {
+	if report.Status == previous.Status && report.ReasonCode == previous.ReasonCode {
+		return "", "", "", false
+	}
+
+	syncing := func(r *healthReport) bool { return r.ReasonCode == "SYNC_IN_PROGRESS" }
+	switch {
+	case syncing(report):
+		return "Normal", "FalkorDBSyncStarted", "Full sync with the master started: " + report.body, true
+	case report.ok() && syncing(previous):
+		return "Normal", "FalkorDBSyncCompleted", "Full sync with the master completed", true
+	case report.ok():
+		return "Normal", "FalkorDBHealthy", "Ready again after " + previous.body, true
+	}
+
+	message = report.body
+	if check, failed := report.failedCheck(); failed {
+		message = fmt.Sprintf("Check %s failed: %s", check.Name, report.body)
+	}
+	return "Warning", "FalkorDBUnhealthy", message, true
+}
+
+// startEventEmitter follows the readiness broadcaster, evaluated at least
+// every K8S_EVENTS_INTERVAL_MS, and records its changes as Events on the pod
+// with EMIT_K8S_EVENTS. Outside a
+// cluster, and when the API server refuses them, the events are only
+// logged. It returns a function stopping it.
+func startEventEmitter(serverCtx context.Context, cfg *Config, p *probes) func() {
+	if !cfg.EmitK8sEvents {
+		return func() {}
+	}
+
+	emitter, err := newEventEmitter(cfg)
+	if err != nil {
+		slog.Warn("not emitting kubernetes events", "error", err)
+		return func() {}
+	}
+
+	emitCtx, cancel := context.WithCancel(withProbes(serverCtx, p))
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		emitReadinessEvents(emitCtx, cfg, emitter)
+	}()
+
+	slog.Info("emitting kubernetes events", "pod", emitter.pod.Namespace+"/"+emitter.pod.Name, "interval", cfg.EventsInterval)
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+func emitReadinessEvents(emitCtx context.Context, cfg *Config, emitter *eventEmitter) {
+	emitter.resolvePodUID(emitCtx)
+
+	streams := probesOf(emitCtx).streams
+	reports, ok := streams.subscribe(emitCtx, cfg, cfg.EventsInterval)
+	if !ok {
+		return
+	}
+	defer streams.unsubscribe(reports)
+
+	// The first report only sets the baseline
+	var previous *healthReport
+	for {
+		select {
+		case <-emitCtx.Done():
+			return
+		case report, ok := <-reports:
+			if !ok {
+				return
+			}
+			if previous != nil {
+				if eventType, reason, message, changed := readinessEvent(previous, report); changed {
+					if err := emitter.emit(emitCtx, eventType, reason, message); err != nil {
+						slog.Warn("error emitting kubernetes event", "reason", reason, "message", message, "error", err)
+					}
+				}
+			}
+			previous = report
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestReadinessEvent(t *testing.T) {
+	pass := &healthReport{Status: "pass", body: "OK"}
+	syncing := &healthReport{Status: "fail", ReasonCode: "SYNC_IN_PROGRESS", body: "SYNC_IN_PROGRESS"}
+	loading := &healthReport{Status: "fail", ReasonCode: "LOADING", body: "LOADING", Checks: []checkResult{{Name: "loading", Detail: "LOADING"}}}
+
+	tests := []struct {
+		name             string
+		previous, report *healthReport
+		wantType         string
+		wantReason       string
+		wantChanged      bool
+	}{
+		{name: "unchanged", previous: pass, report: pass},
+		{name: "sync started", previous: pass, report: syncing, wantType: "Normal", wantReason: "FalkorDBSyncStarted", wantChanged: true},
+		{name: "sync completed", previous: syncing, report: pass, wantType: "Normal", wantReason: "FalkorDBSyncCompleted", wantChanged: true},
+		{name: "unhealthy", previous: pass, report: loading, wantType: "Warning", wantReason: "FalkorDBUnhealthy", wantChanged: true},
+		{name: "healthy again", previous: loading, report: pass, wantType: "Normal", wantReason: "FalkorDBHealthy", wantChanged: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			eventType, reason, _, changed := readinessEvent(tt.previous, tt.report)
+			if eventType != tt.wantType || reason != tt.wantReason || changed != tt.wantChanged {
+				t.Errorf("readinessEvent() = %q, %q, %v, want %q, %q, %v", eventType, reason, changed, tt.wantType, tt.wantReason, tt.wantChanged)
+			}
+		})
+	}
+}
+
+func TestEmitReadinessEvents(t *testing.T) {
+	var mu sync.Mutex
+	var events []kubeEvent
+	apiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Bearer token" {
+			t.Errorf("Authorization = %q", got)
+		}
+		if r.Method != http.MethodPost || r.URL.Path != "/api/v1/namespaces/falkordb/events" {
+			t.Errorf("unexpected %s %s", r.Method, r.URL.Path)
+			return
+		}
+		var event kubeEvent
+		if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+			t.Errorf("invalid event: %v", err)
+		}
+		mu.Lock()
+		events = append(events, event)
+		mu.Unlock()
+	}))
+	defer apiServer.Close()
+	emitted := func() []kubeEvent {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]kubeEvent(nil), events...)
+	}
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("token\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	emitter := &eventEmitter{
+		api:      &kubeAPI{url: apiServer.URL, client: apiServer.Client(), tokenFile: tokenFile},
+		pod:      kubeObjectRef{APIVersion: "v1", Kind: "Pod", Namespace: "falkordb", Name: "node-0", UID: "uid"},
+		emitted:  map[string]*emittedEvent{},
+		similar:  map[string]*similarEvents{},
+		tokens:   eventSpamBurst,
+		refilled: time.Now(),
+	}
+
+	cfg := testConfig(t, map[string]string{"K8S_EVENTS_INTERVAL_MS": "10"})
+	node := newFakeNode(masterInfo)
+	p := newTestProbes(t, cfg, node)
+
+	emitCtx, cancel := context.WithCancel(withProbes(context.Background(), p))
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		emitReadinessEvents(emitCtx, cfg, emitter)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	// The first report only sets the baseline
+	eventually(t, "the baseline evaluation", func() bool { return node.called("INFO") > 0 })
+	node.setInfo(strings.Replace(masterInfo, "loading:0", "loading:1", 1))
+	eventually(t, "the unhealthy event", func() bool { return len(emitted()) == 1 })
+	node.setInfo(masterInfo)
+	eventually(t, "the healthy event", func() bool { return len(emitted()) == 2 })
+
+	got := emitted()
+	if got[0].Type != "Warning" || got[0].Reason != "FalkorDBUnhealthy" || !strings.HasPrefix(got[0].Message, "Check loading failed") {
+		t.Errorf("first event = %s %s %q, want the loading check failing", got[0].Type, got[0].Reason, got[0].Message)
+	}
+	if got[1].Type != "Normal" || got[1].Reason != "FalkorDBHealthy" || got[1].InvolvedObject.UID != "uid" {
+		t.Errorf("second event = %s %s on %+v, want healthy again", got[1].Type, got[1].Reason, got[1].InvolvedObject)
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"falkordb.cloud/main/internal/redisinfo"
+)
+
+// keyspaceDB is the keys of one database, AvgTTL in milliseconds as Redis
+// reports it
+type keyspaceDB struct {
+	Keys    int64 `json:"keys"`
+	Expires int64 `json:"expires"`
+	AvgTTL  int64 `json:"avg_ttl"`
+}
+
+// keyspaceInfo is the keyspace of a node, totalled over its databases
+type keyspaceInfo struct {
+	Keys      int64                 `json:"keys"`
+	Expires   int64                 `json:"expires"`
+	Databases map[string]keyspaceDB `json:"databases"`
+}
+
+// nodeKeyspace reads the dbN entries of INFO keyspace. Redis leaves out empty
+// databases, db0 is reported with zero keys rather than missing.
+func nodeKeyspace(info *redisinfo.Info) *keyspaceInfo {
+	stats := &keyspaceInfo{Databases: map[string]keyspaceDB{"db0": {}}}
+	for name, db := range info.Keyspace() {
+		stats.Databases[name] = keyspaceDB{Keys: db.Keys, Expires: db.Expires, AvgTTL: db.AvgTTL}
+		stats.Keys += db.Keys
+		stats.Expires += db.Expires
+	}
+	return stats
+}
+
+// keyspaceCheck records the keyspace in the report, and with MAX_KEYS set
+// fails readiness with TOO_MANY_KEYS once the node holds more keys than the
+// plan allows, so the rebalancer has to move some away. Only this check
+// writes Keyspace.
+func keyspaceCheck(report *healthReport, info *redisinfo.Info, maxKeys int64) check {
+	return check{name: "keyspace", omitEmpty: true, run: func(context.Context) (string, string, error) {
+		report.Keyspace = nodeKeyspace(info)
+		if maxKeys <= 0 {
+			return "", "", nil
+		}
+
+		detail := fmt.Sprintf("keys=%d max=%d", report.Keyspace.Keys, maxKeys)
+		if report.Keyspace.Keys > maxKeys {
+			return "TOO_MANY_KEYS " + detail, detail, nil
+		}
+		return "", detail, nil
+	}}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// latencyEvent is one entry of LATENCY LATEST
+type latencyEvent struct {
+	Name     string
+	At       time.Time
+	LatestMs int64
+	MaxMs    int64
+}
+
+// parseLatencyLatest parses the LATENCY LATEST reply, an array of
+// [event, unix time, latest ms, max ms] arrays. Malformed entries are
+// skipped.
+func parseLatencyLatest(reply interface{}) []latencyEvent {
+	list, ok := reply.([]interface{})
+	if !ok {
+		return nil
+	}
+
+	var events []latencyEvent
+	for _, item := range list {
+		fields, ok := item.([]interface{})
+		if !ok || len(fields) < 4 {
+			continue
+		}
+
+		name, ok := fields[0].(string)
+		at, okAt := fields[1].(int64)
+		latest, okLatest := fields[2].(int64)
+		maxMs, okMax := fields[3].(int64)
+		if !ok || !okAt || !okLatest || !okMax {
+			continue
+		}
+		events = append(events, latencyEvent{Name: name, At: time.Unix(at, 0), LatestMs: latest, MaxMs: maxMs})
+	}
+	return events
+}
+
+// checkLatencyEvents reports the fork, AOF fsync and command spikes the
+// latency monitor recorded within LATENCY_EVENT_WINDOW_SECONDS. A spike of
+// one of LATENCY_FAIL_EVENTS above LATENCY_FAIL_MS fails readiness. The
+// check is skipped when latency-monitor-threshold is 0 on the node.
+func checkLatencyEvents(probeCtx context.Context, cfg *Config) (string, string, error) {
+	reply, err := nodeClient(probeCtx).Do(probeCtx, "LATENCY", "LATEST").Result()
+	if err != nil {
+		return "", "", err
+	}
+
+	window := time.Duration(cfg.LatencyEventWindowSeconds) * time.Second
+	var recent []latencyEvent
+	for _, event := range parseLatencyLatest(reply) {
+		if time.Since(event.At) <= window {
+			recent = append(recent, event)
+		}
+	}
+
+	if len(recent) == 0 {
+		// Nothing is recorded with the monitor off, tell the two apart
+		threshold, err := nodeClient(probeCtx).ConfigGet(probeCtx, "latency-monitor-threshold").Result()
+		if err != nil {
+			return "", "", err
+		}
+		if value := threshold["latency-monitor-threshold"]; value == "" || value == "0" {
+			return "", "skipped, latency monitor disabled", nil
+		}
+		return "", fmt.Sprintf("no events within %s", window), nil
+	}
+
+	var entries, spikes []string
+	for _, event := range recent {
+		entry := fmt.Sprintf("%s latest_ms=%d max_ms=%d age=%ds", event.Name, event.LatestMs, event.MaxMs, int64(time.Since(event.At).Seconds()))
+		entries = append(entries, entry)
+		if cfg.LatencyFailMs > 0 && event.LatestMs > cfg.LatencyFailMs && slices.Contains(cfg.LatencyFailEvents, event.Name) {
+			spikes = append(spikes, fmt.Sprintf("%s=%dms", event.Name, event.LatestMs))
+		}
+	}
+
+	detail := strings.Join(entries, ", ")
+	if len(spikes) > 0 {
+		return "LATENCY_SPIKE " + strings.Join(spikes, ","), fmt.Sprintf("%s max=%dms", detail, cfg.LatencyFailMs), nil
+	}
+	return "", "warning: " + detail, nil
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseLatencyLatest(t *testing.T) {
+	reply := []interface{}{
+		[]interface{}{"fork", int64(1700000000), int64(120), int64(250)},
+		[]interface{}{"command", int64(1700000100), int64(15), int64(15)},
+		// Newer servers may append fields
+		[]interface{}{"aof-fsync-always", int64(1700000200), int64(30), int64(40), "extra"},
+		[]interface{}{"short", int64(1700000000), int64(1)},
+		[]interface{}{int64(1), int64(1700000000), int64(1), int64(1)},
+		[]interface{}{"bad-time", "yesterday", int64(1), int64(1)},
+		"not an entry",
+	}
+	want := []latencyEvent{
+		{Name: "fork", At: time.Unix(1700000000, 0), LatestMs: 120, MaxMs: 250},
+		{Name: "command", At: time.Unix(1700000100, 0), LatestMs: 15, MaxMs: 15},
+		{Name: "aof-fsync-always", At: time.Unix(1700000200, 0), LatestMs: 30, MaxMs: 40},
+	}
+	if got := parseLatencyLatest(reply); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseLatencyLatest = %+v, want %+v", got, want)
+	}
+
+	for _, reply := range []interface{}{nil, []interface{}{}, "OK"} {
+		if got := parseLatencyLatest(reply); len(got) != 0 {
+			t.Errorf("parseLatencyLatest(%v) = %+v, want no events", reply, got)
+		}
+	}
+}
+
+func TestCheckLatencyEvents(t *testing.T) {
+	recent := time.Now().Add(-10 * time.Second).Unix()
+	old := time.Now().Add(-time.Hour).Unix()
+	tests := []struct {
+		name      string
+		env       map[string]string
+		events    []any
+		threshold string
+		reason    string
+		detail    string
+	}{
+		{
+			name:      "monitor disabled",
+			threshold: "0",
+			detail:    "skipped, latency monitor disabled",
+		},
+		{
+			name:      "no recent events",
+			events:    []any{[]any{"fork", old, int64(500), int64(500)}},
+			threshold: "100",
+			detail:    "no events within 5m0s",
+		},
+		{
+			name:   "recent event",
+			events: []any{[]any{"fork", recent, int64(120), int64(250)}, []any{"command", old, int64(900), int64(900)}},
+			detail: "warning: fork latest_ms=120 max_ms=250 age=10s",
+		},
+		{
+			name:   "spike",
+			env:    map[string]string{"LATENCY_FAIL_MS": "100"},
+			events: []any{[]any{"fork", recent, int64(120), int64(250)}, []any{"command", recent, int64(300), int64(300)}},
+			reason: "LATENCY_SPIKE fork=120ms",
+			detail: "fork latest_ms=120 max_ms=250 age=10s, command latest_ms=300 max_ms=300 age=10s max=100ms",
+		},
+		{
+			name:   "below the spike threshold",
+			env:    map[string]string{"LATENCY_FAIL_MS": "200"},
+			events: []any{[]any{"fork", recent, int64(120), int64(250)}},
+			detail: "warning: fork latest_ms=120 max_ms=250 age=10s",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := newFakeNode(masterInfo)
+			node.reply("LATENCY LATEST", tt.events)
+			node.reply("CONFIG GET", []string{"latency-monitor-threshold", tt.threshold})
+			cfg := testConfig(t, tt.env)
+			p := newTestProbes(t, cfg, node)
+
+			reason, detail, err := checkLatencyEvents(withProbes(context.Background(), p), cfg)
+			if err != nil || reason != tt.reason || detail != tt.detail {
+				t.Errorf("checkLatencyEvents = %q, %q, %v, want %q, %q", reason, detail, err, tt.reason, tt.detail)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+)
+
+type listenerKey struct{}
+
+// nodeListener is one of the listeners of a node serving both plaintext and
+// TLS, e.g. during a TLS migration
+type nodeListener struct {
+	name   string
+	reason string
+	client redis.UniversalClient
+}
+
+// newTLSListenerClient returns the client for NODE_TLS_PORT, sharing the
+// credentials of the plaintext one
+func newTLSListenerClient(cfg *Config, credentials *nodeCredentials) (*redis.Client, error) {
+	tlsCfg := *cfg
+	tlsCfg.TLS, tlsCfg.NodePort = true, cfg.NodeTLSPort
+	options, err := clientOptions(&tlsCfg, credentials)
+	if err != nil {
+		return nil, err
+	}
+	// The handshake isn't bound by the context, a hanging listener would keep
+	// the probe for HEALTH_CHECK_TIMEOUT_MS
+	options.DialTimeout = cfg.ListenerTimeout
+	options.ReadTimeout = cfg.ListenerTimeout
+	options.WriteTimeout = cfg.ListenerTimeout
+	client := redis.NewClient(options)
+	client.AddHook(timingHook{})
+	return client, nil
+}
+
+// checkListeners PINGs the plaintext and TLS listeners concurrently, each
+// within LISTENER_TIMEOUT_MS so a hanging one leaves the rest of the probe
+// budget to the other. A broken listener fails readiness with
+// PLAINTEXT_LISTENER_DOWN: <error> or TLS_LISTENER_DOWN: <error>, the remaining
+// checks run once over the first listener that answered. It returns false
+// when neither did.
+func checkListeners(probeCtx context.Context, report *healthReport, cfg *Config) (context.Context, bool) {
+	listeners := []nodeListener{
+		{name: "plaintext", reason: "PLAINTEXT_LISTENER_DOWN", client: probesOf(probeCtx).client},
+		{name: "tls", reason: "TLS_LISTENER_DOWN", client: probesOf(probeCtx).tlsListener},
+	}
+
+	type pong struct {
+		index int
+		err   error
+	}
+	pongs := make(chan pong, len(listeners))
+	for i, listener := range listeners {
+		i, listener := i, listener
+		go func() {
+			listenerCtx, cancel := context.WithTimeout(probeCtx, cfg.ListenerTimeout)
+			defer cancel()
+			pongs <- pong{index: i, err: listener.client.Ping(listenerCtx).Err()}
+		}()
+	}
+
+	errs := make([]error, len(listeners))
+	var first redis.UniversalClient
+	for range listeners {
+		pong := <-pongs
+		errs[pong.index] = pong.err
+		if pong.err == nil && first == nil {
+			first = listeners[pong.index].client
+		}
+	}
+
+	if first == nil {
+		report.failErr(probeCtx, "listeners", errs[0])
+		return probeCtx, false
+	}
+
+	var up []string
+	for i, listener := range listeners {
+		if errs[i] != nil {
+			handleRedisError(probeCtx, errs[i])
+			if report.ok() {
+				report.err = errs[i]
+			}
+			report.fail(http.StatusServiceUnavailable, listener.reason+": "+errs[i].Error(), "listeners", listener.name+": "+errs[i].Error())
+			continue
+		}
+		up = append(up, listener.name)
+	}
+	if len(up) == len(listeners) {
+		report.pass("listeners", strings.Join(up, ",")+" up")
+	}
+	return context.WithValue(probeCtx, listenerKey{}, first), true
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func TestListenerDown(t *testing.T) {
+	cfg := testConfig(t, map[string]string{"CHECK_BOTH_LISTENERS": "true", "NODE_TLS_PORT": "6380"})
+	p := newTestProbes(t, cfg, newFakeNode(masterInfo))
+	p.tlsListener = redis.NewClient(&redis.Options{
+		Dialer: func(ctx context.Context, network string, addr string) (net.Conn, error) {
+			return nil, errors.New("connection refused")
+		},
+	})
+
+	checks := readinessChecks(t, cfg, p, http.StatusServiceUnavailable)
+	if listeners := checks["listeners"]; listeners.ReasonCode != "TLS_LISTENER_DOWN" || listeners.Detail != "tls: connection refused" {
+		t.Errorf("listeners check = %+v, want TLS_LISTENER_DOWN", listeners)
+	}
+
+	w := serve(t, cfg, p, http.MethodGet, "/readyz?nocache=1", nil)
+	if body := w.Body.String(); !strings.HasPrefix(body, "TLS_LISTENER_DOWN: connection refused") {
+		t.Errorf("GET /readyz = %d %q, want TLS_LISTENER_DOWN: <error>", w.Code, body)
+	}
+	if reason := w.Header().Get("X-Health-Reason"); reason != "TLS_LISTENER_DOWN" {
+		t.Errorf("X-Health-Reason = %q, want TLS_LISTENER_DOWN", reason)
+	}
+}
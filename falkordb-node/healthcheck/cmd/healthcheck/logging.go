@@ -0,0 +1,34 @@
+package main
+
+import (
+	"io"
+	"log/slog"
+)
+
+// logLevels maps LOG_LEVEL values to slog levels. Redis-style level names are
+// accepted as well since the node container shares its LOG_LEVEL with
+// redis-server.
+var logLevels = map[string]slog.Level{
+	"debug":   slog.LevelDebug,
+	"verbose": slog.LevelDebug,
+	"info":    slog.LevelInfo,
+	"notice":  slog.LevelInfo,
+	"warn":    slog.LevelWarn,
+	"warning": slog.LevelWarn,
+	"error":   slog.LevelError,
+}
+
+// setupLogger configures the default slog logger from LOG_LEVEL and
+// LOG_FORMAT.
+func setupLogger(w io.Writer, cfg *Config) {
+	options := &slog.HandlerOptions{Level: logLevels[cfg.LogLevel]}
+
+	var handler slog.Handler
+	if cfg.LogFormat == "json" {
+		handler = slog.NewJSONHandler(w, options)
+	} else {
+		handler = slog.NewTextHandler(w, options)
+	}
+
+	slog.SetDefault(slog.New(handler))
+}
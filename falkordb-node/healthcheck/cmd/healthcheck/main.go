@@ -0,0 +1,1014 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"falkordb.cloud/main/internal/redisinfo"
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/sync/singleflight"
+)
+
+// redisOptions returns the connection options for the probed node, over the
+// unix socket from NODE_SOCKET when set, or TCP to NODE_HOST otherwise.
+func redisOptions(cfg *Config) (*redis.Options, error) {
+	if cfg.NodeSocket != "" {
+		return &redis.Options{Network: "unix", Addr: cfg.NodeSocket}, nil
+	}
+
+	redisURL := fmt.Sprintf("redis://%s", net.JoinHostPort(cfg.NodeHost, cfg.NodePort))
+
+	if cfg.TLS {
+		redisURL = fmt.Sprintf("rediss://%s", net.JoinHostPort(cfg.NodeHost, cfg.NodePort))
+	}
+
+	return redis.ParseURL(redisURL)
+}
+
+// newRedisClient returns the client shared by every probe of the node, so
+// we don't open a new connection per request, go-redis reconnecting pooled
+// connections transparently when they die. With CHECK_BOTH_LISTENERS it also
+// returns the client of the TLS listener, and with CIRCUIT_FAILURE_THRESHOLD
+// the breaker in front of the node.
+func newRedisClient(cfg *Config, credentials *nodeCredentials) (*redis.Client, *redis.Client, *circuitBreaker, error) {
+	// NODE_PORT is the plaintext listener when both are probed
+	plaintextCfg := cfg
+	var tlsListener *redis.Client
+	if cfg.CheckBothListeners {
+		copied := *cfg
+		copied.TLS = false
+		plaintextCfg = &copied
+
+		var err error
+		if tlsListener, err = newTLSListenerClient(cfg, credentials); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+
+	options, err := clientOptions(plaintextCfg, credentials)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	client := redis.NewClient(options)
+	client.AddHook(timingHook{})
+	var breaker *circuitBreaker
+	if cfg.CircuitThreshold > 0 {
+		breaker = newCircuitBreaker(cfg)
+		client.AddHook(circuitHook{breaker: breaker})
+	}
+	return client, tlsListener, breaker, nil
+}
+
+// clientOptions returns the options of a client probing the node of cfg
+// with credentials
+func clientOptions(cfg *Config, credentials *nodeCredentials) (*redis.Options, error) {
+	options, err := redisOptions(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	// Resolved on every new connection so a reloaded password is picked up
+	options.CredentialsProvider = credentials.get
+	// Tells our connections apart in CLIENT LIST
+	options.ClientName = healthCheckClientName
+
+	// Enough connections for the readiness checks that run concurrently
+	options.PoolSize = maxConcurrentChecks
+	options.MinIdleConns = 1
+	options.DialTimeout = cfg.ProbeTimeout
+	options.ReadTimeout = cfg.ProbeTimeout
+	options.WriteTimeout = cfg.ProbeTimeout
+	// Retries are handled by withRetry so they stay within the probe budget
+	options.MaxRetries = -1
+	// Commands give up at the probe deadline rather than ReadTimeout
+	options.ContextTimeoutEnabled = true
+	// go-redis tries RESP3 first by default
+	options.Protocol = int(cfg.RedisProtocol)
+
+	if options.TLSConfig != nil {
+		tlsConfig, err := redisTLSConfig(cfg, options.TLSConfig.ServerName)
+		if err != nil {
+			return nil, err
+		}
+		options.TLSConfig = tlsConfig
+	}
+	return options, nil
+}
+
+// setupProbes creates the clients of the node from the configuration and
+// the probes using them.
+func setupProbes(cfg *Config) (*probes, error) {
+	credentials := newNodeCredentials(cfg)
+	client, tlsListener, breaker, err := newRedisClient(cfg, credentials)
+	if err != nil {
+		return nil, fmt.Errorf("error configuring redis client: %w", err)
+	}
+
+	p := newProbes(cfg, credentials, client)
+	p.tlsListener, p.circuit = tlsListener, breaker
+	updateCredentialMetric(credentials.credential())
+	loadDrainState(cfg, p)
+	return p, setupTargets(cfg, p)
+}
+
+// newHealthCheckHandler builds the HTTP handler serving every endpoint with
+// the probes of p, so the handler can be served against any node.
+func newHealthCheckHandler(cfg *Config, p *probes) http.Handler {
+	mux := http.NewServeMux()
+	var endpoints []string
+	handle := func(path string, handler http.Handler) {
+		mux.Handle(path, handler)
+		endpoints = append(endpoints, path)
+	}
+
+	// /healthcheck stays as an alias of /readyz for existing templates, unless
+	// it aggregates TARGETS
+	if len(cfg.Targets) > 0 {
+		handle("/healthcheck", targetsHandler(cfg))
+		for _, target := range cfg.Targets {
+			handle("/healthcheck/"+target.Name, targetHandler(cfg, target))
+		}
+	} else {
+		handle("/healthcheck", readyzHandler(cfg))
+	}
+	handle("/readyz", readyzHandler(cfg))
+	handle("/livez", livezHandler(cfg))
+	handle("/startupz", startupzHandler(cfg))
+	handle("/metrics", metricsHandler)
+	handle("/version", http.HandlerFunc(versionHandler))
+	handle("/healthz/stream", streamHandler(cfg))
+
+	// The probes stay open for the kubelet, everything changing state or
+	// exposing internals needs HEALTH_ADMIN_TOKEN and is off without it
+	if cfg.AdminToken != "" {
+		admin := func(path string, handler http.Handler) {
+			handle(path, requireAdmin(cfg, handler))
+		}
+
+		admin("/drain", drainHandler(cfg, true))
+		admin("/undrain", drainHandler(cfg, false))
+		admin("/graphs", graphsHandler(cfg))
+		admin("/topology", topologyHandler(cfg))
+		admin("/sync-progress", syncProgressHandler(cfg))
+		admin("/node-info", nodeInfoHandler(cfg))
+		admin("/failover-ready", failoverReadyHandler(cfg))
+		if len(cfg.ClusterHealthNodes) > 0 {
+			admin("/clusterhealth", clusterHealthHandler(cfg))
+		}
+		if cfg.FaultInjection {
+			admin("/fault/unhealthy", faultHandler(cfg, faultUnhealthy))
+			admin("/fault/timeout", faultHandler(cfg, faultTimeout))
+			admin("/fault/clear", faultHandler(cfg, faultNone))
+		}
+		if cfg.DebugEndpoints {
+			admin("/debug/info", http.HandlerFunc(debugInfoHandler))
+			admin("/debug/config", debugConfigHandler(cfg))
+			admin("/debug/preflight", http.HandlerFunc(debugPreflightHandler))
+			admin("/debug/connections", http.HandlerFunc(debugConnectionsHandler))
+			admin("/debug/capabilities", debugCapabilitiesHandler(cfg))
+			admin("/healthz/history", http.HandlerFunc(historyHandler))
+
+			// Profiling stays off the pod network when given its own port
+			if cfg.DebugPort == "" {
+				profiling := http.NewServeMux()
+				registerProfilingHandlers(profiling)
+				admin("/debug/pprof/", profiling)
+				admin("/debug/vars", profiling)
+			}
+		}
+	}
+	mux.Handle("/", indexHandler(endpoints))
+
+	var handler http.Handler = shutdownGuard(mux)
+	if cfg.RateLimitRPS > 0 {
+		handler = rateLimit(cfg, newRateLimiter(cfg), handler)
+	}
+	handler = requestLogger(recoverPanics(httpDefaults(handler)))
+	// Rejected clients are logged on their own, rate limited
+	if len(cfg.AllowedCIDRs) > 0 {
+		handler = allowCIDRs(cfg, handler)
+	}
+	return withProbesHandler(p, handler)
+}
+
+// withProbesHandler hands p to everything serving the request
+func withProbesHandler(p *probes, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(withProbes(r.Context(), p)))
+	})
+}
+
+func StartHealthCheckServer(cfg *Config) {
+
+	PORT := cfg.Port
+	if cfg.SocketOnly {
+		PORT = ""
+	}
+
+	p, err := setupProbes(cfg)
+	if err != nil {
+		slog.Error("invalid configuration", "error", err)
+		os.Exit(1)
+	}
+	defer p.close()
+
+	// Everything started below lives as long as the server
+	serverCtx, cancelServer := context.WithCancel(context.Background())
+	defer cancelServer()
+
+	stopTelemetry, err := startTelemetry(serverCtx, cfg)
+	if err != nil {
+		slog.Error("error setting up telemetry", "error", err)
+		os.Exit(1)
+	}
+	defer func() {
+		flushCtx, cancel := context.WithTimeout(context.WithoutCancel(serverCtx), 5*time.Second)
+		defer cancel()
+		stopTelemetry(flushCtx)
+	}()
+
+	handler := instrumentHandler(cfg, newHealthCheckHandler(cfg, p))
+	debugEnabled := cfg.DebugEndpoints && cfg.AdminToken != ""
+	if debugEnabled && cfg.DebugPort != "" {
+		stopDebug, err := startDebugServer(cfg)
+		if err != nil {
+			slog.Error("error starting debug server", "port", cfg.DebugPort, "error", err)
+			os.Exit(1)
+		}
+		defer stopDebug()
+	}
+	slog.Info("readiness checks", "topology", cfg.Topology, "checks", cfg.activeChecks())
+	slog.Info("debug endpoints", "enabled", debugEnabled, "debug_port", cfg.DebugPort)
+	if cfg.FaultInjection {
+		slog.Warn("fault injection endpoints are enabled, never set ENABLE_FAULT_INJECTION in production")
+	}
+	if (cfg.DebugEndpoints || cfg.FaultInjection) && cfg.AdminToken == "" {
+		slog.Warn("debug and fault injection endpoints are disabled without HEALTH_ADMIN_TOKEN")
+	}
+
+	tlsConfig, err := serverTLSConfig(cfg)
+	if err != nil {
+		slog.Error("invalid configuration", "error", err)
+		os.Exit(1)
+	}
+
+	// Before listening, a misconfiguration shows before the pod is started
+	runPreflight(serverCtx, cfg, p)
+	detectCapabilities(serverCtx, cfg, p)
+
+	server := &http.Server{
+		TLSConfig:         tlsConfig,
+		Handler:           handler,
+		ReadHeaderTimeout: 5 * time.Second,
+		MaxHeaderBytes:    cfg.MaxHeaderBytes,
+		WriteTimeout:      p.probeTimeout + 5*time.Second,
+		IdleTimeout:       60 * time.Second,
+	}
+
+	grace := cfg.ShutdownGrace
+
+	stopGRPC, err := startGRPCHealthServer(serverCtx, cfg, p)
+	if err != nil {
+		slog.Error("invalid configuration", "error", err)
+		os.Exit(1)
+	}
+	defer stopGRPC()
+
+	stopWebhook := startWebhookWatcher(serverCtx, cfg, p)
+	defer stopWebhook()
+
+	stopEvents := startEventEmitter(serverCtx, cfg, p)
+	defer stopEvents()
+
+	stopHeartbeat := startHeartbeat(serverCtx, cfg, p)
+	defer stopHeartbeat()
+
+	stopPublisher := startStatusPublisher(serverCtx, cfg, p)
+	defer stopPublisher()
+
+	stopPoller := startHealthPoller(serverCtx, cfg, p)
+	defer stopPoller()
+
+	sigCtx, stop := signal.NotifyContext(serverCtx, syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// Listen on every address before serving so a bad one fails startup
+	var listeners []serverListener
+	for _, address := range listenAddresses(cfg) {
+		listener, err := net.Listen("tcp", address.addr)
+		if err != nil {
+			slog.Error("error starting server", "address", address.addr, "tls", address.tls, "error", err)
+			p.close()
+			os.Exit(1)
+		}
+		listeners = append(listeners, serverListener{Listener: listener, tls: address.tls})
+	}
+	if cfg.SocketPath != "" {
+		listener, err := listenUnix(cfg)
+		if err != nil {
+			slog.Error("error starting server", "socket", cfg.SocketPath, "error", err)
+			p.close()
+			os.Exit(1)
+		}
+		listeners = append(listeners, serverListener{Listener: listener})
+	}
+
+	info := currentBuildInfo()
+	slog.Info("starting healthcheck server", "port", PORT, "tls_port", cfg.ServerTLSPort, "bind", cfg.BindAddrs, "socket", cfg.SocketPath, "tls", tlsConfig != nil,
+		"version", info.Version, "commit", info.Commit, "build_date", info.BuildDate, "falkordb_version", info.FalkorDBVersion)
+
+	// Both kinds of listeners share the server, so shutdown drains them all
+	serverErr := make(chan error, len(listeners))
+	for _, listener := range listeners {
+		go func(listener serverListener) {
+			if listener.tls {
+				serverErr <- server.ServeTLS(listener, "", "")
+			} else {
+				serverErr <- server.Serve(listener)
+			}
+		}(listener)
+	}
+
+	select {
+	case err = <-serverErr:
+		if !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("error starting server", "error", err)
+			p.close()
+			os.Exit(1)
+		}
+	case <-sigCtx.Done():
+		stop()
+		// Before draining, kubelet may kill the container past its own grace
+		writeShutdownTerminationLog(cfg)
+		shutdown(serverCtx, server, p, grace)
+	}
+
+	slog.Info("server closed")
+}
+
+type listenAddress struct {
+	addr string
+	tls  bool
+}
+
+type serverListener struct {
+	net.Listener
+	tls bool
+}
+
+// listenAddresses returns the addresses the healthcheck listens on, all
+// interfaces unless HEALTH_CHECK_BIND_ADDR is set. With HEALTH_CHECK_TLS_PORT
+// plaintext stays on HEALTH_CHECK_PORT, unless disabled, and TLS is served on
+// its own port; otherwise HEALTH_CHECK_TLS switches HEALTH_CHECK_PORT to TLS.
+// There is none when only HEALTH_CHECK_SOCKET_PATH is set.
+func listenAddresses(cfg *Config) []listenAddress {
+	var addresses []listenAddress
+	if cfg.SocketOnly {
+		return addresses
+	}
+	add := func(port string, tls bool) {
+		if len(cfg.BindAddrs) == 0 {
+			addresses = append(addresses, listenAddress{addr: ":" + port, tls: tls})
+			return
+		}
+		for _, addr := range cfg.BindAddrs {
+			addresses = append(addresses, listenAddress{addr: net.JoinHostPort(addr, port), tls: tls})
+		}
+	}
+
+	if cfg.ServerTLSPort == "" {
+		add(cfg.Port, cfg.ServerTLS)
+		return addresses
+	}
+
+	if cfg.HTTPEnabled {
+		add(cfg.Port, false)
+	}
+	add(cfg.ServerTLSPort, true)
+	return addresses
+}
+
+// shutdown spends the first half of the grace period answering new probes
+// with SHUTTING_DOWN, so orchestration can tell a deliberate stop from a
+// crash, and the second half draining in-flight requests.
+func shutdown(serverCtx context.Context, server *http.Server, p *probes, grace time.Duration) {
+	slog.Info("shutting down healthcheck server", "grace", grace)
+
+	shuttingDown.Store(true)
+	server.SetKeepAlivesEnabled(false)
+	p.streams.closeAll()
+	time.Sleep(grace / 2)
+
+	shutdownCtx, cancel := context.WithTimeout(serverCtx, grace/2)
+	defer cancel()
+
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		slog.Warn("error draining healthcheck server", "error", err)
+	}
+}
+
+var shuttingDown atomic.Bool
+
+func shutdownGuard(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if shuttingDown.Load() {
+			setNotReadyHeaders(w, "SHUTTING_DOWN", nil)
+			writeError(w, r, http.StatusServiceUnavailable, "SHUTTING_DOWN", "")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// probeContext returns the context bounding the Redis calls of one probe
+func probeContext(r *http.Request) (context.Context, context.CancelFunc) {
+	// Derived from the request so a caller giving up aborts the Redis calls,
+	// except for the probes shared by evaluateRequest
+	probeCtx := r.Context()
+	if r.URL.Query().Get("nocache") == "1" {
+		probeCtx = withNoCache(probeCtx)
+	}
+	return context.WithTimeout(probeCtx, probesOf(probeCtx).probeTimeout)
+}
+
+// probeFlight collapses concurrent evaluations of the same probe, from the
+// kubelet, the agent and monitoring polling at once, into one
+var probeFlight singleflight.Group
+
+// evaluateRequest runs evaluateRecorded for a probe request, sharing the
+// evaluation with the concurrent requests for the same endpoint and
+// parameters. It returns early with a nil report when the caller
+// disconnects. The shared evaluation is detached from the request that
+// started it so the others still get a report, and ends by the probe
+// deadline.
+func evaluateRequest(r *http.Request, source string, evaluate func(context.Context, *Config) *healthReport, probeCtx context.Context, cfg *Config) *healthReport {
+	query := r.URL.Query()
+	key := strings.Join([]string{source, query.Get("target"), query.Get("expect_role"), query.Get("nocache")}, "|")
+
+	// Only set when this request runs the evaluation, before its result is
+	// sent on done
+	started := false
+	done := probeFlight.DoChan(key, func() (interface{}, error) {
+		started = true
+		sharedCtx, cancel := context.WithTimeout(context.WithoutCancel(probeCtx), probesOf(probeCtx).probeTimeout)
+		defer cancel()
+		return evaluateRecorded(source, evaluate, sharedCtx, cfg), nil
+	})
+
+	select {
+	case result := <-done:
+		if !started {
+			sharedEvaluationCounter.Inc()
+		}
+		return result.Val.(*healthReport)
+	case <-r.Context().Done():
+		return nil
+	}
+}
+
+// livezHandler only verifies the Redis process answers PING, regardless of
+// role or sync state, so a syncing replica is never restarted.
+func livezHandler(cfg *Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if servePolled(w, r, cfg, "livez") {
+			return
+		}
+
+		probeCtx, cancel := probeContext(r)
+		defer cancel()
+
+		probeCtx, ok := withTarget(probeCtx, r, cfg)
+		if !ok {
+			writeInvalidTarget(w, r)
+			return
+		}
+
+		writeReport(w, r, evaluateRequest(r, "livez", evaluateLiveness, probeCtx, cfg))
+	}
+}
+
+// startupzHandler passes once the node is reachable and has finished loading
+// its dataset, so Kubernetes can use a generous startup probe for big RDB/AOF.
+func startupzHandler(cfg *Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if servePolled(w, r, cfg, "startupz") {
+			return
+		}
+
+		probeCtx, cancel := probeContext(r)
+		defer cancel()
+
+		probeCtx, ok := withTarget(probeCtx, r, cfg)
+		if !ok {
+			writeInvalidTarget(w, r)
+			return
+		}
+
+		writeReport(w, r, evaluateRequest(r, "startupz", evaluateStartup, probeCtx, cfg))
+	}
+}
+
+// readyzHandler checks the node role and, for replicas, the sync state.
+func readyzHandler(cfg *Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if servePolled(w, r, cfg, "readyz") {
+			return
+		}
+
+		probeCtx, cancel := probeContext(r)
+		defer cancel()
+
+		probeCtx, ok := withTarget(probeCtx, r, cfg)
+		if !ok {
+			writeInvalidTarget(w, r)
+			return
+		}
+
+		probeCtx, ok = withExpectedRole(probeCtx, r, cfg.ExpectedRole)
+		if !ok {
+			writeError(w, r, http.StatusBadRequest, "INVALID_ROLE", "accepted roles: "+strings.Join(acceptedRoles, ", "))
+			return
+		}
+
+		report := evaluateRequest(r, "readyz", evaluateReadiness, probeCtx, cfg)
+		// The poller keeps the status file when enabled
+		if query := r.URL.Query(); cfg.PollInterval <= 0 && query.Get("target") == "" && query.Get("expect_role") == "" {
+			writeStatusFile(cfg, report)
+		}
+		writeReport(w, r, report)
+	}
+}
+
+func evaluateLiveness(probeCtx context.Context, cfg *Config) *healthReport {
+	report := newHealthReport()
+
+	err := withRetry(probeCtx, func() error {
+		return nodeClient(probeCtx).Ping(probeCtx).Err()
+	})
+
+	// A node rejecting our credentials is up, restarting it won't fix them
+	if err != nil && cfg.FailOpenOnAuthError && (isAuthError(err) || isNoPermError(err)) {
+		handleRedisError(probeCtx, err)
+		report.pass("ping", "AUTH_FAILED ignored")
+		return report
+	}
+
+	// Restarting a node loading its dataset or running a long operation only
+	// starts it over
+	if err != nil {
+		if _, reason := classifyRedisError(err); reason == "STARTING" || reason == "BUSY" {
+			report.pass("ping", reason+" ignored")
+			return report
+		}
+	}
+
+	if err != nil {
+		report.failErr(probeCtx, "ping", err)
+		return report
+	}
+
+	report.pass("ping", "")
+	return report
+}
+
+func evaluateStartup(probeCtx context.Context, cfg *Config) *healthReport {
+	report := newHealthReport()
+
+	info, err := fetchInfo(probeCtx)
+
+	if err != nil {
+		report.failErr(probeCtx, "info", err)
+		return report
+	}
+	report.pass("info", "")
+
+	if cfg.checkEnabled("loading") {
+		if loading, body := loadingStatus(info); loading {
+			report.fail(http.StatusServiceUnavailable, body, "loading", body)
+			return report
+		}
+		report.pass("loading", "")
+	}
+
+	return report
+}
+
+func evaluateReadiness(probeCtx context.Context, cfg *Config) *healthReport {
+	report := newHealthReport()
+	p := probesOf(probeCtx)
+
+	// A drained node is out of rotation whatever its state, Redis isn't asked
+	if drainEnabled(cfg) {
+		if p.draining.Load() {
+			report.fail(http.StatusServiceUnavailable, "DRAINING", "drain", "DRAINING")
+			return report
+		}
+		report.pass("drain", "")
+	}
+
+	if !injectFault(probeCtx, report) {
+		return report
+	}
+
+	if cfg.BootstrapGrace > 0 {
+		report.Phase = p.credentials.bootstrapPhase()
+	}
+
+	if cfg.CheckBothListeners && probeTarget(probeCtx) == "" {
+		var ok bool
+		if probeCtx, ok = checkListeners(probeCtx, report, cfg); !ok {
+			return report
+		}
+	}
+
+	// Every INFO based check shares this single snapshot
+	infoCtx, infoTiming := withCheckTiming(probeCtx)
+	infoStart := time.Now()
+	info, err := fetchInfo(infoCtx)
+
+	if err != nil {
+		report.failErr(probeCtx, "info", err)
+		report.timeLastCheck(time.Since(infoStart), infoTiming)
+		return report
+	}
+	report.pass("info", "")
+	report.timeLastCheck(time.Since(infoStart), infoTiming)
+	checkBootstrapAuth(probeCtx, report)
+	// Tells whether the node moved to the rotated admin password yet
+	if probeTarget(probeCtx) == "" {
+		report.Credential = p.credentials.credential()
+	}
+
+	if cfg.checkEnabled("loading") {
+		if loading, body := loadingStatus(info); loading {
+			report.fail(http.StatusServiceUnavailable, body, "loading", body)
+			return report
+		}
+		report.pass("loading", "")
+	}
+
+	if isSentinel(info, cfg.SentinelMode) {
+		report.Role = "sentinel"
+		report.RoleClass, _ = classifyRole(probeCtx, "sentinel")
+		updateRoleClassMetric(report.RoleClass)
+		if cfg.checkEnabled("role") && !checkExpectedRole(probeCtx, report, "sentinel") {
+			return report
+		}
+
+		runChecks(probeCtx, report, enabledChecks(cfg, []check{
+			pingLatencyCheck(report, cfg.MaxPingLatencyMs),
+			{name: "protocol", omitEmpty: true, run: func(ctx context.Context) (string, string, error) {
+				return checkProtocol(ctx, cfg)
+			}},
+			{name: "sentinel", run: func(ctx context.Context) (string, string, error) {
+				reason, err := checkSentinel(ctx, cfg)
+				return reason, "", err
+			}},
+			{name: "quorum", run: func(ctx context.Context) (string, string, error) {
+				reason, err := checkQuorum(ctx, cfg.MasterName)
+				return reason, cfg.MasterName, err
+			}},
+			{name: "sentinel_peers", run: func(ctx context.Context) (string, string, error) {
+				return checkSentinelPeers(ctx, cfg.MasterName)
+			}},
+			e2eSentinelCheck(report, cfg),
+		}))
+		return report
+	}
+
+	nodeRole, role, err := info.NodeRole()
+	if err != nil {
+		report.fail(http.StatusServiceUnavailable, "ROLE_NOT_FOUND", "role", err.Error())
+		return report
+	}
+	report.Role = role
+	report.FragRatio, _ = info.Float("mem_fragmentation_ratio")
+	if lastIO, err := info.Int("master_last_io_seconds_ago"); err == nil {
+		report.MasterLastIO = &lastIO
+	}
+
+	if cfg.checkEnabled("role") && !checkExpectedRole(probeCtx, report, role) {
+		return report
+	}
+
+	// The checks below only make sense on a master or a replica
+	if nodeRole != redisinfo.RoleMaster && nodeRole != redisinfo.RoleReplica {
+		report.fail(http.StatusServiceUnavailable, "UNKNOWN_ROLE value="+role, "role", role)
+		return report
+	}
+	if cfg.checkEnabled("role") {
+		report.pass("role", role)
+	}
+
+	report.RoleClass, report.Promotable = classifyRole(probeCtx, role)
+	updateRoleClassMetric(report.RoleClass)
+
+	checks := []check{
+		pingLatencyCheck(report, cfg.MaxPingLatencyMs),
+		{name: "protocol", omitEmpty: true, run: func(ctx context.Context) (string, string, error) {
+			return checkProtocol(ctx, cfg)
+		}},
+		{name: "module", run: func(ctx context.Context) (string, string, error) {
+			reason, err := checkModule(ctx)
+			if reason != "" {
+				return reason, falkorDBModuleName + " module not loaded", err
+			}
+			return "", falkorDBModuleName, err
+		}},
+		{name: "module_version", omitEmpty: true, run: func(ctx context.Context) (string, string, error) {
+			return checkModuleVersion(ctx, cfg.FalkorDBVersion, cfg.VersionMismatchWarnOnly)
+		}},
+	}
+
+	if role == "master" {
+		checks = append(checks,
+			infoCheck("failover", func() (string, string) { return checkFailoverState(info) }),
+			check{name: "sentinel_master", omitEmpty: true, run: func(ctx context.Context) (string, string, error) {
+				return checkSentinelMaster(ctx, cfg)
+			}},
+			thresholdCheck("connected_replicas", func() (string, string) {
+				return checkConnectedReplicas(info, cfg.MinConnectedReplicas)
+			}),
+			thresholdCheck("repl_backlog", func() (string, string) {
+				return checkReplBacklog(info, cfg.MinReplBacklogBytes)
+			}),
+			thresholdCheck("replica_lag", func() (string, string) {
+				return checkReplicaLag(info, cfg.MaxReplicaLagBytes)
+			}),
+			check{name: "write_probe", run: func(ctx context.Context) (string, string, error) {
+				return checkWriteProbe(ctx, info, cfg.ClusterMode)
+			}},
+		)
+	} else {
+		checks = append(checks,
+			check{name: "sync", run: func(ctx context.Context) (string, string, error) {
+				reason, detail, progress := checkSync(ctx, info, cfg.MaxSyncStallSeconds, cfg.SyncStallWarnSeconds)
+				if progress != nil && progress.InProgress {
+					report.Sync = progress
+					if reason != "" && progress.ETASeconds != nil {
+						// Rounded like the eta= of the reason
+						eta := time.Duration(*progress.ETASeconds * float64(time.Second)).Round(time.Second)
+						report.syncETA = &eta
+					}
+				}
+				return reason, detail, nil
+			}},
+			infoCheck("master_link", func() (string, string) {
+				if reason := checkMasterLink(info); reason != "" {
+					return reason, reason
+				}
+				return "", "master_link_status=up"
+			}),
+			infoCheck("replica_stale", func() (string, string) {
+				return checkReplicaStale(info, cfg.MaxMasterLastIOSeconds)
+			}),
+			check{name: "replica_lag", omitEmpty: true, run: func(ctx context.Context) (string, string, error) {
+				reason, detail := checkReplicaOffsetLag(ctx, info, cfg.MaxReplicaLagBytes)
+				return reason, detail, nil
+			}},
+			check{name: "replica_config", run: func(ctx context.Context) (string, string, error) {
+				return checkReplicaConfig(ctx, cfg.ExpectFailoverEligible)
+			}},
+			thresholdCheck("standby", func() (string, string) {
+				return checkStandby(report.RoleClass, cfg.StandbyReady)
+			}),
+		)
+	}
+
+	runChecks(probeCtx, report, enabledChecks(cfg, append(checks, readyChecks(report, cfg, info, role)...)))
+	return report
+}
+
+// readyChecks returns the checks that apply to any data node: memory,
+// clients, persistence and the rewrite or save in progress, the keyspace, the
+// deep graph query, the graph configuration, the expected graphs, the slowlog
+// growth, the network exposure, the sentinel registration, the external
+// address and the end to end check through the sentinels, and the additional
+// cluster checks in cluster mode.
+func readyChecks(report *healthReport, cfg *Config, info *redisinfo.Info, role string) []check {
+	checks := []check{
+		thresholdCheck("memory", func() (string, string) {
+			return checkMemoryPressure(info, cfg.MaxMemoryUsedPercent)
+		}),
+		fragmentationCheck(info, cfg.FragWarnRatio, cfg.FragFailRatio),
+		{name: "cpu", omitEmpty: true, run: func(ctx context.Context) (string, string, error) {
+			reason, detail := checkCPU(ctx, info, cfg)
+			return reason, detail, nil
+		}},
+		{name: "disk", omitEmpty: true, run: func(ctx context.Context) (string, string, error) {
+			reason, detail := checkDiskSpace(ctx, info, cfg)
+			return reason, detail, nil
+		}},
+		{name: "clients", omitEmpty: true, run: func(ctx context.Context) (string, string, error) {
+			return checkClientSaturation(ctx, info, cfg)
+		}},
+		{name: "persistence", run: func(ctx context.Context) (string, string, error) {
+			reason, err := checkPersistence(ctx, info, cfg.MaxSecondsSinceLastSave)
+			return reason, "", err
+		}},
+		{name: "persistence_mode", omitEmpty: true, run: func(ctx context.Context) (string, string, error) {
+			return checkPersistenceMode(ctx, info, cfg.ExpectedPersistence)
+		}},
+		persistenceActivityCheck(report, info, cfg),
+		keyspaceCheck(report, info, cfg.MaxKeys),
+		{name: "rdb_age", omitEmpty: true, run: func(ctx context.Context) (string, string, error) {
+			return checkRDBAge(ctx, info, cfg.MaxRDBAgeSeconds, cfg.RDBStalenessWarnOnly)
+		}},
+		{name: "graph_query", run: func(ctx context.Context) (string, string, error) {
+			return checkGraphQuery(ctx, role), "", nil
+		}},
+		{name: "read_probe", omitEmpty: true, run: func(ctx context.Context) (string, string, error) {
+			return checkReadProbe(ctx, cfg, role)
+		}},
+		{name: "graph_config", omitEmpty: true, run: func(ctx context.Context) (string, string, error) {
+			return checkGraphConfig(ctx, cfg)
+		}},
+		{name: "expected_graphs", omitEmpty: true, run: func(ctx context.Context) (string, string, error) {
+			return checkExpectedGraphs(ctx, info, cfg.ExpectedGraphs)
+		}},
+		{name: "slowlog", omitEmpty: true, run: func(ctx context.Context) (string, string, error) {
+			return checkSlowlogGrowth(ctx, cfg.SlowlogGrowthPerMinute, cfg.SlowlogFailThreshold)
+		}},
+		{name: "latency_events", run: func(ctx context.Context) (string, string, error) {
+			return checkLatencyEvents(ctx, cfg)
+		}},
+		{name: "network", run: func(ctx context.Context) (string, string, error) {
+			return checkNetworkExposure(ctx, cfg)
+		}},
+		{name: "sentinel_registration", omitEmpty: true, run: func(ctx context.Context) (string, string, error) {
+			return checkSentinelRegistration(ctx, cfg, role)
+		}},
+		{name: "external_address", omitEmpty: true, run: func(ctx context.Context) (string, string, error) {
+			return checkExternalAddress(ctx, cfg)
+		}},
+		aclUsersCheck(report, cfg),
+		e2eSentinelCheck(report, cfg),
+	}
+
+	if cfg.ClusterMode {
+		checks = append(checks,
+			check{name: "cluster", run: func(ctx context.Context) (string, string, error) {
+				reason, err := checkCluster(ctx)
+				if reason == "" && err == nil {
+					return "", "cluster_state=ok", nil
+				}
+				return reason, "", err
+			}},
+			check{name: "cluster_nodes", run: func(ctx context.Context) (string, string, error) {
+				return checkClusterNodes(ctx, cfg.MaxFailedPeersPercent)
+			}},
+			check{name: "cluster_master", omitEmpty: true, run: func(ctx context.Context) (string, string, error) {
+				return checkClusterMaster(ctx, role, cfg.FailoverGraceSeconds)
+			}},
+			check{name: "slot_migrations", omitEmpty: true, run: func(ctx context.Context) (string, string, error) {
+				return checkSlotMigrations(ctx, cfg.MaxSlotMigrationSeconds)
+			}},
+			check{name: "announce", run: func(ctx context.Context) (string, string, error) {
+				return checkAnnounceAddress(ctx, cfg)
+			}},
+			check{name: "slots", run: func(ctx context.Context) (string, string, error) {
+				reason, err := checkSlotCoverage(ctx, role, cfg.CheckFullSlotCoverage)
+				return reason, "", err
+			}},
+		)
+	}
+
+	return checks
+}
+
+// infoCheck adapts a check evaluated purely from the INFO snapshot
+func infoCheck(name string, fn func() (string, string)) check {
+	return check{name: name, run: func(context.Context) (string, string, error) {
+		reason, detail := fn()
+		return reason, detail, nil
+	}}
+}
+
+// thresholdCheck is an infoCheck that is only reported once configured
+func thresholdCheck(name string, fn func() (string, string)) check {
+	c := infoCheck(name, fn)
+	c.omitEmpty = true
+	return c
+}
+
+func fetchInfo(probeCtx context.Context) (*redisinfo.Info, error) {
+	info, _, err := fetchInfoAt(probeCtx)
+	return info, err
+}
+
+// fetchInfoAt returns the parsed INFO reply, possibly from the cache, along
+// with the time it was fetched from the node.
+func fetchInfoAt(probeCtx context.Context) (*redisinfo.Info, time.Time, error) {
+	remote := probeTarget(probeCtx) != ""
+	if !noCache(probeCtx) {
+		if info, fetchedAt, ok := probesOf(probeCtx).info.get(); ok {
+			return info, fetchedAt, nil
+		}
+	}
+
+	var raw string
+	var fetchedAt time.Time
+	err := withRetry(probeCtx, func() error {
+		var err error
+		fetchedAt = time.Now()
+		raw, err = fetchRawInfo(probeCtx)
+		observeInfoLatency(time.Since(fetchedAt))
+		return err
+	})
+
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	if !remote {
+		logInfoPayload(probeCtx, len(raw))
+	}
+
+	info := redisinfo.Parse(raw)
+	// Metrics and the cache describe the local node only
+	if !remote {
+		updateInfoMetrics(info)
+		probesOf(probeCtx).info.set(info, fetchedAt)
+	}
+	return info, fetchedAt, nil
+}
+
+// loadingStatus reports whether the node is still loading its dataset and
+// the probe body to return while it is.
+func loadingStatus(info *redisinfo.Info) (bool, string) {
+	loading, _ := info.Loading()
+	if !loading {
+		return false, ""
+	}
+
+	if perc, err := info.String("loading_loaded_perc"); err == nil {
+		return true, fmt.Sprintf("LOADING %s%%", perc)
+	}
+
+	return true, "LOADING"
+}
+
+func isTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+func main() {
+	once := flag.Bool("once", false, "run a single check and exit instead of serving HTTP")
+	endpoint := flag.String("endpoint", "readyz", "semantics to apply with -once: readyz, livez or startupz")
+	printVersion := flag.Bool("version", false, "print the build information and exit")
+	checkFile := flag.String("check-file", "", "check the status file written with HEALTH_STATUS_FILE and exit")
+	maxAge := flag.Duration("max-age", 30*time.Second, "age past which -check-file treats the status file as stale")
+	registerConfigFlags(flag.CommandLine)
+	flag.Parse()
+
+	if *printVersion {
+		info := currentBuildInfo()
+		fmt.Printf("healthcheck %s (commit %s, built %s, %s)\n", info.Version, info.Commit, info.BuildDate, info.GoVersion)
+		if info.FalkorDBVersion != "" {
+			fmt.Printf("built for FalkorDB %s\n", info.FalkorDBVersion)
+		}
+		return
+	}
+
+	// Needs no configuration, the file says it all
+	if *checkFile != "" {
+		os.Exit(runCheckFile(*checkFile, *maxAge))
+	}
+
+	cfg, err := loadConfig(flag.CommandLine)
+
+	if *once {
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(exitConfigError)
+		}
+
+		// Keep stdout for the check result
+		setupLogger(os.Stderr, cfg)
+		os.Exit(runOnce(cfg, *endpoint))
+	}
+
+	if err != nil {
+		slog.Error("error loading configuration", "error", err)
+		os.Exit(1)
+	}
+
+	setupLogger(os.Stdout, cfg)
+	StartHealthCheckServer(cfg)
+}
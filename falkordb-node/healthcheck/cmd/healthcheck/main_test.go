@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+const replicaInfo = `# Server
+redis_version:7.2.4
+redis_mode:standalone
+
+# Replication
+role:slave
+master_host:10.0.0.1
+master_port:6379
+master_link_status:up
+master_last_io_seconds_ago:1
+master_sync_in_progress:0
+slave_repl_offset:100
+master_repl_offset:100
+
+# Persistence
+loading:0
+`
+
+func TestReadyz(t *testing.T) {
+	syncing := strings.NewReplacer("master_link_status:up", "master_link_status:down", "master_sync_in_progress:0", "master_sync_in_progress:1").Replace(replicaInfo)
+
+	tests := []struct {
+		name string
+		info string
+		code int
+		body string
+	}{
+		{name: "master", info: masterInfo, code: http.StatusOK, body: "OK"},
+		{name: "replica in sync", info: replicaInfo, code: http.StatusOK, body: "OK"},
+		{name: "replica syncing", info: syncing, code: http.StatusServiceUnavailable, body: "SYNC_IN_PROGRESS"},
+		{name: "role missing", info: strings.Replace(masterInfo, "role:master\n", "", 1), code: http.StatusServiceUnavailable, body: "ROLE_NOT_FOUND"},
+		{name: "replica alias", info: strings.Replace(replicaInfo, "role:slave", "role:replica", 1), code: http.StatusOK, body: "OK"},
+		{name: "unknown role", info: strings.Replace(masterInfo, "role:master", "role:arbiter", 1), code: http.StatusServiceUnavailable, body: "UNKNOWN_ROLE value=arbiter"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig(t, nil)
+			p := newTestProbes(t, cfg, newFakeNode(tt.info))
+
+			w := serve(t, cfg, p, http.MethodGet, "/readyz", nil)
+			if w.Code != tt.code || !strings.HasPrefix(w.Body.String(), tt.body) {
+				t.Errorf("GET /readyz = %d %q, want %d %q", w.Code, w.Body.String(), tt.code, tt.body)
+			}
+		})
+	}
+}
+
+func TestReadyzUnreachable(t *testing.T) {
+	node := newFakeNode(masterInfo)
+	node.dialErr = &net.OpError{Op: "dial", Net: "tcp", Err: syscall.EHOSTUNREACH}
+	cfg := testConfig(t, nil)
+	p := newTestProbes(t, cfg, node)
+
+	w := serve(t, cfg, p, http.MethodGet, "/readyz", nil)
+	if w.Code != http.StatusBadGateway || !strings.HasPrefix(w.Body.String(), "REDIS_UNREACHABLE") {
+		t.Errorf("GET /readyz = %d %q, want 502 REDIS_UNREACHABLE", w.Code, w.Body.String())
+	}
+}
+
+func TestReadyzAuthFailure(t *testing.T) {
+	node := newFakeNode(masterInfo)
+	node.password = "secret"
+	cfg := testConfig(t, map[string]string{"ADMIN_PASSWORD": "wrong"})
+	p := newTestProbes(t, cfg, node)
+
+	w := serve(t, cfg, p, http.MethodGet, "/readyz", nil)
+	if w.Code != http.StatusServiceUnavailable || !strings.HasPrefix(w.Body.String(), "AUTH_FAILED") {
+		t.Errorf("GET /readyz = %d %q, want 503 AUTH_FAILED", w.Code, w.Body.String())
+	}
+}
+
+func TestRedisOptions(t *testing.T) {
+	tests := []struct {
+		name       string
+		env        map[string]string
+		network    string
+		addr       string
+		serverName string
+	}{
+		{name: "plaintext", env: map[string]string{"NODE_HOST": "node-0.svc", "NODE_PORT": "6380"}, network: "tcp", addr: "node-0.svc:6380"},
+		{name: "tls", env: map[string]string{"NODE_HOST": "node-0.svc", "NODE_PORT": "6380", "TLS": "true"}, network: "tcp", addr: "node-0.svc:6380", serverName: "node-0.svc"},
+		{name: "tls ipv6", env: map[string]string{"NODE_HOST": "::1", "TLS": "true"}, network: "tcp", addr: "[::1]:6379", serverName: "::1"},
+		{name: "socket", env: map[string]string{"NODE_SOCKET": "/run/redis.sock"}, network: "unix", addr: "/run/redis.sock"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options, err := redisOptions(testConfig(t, tt.env))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if options.Network != tt.network || options.Addr != tt.addr {
+				t.Errorf("redisOptions() = %s %s, want %s %s", options.Network, options.Addr, tt.network, tt.addr)
+			}
+
+			serverName := ""
+			if options.TLSConfig != nil {
+				serverName = options.TLSConfig.ServerName
+			}
+			if (options.TLSConfig != nil) != (tt.serverName != "") || serverName != tt.serverName {
+				t.Errorf("redisOptions() TLS server name = %q, want %q", serverName, tt.serverName)
+			}
+		})
+	}
+}
+
+func TestConcurrentProbesShareOneEvaluation(t *testing.T) {
+	cfg := testConfig(t, nil)
+	node := newFakeNode(masterInfo)
+	// Long enough for every request to join the first evaluation
+	node.delay("INFO", 200*time.Millisecond)
+	p := newTestProbes(t, cfg, node)
+	handler := newHealthCheckHandler(cfg, p)
+	shared := testutil.ToFloat64(sharedEvaluationCounter)
+
+	// The request starting the evaluation leaves, the others still get the
+	// report
+	leaderCtx, cancelLeader := context.WithCancel(context.Background())
+	leaderDone := make(chan struct{})
+	go func() {
+		defer close(leaderDone)
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/readyz", nil).WithContext(leaderCtx))
+	}()
+	eventually(t, "the INFO call", func() bool { return node.called("INFO") == 1 })
+
+	const followers = 10
+	var wg sync.WaitGroup
+	responses := make([]*httptest.ResponseRecorder, followers)
+	for i := range responses {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			responses[i] = httptest.NewRecorder()
+			handler.ServeHTTP(responses[i], httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		}(i)
+	}
+	time.Sleep(50 * time.Millisecond)
+	cancelLeader()
+	<-leaderDone
+	wg.Wait()
+
+	for i, w := range responses {
+		if w.Code != http.StatusOK || !strings.HasPrefix(w.Body.String(), "OK") {
+			t.Errorf("request %d: GET /readyz = %d %q, want 200 OK", i, w.Code, w.Body.String())
+		}
+	}
+	if got := node.called("INFO"); got != 1 {
+		t.Errorf("INFO called %d times for %d concurrent probes, want 1", got, followers+1)
+	}
+	if got := testutil.ToFloat64(sharedEvaluationCounter) - shared; got != followers {
+		t.Errorf("shared evaluations = %v, want %d", got, followers)
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"falkordb.cloud/main/internal/redisinfo"
+)
+
+// memoryUsedPercent returns used_memory as a percentage of maxmemory. The
+// second value is false when maxmemory is unlimited or unknown.
+func memoryUsedPercent(info *redisinfo.Info) (float64, bool) {
+	used, err := info.Int("used_memory")
+	if err != nil {
+		return 0, false
+	}
+
+	max, err := info.Int("maxmemory")
+	if err != nil || max <= 0 {
+		return 0, false
+	}
+
+	return float64(used) * 100 / float64(max), true
+}
+
+// checkMemoryPressure fails a node close to maxmemory, which starts rejecting
+// writes under noeviction while otherwise looking healthy. Disabled unless
+// MAX_MEMORY_USED_PERCENT is set.
+func checkMemoryPressure(info *redisinfo.Info, threshold int64) (string, string) {
+	if threshold <= 0 {
+		return "", ""
+	}
+
+	pct, ok := memoryUsedPercent(info)
+	if !ok {
+		return "", "maxmemory unlimited"
+	}
+
+	if pct > float64(threshold) {
+		return fmt.Sprintf("MEMORY_PRESSURE used=%.1f%%", pct), fmt.Sprintf("used=%.1f%% max=%d%%", pct, threshold)
+	}
+	return "", fmt.Sprintf("used=%.1f%%", pct)
+}
+
+// minFragUsedMemory is the used_memory below which fragmentation ratios are
+// meaningless, the allocator overhead dominates
+const minFragUsedMemory = 50 << 20
+
+// fragmentationCheck flags a node whose RSS outgrows its dataset, which gets
+// the container OOM-killed while everything else looks healthy. The highest
+// of mem_fragmentation_ratio and allocator_frag_ratio is only reported above
+// FRAG_WARN_RATIO and fails readiness above FRAG_FAIL_RATIO, along with the
+// activedefrag setting.
+func fragmentationCheck(info *redisinfo.Info, warnRatio float64, failRatio float64) check {
+	return check{name: "fragmentation", omitEmpty: true, run: func(ctx context.Context) (string, string, error) {
+		if warnRatio <= 0 && failRatio <= 0 {
+			return "", "", nil
+		}
+
+		ratio, err := info.Float("mem_fragmentation_ratio")
+		if err != nil {
+			return "", "", nil
+		}
+
+		if used, err := info.Int("used_memory"); err != nil || used < minFragUsedMemory {
+			return "", "skipped, used_memory below 50MB", nil
+		}
+
+		detail := fmt.Sprintf("mem_fragmentation_ratio=%.2f", ratio)
+		worst := ratio
+		if allocator, err := info.Float("allocator_frag_ratio"); err == nil {
+			detail += fmt.Sprintf(" allocator_frag_ratio=%.2f", allocator)
+			worst = max(worst, allocator)
+		}
+
+		failing := failRatio > 0 && worst > failRatio
+		if !failing && (warnRatio <= 0 || worst <= warnRatio) {
+			return "", detail, nil
+		}
+
+		if defrag, err := nodeClient(ctx).ConfigGet(ctx, "activedefrag").Result(); err == nil && defrag["activedefrag"] != "" {
+			detail += " activedefrag=" + defrag["activedefrag"]
+		}
+		if failing {
+			return fmt.Sprintf("HIGH_FRAGMENTATION ratio=%.2f", worst), fmt.Sprintf("%s max=%.2f", detail, failRatio), nil
+		}
+		return "", "warning: " + detail, nil
+	}}
+}
@@ -0,0 +1,343 @@
+package main
+
+import (
+	"time"
+
+	"falkordb.cloud/main/internal/redisinfo"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Metrics are populated from the INFO call the probe already makes, so
+// scraping /metrics never adds load on the node.
+var (
+	roleGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "falkordb_node_role",
+		Help: "Replication role of the node (1 for the current role).",
+	}, []string{"role"})
+
+	masterSyncInProgressGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "falkordb_node_master_sync_in_progress",
+		Help: "Whether the replica is currently syncing with its master.",
+	})
+
+	replicationLagGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "falkordb_node_replication_offset_lag_bytes",
+		Help: "Replication offset lag in bytes (largest replica lag on masters).",
+	})
+
+	usedMemoryGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "falkordb_node_used_memory_bytes",
+		Help: "Memory used by the node as reported by INFO.",
+	})
+
+	memoryUsedPercentGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "falkordb_node_memory_used_percent",
+		Help: "used_memory as a percentage of maxmemory (0 when unlimited).",
+	})
+
+	memFragmentationRatioGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "falkordb_node_mem_fragmentation_ratio",
+		Help: "RSS divided by used_memory as reported by INFO.",
+	})
+
+	allocatorFragRatioGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "falkordb_node_allocator_frag_ratio",
+		Help: "Fragmentation within the allocator as reported by INFO.",
+	})
+
+	connectedSlavesGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "falkordb_node_connected_slaves",
+		Help: "Number of replicas connected to the node.",
+	})
+
+	replBacklogActiveGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "falkordb_node_repl_backlog_active",
+		Help: "Whether the node keeps a replication backlog for partial resyncs.",
+	})
+
+	replBacklogHistlenGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "falkordb_node_repl_backlog_histlen_bytes",
+		Help: "Bytes of replication stream held in the backlog.",
+	})
+
+	aofRewriteInProgressGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "falkordb_node_aof_rewrite_in_progress",
+		Help: "Whether the node is rewriting its AOF.",
+	})
+
+	rdbBgsaveInProgressGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "falkordb_node_rdb_bgsave_in_progress",
+		Help: "Whether the node is writing an RDB snapshot.",
+	})
+
+	currentForkPercentGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "falkordb_node_current_fork_percent",
+		Help: "Progress of the running AOF rewrite or BGSAVE (0 when none).",
+	})
+
+	keyspaceKeysGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "falkordb_node_keyspace_keys",
+		Help: "Keys per database, from INFO keyspace (db0 is 0 when empty).",
+	}, []string{"db"})
+
+	keyspaceExpiresGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "falkordb_node_keyspace_expires",
+		Help: "Keys with an expiry per database, from INFO keyspace.",
+	}, []string{"db"})
+
+	keyspaceAvgTTLGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "falkordb_node_keyspace_avg_ttl_seconds",
+		Help: "Average TTL of the expiring keys per database, from INFO keyspace.",
+	}, []string{"db"})
+
+	healthCheckCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "falkordb_node_healthcheck_total",
+		Help: "Healthcheck results by outcome.",
+	}, []string{"result"})
+
+	infoLatencyHistogram = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "falkordb_node_info_duration_seconds",
+		Help:    "Round trip time of the INFO command used by the healthcheck.",
+		Buckets: prometheus.DefBuckets,
+	})
+)
+
+var pingLatencyHistogram = promauto.NewHistogram(prometheus.HistogramOpts{
+	Name:    "falkordb_node_ping_duration_seconds",
+	Help:    "Best round trip time of the PINGs sent by the readiness probe.",
+	Buckets: prometheus.DefBuckets,
+})
+
+var writeProbeHistogram = promauto.NewHistogram(prometheus.HistogramOpts{
+	Name:    "falkordb_node_write_probe_duration_seconds",
+	Help:    "Round trip time of the SET sent by the write probe.",
+	Buckets: prometheus.DefBuckets,
+})
+
+var checkDurationHistogram = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "falkordb_node_check_duration_seconds",
+	Help:    "Wall clock time of each readiness check.",
+	Buckets: prometheus.DefBuckets,
+}, []string{"check"})
+
+var checkRedisDurationHistogram = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "falkordb_node_check_redis_duration_seconds",
+	Help:    "Part of the time of each readiness check spent in the Redis round trip, the rest being the healthcheck's own.",
+	Buckets: prometheus.DefBuckets,
+}, []string{"check"})
+
+var slowlogGrowthGauge = promauto.NewGauge(prometheus.GaugeOpts{
+	Name: "falkordb_node_slowlog_growth_per_minute",
+	Help: "Entries added to the slowlog per minute, when the slowlog check is enabled.",
+})
+
+var cpuPercentGauge = promauto.NewGauge(prometheus.GaugeOpts{
+	Name: "falkordb_node_cpu_percent",
+	Help: "CPU used by the node between probes, 100 per busy core, when the cpu check is enabled.",
+})
+
+var sharedEvaluationCounter = promauto.NewCounter(prometheus.CounterOpts{
+	Name: "falkordb_node_healthcheck_shared_evaluations_total",
+	Help: "Probe requests answered by an evaluation already running for another request.",
+})
+
+var throttledCounter = promauto.NewCounter(prometheus.CounterOpts{
+	Name: "falkordb_node_healthcheck_throttled_total",
+	Help: "Requests rejected with 429 by HEALTH_RATE_LIMIT_RPS.",
+})
+
+var credentialGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "falkordb_node_healthcheck_credential",
+	Help: "Admin password the probes authenticate with during a rotation (1 for the one in use).",
+}, []string{"credential"})
+
+var authFailureCounter = promauto.NewCounter(prometheus.CounterOpts{
+	Name: "falkordb_node_healthcheck_auth_failures_total",
+	Help: "Probe commands rejected by the node with WRONGPASS, NOAUTH or NOPERM.",
+})
+
+var panicCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "falkordb_node_healthcheck_panics_total",
+	Help: "Panics recovered by the healthcheck, by where they happened: handler, check or evaluation.",
+}, []string{"scope"})
+
+var roleClassGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "falkordb_node_role_class",
+	Help: "Role class of the node (1 for the current class): serving-master, serving-replica, standby-replica or sentinel.",
+}, []string{"class"})
+
+var healthTransitionCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "falkordb_health_transitions_total",
+	Help: "Changes of the evaluated health status, by endpoint and the statuses changed from and to.",
+}, []string{"endpoint", "from", "to"})
+
+var healthStatusGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "falkordb_health_status",
+	Help: "Last evaluated health status by endpoint (1 for pass).",
+}, []string{"endpoint"})
+
+var healthLastTransitionGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "falkordb_health_last_transition_timestamp_seconds",
+	Help: "Time of the last change of the health status by endpoint, or of its first evaluation since the process started.",
+}, []string{"endpoint"})
+
+var circuitStateGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "falkordb_node_healthcheck_circuit_state",
+	Help: "State of the circuit breaker in front of the node with CIRCUIT_FAILURE_THRESHOLD (1 for the current state): closed, open or half-open.",
+}, []string{"state"})
+
+var aclUserGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "falkordb_node_acl_user_verified",
+	Help: "Whether each user of VERIFY_ACL_USERS could authenticate and run its commands at the last verification (1 for yes).",
+}, []string{"user"})
+
+var metricsHandler = promhttp.Handler()
+
+func observeInfoLatency(elapsed time.Duration) {
+	infoLatencyHistogram.Observe(elapsed.Seconds())
+}
+
+func observePingLatency(elapsed time.Duration) {
+	pingLatencyHistogram.Observe(elapsed.Seconds())
+}
+
+func observeWriteProbeLatency(elapsed time.Duration) {
+	writeProbeHistogram.Observe(elapsed.Seconds())
+}
+
+func observeCheckDuration(name string, elapsed time.Duration, redisTime time.Duration) {
+	checkDurationHistogram.WithLabelValues(name).Observe(elapsed.Seconds())
+	checkRedisDurationHistogram.WithLabelValues(name).Observe(redisTime.Seconds())
+}
+
+func updateACLUserMetric(user string, ok bool) {
+	value := 0.0
+	if ok {
+		value = 1
+	}
+	aclUserGauge.WithLabelValues(user).Set(value)
+}
+
+func recordHealthCheck(ok bool) {
+	probesVar.Add(1)
+	if !ok {
+		probeFailureVar.Add(1)
+	}
+
+	if ok {
+		healthCheckCounter.WithLabelValues("success").Inc()
+	} else {
+		healthCheckCounter.WithLabelValues("failure").Inc()
+	}
+}
+
+func updateInfoMetrics(info *redisinfo.Info) {
+	role, _ := info.Role()
+	roleGauge.Reset()
+	if role != "" {
+		roleGauge.WithLabelValues(role).Set(1)
+	}
+
+	if v, err := info.Int("master_sync_in_progress"); err == nil {
+		masterSyncInProgressGauge.Set(float64(v))
+	} else {
+		masterSyncInProgressGauge.Set(0)
+	}
+
+	if v, err := info.Int("used_memory"); err == nil {
+		usedMemoryGauge.Set(float64(v))
+	}
+
+	pct, _ := memoryUsedPercent(info)
+	memoryUsedPercentGauge.Set(pct)
+
+	if v, err := info.Float("mem_fragmentation_ratio"); err == nil {
+		memFragmentationRatioGauge.Set(v)
+	}
+	if v, err := info.Float("allocator_frag_ratio"); err == nil {
+		allocatorFragRatioGauge.Set(v)
+	}
+
+	if v, err := info.Int("connected_slaves"); err == nil {
+		connectedSlavesGauge.Set(float64(v))
+	}
+
+	if v, err := info.Int("repl_backlog_active"); err == nil {
+		replBacklogActiveGauge.Set(float64(v))
+	}
+	if v, err := info.Int("repl_backlog_histlen"); err == nil {
+		replBacklogHistlenGauge.Set(float64(v))
+	}
+
+	rewriting, _ := info.Bool("aof_rewrite_in_progress")
+	aofRewriteInProgressGauge.Set(float64(boolInt(rewriting)))
+	saving, _ := info.Bool("rdb_bgsave_in_progress")
+	rdbBgsaveInProgressGauge.Set(float64(boolInt(saving)))
+	forkPercent, _ := info.Float("current_fork_perc")
+	currentForkPercentGauge.Set(forkPercent)
+
+	replicationLagGauge.Set(float64(replicationLag(info, role)))
+
+	// Only fetched when the keyspace check runs
+	if info.Section("keyspace") != nil {
+		updateKeyspaceMetrics(nodeKeyspace(info))
+	}
+}
+
+func updateKeyspaceMetrics(keyspace *keyspaceInfo) {
+	keyspaceKeysGauge.Reset()
+	keyspaceExpiresGauge.Reset()
+	keyspaceAvgTTLGauge.Reset()
+	for name, db := range keyspace.Databases {
+		keyspaceKeysGauge.WithLabelValues(name).Set(float64(db.Keys))
+		keyspaceExpiresGauge.WithLabelValues(name).Set(float64(db.Expires))
+		keyspaceAvgTTLGauge.WithLabelValues(name).Set(float64(db.AvgTTL) / 1000)
+	}
+}
+
+func replicationLag(info *redisinfo.Info, role string) int64 {
+	masterOffset, err := info.Int("master_repl_offset")
+	if err != nil {
+		return 0
+	}
+
+	var lag int64
+	switch role {
+	case "slave":
+		if slaveOffset, err := info.Int("slave_repl_offset"); err == nil {
+			lag = masterOffset - slaveOffset
+		}
+	case "master":
+		for _, replica := range info.Replicas() {
+			if masterOffset-replica.Offset > lag {
+				lag = masterOffset - replica.Offset
+			}
+		}
+	}
+
+	if lag < 0 {
+		return 0
+	}
+	return lag
+}
+
+func updateCredentialMetric(credential string) {
+	credentialGauge.Reset()
+	if credential != "" {
+		credentialGauge.WithLabelValues(credential).Set(1)
+	}
+}
+
+func updateRoleClassMetric(class string) {
+	roleClassGauge.Reset()
+	if class != "" {
+		roleClassGauge.WithLabelValues(class).Set(1)
+	}
+}
+
+func updateCircuitMetric(state string) {
+	circuitStateGauge.Reset()
+	circuitStateGauge.WithLabelValues(state).Set(1)
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type requestIDKey struct{}
+
+func requestID(r *http.Request) string {
+	id, _ := r.Context().Value(requestIDKey{}).(string)
+	return id
+}
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// statusRecorder captures the status code written by a handler
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (s *statusRecorder) WriteHeader(status int) {
+	s.status = status
+	s.ResponseWriter.WriteHeader(status)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (s *statusRecorder) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
+
+// requestLogger assigns every request an ID, echoed in X-Request-Id, and logs
+// it once served so probe sources (kubelet, the Omnistrate agent, curl) can
+// be told apart. Successful requests are only logged at debug level, and
+// those of a node still starting at info level.
+func requestLogger(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		id := newRequestID()
+		w.Header().Set("X-Request-Id", id)
+		r = r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id))
+
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r)
+
+		level := slog.LevelDebug
+		if recorder.status >= http.StatusBadRequest {
+			level = slog.LevelWarn
+		}
+		if recorder.Header().Get("X-Health-Reason") == "STARTING" {
+			level = slog.LevelInfo
+		}
+
+		slog.Log(r.Context(), level, "request served",
+			"request_id", id,
+			"method", r.Method,
+			"path", r.URL.Path,
+			"remote_addr", r.RemoteAddr,
+			"user_agent", r.UserAgent(),
+			"status", recorder.status,
+			"duration", time.Since(start),
+		)
+	})
+}
+
+// postEndpoints change state and only accept POST, everything else is
+// read-only
+var postEndpoints = map[string]bool{
+	"/drain":           true,
+	"/undrain":         true,
+	"/fault/unhealthy": true,
+	"/fault/timeout":   true,
+	"/fault/clear":     true,
+}
+
+// httpDefaults only lets GET and HEAD through, and sets the headers every
+// response shares. Intermediaries must never cache a stale OK, and
+// X-Health-Schema-Version tells scrapers which JSON shape to expect.
+// Handlers returning JSON override the plain text Content-Type. HEAD
+// responses get their body dropped by net/http.
+func httpDefaults(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-store")
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Set("X-Health-Schema-Version", strconv.Itoa(reportSchemaVersion))
+
+		if postEndpoints[r.URL.Path] {
+			if r.Method != http.MethodPost {
+				w.Header().Set("Allow", "POST")
+				writeError(w, r, http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED", "")
+				return
+			}
+		} else if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			writeError(w, r, http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED", "")
+			return
+		}
+		if !validFormat(w, r) {
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// indexHandler lists the registered endpoints at / and answers anything else
+// with an explicit NOT_FOUND body.
+func indexHandler(endpoints []string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			writeError(w, r, http.StatusNotFound, "NOT_FOUND", "")
+			return
+		}
+
+		w.Write([]byte(strings.Join(endpoints, "\n") + "\n"))
+	}
+}
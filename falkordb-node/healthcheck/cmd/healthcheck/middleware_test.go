@@ -0,0 +1,102 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestHTTPMethods(t *testing.T) {
+	cfg := testConfig(t, nil)
+	p := newTestProbes(t, cfg, newFakeNode(masterInfo))
+
+	tests := []struct {
+		method string
+		path   string
+		code   int
+		allow  string
+		body   string
+	}{
+		{method: http.MethodGet, path: "/healthcheck", code: http.StatusOK, body: "OK"},
+		{method: http.MethodGet, path: "/readyz", code: http.StatusOK, body: "OK"},
+		{method: http.MethodGet, path: "/livez", code: http.StatusOK},
+		{method: http.MethodGet, path: "/startupz", code: http.StatusOK},
+		{method: http.MethodPost, path: "/readyz", code: http.StatusMethodNotAllowed, allow: "GET, HEAD", body: "METHOD_NOT_ALLOWED"},
+		{method: http.MethodPut, path: "/healthcheck", code: http.StatusMethodNotAllowed, allow: "GET, HEAD", body: "METHOD_NOT_ALLOWED"},
+		{method: http.MethodDelete, path: "/livez", code: http.StatusMethodNotAllowed, allow: "GET, HEAD", body: "METHOD_NOT_ALLOWED"},
+		{method: http.MethodOptions, path: "/", code: http.StatusMethodNotAllowed, allow: "GET, HEAD", body: "METHOD_NOT_ALLOWED"},
+		{method: http.MethodGet, path: "/nope", code: http.StatusNotFound, body: "NOT_FOUND"},
+		{method: http.MethodGet, path: "/readyz/extra", code: http.StatusNotFound, body: "NOT_FOUND"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			w := serve(t, cfg, p, tt.method, tt.path, nil)
+			if w.Code != tt.code {
+				t.Errorf("%s %s = %d %q, want %d", tt.method, tt.path, w.Code, w.Body.String(), tt.code)
+			}
+			if got := w.Header().Get("Allow"); got != tt.allow {
+				t.Errorf("Allow = %q, want %q", got, tt.allow)
+			}
+			if !strings.HasPrefix(w.Body.String(), tt.body) {
+				t.Errorf("body = %q, want it to start with %q", w.Body.String(), tt.body)
+			}
+			if got := w.Header().Get("Cache-Control"); got != "no-store" {
+				t.Errorf("Cache-Control = %q, want no-store", got)
+			}
+			if got := w.Header().Get("Content-Type"); got != "text/plain; charset=utf-8" {
+				t.Errorf("Content-Type = %q, want plain text", got)
+			}
+		})
+	}
+}
+
+func TestHTTPIndex(t *testing.T) {
+	cfg := testConfig(t, nil)
+	p := newTestProbes(t, cfg, newFakeNode(masterInfo))
+
+	w := serve(t, cfg, p, http.MethodGet, "/", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET / = %d %q", w.Code, w.Body.String())
+	}
+	endpoints := strings.Fields(w.Body.String())
+	for _, want := range []string{"/healthcheck", "/readyz", "/livez", "/startupz", "/version"} {
+		if !slices.Contains(endpoints, want) {
+			t.Errorf("index %q doesn't list %s", w.Body.String(), want)
+		}
+	}
+}
+
+// TestHTTPHead goes through a real server, which drops the body of HEAD
+// responses
+func TestHTTPHead(t *testing.T) {
+	cfg := testConfig(t, nil)
+	server := httptest.NewServer(newHealthCheckHandler(cfg, newTestProbes(t, cfg, newFakeNode(masterInfo))))
+	defer server.Close()
+
+	for _, path := range []string{"/readyz", "/livez", "/"} {
+		get, err := http.Get(server.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		get.Body.Close()
+
+		head, err := http.Head(server.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(head.Body)
+		head.Body.Close()
+
+		if head.StatusCode != get.StatusCode || len(body) != 0 {
+			t.Errorf("HEAD %s = %d with %d bytes, want GET's %d without a body", path, head.StatusCode, len(body), get.StatusCode)
+		}
+		for _, header := range []string{"Content-Type", "Cache-Control"} {
+			if head.Header.Get(header) != get.Header.Get(header) {
+				t.Errorf("HEAD %s %s = %q, want GET's %q", path, header, head.Header.Get(header), get.Header.Get(header))
+			}
+		}
+	}
+}
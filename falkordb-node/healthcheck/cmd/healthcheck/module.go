@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// falkorDBModuleName is the name the FalkorDB module registers with Redis
+const falkorDBModuleName = "graph"
+
+// checkModule verifies the FalkorDB module is loaded, since redis-server
+// starts fine even when loadmodule points at a bad path. It returns an empty
+// string when healthy, or the reason otherwise.
+func checkModule(probeCtx context.Context) (string, error) {
+	reply, err := nodeClient(probeCtx).Do(probeCtx, "MODULE", "LIST").Result()
+	if err != nil {
+		return "", err
+	}
+
+	for _, module := range replyEntries(reply) {
+		if module["name"] == falkorDBModuleName {
+			return "", nil
+		}
+	}
+
+	return "MODULE_NOT_LOADED", nil
+}
+
+// moduleSemver converts the integer version MODULE LIST reports, two digits
+// per minor and patch, e.g. 41411 to 4.14.11 and 40210 to 4.2.10
+func moduleSemver(ver int64) string {
+	return fmt.Sprintf("%d.%d.%d", ver/10000, ver/100%100, ver%100)
+}
+
+// checkModuleVersion compares the FalkorDB module running on the node with
+// FALKORDB_VERSION, catching images whose module binary wasn't replaced.
+// A mismatch fails with MODULE_VERSION_MISMATCH, or is only reported with
+// VERSION_CHECK_MODE=warn. The check is skipped without FALKORDB_VERSION.
+func checkModuleVersion(probeCtx context.Context, expected string, warnOnly bool) (string, string, error) {
+	expected = strings.TrimPrefix(expected, "v")
+	if expected == "" {
+		return "", "", nil
+	}
+
+	reply, err := nodeClient(probeCtx).Do(probeCtx, "MODULE", "LIST").Result()
+	if err != nil {
+		return "", "", err
+	}
+
+	for _, module := range replyEntries(reply) {
+		if module["name"] != falkorDBModuleName {
+			continue
+		}
+
+		ver, err := strconv.ParseInt(module["ver"], 10, 64)
+		if err != nil {
+			return "", "warning: unknown module version ver=" + module["ver"], nil
+		}
+
+		running := moduleSemver(ver)
+		detail := fmt.Sprintf("running=%s expected=%s", running, expected)
+		if running == expected {
+			return "", "version=" + running, nil
+		}
+		if warnOnly {
+			return "", "warning: " + detail, nil
+		}
+		return "MODULE_VERSION_MISMATCH " + detail, detail, nil
+	}
+
+	// The module check reports a missing module
+	return "", "", nil
+}
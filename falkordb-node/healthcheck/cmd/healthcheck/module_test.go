@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestModuleSemver(t *testing.T) {
+	tests := []struct {
+		ver  int64
+		want string
+	}{
+		{ver: 41411, want: "4.14.11"},
+		{ver: 40210, want: "4.2.10"},
+		{ver: 40002, want: "4.0.2"},
+		{ver: 41000, want: "4.10.0"},
+		{ver: 21299, want: "2.12.99"},
+		{ver: 100001, want: "10.0.1"},
+		{ver: 99, want: "0.0.99"},
+	}
+	for _, tt := range tests {
+		if got := moduleSemver(tt.ver); got != tt.want {
+			t.Errorf("moduleSemver(%d) = %s, want %s", tt.ver, got, tt.want)
+		}
+	}
+}
+
+func TestCheckModuleVersion(t *testing.T) {
+	falkordb := func(ver any) []any {
+		return []any{[]any{"name", "graph", "ver", ver, "path", "/falkordb.so", "args", []any{}}}
+	}
+	tests := []struct {
+		name     string
+		modules  []any
+		expected string
+		warnOnly bool
+		reason   string
+		detail   string
+	}{
+		{name: "match", modules: falkordb(int64(41411)), expected: "4.14.11", detail: "version=4.14.11"},
+		{name: "v prefix", modules: falkordb(int64(41411)), expected: "v4.14.11", detail: "version=4.14.11"},
+		{name: "two digit patch", modules: falkordb(int64(40210)), expected: "4.2.10", detail: "version=4.2.10"},
+		{name: "mismatch", modules: falkordb(int64(40210)), expected: "4.14.11", reason: "MODULE_VERSION_MISMATCH running=4.2.10 expected=4.14.11", detail: "running=4.2.10 expected=4.14.11"},
+		{name: "mismatch warn only", modules: falkordb(int64(40210)), expected: "4.14.11", warnOnly: true, detail: "warning: running=4.2.10 expected=4.14.11"},
+		{name: "not configured", modules: falkordb(int64(40210))},
+		{name: "unparsable version", modules: falkordb("dev"), expected: "4.14.11", detail: "warning: unknown module version ver=dev"},
+		{name: "module not loaded", modules: []any{[]any{"name", "search", "ver", int64(20810)}}, expected: "4.14.11"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := newFakeNode(masterInfo)
+			node.reply("MODULE LIST", tt.modules)
+			cfg := testConfig(t, nil)
+			p := newTestProbes(t, cfg, node)
+
+			reason, detail, err := checkModuleVersion(withProbes(context.Background(), p), tt.expected, tt.warnOnly)
+			if err != nil || reason != tt.reason || detail != tt.detail {
+				t.Errorf("checkModuleVersion = %q, %q, %v, want %q, %q", reason, detail, err, tt.reason, tt.detail)
+			}
+		})
+	}
+}
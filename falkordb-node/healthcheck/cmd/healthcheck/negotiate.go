@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"falkordb.cloud/main/internal/server"
+)
+
+// errorPayload is the JSON body of every failing response, probes and
+// rejected requests alike:
+//
+//	{"schema_version":1,"status":"fail","reason_code":"SYNC_IN_PROGRESS","detail":"..."}
+type errorPayload struct {
+	SchemaVersion int    `json:"schema_version"`
+	Status        string `json:"status"`
+	ReasonCode    string `json:"reason_code"`
+	Detail        string `json:"detail,omitempty"`
+}
+
+func wantsJSON(r *http.Request) bool {
+	return server.NegotiateFormat(r) == server.FormatJSON
+}
+
+// validFormat rejects a ?format= that is neither json nor text, answering
+// 400 INVALID_FORMAT
+func validFormat(w http.ResponseWriter, r *http.Request) bool {
+	if format := r.URL.Query().Get("format"); format != "" && format != "json" && format != "text" {
+		writeError(w, r, http.StatusBadRequest, "INVALID_FORMAT", "accepted formats: json, text")
+		return false
+	}
+	return true
+}
+
+// writeError answers a failing request in the negotiated format. The plain
+// text body starts with the reason code, followed by the detail.
+func writeError(w http.ResponseWriter, r *http.Request, code int, reason string, detail string) {
+	if wantsJSON(r) {
+		writeJSON(w, r, code, errorPayload{SchemaVersion: reportSchemaVersion, Status: "fail", ReasonCode: reason, Detail: detail})
+		return
+	}
+
+	body := reason
+	if detail != "" {
+		body += " " + detail
+	}
+	w.WriteHeader(code)
+	w.Write([]byte(body))
+}
+
+// writeRedisError answers 502 for an endpoint that couldn't query the node,
+// with the reason code classifyRedisError gives the error
+func writeRedisError(w http.ResponseWriter, r *http.Request, err error) {
+	handleRedisError(r.Context(), err)
+	_, reason := classifyRedisError(err)
+	writeError(w, r, http.StatusBadGateway, reason, err.Error())
+}
+
+// writeJSON answers with v as the JSON body, gzipped from
+// JSON_GZIP_MIN_BYTES for the clients accepting it. Plain text bodies, the
+// probes' included, are never compressed.
+func writeJSON(w http.ResponseWriter, r *http.Request, code int, v any) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		slog.Error("error encoding the response", "request_id", requestID(r), "endpoint", r.URL.Path, "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	server.WriteJSON(w, r, code, append(body, '\n'), probesOf(r.Context()).gzipMinBytes)
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestSchemaVersionHeader(t *testing.T) {
+	cfg := testConfig(t, nil)
+	p := newTestProbes(t, cfg, newFakeNode(masterInfo))
+
+	requests := []struct {
+		method, path string
+	}{
+		{http.MethodGet, "/readyz"},
+		{http.MethodGet, "/readyz?format=json"},
+		{http.MethodGet, "/livez"},
+		{http.MethodGet, "/version"},
+		{http.MethodGet, "/metrics"},
+		{http.MethodGet, "/"},
+		{http.MethodGet, "/nothing-here"},
+		{http.MethodPost, "/readyz"},
+		{http.MethodGet, "/readyz?format=xml"},
+	}
+	for _, req := range requests {
+		w := serve(t, cfg, p, req.method, req.path, nil)
+		if got := w.Header().Get("X-Health-Schema-Version"); got != "1" {
+			t.Errorf("%s %s X-Health-Schema-Version = %q, want 1", req.method, req.path, got)
+		}
+	}
+}
+
+func TestReportEnvelope(t *testing.T) {
+	syncing := strings.NewReplacer("master_link_status:up", "master_link_status:down", "master_sync_in_progress:0", "master_sync_in_progress:1").Replace(replicaInfo)
+	tests := []struct {
+		name   string
+		info   string
+		code   int
+		status string
+		reason string
+	}{
+		{name: "pass", info: masterInfo, code: http.StatusOK, status: "pass"},
+		{name: "fail", info: syncing, code: http.StatusServiceUnavailable, status: "fail", reason: "SYNC_IN_PROGRESS"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig(t, nil)
+			p := newTestProbes(t, cfg, newFakeNode(tt.info))
+
+			// Plain text stays the bare reason
+			if w := serve(t, cfg, p, http.MethodGet, "/readyz?nocache=1", nil); w.Code != tt.code || strings.HasPrefix(w.Body.String(), "{") {
+				t.Errorf("GET /readyz = %d %q, want %d in plain text", w.Code, w.Body.String(), tt.code)
+			}
+
+			for _, header := range []http.Header{{"Accept": {"application/json"}}, nil} {
+				path := "/readyz?nocache=1"
+				if header == nil {
+					path += "&format=json"
+				}
+				w := serve(t, cfg, p, http.MethodGet, path, header)
+				var body errorPayload
+				if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || w.Code != tt.code {
+					t.Fatalf("GET %s = %d %q, want %d JSON", path, w.Code, w.Body.String(), tt.code)
+				}
+				if body.SchemaVersion != reportSchemaVersion || body.Status != tt.status || body.ReasonCode != tt.reason {
+					t.Errorf("GET %s = %+v, want schema %d %s %s", path, body, reportSchemaVersion, tt.status, tt.reason)
+				}
+				if tt.reason != "" && body.Detail == "" {
+					t.Errorf("GET %s = %+v, want the detail of the failure", path, body)
+				}
+			}
+		})
+	}
+}
+
+func TestWriteError(t *testing.T) {
+	cfg := testConfig(t, nil)
+	p := newTestProbes(t, cfg, newFakeNode(masterInfo))
+
+	w := serve(t, cfg, p, http.MethodGet, "/readyz?format=xml", nil)
+	if w.Code != http.StatusBadRequest || w.Body.String() != "INVALID_FORMAT accepted formats: json, text" {
+		t.Errorf("GET /readyz?format=xml = %d %q, want the reason then the detail", w.Code, w.Body.String())
+	}
+
+	// Rejected requests share the shape of failing probes
+	w = serve(t, cfg, p, http.MethodGet, "/nothing-here", http.Header{"Accept": {"application/json"}})
+	var body map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || w.Code != http.StatusNotFound {
+		t.Fatalf("GET /nothing-here = %d %q, want 404 JSON", w.Code, w.Body.String())
+	}
+	want := map[string]any{"schema_version": float64(reportSchemaVersion), "status": "fail", "reason_code": "NOT_FOUND"}
+	for key, value := range want {
+		if body[key] != value {
+			t.Errorf("GET /nothing-here %s = %v, want %v", key, body[key], value)
+		}
+	}
+	for key := range body {
+		if _, ok := want[key]; !ok && key != "detail" {
+			t.Errorf("GET /nothing-here has %s outside the error payload", key)
+		}
+	}
+	if got := w.Header().Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", got)
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// nodeIdentity is the body of /node-info. Fields that can't be determined
+// are null rather than omitted, with the reason in Warnings, so consumers
+// notice an incomplete configuration.
+type nodeIdentity struct {
+	PodName       *string  `json:"pod_name"`
+	NodeIndex     *int64   `json:"node_index"`
+	ShardID       *string  `json:"shard_id"`
+	NodeID        *string  `json:"node_id"`
+	Role          *string  `json:"role"`
+	AnnouncedAddr *string  `json:"announced_addr"`
+	Warnings      []string `json:"warnings"`
+}
+
+func (n *nodeIdentity) warn(warning string) {
+	n.Warnings = append(n.Warnings, warning)
+}
+
+// nodeInfoHandler tells who the node is: its pod, its index in the
+// StatefulSet, its shard and cluster node ID, its role and the address it
+// announces. A node that can't be queried still returns what the
+// environment tells.
+func nodeInfoHandler(cfg *Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		probeCtx, cancel := probeContext(r)
+		defer cancel()
+
+		identity := &nodeIdentity{Warnings: []string{}}
+		if cfg.PodName == "" {
+			identity.warn("POD_NAME unset, pod_name is the hostname")
+		}
+		if podName := nodeName(cfg); podName != "" {
+			identity.PodName = &podName
+		}
+		identity.NodeIndex = nodeIndex(cfg, identity)
+
+		if info, err := fetchInfo(probeCtx); err != nil {
+			identity.warn("role unknown: " + err.Error())
+		} else if role, err := info.Role(); err != nil {
+			identity.warn("role unknown: " + err.Error())
+		} else {
+			identity.Role = &role
+		}
+
+		if cfg.ClusterMode {
+			clusterIdentity(probeCtx, identity)
+		}
+		announcedAddr(probeCtx, cfg, identity)
+
+		writeJSON(w, r, http.StatusOK, identity)
+	}
+}
+
+// nodeIndex returns NODE_INDEX, or the StatefulSet ordinal ending the pod
+// name, e.g. 2 for node-f-2
+func nodeIndex(cfg *Config, identity *nodeIdentity) *int64 {
+	if cfg.NodeIndex != "" {
+		index, err := strconv.ParseInt(cfg.NodeIndex, 10, 64)
+		if err != nil || index < 0 {
+			identity.warn("NODE_INDEX is not a non-negative integer: " + cfg.NodeIndex)
+			return nil
+		}
+		return &index
+	}
+
+	if identity.PodName != nil {
+		name := *identity.PodName
+		if index, err := strconv.ParseInt(name[strings.LastIndexByte(name, '-')+1:], 10, 64); err == nil && index >= 0 {
+			return &index
+		}
+	}
+	identity.warn("NODE_INDEX unset and the pod name has no StatefulSet ordinal")
+	return nil
+}
+
+// clusterIdentity reads the node ID and, on Redis 7.2+, the shard ID of the
+// node in the cluster
+func clusterIdentity(probeCtx context.Context, identity *nodeIdentity) {
+	if id, err := nodeClient(probeCtx).Do(probeCtx, "CLUSTER", "MYID").Text(); err != nil {
+		identity.warn("node_id unknown: " + err.Error())
+	} else {
+		identity.NodeID = &id
+	}
+
+	if id, err := nodeClient(probeCtx).Do(probeCtx, "CLUSTER", "MYSHARDID").Text(); err != nil {
+		identity.warn("shard_id unknown: " + err.Error())
+	} else {
+		identity.ShardID = &id
+	}
+}
+
+// announcedAddr returns the address other nodes and clients are told to use
+func announcedAddr(probeCtx context.Context, cfg *Config, identity *nodeIdentity) {
+	host, port, err := nodeAnnouncedAddr(probeCtx, cfg)
+	if err != nil {
+		identity.warn("announced_addr unknown: " + err.Error())
+		return
+	}
+	addr := net.JoinHostPort(host, port)
+	identity.AnnouncedAddr = &addr
+}
+
+// nodeAnnouncedAddr reads cluster-announce-* in cluster mode,
+// replica-announce-* otherwise, each falling back to the pod address and
+// NODE_PORT
+func nodeAnnouncedAddr(probeCtx context.Context, cfg *Config) (string, string, error) {
+	prefix := "replica-announce-"
+	if cfg.ClusterMode {
+		prefix = "cluster-announce-"
+	}
+
+	reply, err := nodeClient(probeCtx).ConfigGet(probeCtx, prefix+"*").Result()
+	if err != nil {
+		return "", "", err
+	}
+
+	host := reply[prefix+"hostname"]
+	if host == "" {
+		host = reply[prefix+"ip"]
+	}
+	if host == "" {
+		if host, err = podIP(cfg); err != nil {
+			return "", "", err
+		}
+	}
+
+	port := reply[prefix+"port"]
+	if port == "" || port == "0" {
+		port = cfg.NodePort
+	}
+	// Only a unix socket is known with NODE_SOCKET
+	if port == "" {
+		return "", "", errors.New("no announced port and no NODE_PORT")
+	}
+	return host, port, nil
+}
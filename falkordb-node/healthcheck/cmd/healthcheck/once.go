@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"fmt"
+)
+
+// Exit codes of the -once mode, suitable for Docker HEALTHCHECK and exec probes
+const (
+	exitHealthy     = 0
+	exitUnhealthy   = 1
+	exitConfigError = 2
+	// exitInternalFailure is used when the server gives up on itself, see
+	// MAX_CONSECUTIVE_INTERNAL_FAILURES
+	exitInternalFailure = 3
+)
+
+// runOnce performs a single evaluation with the semantics of the given
+// endpoint, prints the reason to stdout and returns the process exit code.
+func runOnce(cfg *Config, endpoint string) int {
+	var evaluate func(context.Context, *Config) *healthReport
+	switch endpoint {
+	case "readyz":
+		evaluate = evaluateReadiness
+	case "livez":
+		evaluate = evaluateLiveness
+	case "startupz":
+		evaluate = evaluateStartup
+	default:
+		fmt.Printf("unknown endpoint %q, expected readyz, livez or startupz\n", endpoint)
+		return exitConfigError
+	}
+
+	p, err := setupProbes(cfg)
+	if err != nil {
+		fmt.Println(err)
+		return exitConfigError
+	}
+	defer p.close()
+
+	probeCtx, cancel := context.WithTimeout(withProbes(context.Background(), p), p.probeTimeout)
+	defer cancel()
+
+	report := evaluate(probeCtx, cfg)
+	fmt.Println(report.body)
+
+	if !report.ok() {
+		return exitUnhealthy
+	}
+	return exitHealthy
+}
@@ -0,0 +1,21 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"falkordb.cloud/main/internal/server"
+)
+
+// parseListPage reads the page asked for of /graphs or /clusterhealth, at
+// most MAX_LIST_ITEMS long. It answers 400 INVALID_LIMIT or INVALID_CURSOR
+// and returns false when ?limit= or ?cursor= is malformed.
+func parseListPage(w http.ResponseWriter, r *http.Request) (server.Page, bool) {
+	page, err := server.ParsePage(r.URL.Query(), probesOf(r.Context()).maxListItems)
+	var invalid *server.PageError
+	if errors.As(err, &invalid) {
+		writeError(w, r, http.StatusBadRequest, invalid.Reason, invalid.Detail)
+		return page, false
+	}
+	return page, true
+}
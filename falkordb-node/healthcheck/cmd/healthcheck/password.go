@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// passwordFile holds the admin password read from ADMIN_PASSWORD_FILE, and
+// the previous one from its second line during a rotation. The file is
+// re-read whenever Redis rejects our credentials so the probe heals itself
+// after a secret rotation without a restart.
+type passwordFile struct {
+	path string
+
+	mu       sync.Mutex
+	password string
+	previous string
+}
+
+func newPasswordFile(path string) *passwordFile {
+	p := &passwordFile{path: path}
+	p.reload()
+	return p
+}
+
+func (p *passwordFile) reload() {
+	data, err := os.ReadFile(p.path)
+	if err != nil {
+		slog.Error("error reading ADMIN_PASSWORD_FILE", "path", p.path, "error", err)
+		return
+	}
+
+	password, previous, _ := strings.Cut(strings.TrimSpace(string(data)), "\n")
+	password, previous = strings.TrimSpace(password), strings.TrimSpace(previous)
+	if password == "" {
+		slog.Error("ADMIN_PASSWORD_FILE is empty", "path", p.path)
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.password != "" && p.password != password {
+		slog.Info("admin password changed on disk", "path", p.path)
+	}
+	p.password, p.previous = password, previous
+}
+
+func (p *passwordFile) current() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.password
+}
+
+func (p *passwordFile) previousPassword() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.previous
+}
+
+// nodeCredentials holds the credentials used to authenticate against the node
+type nodeCredentials struct {
+	user             string
+	password         string
+	adminPassword    string
+	previousPassword string
+	file             *passwordFile
+	// Set while the node only accepts the previous admin password, see
+	// switchCredential
+	usingPrevious atomic.Bool
+	// Set during bootstrap while the node has no password, see
+	// skipAuthForBootstrap, until it accepts AUTH, see checkBootstrapAuth
+	unauthenticated atomic.Bool
+	// Ends the BOOTSTRAP_GRACE_SECONDS window after startup in which the
+	// node may not have its password applied yet
+	bootstrapUntil time.Time
+}
+
+func newNodeCredentials(cfg *Config) *nodeCredentials {
+	c := &nodeCredentials{user: cfg.User, password: cfg.Password, adminPassword: cfg.AdminPassword, previousPassword: cfg.AdminPasswordPrevious,
+		bootstrapUntil: time.Now().Add(cfg.BootstrapGrace)}
+	if cfg.AdminPasswordFile != "" {
+		c.file = newPasswordFile(cfg.AdminPasswordFile)
+	}
+	return c
+}
+
+// get returns the username and password used to authenticate. A dedicated
+// ACL user from HEALTH_CHECK_USER/HEALTH_CHECK_PASSWORD takes precedence over
+// the default user with the admin password.
+func (c *nodeCredentials) get() (string, string) {
+	if c.unauthenticated.Load() && c.inBootstrap() {
+		return "", ""
+	}
+	return c.configured()
+}
+
+// configured returns the credentials to authenticate with once the node has
+// its password, see get
+func (c *nodeCredentials) configured() (string, string) {
+	if c.user != "" {
+		return c.user, c.password
+	}
+	if c.usingPrevious.Load() {
+		return "", c.previous()
+	}
+	return "", c.admin()
+}
+
+// previous returns the admin password being rotated out, from the second
+// line of ADMIN_PASSWORD_FILE or ADMIN_PASSWORD_PREVIOUS
+func (c *nodeCredentials) previous() string {
+	if c.file != nil {
+		if previous := c.file.previousPassword(); previous != "" {
+			return previous
+		}
+	}
+	return c.previousPassword
+}
+
+// credential names the admin password new connections use, or "" when no
+// previous one is configured
+func (c *nodeCredentials) credential() string {
+	if c.user != "" || c.previous() == "" {
+		return ""
+	}
+	if c.usingPrevious.Load() {
+		return "previous"
+	}
+	return "current"
+}
+
+// switchCredential swaps between the current and previous admin password
+// after WRONGPASS, so a node not yet on the rotated secret still passes. It
+// returns false when there is no previous password to switch to.
+func (c *nodeCredentials) switchCredential(err error) bool {
+	if !isAuthError(err) || c.credential() == "" {
+		return false
+	}
+
+	if c.usingPrevious.Swap(!c.usingPrevious.Load()) {
+		slog.Info("switching back to the current admin password")
+	} else {
+		slog.Warn("current admin password rejected, trying the previous one")
+	}
+	updateCredentialMetric(c.credential())
+	return true
+}
+
+// admin returns the admin password, preferring ADMIN_PASSWORD_FILE over the
+// ADMIN_PASSWORD env var.
+func (c *nodeCredentials) admin() string {
+	if c.file != nil {
+		return c.file.current()
+	}
+	return c.adminPassword
+}
+
+func isAuthError(err error) bool {
+	if !isRedisReply(err) {
+		return false
+	}
+
+	msg := err.Error()
+	return strings.HasPrefix(msg, "WRONGPASS") || strings.HasPrefix(msg, "NOAUTH") ||
+		strings.Contains(msg, "invalid password") || strings.Contains(msg, "invalid username-password")
+}
+
+// isNoPermError reports whether the ACL user lacks permission for a command
+func isNoPermError(err error) bool {
+	return isRedisReply(err) && strings.HasPrefix(err.Error(), "NOPERM")
+}
+
+// handleRedisError reacts to errors returned by Redis commands issued by the
+// probes. Credentials are reloaded from disk on authentication failures,
+// which are logged at error level since they usually mean a wrong or not yet
+// propagated secret rather than a sick node.
+func handleRedisError(probeCtx context.Context, err error) {
+	redisErrorsVar.Add(1)
+
+	p := probesOf(probeCtx)
+	if isAuthError(err) && p.credentials.unauthenticated.Swap(false) {
+		// New connections authenticate again
+		slog.Info("node password applied, authenticating again", "phase", p.credentials.bootstrapPhase())
+		return
+	}
+
+	if isAuthError(err) {
+		authFailureCounter.Inc()
+		user, _ := p.credentials.get()
+		slog.Error("authentication with the node failed, check the ADMIN_PASSWORD secret", "user", user, "error", err)
+
+		if p.credentials.file != nil {
+			slog.Warn("reloading ADMIN_PASSWORD_FILE")
+			p.credentials.file.reload()
+		}
+		for _, credentials := range p.targets.credentials {
+			if credentials.file != nil {
+				credentials.file.reload()
+			}
+		}
+	}
+
+	if isNoPermError(err) {
+		authFailureCounter.Inc()
+		user, _ := p.credentials.get()
+		slog.Error("healthcheck user lacks permission for a probe command, check its ACL", "user", user, "error", err)
+	}
+}
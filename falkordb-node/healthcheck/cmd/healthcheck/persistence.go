@@ -0,0 +1,225 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"falkordb.cloud/main/internal/redisinfo"
+	"github.com/redis/go-redis/v9"
+)
+
+// checkPersistence fails a node whose last BGSAVE or AOF write/rewrite failed,
+// typically because its disk filled up. With MAX_SECONDS_SINCE_LAST_SAVE set
+// it also fails when the last successful save is too old. Nodes with
+// persistence disabled pass since their statuses are never updated.
+func checkPersistence(probeCtx context.Context, info *redisinfo.Info, maxAge int64) (string, error) {
+	fields := []string{"rdb_last_bgsave_status"}
+	if aofEnabled, _ := info.Bool("aof_enabled"); aofEnabled {
+		fields = append(fields, "aof_last_write_status", "aof_last_bgrewrite_status")
+	}
+
+	for _, field := range fields {
+		status, err := info.String(field)
+		if err != nil {
+			continue
+		}
+		if status != "ok" {
+			return fmt.Sprintf("PERSISTENCE_FAILED %s=%s", field, status), nil
+		}
+	}
+
+	if maxAge <= 0 {
+		return "", nil
+	}
+
+	// Only nodes with save points are expected to snapshot regularly
+	if saving, err := rdbEnabled(probeCtx); err != nil || !saving {
+		return "", err
+	}
+
+	lastSave, err := info.Int("rdb_last_save_time")
+	if err != nil {
+		return "", nil
+	}
+
+	age := time.Now().Unix() - lastSave
+	if age > maxAge {
+		return fmt.Sprintf("PERSISTENCE_FAILED rdb_last_save_time=%ds ago", age), nil
+	}
+
+	return "", nil
+}
+
+// rdbEnabled reports whether the node has save points configured
+func rdbEnabled(probeCtx context.Context) (bool, error) {
+	savePoints, err := nodeClient(probeCtx).ConfigGet(probeCtx, "save").Result()
+	if err != nil {
+		return false, err
+	}
+	return savePoints["save"] != "", nil
+}
+
+// checkPersistenceMode fails a node whose appendonly and save points don't
+// match EXPECTED_PERSISTENCE, e.g. AOF left off after a failed CONFIG
+// REWRITE. A node rewriting its AOF is given the benefit of the doubt, since
+// enabling AOF starts with a rewrite. The check is skipped without
+// EXPECTED_PERSISTENCE.
+func checkPersistenceMode(probeCtx context.Context, info *redisinfo.Info, expected string) (string, string, error) {
+	if expected == "" {
+		return "", "", nil
+	}
+
+	var appendOnly, save *redis.MapStringStringCmd
+	_, err := nodeClient(probeCtx).Pipelined(probeCtx, func(pipe redis.Pipeliner) error {
+		appendOnly = pipe.ConfigGet(probeCtx, "appendonly")
+		save = pipe.ConfigGet(probeCtx, "save")
+		return nil
+	})
+	if err != nil {
+		return "", "", err
+	}
+
+	aof, rdb := appendOnly.Val()["appendonly"] == "yes", save.Val()["save"] != ""
+	actual := "none"
+	switch {
+	case aof && rdb:
+		actual = "both"
+	case aof:
+		actual = "aof"
+	case rdb:
+		actual = "rdb"
+	}
+
+	detail := fmt.Sprintf("expected=%s actual=%s", expected, actual)
+	if actual == expected {
+		return "", detail, nil
+	}
+	if rewriting, _ := info.Bool("aof_rewrite_in_progress"); rewriting {
+		return "", detail + " aof_rewrite_in_progress=1", nil
+	}
+	return "PERSISTENCE_MODE_MISMATCH " + detail, detail, nil
+}
+
+// checkRDBAge fails a node whose last RDB snapshot is older than
+// MAX_RDB_AGE_SECONDS, which breaks the RPO promised to customers. With
+// RDB_STALENESS_MODE=warn it is only reported. Nodes without save points,
+// and those with no changes to save, are exempt. A node that hasn't saved
+// since it started reports the startup time as last save, so it gets the
+// same allowance counted from its uptime.
+func checkRDBAge(probeCtx context.Context, info *redisinfo.Info, maxAge int64, warnOnly bool) (string, string, error) {
+	if maxAge <= 0 {
+		return "", "", nil
+	}
+
+	if saving, err := rdbEnabled(probeCtx); err != nil || !saving {
+		if err == nil {
+			return "", "rdb disabled", nil
+		}
+		return "", "", err
+	}
+
+	lastSave, err := info.Int("rdb_last_save_time")
+	if err != nil {
+		return "", "", nil
+	}
+	if changes, err := info.Int("rdb_changes_since_last_save"); err == nil && changes == 0 {
+		return "", "no changes since last save", nil
+	}
+
+	now := time.Now().Unix()
+	age := now - lastSave
+	detail := fmt.Sprintf("age=%ds max=%ds", age, maxAge)
+	// uptime_in_seconds is truncated, allow a second for it
+	if uptime, err := info.Int("uptime_in_seconds"); err == nil && lastSave <= now-uptime+1 {
+		detail = fmt.Sprintf("never saved uptime=%ds max=%ds", uptime, maxAge)
+		age = uptime
+	}
+
+	if age <= maxAge {
+		return "", detail, nil
+	}
+	if warnOnly {
+		return "", "warning: " + detail, nil
+	}
+	return fmt.Sprintf("RDB_STALE age=%ds", age), detail, nil
+}
+
+// forkActivity is the fork based persistence running on the node,
+// reported while either of them is in progress
+type forkActivity struct {
+	AOFRewriteInProgress bool    `json:"aof_rewrite_in_progress"`
+	BgsaveInProgress     bool    `json:"rdb_bgsave_in_progress"`
+	ForkPercent          float64 `json:"current_fork_perc"`
+	// RunningSeconds is how long the activity has been observed
+	RunningSeconds int64 `json:"running_seconds"`
+}
+
+// persistenceDrain remembers when the node was first seen rewriting or
+// saving, bounding how long DRAIN_DURING_PERSISTENCE keeps it out of rotation
+var persistenceDrain = struct {
+	mu      sync.Mutex
+	since   time.Time
+	expired bool
+}{}
+
+// persistenceActivityCheck records the AOF rewrite or BGSAVE in progress in
+// the report. Only this check writes Persistence.
+func persistenceActivityCheck(report *healthReport, info *redisinfo.Info, cfg *Config) check {
+	return check{name: "persistence_activity", omitEmpty: true, run: func(context.Context) (string, string, error) {
+		reason, detail, activity := checkPersistenceActivity(info, cfg.DrainDuringPersistence, cfg.MaxDrainSeconds)
+		report.Persistence = activity
+		return reason, detail, nil
+	}}
+}
+
+// checkPersistenceActivity reports an AOF rewrite or BGSAVE in progress and
+// how far along the fork is. With DRAIN_DURING_PERSISTENCE it fails
+// readiness with PERSISTENCE_IN_PROGRESS meanwhile, until
+// MAX_PERSISTENCE_DRAIN_SECONDS when the node is put back in rotation with a
+// warning: a stuck rewrite must not drain the node forever.
+func checkPersistenceActivity(info *redisinfo.Info, drain bool, maxDrainSeconds int64) (string, string, *forkActivity) {
+	rewriting, _ := info.Bool("aof_rewrite_in_progress")
+	saving, _ := info.Bool("rdb_bgsave_in_progress")
+
+	persistenceDrain.mu.Lock()
+	defer persistenceDrain.mu.Unlock()
+
+	if !rewriting && !saving {
+		persistenceDrain.since, persistenceDrain.expired = time.Time{}, false
+		return "", "", nil
+	}
+	if persistenceDrain.since.IsZero() {
+		persistenceDrain.since = time.Now()
+	}
+
+	activity := &forkActivity{
+		AOFRewriteInProgress: rewriting,
+		BgsaveInProgress:     saving,
+		RunningSeconds:       int64(time.Since(persistenceDrain.since).Seconds()),
+	}
+	activity.ForkPercent, _ = info.Float("current_fork_perc")
+
+	detail := fmt.Sprintf("aof_rewrite_in_progress=%d rdb_bgsave_in_progress=%d current_fork_perc=%.2f", boolInt(rewriting), boolInt(saving), activity.ForkPercent)
+	if !drain {
+		return "", detail, activity
+	}
+
+	if activity.RunningSeconds > maxDrainSeconds {
+		if !persistenceDrain.expired {
+			slog.Warn("persistence still in progress past MAX_PERSISTENCE_DRAIN_SECONDS, back in rotation", "running_seconds", activity.RunningSeconds, "max", maxDrainSeconds)
+			persistenceDrain.expired = true
+		}
+		return "", fmt.Sprintf("warning: PERSISTENCE_DRAIN_EXPIRED %s running=%ds", detail, activity.RunningSeconds), activity
+	}
+	return "PERSISTENCE_IN_PROGRESS " + detail, detail, activity
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
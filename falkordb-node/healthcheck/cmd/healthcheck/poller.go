@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type polledReport struct {
+	report    *healthReport
+	evaluated time.Time
+}
+
+// healthPoller holds the latest report of each probe when HEALTH_POLL_INTERVAL_MS
+// is set, so handlers answer instantly instead of paying for INFO each time.
+var healthPoller = struct {
+	mu      sync.RWMutex
+	reports map[string]polledReport
+}{reports: map[string]polledReport{}}
+
+var polledEvaluations = map[string]func(context.Context, *Config) *healthReport{
+	"readyz":   evaluateReadiness,
+	"livez":    evaluateLiveness,
+	"startupz": evaluateStartup,
+}
+
+// startHealthPoller evaluates every probe on a fixed interval until the
+// returned function is called. It does nothing unless polling is enabled.
+func startHealthPoller(serverCtx context.Context, cfg *Config, p *probes) func() {
+	if cfg.PollInterval <= 0 {
+		return func() {}
+	}
+
+	pollCtx, cancel := context.WithCancel(withProbes(serverCtx, p))
+	go func() {
+		ticker := time.NewTicker(cfg.PollInterval)
+		defer ticker.Stop()
+
+		for {
+			for name, evaluate := range polledEvaluations {
+				probeCtx, cancelProbe := context.WithTimeout(withConfiguredRole(pollCtx, cfg), probesOf(pollCtx).probeTimeout)
+				report := evaluateRecorded("poller/"+name, evaluate, probeCtx, cfg)
+				cancelProbe()
+
+				healthPoller.mu.Lock()
+				healthPoller.reports[name] = polledReport{report: report, evaluated: time.Now()}
+				healthPoller.mu.Unlock()
+				if name == "readyz" {
+					writeStatusFile(cfg, report)
+				}
+			}
+
+			select {
+			case <-pollCtx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	slog.Info("starting background health poller", "interval", cfg.PollInterval)
+	return cancel
+}
+
+// servePolled answers the probe from the last polled report and returns
+// true, or returns false when the request must be evaluated synchronously:
+// polling is off, the request asks for a specific role or target, or readiness
+// is drained so it fails right away instead of at the next poll. A report
+// older than HEALTH_POLL_MAX_AGE_MS means the poller is wedged and fails closed.
+func servePolled(w http.ResponseWriter, r *http.Request, cfg *Config, name string) bool {
+	if cfg.PollInterval <= 0 {
+		return false
+	}
+
+	query := r.URL.Query()
+	if query.Get("expect_role") != "" || query.Get("target") != "" || query.Get("nocache") == "1" {
+		return false
+	}
+	if p := probesOf(r.Context()); name == "readyz" && (p.draining.Load() || p.fault.active().mode != faultNone) {
+		return false
+	}
+
+	healthPoller.mu.RLock()
+	polled, ok := healthPoller.reports[name]
+	healthPoller.mu.RUnlock()
+
+	age := time.Since(polled.evaluated)
+	report := polled.report
+	if !ok || age > cfg.PollMaxAge {
+		report = newHealthReport()
+		detail := "no report yet"
+		if ok {
+			detail = fmt.Sprintf("age_ms=%d", age.Milliseconds())
+		}
+		report.fail(http.StatusServiceUnavailable, "STALE_HEALTH_DATA", "poller", detail)
+	}
+
+	if ok {
+		w.Header().Set("X-Health-Age-Ms", strconv.FormatInt(age.Milliseconds(), 10))
+	}
+	writeReport(w, r, report)
+	return true
+}
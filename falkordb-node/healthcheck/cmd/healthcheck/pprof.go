@@ -0,0 +1,60 @@
+package main
+
+import (
+	"errors"
+	"expvar"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"time"
+)
+
+// Counters exposed at /debug/vars along with the runtime memstats
+var (
+	probesVar       = expvar.NewInt("probes_total")
+	probeFailureVar = expvar.NewInt("probe_failures_total")
+	redisErrorsVar  = expvar.NewInt("redis_errors_total")
+)
+
+func init() {
+	expvar.Publish("goroutines", expvar.Func(func() any { return runtime.NumGoroutine() }))
+}
+
+func registerProfilingHandlers(mux *http.ServeMux) {
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/debug/vars", expvar.Handler())
+}
+
+// startDebugServer serves pprof and expvar on 127.0.0.1:DEBUG_PORT, so they
+// are never reachable from the pod network, and like every debug endpoint
+// only to HEALTH_ADMIN_TOKEN. It returns a function stopping the server.
+func startDebugServer(cfg *Config) (func(), error) {
+	mux := http.NewServeMux()
+	registerProfilingHandlers(mux)
+
+	address := net.JoinHostPort("127.0.0.1", cfg.DebugPort)
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+
+	// No write timeout, CPU profiles and traces stream for their duration
+	server := &http.Server{
+		Handler:           requireAdmin(cfg, mux),
+		ReadHeaderTimeout: 5 * time.Second,
+		MaxHeaderBytes:    cfg.MaxHeaderBytes,
+	}
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("debug server stopped", "error", err)
+		}
+	}()
+
+	return func() { server.Close() }, nil
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestDebugEndpointsOffByDefault(t *testing.T) {
+	cfg := testConfig(t, nil)
+	p := newTestProbes(t, cfg, newFakeNode(masterInfo))
+
+	for _, path := range []string{"/debug/pprof/", "/debug/pprof/cmdline", "/debug/vars", "/debug/info"} {
+		if w := serve(t, cfg, p, http.MethodGet, path, nil); w.Code != http.StatusNotFound {
+			t.Errorf("GET %s = %d %q, want 404", path, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestDebugEndpoints(t *testing.T) {
+	cfg := testConfig(t, map[string]string{"ENABLE_DEBUG_ENDPOINTS": "true", "HEALTH_ADMIN_TOKEN": "secret"})
+	p := newTestProbes(t, cfg, newFakeNode(masterInfo))
+	admin := http.Header{"Authorization": {"Bearer secret"}}
+
+	if w := serve(t, cfg, p, http.MethodGet, "/debug/pprof/", admin); w.Code != http.StatusOK {
+		t.Errorf("GET /debug/pprof/ = %d %q, want 200", w.Code, w.Body.String())
+	}
+
+	w := serve(t, cfg, p, http.MethodGet, "/debug/vars", admin)
+	var vars map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &vars); w.Code != http.StatusOK || err != nil {
+		t.Fatalf("GET /debug/vars = %d %q", w.Code, w.Body.String())
+	}
+	for _, name := range []string{"probes_total", "probe_failures_total", "redis_errors_total", "goroutines", "memstats"} {
+		if _, ok := vars[name]; !ok {
+			t.Errorf("/debug/vars has no %s", name)
+		}
+	}
+}
+
+func TestDebugPort(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, port, _ := net.SplitHostPort(listener.Addr().String())
+	listener.Close()
+
+	cfg := testConfig(t, map[string]string{"ENABLE_DEBUG_ENDPOINTS": "true", "HEALTH_ADMIN_TOKEN": "secret", "DEBUG_PORT": port, "HEALTH_CHECK_MAX_HEADER_BYTES": "1024"})
+	p := newTestProbes(t, cfg, newFakeNode(masterInfo))
+	admin := http.Header{"Authorization": {"Bearer secret"}}
+
+	// Profiling moves off the main port, the other debug endpoints stay
+	if w := serve(t, cfg, p, http.MethodGet, "/debug/pprof/", admin); w.Code != http.StatusNotFound {
+		t.Errorf("GET /debug/pprof/ on the main port = %d, want 404", w.Code)
+	}
+	if w := serve(t, cfg, p, http.MethodGet, "/debug/info", admin); w.Code != http.StatusOK {
+		t.Errorf("GET /debug/info = %d %q, want 200", w.Code, w.Body.String())
+	}
+
+	stop, err := startDebugServer(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+
+	get := func(path string, token string) int {
+		r, err := http.NewRequest(http.MethodGet, "http://127.0.0.1:"+port+path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	for _, path := range []string{"/debug/pprof/", "/debug/vars"} {
+		if code := get(path, "secret"); code != http.StatusOK {
+			t.Errorf("GET %s on the debug port = %d, want 200", path, code)
+		}
+		if code := get(path, ""); code != http.StatusUnauthorized {
+			t.Errorf("GET %s on the debug port without the token = %d, want 401", path, code)
+		}
+	}
+	// net/http reads a few kilobytes past MaxHeaderBytes before refusing
+	if code := get("/debug/vars", strings.Repeat("x", 64<<10)); code != http.StatusRequestHeaderFieldsTooLarge {
+		t.Errorf("GET /debug/vars with headers over HEALTH_CHECK_MAX_HEADER_BYTES = %d, want 431", code)
+	}
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// preflightItem is one step of the preflight, skipped once an earlier one
+// failed
+type preflightItem struct {
+	Name   string `json:"name"`
+	Result string `json:"result"` // PASS, FAIL or SKIP
+	Detail string `json:"detail,omitempty"`
+}
+
+// preflightResult is the body of /debug/preflight
+type preflightResult struct {
+	Mode       string          `json:"mode"`
+	Passed     bool            `json:"passed"`
+	Attempts   int             `json:"attempts"`
+	StartedAt  time.Time       `json:"started_at"`
+	FinishedAt time.Time       `json:"finished_at"`
+	Items      []preflightItem `json:"items"`
+}
+
+var preflight struct {
+	mu     sync.Mutex
+	result *preflightResult
+}
+
+// runPreflight checks the configuration and the node once before serving,
+// so a wrong port, password or TLS file shows at startup rather than on the
+// first probe. Each attempt goes through the configuration, the TCP
+// connection, the TLS handshake, the authentication and the FalkorDB
+// module, retried PREFLIGHT_RETRIES times. With PREFLIGHT_MODE=abort a
+// failure exits, with wait it is retried until it passes, and with warn,
+// the default, it is only logged.
+func runPreflight(parent context.Context, cfg *Config, p *probes) {
+	result := &preflightResult{Mode: cfg.PreflightMode, StartedAt: time.Now().UTC()}
+	for {
+		result.Attempts++
+		attemptCtx, cancel := context.WithTimeout(withProbes(parent, p), p.probeTimeout)
+		result.Items = preflightItems(attemptCtx, cfg)
+		cancel()
+
+		result.Passed = true
+		for _, item := range result.Items {
+			if item.Result == "FAIL" {
+				result.Passed = false
+				slog.Debug("preflight attempt failed", "attempt", result.Attempts, "item", item.Name, "detail", item.Detail)
+			}
+		}
+		if result.Passed || (cfg.PreflightMode != "wait" && int64(result.Attempts) > cfg.PreflightRetries) {
+			break
+		}
+		if cfg.PreflightMode == "wait" && result.Attempts%10 == 1 {
+			slog.Warn("preflight failed, waiting for it to pass before serving", "attempts", result.Attempts, "items", result.Items)
+		}
+		time.Sleep(cfg.PreflightRetryInterval)
+	}
+	result.FinishedAt = time.Now().UTC()
+
+	for _, item := range result.Items {
+		level := slog.LevelInfo
+		if item.Result == "FAIL" {
+			level = slog.LevelWarn
+		}
+		slog.Log(parent, level, "preflight", "item", item.Name, "result", item.Result, "detail", item.Detail)
+	}
+
+	preflight.mu.Lock()
+	preflight.result = result
+	preflight.mu.Unlock()
+
+	switch {
+	case result.Passed:
+		slog.Info("preflight passed", "attempts", result.Attempts)
+	case cfg.PreflightMode == "abort":
+		slog.Error("preflight failed, exiting", "attempts", result.Attempts)
+		os.Exit(1)
+	default:
+		slog.Warn("preflight failed, serving anyway", "attempts", result.Attempts)
+	}
+}
+
+// preflightItems runs the preflight steps in order
+func preflightItems(attemptCtx context.Context, cfg *Config) []preflightItem {
+	// loadConfig exits on an invalid configuration, and setupProbes on
+	// unreadable TLS files
+	items := []preflightItem{{Name: "config", Result: "PASS", Detail: "topology=" + cfg.Topology}}
+	failed := false
+	step := func(name string, run func() (string, error)) {
+		if failed {
+			items = append(items, preflightItem{Name: name, Result: "SKIP"})
+			return
+		}
+		detail, err := run()
+		if err != nil {
+			failed = true
+			items = append(items, preflightItem{Name: name, Result: "FAIL", Detail: err.Error()})
+			return
+		}
+		items = append(items, preflightItem{Name: name, Result: "PASS", Detail: detail})
+	}
+
+	options := probesOf(attemptCtx).targets.base
+	step("reachability", func() (string, error) {
+		return dialNode(attemptCtx, options.Network, options.Addr, nil)
+	})
+
+	switch {
+	case cfg.CheckBothListeners:
+		step("tls", func() (string, error) {
+			tlsOptions := probesOf(attemptCtx).tlsListener.Options()
+			return dialNode(attemptCtx, tlsOptions.Network, tlsOptions.Addr, tlsOptions.TLSConfig)
+		})
+	case options.TLSConfig != nil:
+		step("tls", func() (string, error) {
+			return dialNode(attemptCtx, options.Network, options.Addr, options.TLSConfig)
+		})
+	}
+
+	step("auth", func() (string, error) {
+		if err := probesOf(attemptCtx).client.Ping(attemptCtx).Err(); err != nil {
+			if isAuthError(err) {
+				return "", errors.New("authentication rejected: " + err.Error())
+			}
+			return "", errors.New("PING failed: " + err.Error())
+		}
+		return "PONG", nil
+	})
+
+	if !cfg.SentinelMode && !cfg.SkipModuleCheck {
+		step("module", func() (string, error) {
+			reason, err := checkModule(attemptCtx)
+			if err == nil && reason != "" {
+				err = errors.New(reason + " " + falkorDBModuleName)
+			}
+			return falkorDBModuleName, err
+		})
+	}
+	return items
+}
+
+// dialNode opens a connection to the node, and completes the TLS handshake
+// with tlsConfig
+func dialNode(dialCtx context.Context, network string, addr string, tlsConfig *tls.Config) (string, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(dialCtx, network, addr)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	if tlsConfig == nil {
+		return addr, nil
+	}
+	tlsConn := tls.Client(conn, withServerName(tlsConfig, addr))
+	if err := tlsConn.HandshakeContext(dialCtx); err != nil {
+		return "", err
+	}
+	return addr + " " + tls.VersionName(tlsConn.ConnectionState().Version), nil
+}
+
+// withServerName fills in the server name go-redis derives from the address
+func withServerName(tlsConfig *tls.Config, addr string) *tls.Config {
+	if tlsConfig.ServerName != "" || tlsConfig.InsecureSkipVerify {
+		return tlsConfig
+	}
+	copied := tlsConfig.Clone()
+	copied.ServerName, _, _ = net.SplitHostPort(addr)
+	return copied
+}
+
+func debugPreflightHandler(w http.ResponseWriter, r *http.Request) {
+	preflight.mu.Lock()
+	result := preflight.result
+	preflight.mu.Unlock()
+
+	if result == nil {
+		writeError(w, r, http.StatusNotFound, "PREFLIGHT_NOT_RUN", "")
+		return
+	}
+	writeJSON(w, r, http.StatusOK, result)
+}
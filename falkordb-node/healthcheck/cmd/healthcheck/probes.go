@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// probes is what the probes of the local node talk to and the settings they
+// run with. It is built once from the configuration and given to the HTTP
+// handler and the background loops, which hand it to the checks in the probe
+// context, so the handler can be served against any client, a fake one
+// included.
+type probes struct {
+	client       redis.UniversalClient
+	credentials  *nodeCredentials
+	targets      *targetClients
+	tlsListener  *redis.Client          // with CHECK_BOTH_LISTENERS
+	sentinel     *redis.SentinelClient  // with SENTINEL_HOST
+	registration []registrationSentinel // with REQUIRE_SENTINEL_REGISTRATION
+	circuit      *circuitBreaker        // with CIRCUIT_FAILURE_THRESHOLD
+
+	info         *infoCache
+	graphs       *graphInventoryCache
+	history      *healthHistory
+	internal     *internalFailures
+	streams      *healthBroadcaster
+	infoSections []string
+	infoModes    *infoModes
+	capabilities *capabilities
+	fault        *injectedFault
+	sync         *syncHistory
+	states       *healthStates
+	// Fails readiness with DRAINING, see drainHandler
+	draining atomic.Bool
+
+	probeTimeout     time.Duration // bounds every Redis call of a probe
+	checkTimeout     time.Duration
+	deepCheckTimeout time.Duration
+	retries          int // extra attempts on connection errors
+	gzipMinBytes     int64
+	maxListItems     int
+	// Ends the STARTUP_GRACE_SECONDS window in which redis-server may still
+	// be booting
+	startupUntil time.Time
+}
+
+// newProbes returns the probes of the node client talks to with
+// credentials, configured from cfg
+func newProbes(cfg *Config, credentials *nodeCredentials, client redis.UniversalClient) *probes {
+	p := &probes{
+		client:           client,
+		credentials:      credentials,
+		targets:          newTargetClients(),
+		info:             &infoCache{ttl: cfg.CacheTTL},
+		graphs:           newGraphInventoryCache(cfg.GraphCacheTTL),
+		history:          newHealthHistory(cfg.HistorySize),
+		internal:         &internalFailures{max: cfg.MaxInternalFails},
+		infoSections:     neededInfoSections(cfg),
+		infoModes:        &infoModes{modes: map[string]infoMode{}},
+		capabilities:     &capabilities{commands: map[string]capability{}},
+		fault:            &injectedFault{},
+		sync:             &syncHistory{},
+		states:           &healthStates{statuses: map[string]string{}},
+		probeTimeout:     cfg.ProbeTimeout,
+		checkTimeout:     cfg.CheckTimeout,
+		deepCheckTimeout: cfg.DeepCheckTimeout,
+		retries:          cfg.Retries,
+		gzipMinBytes:     cfg.GzipMinBytes,
+		maxListItems:     cfg.MaxListItems,
+		startupUntil:     processStart.Add(cfg.StartupGrace),
+	}
+
+	// Remote nodes are probed with the options of the local node
+	if local, ok := client.(*redis.Client); ok {
+		p.targets.base = local.Options()
+	}
+	p.streams = newHealthBroadcaster(p)
+	if cfg.SentinelHost != "" {
+		p.sentinel = newSentinelClient(cfg)
+	}
+	if cfg.SentinelRegistration {
+		p.registration = newRegistrationSentinels(cfg)
+	}
+	return p
+}
+
+func (p *probes) inStartupGrace() bool {
+	return time.Now().Before(p.startupUntil)
+}
+
+// close closes the clients of the probes
+func (p *probes) close() {
+	p.client.Close()
+	p.targets.close()
+	if p.tlsListener != nil {
+		p.tlsListener.Close()
+	}
+	if p.sentinel != nil {
+		p.sentinel.Close()
+	}
+	for _, sentinel := range p.registration {
+		sentinel.client.Close()
+	}
+}
+
+type probesKey struct{}
+
+// withProbes returns a context carrying the probes to the checks run within
+// it
+func withProbes(parent context.Context, p *probes) context.Context {
+	return context.WithValue(parent, probesKey{}, p)
+}
+
+// probesOf returns the probes carried by a context derived from withProbes,
+// which every request and background evaluation is
+func probesOf(probeCtx context.Context) *probes {
+	return probeCtx.Value(probesKey{}).(*probes)
+}
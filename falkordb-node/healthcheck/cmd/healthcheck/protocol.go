@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"fmt"
+)
+
+// checkProtocol verifies the node negotiates the REDIS_PROTOCOL the clients
+// are configured with. go-redis silently falls back to RESP2 when HELLO
+// fails, so HELLO is sent again and the proto and mode it reports are
+// compared with the configuration, failing with PROTOCOL_MISMATCH. The
+// connection already speaks that protocol, so the HELLO changes nothing. The
+// check is skipped without REDIS_PROTOCOL.
+func checkProtocol(probeCtx context.Context, cfg *Config) (string, string, error) {
+	if cfg.RedisProtocol == 0 {
+		return "", "", nil
+	}
+
+	reply, err := nodeClient(probeCtx).Do(probeCtx, "HELLO", cfg.RedisProtocol).Result()
+	if err != nil {
+		if isRedisReply(err) && !isAuthError(err) {
+			detail := fmt.Sprintf("HELLO %d failed: %s", cfg.RedisProtocol, err)
+			return "PROTOCOL_MISMATCH " + detail, detail, nil
+		}
+		return "", "", err
+	}
+
+	// A flat array under RESP2, a map under RESP3
+	entries := replyEntries([]interface{}{reply})
+	if len(entries) == 0 {
+		return "", "", fmt.Errorf("unexpected HELLO reply %v", reply)
+	}
+	hello := entries[0]
+
+	mode := "standalone"
+	switch {
+	case cfg.SentinelMode:
+		mode = "sentinel"
+	case cfg.ClusterMode:
+		mode = "cluster"
+	}
+
+	detail := fmt.Sprintf("proto=%s mode=%s", hello["proto"], hello["mode"])
+	if hello["proto"] != fmt.Sprint(cfg.RedisProtocol) || hello["mode"] != mode {
+		return fmt.Sprintf("PROTOCOL_MISMATCH expected proto=%d mode=%s actual %s", cfg.RedisProtocol, mode, detail), detail, nil
+	}
+	return "", detail, nil
+}
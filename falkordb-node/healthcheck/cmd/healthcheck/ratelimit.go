@@ -0,0 +1,109 @@
+package main
+
+import (
+	"container/list"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// maxRateLimitedClients bounds the buckets kept, the least recently seen
+// client is forgotten first
+const maxRateLimitedClients = 4096
+
+// rateLimiter is a token bucket per remote IP, refilled at
+// HEALTH_RATE_LIMIT_RPS up to HEALTH_RATE_LIMIT_BURST tokens
+type rateLimiter struct {
+	rps    float64
+	burst  float64
+	exempt []*net.IPNet
+
+	mu      sync.Mutex
+	buckets map[string]*list.Element
+	recent  *list.List // of *rateBucket, most recently seen first
+}
+
+type rateBucket struct {
+	ip     string
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(cfg *Config) *rateLimiter {
+	return &rateLimiter{
+		rps:     cfg.RateLimitRPS,
+		burst:   float64(cfg.RateLimitBurst),
+		exempt:  cfg.RateLimitExempt,
+		buckets: map[string]*list.Element{},
+		recent:  list.New(),
+	}
+}
+
+// rateLimit answers 429 TOO_MANY_REQUESTS, with Retry-After, to a client
+// probing faster than the limiter allows, since every probe costs the node
+// an INFO call. The client is the one allowCIDRs tells, behind a trusted
+// proxy the clients don't share its bucket. Clients in
+// HEALTH_RATE_LIMIT_EXEMPT_CIDRS, localhost by default, and the unix socket
+// are never limited.
+func rateLimit(cfg *Config, limiter *rateLimiter, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		source := requestSource(r)
+		if net.ParseIP(source) != nil {
+			// A malformed X-Forwarded-For is limited with the proxy
+			if client := clientIP(r, cfg.TrustProxyHeaders); net.ParseIP(client) != nil {
+				source = client
+			}
+		}
+		if wait, ok := limiter.allow(source); !ok {
+			throttledCounter.Inc()
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			writeError(w, r, http.StatusTooManyRequests, "TOO_MANY_REQUESTS", "")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// allow takes a token from the bucket of source, or returns false with the
+// time until one is available
+func (l *rateLimiter) allow(source string) (time.Duration, bool) {
+	ip := net.ParseIP(source)
+	if ip == nil {
+		// Not a TCP peer, the unix socket
+		return 0, true
+	}
+	for _, exempt := range l.exempt {
+		if exempt.Contains(ip) {
+			return 0, true
+		}
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	var bucket *rateBucket
+	if element, ok := l.buckets[source]; ok {
+		l.recent.MoveToFront(element)
+		bucket = element.Value.(*rateBucket)
+		bucket.tokens = min(l.burst, bucket.tokens+now.Sub(bucket.last).Seconds()*l.rps)
+		bucket.last = now
+	} else {
+		if l.recent.Len() >= maxRateLimitedClients {
+			oldest := l.recent.Back()
+			l.recent.Remove(oldest)
+			delete(l.buckets, oldest.Value.(*rateBucket).ip)
+		}
+		bucket = &rateBucket{ip: source, tokens: l.burst, last: now}
+		l.buckets[source] = l.recent.PushFront(bucket)
+	}
+
+	if bucket.tokens < 1 {
+		return time.Duration((1 - bucket.tokens) / l.rps * float64(time.Second)), false
+	}
+	bucket.tokens--
+	return 0, true
+}
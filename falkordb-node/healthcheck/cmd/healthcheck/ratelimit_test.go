@@ -0,0 +1,84 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRateLimit(t *testing.T) {
+	type request struct {
+		remoteAddr string
+		forwarded  string
+		want       int
+	}
+	tests := []struct {
+		name     string
+		env      map[string]string
+		requests []request
+	}{
+		{
+			name: "per peer",
+			requests: []request{
+				{remoteAddr: "10.0.0.1:1000", want: http.StatusOK},
+				{remoteAddr: "10.0.0.1:1001", want: http.StatusTooManyRequests},
+				{remoteAddr: "10.0.0.2:1000", want: http.StatusOK},
+			},
+		},
+		{
+			name: "untrusted proxy headers share the proxy's bucket",
+			requests: []request{
+				{remoteAddr: "10.0.0.1:1000", forwarded: "203.0.113.1", want: http.StatusOK},
+				{remoteAddr: "10.0.0.1:1000", forwarded: "203.0.113.2", want: http.StatusTooManyRequests},
+			},
+		},
+		{
+			name: "per client behind a trusted proxy",
+			env:  map[string]string{"TRUST_PROXY_HEADERS": "true"},
+			requests: []request{
+				{remoteAddr: "10.0.0.1:1000", forwarded: "203.0.113.1", want: http.StatusOK},
+				{remoteAddr: "10.0.0.1:1000", forwarded: "203.0.113.2", want: http.StatusOK},
+				{remoteAddr: "10.0.0.1:1000", forwarded: "198.51.100.7, 203.0.113.1", want: http.StatusTooManyRequests},
+				{remoteAddr: "10.0.0.1:1000", forwarded: "not-an-ip", want: http.StatusOK},
+				{remoteAddr: "10.0.0.1:1000", forwarded: "also-not-an-ip", want: http.StatusTooManyRequests},
+			},
+		},
+		{
+			name: "exempt",
+			requests: []request{
+				{remoteAddr: "127.0.0.1:1000", want: http.StatusOK},
+				{remoteAddr: "127.0.0.1:1000", want: http.StatusOK},
+				{remoteAddr: "@", want: http.StatusOK},
+				{remoteAddr: "@", want: http.StatusOK},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := map[string]string{"HEALTH_RATE_LIMIT_RPS": "0.001", "HEALTH_RATE_LIMIT_BURST": "1"}
+			for key, value := range tt.env {
+				env[key] = value
+			}
+			cfg := testConfig(t, env)
+			handler := rateLimit(cfg, newRateLimiter(cfg), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+			for i, req := range tt.requests {
+				r := httptest.NewRequest(http.MethodGet, "/readyz", nil)
+				r.RemoteAddr = req.remoteAddr
+				if req.forwarded != "" {
+					r.Header.Set("X-Forwarded-For", req.forwarded)
+				}
+				w := httptest.NewRecorder()
+				handler.ServeHTTP(w, r)
+
+				if w.Code != req.want {
+					t.Errorf("request %d from %s, X-Forwarded-For %q = %d, want %d", i, req.remoteAddr, req.forwarded, w.Code, req.want)
+				}
+				if w.Code == http.StatusTooManyRequests && w.Header().Get("Retry-After") == "" {
+					t.Errorf("request %d throttled without Retry-After", i)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+
+	"falkordb.cloud/main/internal/redisinfo"
+)
+
+// checkMasterLink verifies a replica's link to its master is up. A replica
+// whose link dropped after its last sync still reports sync done, but serves
+// stale data. It returns an empty string when healthy, or the reason otherwise.
+func checkMasterLink(info *redisinfo.Info) string {
+	status, err := info.String("master_link_status")
+	if err != nil {
+		return "MASTER_LINK_DOWN master_link_status not found"
+	}
+
+	if status == "up" {
+		return ""
+	}
+
+	reason := []string{"MASTER_LINK_DOWN", "master_link_status=" + status}
+	if lastIO, err := info.Int("master_last_io_seconds_ago"); err == nil {
+		reason = append(reason, fmt.Sprintf("master_last_io_seconds_ago=%d", lastIO))
+	}
+	if downSince, err := info.Int("master_link_down_since_seconds"); err == nil {
+		reason = append(reason, fmt.Sprintf("master_link_down_since_seconds=%d", downSince))
+	}
+
+	return strings.Join(reason, " ")
+}
+
+// checkReplicaLag reports how far behind the replicas of a master are, from
+// the offsets of its slaveN entries: a replica only knows its own offset, its
+// INFO can't tell its lag. A replica further behind than
+// MAX_REPLICA_LAG_BYTES is a warning, it says nothing about the master.
+// Disabled when negative. Replicas that haven't synced yet read offset 0 and
+// are left out, and a replica ahead after a failover counts as no lag.
+func checkReplicaLag(info *redisinfo.Info, maxBytes int64) (string, string) {
+	if maxBytes < 0 {
+		return "", ""
+	}
+	masterOffset, err := info.Int("master_repl_offset")
+	if err != nil {
+		return "", ""
+	}
+
+	var lags, behind []string
+	for _, replica := range info.Replicas() {
+		if replica.Offset <= 0 {
+			continue
+		}
+		lag := max(masterOffset-replica.Offset, 0)
+		lags = append(lags, fmt.Sprintf("%s=%d", replica.Name, lag))
+		if lag > maxBytes {
+			behind = append(behind, fmt.Sprintf("%s lag=%d", replica.Name, lag))
+		}
+	}
+
+	if len(behind) > 0 {
+		return "", fmt.Sprintf("warning: REPLICA_LAG_EXCEEDED %s max=%d", strings.Join(behind, " "), maxBytes)
+	}
+	if len(lags) == 0 {
+		return "", ""
+	}
+	return "", "lag_bytes " + strings.Join(lags, " ")
+}
+
+// checkReplicaOffsetLag fails a replica further behind its master than
+// MAX_REPLICA_LAG_BYTES with REPLICA_LAG_EXCEEDED. Its slave_repl_offset is
+// compared to the master_repl_offset of the master_host it replicates from,
+// its own INFO only knows how far it got. Disabled when negative. A replica
+// that hasn't synced yet reads offset 0 and passes, one ahead after a
+// failover counts as no lag, and a master that can't be asked is only
+// reported, master_link tells whether the replica still hears from it.
+func checkReplicaOffsetLag(probeCtx context.Context, info *redisinfo.Info, maxBytes int64) (string, string) {
+	if maxBytes < 0 {
+		return "", ""
+	}
+	offset, err := info.Int("slave_repl_offset")
+	if err != nil || offset <= 0 {
+		return "", ""
+	}
+	host, errHost := info.String("master_host")
+	port, errPort := info.String("master_port")
+	if errHost != nil || errPort != nil {
+		return "", ""
+	}
+
+	master := net.JoinHostPort(host, port)
+	raw, err := probesOf(probeCtx).targets.client(master).Info(probeCtx, "replication").Result()
+	if err != nil {
+		return "", fmt.Sprintf("warning: master offset unavailable master=%s: %v", master, err)
+	}
+	masterOffset, err := redisinfo.Parse(raw).Int("master_repl_offset")
+	if err != nil {
+		return "", fmt.Sprintf("warning: master offset unavailable master=%s: %v", master, err)
+	}
+
+	lag := max(masterOffset-offset, 0)
+	detail := fmt.Sprintf("lag_bytes=%d max=%d master=%s", lag, maxBytes, master)
+	if lag > maxBytes {
+		return fmt.Sprintf("REPLICA_LAG_EXCEEDED lag=%d", lag), detail
+	}
+	return "", detail
+}
+
+// checkReplicaStale fails a replica that heard nothing from its master for
+// longer than MAX_MASTER_LAST_IO_SECONDS while the link still reads up, e.g.
+// behind a wedged master. The value is reported even without a threshold.
+// It reads -1 for a moment after a reconnect, which never fails.
+func checkReplicaStale(info *redisinfo.Info, maxSeconds int64) (string, string) {
+	lastIO, err := info.Int("master_last_io_seconds_ago")
+	if err != nil {
+		return "", ""
+	}
+
+	detail := fmt.Sprintf("master_last_io_seconds_ago=%d", lastIO)
+	if maxSeconds > 0 && lastIO > maxSeconds {
+		return fmt.Sprintf("REPLICA_STALE last_io=%ds", lastIO), fmt.Sprintf("%s max=%d", detail, maxSeconds)
+	}
+	return "", detail
+}
+
+// checkConnectedReplicas fails a master with fewer fully online replicas than
+// MIN_CONNECTED_REPLICAS, so orchestration holds off disruptive steps while
+// the shard has no redundancy. Replicas still in send_bulk or wait_bgsave
+// don't count.
+func checkConnectedReplicas(info *redisinfo.Info, want int64) (string, string) {
+	if want <= 0 {
+		return "", ""
+	}
+
+	have := int64(0)
+	for _, replica := range info.Replicas() {
+		if replica.State == "online" {
+			have++
+		}
+	}
+
+	detail := fmt.Sprintf("have=%d want=%d", have, want)
+	if have < want {
+		return "INSUFFICIENT_REPLICAS " + detail, detail
+	}
+	return "", detail
+}
+
+// checkFailoverState fails readiness on a master coordinating a FAILOVER.
+// Redis reports no-failover, waiting-for-sync while writes are paused until
+// the target replica catches up, and failover-in-progress while the target
+// is being promoted. Older servers don't report the field at all.
+func checkFailoverState(info *redisinfo.Info) (string, string) {
+	state, err := info.String("master_failover_state")
+	if err != nil || state == "no-failover" {
+		return "", ""
+	}
+
+	detail := "master_failover_state=" + state
+	return "FAILOVER_IN_PROGRESS " + detail, detail
+}
+
+// checkReplicaConfig fails a replica accepting writes, which are lost on the
+// next failover, and with EXPECT_FAILOVER_ELIGIBLE one whose replica-priority
+// of 0 keeps Sentinel from ever promoting it.
+func checkReplicaConfig(probeCtx context.Context, expectFailoverEligible bool) (string, string, error) {
+	reply, err := nodeClient(probeCtx).ConfigGet(probeCtx, "replica-*").Result()
+	if err != nil {
+		return "", "", err
+	}
+
+	readOnly := reply["replica-read-only"]
+	if readOnly != "yes" {
+		detail := "replica-read-only=" + readOnly
+		return "REPLICA_CONFIG_INVALID " + detail, detail, nil
+	}
+
+	priority := reply["replica-priority"]
+	if expectFailoverEligible && priority == "0" {
+		detail := "replica-priority=" + priority
+		return "REPLICA_CONFIG_INVALID " + detail, detail, nil
+	}
+
+	return "", "replica-read-only=yes replica-priority=" + priority, nil
+}
+
+// checkReplBacklog reports the replication backlog of a master, which bounds
+// how far behind a replica can fall and still partially resync. An inactive
+// backlog with replicas attached is only reported, since every blip then
+// costs a full resync. A backlog smaller than MIN_REPL_BACKLOG_BYTES fails
+// readiness.
+func checkReplBacklog(info *redisinfo.Info, minBytes int64) (string, string) {
+	active, errActive := info.Int("repl_backlog_active")
+	size, errSize := info.Int("repl_backlog_size")
+	if errActive != nil || errSize != nil {
+		return "", ""
+	}
+
+	detail := fmt.Sprintf("active=%d size=%d", active, size)
+	if histlen, err := info.Int("repl_backlog_histlen"); err == nil {
+		detail += fmt.Sprintf(" histlen=%d", histlen)
+	}
+	if offset, err := info.Int("repl_backlog_first_byte_offset"); err == nil {
+		detail += fmt.Sprintf(" first_byte_offset=%d", offset)
+	}
+
+	if minBytes > 0 && size < minBytes {
+		return fmt.Sprintf("REPL_BACKLOG_TOO_SMALL size=%d min=%d", size, minBytes), detail
+	}
+	if replicas, err := info.Int("connected_slaves"); err == nil && replicas > 0 && active == 0 {
+		return "", "warning: backlog inactive with replicas attached " + detail
+	}
+	return "", detail
+}
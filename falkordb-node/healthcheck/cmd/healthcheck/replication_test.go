@@ -0,0 +1,242 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"falkordb.cloud/main/internal/redisinfo"
+)
+
+func TestCheckReplicaLag(t *testing.T) {
+	master := func(replicas ...string) *redisinfo.Info {
+		return redisinfo.Parse("# Replication\nrole:master\nmaster_repl_offset:5000\n" + strings.Join(replicas, "\n"))
+	}
+
+	tests := []struct {
+		name       string
+		info       *redisinfo.Info
+		maxBytes   int64
+		wantDetail string
+	}{
+		{name: "disabled", info: master("slave0:ip=10.0.0.2,port=6379,state=online,offset=1000,lag=0"), maxBytes: -1},
+		{name: "within", info: master("slave0:ip=10.0.0.2,port=6379,state=online,offset=4900,lag=0"), maxBytes: 1000, wantDetail: "lag_bytes slave0=100"},
+		{
+			name:       "one behind",
+			info:       master("slave0:ip=10.0.0.2,port=6379,state=online,offset=4900,lag=0", "slave1:ip=10.0.0.3,port=6379,state=online,offset=1000,lag=3"),
+			maxBytes:   1000,
+			wantDetail: "warning: REPLICA_LAG_EXCEEDED slave1 lag=4000 max=1000",
+		},
+		{name: "not synced yet", info: master("slave0:ip=10.0.0.2,port=6379,state=wait_bgsave,offset=0,lag=0"), maxBytes: 1000},
+		{name: "ahead after a failover", info: master("slave0:ip=10.0.0.2,port=6379,state=online,offset=6000,lag=0"), maxBytes: 0, wantDetail: "lag_bytes slave0=0"},
+		{name: "no replicas", info: master(), maxBytes: 1000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reason, detail := checkReplicaLag(tt.info, tt.maxBytes)
+			if reason != "" || detail != tt.wantDetail {
+				t.Errorf("checkReplicaLag() = %q, %q, want no failure and %q", reason, detail, tt.wantDetail)
+			}
+		})
+	}
+}
+
+func TestCheckReplicaStale(t *testing.T) {
+	tests := []struct {
+		name       string
+		lastIO     string
+		maxSeconds int64
+		wantReason string
+	}{
+		{name: "recent", lastIO: "master_last_io_seconds_ago:1", maxSeconds: 10},
+		{name: "stale", lastIO: "master_last_io_seconds_ago:30", maxSeconds: 10, wantReason: "REPLICA_STALE last_io=30s"},
+		{name: "disabled", lastIO: "master_last_io_seconds_ago:30"},
+		{name: "reconnecting", lastIO: "master_last_io_seconds_ago:-1", maxSeconds: 10},
+		{name: "absent", maxSeconds: 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info := redisinfo.Parse("# Replication\nrole:slave\nmaster_link_status:up\n" + tt.lastIO)
+			if reason, _ := checkReplicaStale(info, tt.maxSeconds); reason != tt.wantReason {
+				t.Errorf("checkReplicaStale() = %q, want %q", reason, tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestReplicaLastIOThreshold(t *testing.T) {
+	stale := strings.Replace(replicaInfo, "master_last_io_seconds_ago:1", "master_last_io_seconds_ago:30", 1)
+	cfg := testConfig(t, map[string]string{"MAX_MASTER_LAST_IO_SECONDS": "10"})
+	p := newTestProbes(t, cfg, newFakeNode(stale))
+
+	w := serve(t, cfg, p, http.MethodGet, "/readyz", nil)
+	if w.Code != http.StatusServiceUnavailable || !strings.HasPrefix(w.Body.String(), "REPLICA_STALE last_io=30s") {
+		t.Errorf("GET /readyz = %d %q, want 503 REPLICA_STALE", w.Code, w.Body.String())
+	}
+}
+
+// withMaster makes the clients of p for remote nodes dial master
+func withMaster(t *testing.T, p *probes, master *fakeNode) {
+	t.Helper()
+
+	options := *p.targets.base
+	options.Dialer = master.dial
+	p.targets.base = &options
+}
+
+func TestReplicaLagOnReplica(t *testing.T) {
+	tests := []struct {
+		name         string
+		replica      string
+		masterOffset string
+		code         int
+		wantReason   string
+		wantDetail   string
+	}{
+		{name: "within", replica: "slave_repl_offset:100", masterOffset: "master_repl_offset:140", code: http.StatusOK, wantDetail: "lag_bytes=40 max=50 master=10.0.0.1:6379"},
+		{name: "behind", replica: "slave_repl_offset:100", masterOffset: "master_repl_offset:1000", code: http.StatusServiceUnavailable, wantReason: "REPLICA_LAG_EXCEEDED", wantDetail: "lag_bytes=900 max=50"},
+		{name: "not synced yet", replica: "slave_repl_offset:0", masterOffset: "master_repl_offset:1000", code: http.StatusOK},
+		{name: "ahead after a failover", replica: "slave_repl_offset:100", masterOffset: "master_repl_offset:10", code: http.StatusOK, wantDetail: "lag_bytes=0 max=50 master=10.0.0.1:6379"},
+		{name: "master unreachable", replica: "slave_repl_offset:100", code: http.StatusOK, wantDetail: "warning: master offset unavailable master=10.0.0.1:6379"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig(t, map[string]string{"MAX_REPLICA_LAG_BYTES": "50"})
+			p := newTestProbes(t, cfg, newFakeNode(strings.Replace(replicaInfo, "slave_repl_offset:100", tt.replica, 1)))
+			master := newFakeNode("# Replication\nrole:master\n" + tt.masterOffset + "\n")
+			if tt.masterOffset == "" {
+				master.dialErr = errors.New("connection refused")
+			}
+			withMaster(t, p, master)
+
+			checks := readinessChecks(t, cfg, p, tt.code)
+			lag := checks["replica_lag"]
+			if lag.ReasonCode != tt.wantReason || !strings.HasPrefix(lag.Detail, tt.wantDetail) {
+				t.Errorf("replica_lag = %+v, want %q %q", lag, tt.wantReason, tt.wantDetail)
+			}
+			if w := serve(t, cfg, p, http.MethodGet, "/readyz?nocache=1", nil); tt.wantReason != "" && !strings.HasPrefix(w.Body.String(), "REPLICA_LAG_EXCEEDED lag=900") {
+				t.Errorf("GET /readyz = %d %q, want REPLICA_LAG_EXCEEDED lag=900", w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestReplicaLagOnMaster(t *testing.T) {
+	behind := strings.Replace(masterInfo, "offset=100,", "offset=10,", 1)
+	cfg := testConfig(t, map[string]string{"MAX_REPLICA_LAG_BYTES": "50"})
+	p := newTestProbes(t, cfg, newFakeNode(behind))
+
+	w := serve(t, cfg, p, http.MethodGet, "/readyz", http.Header{"Accept": {"application/json"}})
+	var report struct {
+		Checks []checkResult `json:"checks"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil {
+		t.Fatalf("invalid report %q: %v", w.Body.String(), err)
+	}
+	if w.Code != http.StatusOK {
+		t.Errorf("GET /readyz = %d, a lagging replica must not fail its master", w.Code)
+	}
+	for _, result := range report.Checks {
+		if result.Name == "replica_lag" {
+			if !result.OK || result.Detail != "warning: REPLICA_LAG_EXCEEDED slave0 lag=90 max=50" {
+				t.Errorf("replica_lag = %+v, want the lag warning", result)
+			}
+			return
+		}
+	}
+	t.Errorf("no replica_lag check in %s", w.Body.String())
+}
+
+func TestCheckMasterLink(t *testing.T) {
+	tests := []struct {
+		name       string
+		link       string
+		wantReason string
+	}{
+		{name: "up", link: "master_link_status:up\nmaster_last_io_seconds_ago:1"},
+		{
+			name:       "down",
+			link:       "master_link_status:down\nmaster_last_io_seconds_ago:42\nmaster_link_down_since_seconds:40",
+			wantReason: "MASTER_LINK_DOWN master_link_status=down master_last_io_seconds_ago=42 master_link_down_since_seconds=40",
+		},
+		{
+			// Never connected since the restart: no I/O to report yet
+			name:       "reconnecting",
+			link:       "master_link_status:down\nmaster_last_io_seconds_ago:-1\nmaster_link_down_since_seconds:3",
+			wantReason: "MASTER_LINK_DOWN master_link_status=down master_last_io_seconds_ago=-1 master_link_down_since_seconds=3",
+		},
+		{name: "without the timings", link: "master_link_status:down", wantReason: "MASTER_LINK_DOWN master_link_status=down"},
+		{name: "absent", wantReason: "MASTER_LINK_DOWN master_link_status not found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info := redisinfo.Parse("# Replication\nrole:slave\nmaster_host:10.0.0.1\nmaster_sync_in_progress:0\n" + tt.link)
+			if reason := checkMasterLink(info); reason != tt.wantReason {
+				t.Errorf("checkMasterLink() = %q, want %q", reason, tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestMasterLinkDown(t *testing.T) {
+	down := strings.Replace(replicaInfo, "master_link_status:up\nmaster_last_io_seconds_ago:1", "master_link_status:down\nmaster_last_io_seconds_ago:42\nmaster_link_down_since_seconds:40", 1)
+	cfg := testConfig(t, nil)
+	p := newTestProbes(t, cfg, newFakeNode(down))
+
+	w := serve(t, cfg, p, http.MethodGet, "/readyz", nil)
+	want := "MASTER_LINK_DOWN master_link_status=down master_last_io_seconds_ago=42 master_link_down_since_seconds=40"
+	if w.Code != http.StatusServiceUnavailable || !strings.HasPrefix(w.Body.String(), want) {
+		t.Errorf("GET /readyz = %d %q, want 503 %q", w.Code, w.Body.String(), want)
+	}
+
+	p = newTestProbes(t, cfg, newFakeNode(replicaInfo))
+	if w := serve(t, cfg, p, http.MethodGet, "/readyz", nil); w.Code != http.StatusOK {
+		t.Errorf("GET /readyz with the link up = %d %q, want 200", w.Code, w.Body.String())
+	}
+}
+
+func TestCheckFailoverState(t *testing.T) {
+	tests := []struct {
+		state      string
+		wantReason string
+	}{
+		{state: "master_failover_state:no-failover"},
+		{state: "master_failover_state:waiting-for-sync", wantReason: "FAILOVER_IN_PROGRESS master_failover_state=waiting-for-sync"},
+		{state: "master_failover_state:failover-in-progress", wantReason: "FAILOVER_IN_PROGRESS master_failover_state=failover-in-progress"},
+		// Servers before Redis 6.2 don't report the field
+		{},
+	}
+	for _, tt := range tests {
+		info := redisinfo.Parse("# Replication\nrole:master\nconnected_slaves:1\n" + tt.state)
+		if reason, _ := checkFailoverState(info); reason != tt.wantReason {
+			t.Errorf("checkFailoverState(%q) = %q, want %q", tt.state, reason, tt.wantReason)
+		}
+	}
+}
+
+func TestFailoverInProgress(t *testing.T) {
+	failover := func(state string) string {
+		return strings.Replace(masterInfo, "master_repl_offset:100", "master_repl_offset:100\nmaster_failover_state:"+state, 1)
+	}
+	cfg := testConfig(t, nil)
+	node := newFakeNode(failover("failover-in-progress"))
+	p := newTestProbes(t, cfg, node)
+
+	w := serve(t, cfg, p, http.MethodGet, "/readyz?nocache=1", nil)
+	if w.Code != http.StatusServiceUnavailable || !strings.HasPrefix(w.Body.String(), "FAILOVER_IN_PROGRESS master_failover_state=failover-in-progress") {
+		t.Errorf("GET /readyz during the failover = %d %q, want 503 FAILOVER_IN_PROGRESS", w.Code, w.Body.String())
+	}
+	if w := serve(t, cfg, p, http.MethodGet, "/livez", nil); w.Code != http.StatusOK {
+		t.Errorf("GET /livez during the failover = %d %q, want 200", w.Code, w.Body.String())
+	}
+
+	// Readiness recovers once the failover is over, the node demoted or not
+	for _, info := range []string{failover("no-failover"), replicaInfo} {
+		node.setInfo(info)
+		if w := serve(t, cfg, p, http.MethodGet, "/readyz?nocache=1", nil); w.Code != http.StatusOK {
+			t.Errorf("GET /readyz after the failover = %d %q, want 200", w.Code, w.Body.String())
+		}
+	}
+}
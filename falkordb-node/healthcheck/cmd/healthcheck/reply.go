@@ -0,0 +1,35 @@
+package main
+
+import "fmt"
+
+// replyEntries normalizes replies such as SENTINEL MASTERS or MODULE LIST,
+// which are a list of flat key/value arrays under RESP2 and a list of maps
+// under RESP3.
+func replyEntries(reply interface{}) []map[string]string {
+	list, ok := reply.([]interface{})
+	if !ok {
+		return nil
+	}
+
+	entries := make([]map[string]string, 0, len(list))
+	for _, item := range list {
+		entry := map[string]string{}
+
+		switch v := item.(type) {
+		case []interface{}:
+			for i := 0; i+1 < len(v); i += 2 {
+				entry[fmt.Sprint(v[i])] = fmt.Sprint(v[i+1])
+			}
+		case map[interface{}]interface{}:
+			for key, value := range v {
+				entry[fmt.Sprint(key)] = fmt.Sprint(value)
+			}
+		default:
+			continue
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// reportSchemaVersion must be bumped on incompatible changes to the JSON body
+const reportSchemaVersion = 1
+
+const (
+	// defaultRetryAfter is suggested on 503 responses without a better estimate
+	defaultRetryAfter = 5 * time.Second
+	// maxRetryAfter keeps callers polling while a long sync may still speed up
+	maxRetryAfter = 30 * time.Second
+)
+
+type checkResult struct {
+	Name       string `json:"name"`
+	OK         bool   `json:"ok"`
+	ReasonCode string `json:"reason_code,omitempty"`
+	Detail     string `json:"detail,omitempty"`
+	// Wall clock time of the check, and the part of it spent in the Redis
+	// round trip
+	DurationMs float64 `json:"duration_ms,omitempty"`
+	RedisMs    float64 `json:"redis_ms,omitempty"`
+}
+
+// healthReport is the outcome of one probe evaluation. The HTTP status,
+// plain-text body and the reason code and detail of a failing report are
+// taken from the first failing check, so the JSON of a failure extends the
+// errorPayload shape.
+type healthReport struct {
+	SchemaVersion int           `json:"schema_version"`
+	Status        string        `json:"status"`
+	ReasonCode    string        `json:"reason_code,omitempty"`
+	Detail        string        `json:"detail,omitempty"`
+	Role          string        `json:"role,omitempty"`
+	Phase         string        `json:"phase,omitempty"`      // with BOOTSTRAP_GRACE_SECONDS
+	Credential    string        `json:"credential,omitempty"` // current or previous admin password
+	PingLatencyMs float64       `json:"ping_latency_ms,omitempty"`
+	FragRatio     float64       `json:"mem_fragmentation_ratio,omitempty"`
+	Sync          *syncProgress `json:"sync,omitempty"`
+	Persistence   *forkActivity `json:"persistence,omitempty"`
+	Keyspace      *keyspaceInfo `json:"keyspace,omitempty"`
+	MasterLastIO  *int64        `json:"master_last_io_seconds_ago,omitempty"` // replicas, -1 right after a reconnect
+	RoleClass     string        `json:"role_class,omitempty"`
+	Promotable    *bool         `json:"failover_eligible,omitempty"` // replicas, false with replica-priority 0
+	E2EMaster     string        `json:"e2e_master,omitempty"`        // resolved through the sentinels
+	ACLUsers      []aclResult   `json:"acl_users,omitempty"`
+	Checks        []checkResult `json:"checks"`
+	Circuit       string        `json:"circuit,omitempty"` // with CIRCUIT_FAILURE_THRESHOLD
+	FaultInjected bool          `json:"fault_injected,omitempty"`
+	DurationMs    float64       `json:"duration_ms,omitempty"` // of the whole evaluation
+
+	code    int
+	body    string
+	err     error
+	syncETA *time.Duration // of a failing local sync, once estimated
+}
+
+func newHealthReport() *healthReport {
+	return &healthReport{
+		SchemaVersion: reportSchemaVersion,
+		Status:        "pass",
+		Checks:        []checkResult{},
+		code:          http.StatusOK,
+		body:          "OK",
+	}
+}
+
+func (h *healthReport) pass(name string, detail string) {
+	h.Checks = append(h.Checks, checkResult{Name: name, OK: true, Detail: detail})
+}
+
+// reasonOf returns the reason code opening body, e.g. TLS_LISTENER_DOWN for
+// TLS_LISTENER_DOWN: connection refused
+func reasonOf(body string) string {
+	reason, _, _ := strings.Cut(body, " ")
+	return strings.TrimSuffix(reason, ":")
+}
+
+func (h *healthReport) fail(code int, body string, name string, detail string) {
+	reason := reasonOf(body)
+	h.Checks = append(h.Checks, checkResult{Name: name, OK: false, ReasonCode: reason, Detail: detail})
+
+	if h.Status == "pass" {
+		h.Status = "fail"
+		h.ReasonCode = reason
+		h.Detail = detail
+		h.code = code
+		h.body = body
+	}
+}
+
+// timeLastCheck records the timing of the check recorded last
+func (h *healthReport) timeLastCheck(elapsed time.Duration, timing *checkTiming) {
+	last := &h.Checks[len(h.Checks)-1]
+	last.DurationMs, last.RedisMs = milliseconds(elapsed), milliseconds(timing.redisTime())
+}
+
+// failErr records a failing check caused by an error talking to Redis
+func (h *healthReport) failErr(probeCtx context.Context, name string, err error) {
+	handleRedisError(probeCtx, err)
+
+	if h.Status == "pass" {
+		h.err = err
+	}
+	code, reason := classifyRedisError(err)
+	detail := err.Error()
+	// The handshake details only go to the log, see logReport
+	if reason == "TLS_HANDSHAKE_FAILED" {
+		detail = reason
+	}
+	h.fail(code, reason, name, detail)
+}
+
+// classifyRedisError maps an error talking to Redis to the HTTP status and
+// reason code of the probe. Reason codes are stable and always come first in
+// the body so callers can match on them:
+//
+//	REDIS_UNREACHABLE    502, the node can't be reached at all
+//	TIMEOUT              503, the node didn't answer within the probe timeout
+//	AUTH_FAILED          503, the node rejected our credentials or ACL user
+//	TLS_HANDSHAKE_FAILED 503, the TLS handshake with the node failed
+//	STARTING             503, the node is loading its dataset, or refuses
+//	                     connections within STARTUP_GRACE_SECONDS
+//	BUSY                 503, a script or module operation blocks the node
+//	COMMAND_FAILED       503, the node answered a check command with an error
+func classifyRedisError(err error) (int, string) {
+	switch {
+	case isTimeout(err):
+		return http.StatusServiceUnavailable, "TIMEOUT"
+	case isLoadingError(err), isStartingError(err):
+		return http.StatusServiceUnavailable, "STARTING"
+	case isBusyError(err):
+		return http.StatusServiceUnavailable, "BUSY"
+	case isAuthError(err), isNoPermError(err):
+		return http.StatusServiceUnavailable, "AUTH_FAILED"
+	case isTLSHandshakeError(err):
+		return http.StatusServiceUnavailable, "TLS_HANDSHAKE_FAILED"
+	case isRedisReply(err):
+		return http.StatusServiceUnavailable, "COMMAND_FAILED"
+	}
+	return http.StatusBadGateway, "REDIS_UNREACHABLE"
+}
+
+// failedCheck returns the first failing check, if any
+func (h *healthReport) failedCheck() (checkResult, bool) {
+	for _, check := range h.Checks {
+		if !check.OK {
+			return check, true
+		}
+	}
+	return checkResult{}, false
+}
+
+func (h *healthReport) ok() bool {
+	return h.Status == "pass"
+}
+
+// logReport logs failures at warn and successes only at debug level so probes
+// don't flood the logs. A node still starting is expected, not a warning.
+func logReport(r *http.Request, report *healthReport) {
+	check, failed := report.failedCheck()
+	if !failed {
+		slog.Debug("probe succeeded", "request_id", requestID(r), "endpoint", r.URL.Path, "role", report.Role)
+		return
+	}
+
+	attrs := []any{"request_id", requestID(r), "endpoint", r.URL.Path, "role", report.Role, "check", check.Name, "detail", check.Detail}
+	if report.Phase != "" {
+		attrs = append(attrs, "phase", report.Phase)
+	}
+	if target := r.URL.Query().Get("target"); target != "" {
+		attrs = append(attrs, "target", target)
+	}
+	if report.err != nil {
+		attrs = append(attrs, "error", report.err)
+	}
+	if report.ReasonCode == "STARTING" {
+		slog.Info("node starting", attrs...)
+		return
+	}
+	slog.Warn("probe failed", attrs...)
+}
+
+// writeReport renders the report in the format negotiated with the caller,
+// plain text by default. Nothing is written or recorded for a caller that
+// went away, the report is nil then.
+func writeReport(w http.ResponseWriter, r *http.Request, report *healthReport) {
+	if report == nil || errors.Is(r.Context().Err(), context.Canceled) {
+		slog.Debug("probe abandoned by the client", "request_id", requestID(r), "endpoint", r.URL.Path)
+		return
+	}
+
+	recordHealthCheck(report.ok())
+	logReport(r, report)
+
+	if report.err != nil && isInternalError(report.err) {
+		probesOf(r.Context()).internal.record(report.err.Error())
+	} else {
+		probesOf(r.Context()).internal.reset()
+	}
+
+	// Don't let a cached reply hide recovery, or another failure
+	if !report.ok() {
+		probesOf(r.Context()).info.invalidate()
+	}
+
+	if report.code == http.StatusServiceUnavailable {
+		reason := reasonOf(report.body)
+		var syncETA *time.Duration
+		if reason == "SYNC_IN_PROGRESS" {
+			syncETA = report.syncETA
+		}
+		setNotReadyHeaders(w, reason, syncETA)
+	}
+
+	if wantsJSON(r) {
+		writeJSON(w, r, report.code, report)
+		return
+	}
+
+	if r.URL.Query().Get("verbose") == "1" {
+		w.WriteHeader(report.code)
+		w.Write([]byte(renderVerbose(r.URL.Path, report)))
+		return
+	}
+
+	w.WriteHeader(report.code)
+	w.Write([]byte(report.body))
+}
+
+// renderVerbose renders one line per executed check in the style of the
+// Kubernetes /readyz?verbose output, followed by the overall verdict.
+func renderVerbose(endpoint string, report *healthReport) string {
+	var b strings.Builder
+	for _, check := range report.Checks {
+		line := "[+] " + check.Name + " ok"
+		if !check.OK {
+			line = "[-] " + check.Name + " fail"
+		}
+		if check.Detail != "" {
+			line += ": " + check.Detail
+		}
+		b.WriteString(line + "\n")
+	}
+
+	name := strings.TrimPrefix(endpoint, "/")
+	if report.ok() {
+		b.WriteString(name + " check passed\n")
+	} else {
+		b.WriteString(name + " check failed: " + report.body + "\n")
+	}
+	return b.String()
+}
+
+// setNotReadyHeaders sets the headers of a 503 response: X-Health-Reason with
+// the reason code, and Retry-After with the sync ETA when there is one, up to
+// maxRetryAfter.
+func setNotReadyHeaders(w http.ResponseWriter, reason string, syncETA *time.Duration) {
+	w.Header().Set("X-Health-Reason", reason)
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfterSeconds(syncETA)))
+}
+
+func retryAfterSeconds(syncETA *time.Duration) int {
+	retryAfter := defaultRetryAfter
+	if syncETA != nil {
+		retryAfter = *syncETA
+	}
+	retryAfter = min(max(retryAfter, time.Second), maxRetryAfter)
+	return int(math.Ceil(retryAfter.Seconds()))
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestWriteReport(t *testing.T) {
+	p := newTestProbes(t, testConfig(t, nil), newFakeNode(masterInfo))
+	request := func(target string) *http.Request {
+		r := httptest.NewRequest(http.MethodGet, target, nil)
+		return r.WithContext(withProbes(r.Context(), p))
+	}
+	report := newHealthReport()
+	report.pass("loading", "")
+	report.fail(http.StatusServiceUnavailable, "SYNC_IN_PROGRESS", "sync", "master_sync_in_progress=1")
+	report.fail(http.StatusServiceUnavailable, "MASTER_LINK_DOWN", "master_link", "master_link_status=down")
+
+	// The first failure decides the status and the plain text body
+	w := httptest.NewRecorder()
+	writeReport(w, request("/readyz"), report)
+	if w.Code != http.StatusServiceUnavailable || w.Body.String() != "SYNC_IN_PROGRESS" {
+		t.Errorf("plain text = %d %q, want 503 SYNC_IN_PROGRESS", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	writeReport(w, request("/readyz?format=json"), report)
+	var body healthReport
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("invalid JSON %q: %v", w.Body.String(), err)
+	}
+	if w.Code != http.StatusServiceUnavailable || w.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("JSON = %d %s, want 503 application/json", w.Code, w.Header().Get("Content-Type"))
+	}
+	if body.SchemaVersion != reportSchemaVersion || body.Status != "fail" || len(body.Checks) != 3 {
+		t.Errorf("JSON = %+v, want the failing report with every check", body)
+	}
+	if sync := body.Checks[1]; sync.Name != "sync" || sync.OK || sync.Detail != "master_sync_in_progress=1" {
+		t.Errorf("sync = %+v, want the failed check with its detail", sync)
+	}
+}
+
+func TestRetryAfterSeconds(t *testing.T) {
+	tests := []struct {
+		eta  time.Duration
+		want int
+	}{
+		{eta: 12 * time.Second, want: 12},
+		{eta: 20 * time.Minute, want: 30},
+		{eta: 0, want: 1},
+		{eta: 1500 * time.Millisecond, want: 2},
+	}
+	for _, tt := range tests {
+		if got := retryAfterSeconds(&tt.eta); got != tt.want {
+			t.Errorf("retryAfterSeconds(%v) = %d, want %d", tt.eta, got, tt.want)
+		}
+	}
+	if got := retryAfterSeconds(nil); got != 5 {
+		t.Errorf("retryAfterSeconds(nil) = %d, want 5", got)
+	}
+}
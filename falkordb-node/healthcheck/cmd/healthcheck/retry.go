@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var retryBackoff = []time.Duration{100 * time.Millisecond, 300 * time.Millisecond}
+
+// isRetryable reports whether err is a transient connection problem. Replies
+// from Redis, such as AUTH failures, and failed TLS handshakes are definitive
+// and never retried.
+func isRetryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	// An open circuit fails every attempt until it half-opens
+	var open *circuitOpenError
+	return !isRedisReply(err) && !isTLSHandshakeError(err) && !errors.As(err, &open)
+}
+
+// isRedisReply reports whether err is an error reply sent by Redis
+func isRedisReply(err error) bool {
+	var reply redis.Error
+	return errors.As(err, &reply)
+}
+
+// withRetry runs fn, retrying connection errors with exponential backoff
+// until probeCtx expires. The returned error notes how many attempts were
+// made, and is a startingError when the node still refuses connections within
+// STARTUP_GRACE_SECONDS.
+func withRetry(probeCtx context.Context, fn func() error) error {
+	p := probesOf(probeCtx)
+	attempts := 0
+	switched := false
+	for {
+		attempts++
+		err := fn()
+		if err == nil {
+			if switched && p.credentials.usingPrevious.Load() {
+				slog.Warn("node still uses the previous admin password")
+			}
+			return nil
+		}
+		if skipAuthForBootstrap(probeCtx, err) {
+			continue
+		}
+		// Within the same probe deadline, once per probe
+		if !switched && probeTarget(probeCtx) == "" && p.credentials.switchCredential(err) {
+			switched = true
+			continue
+		}
+
+		if !isRetryable(err) || attempts > p.retries {
+			if attempts > 1 {
+				err = fmt.Errorf("%w (after %d attempts)", err, attempts)
+			}
+			return startingIn(probeCtx, err)
+		}
+
+		backoff := retryBackoff[len(retryBackoff)-1]
+		if attempts-1 < len(retryBackoff) {
+			backoff = retryBackoff[attempts-1]
+		}
+
+		select {
+		case <-probeCtx.Done():
+			return startingIn(probeCtx, fmt.Errorf("%w (after %d attempts)", err, attempts))
+		case <-time.After(backoff):
+		}
+	}
+}
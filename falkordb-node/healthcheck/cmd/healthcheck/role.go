@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"falkordb.cloud/main/internal/redisinfo"
+)
+
+// acceptedRoles are the values accepted by expect_role and EXPECTED_ROLE.
+// replica is an alias of slave, the name used by INFO.
+var acceptedRoles = []string{"master", "slave", "replica", "sentinel"}
+
+func normalizeRole(role string) (string, bool) {
+	parsed := redisinfo.ParseRole(strings.ToLower(role))
+	return parsed.String(), parsed != redisinfo.RoleUnknown
+}
+
+type expectedRoleKey struct{}
+
+// requestedRoleKey marks a role asserted by the expect_role of the request
+// rather than EXPECTED_ROLE
+type requestedRoleKey struct{}
+
+// withExpectedRole resolves the role a probe must find, from the expect_role
+// query parameter or the configured EXPECTED_ROLE. It returns false for
+// invalid values.
+func withExpectedRole(probeCtx context.Context, r *http.Request, configured string) (context.Context, bool) {
+	value := r.URL.Query().Get("expect_role")
+	if value != "" {
+		probeCtx = context.WithValue(probeCtx, requestedRoleKey{}, true)
+	} else {
+		value = configured
+	}
+	if value == "" {
+		return probeCtx, true
+	}
+
+	role, ok := normalizeRole(value)
+	if !ok {
+		return probeCtx, false
+	}
+	return context.WithValue(probeCtx, expectedRoleKey{}, role), true
+}
+
+// requestedRole reports whether the probe asserts the expect_role of its
+// request
+func requestedRole(probeCtx context.Context) bool {
+	requested, _ := probeCtx.Value(requestedRoleKey{}).(bool)
+	return requested
+}
+
+// withConfiguredRole applies EXPECTED_ROLE to evaluations not tied to a
+// request, which was validated at startup.
+func withConfiguredRole(probeCtx context.Context, cfg *Config) context.Context {
+	role, ok := normalizeRole(cfg.ExpectedRole)
+	if !ok {
+		return probeCtx
+	}
+	return context.WithValue(probeCtx, expectedRoleKey{}, role)
+}
+
+// checkExpectedRole fails the report when the probe asserted a role the node
+// doesn't have, e.g. while confirming a promotion completed.
+func checkExpectedRole(probeCtx context.Context, report *healthReport, actual string) bool {
+	expected, _ := probeCtx.Value(expectedRoleKey{}).(string)
+	if expected == "" {
+		return true
+	}
+
+	detail := fmt.Sprintf("expected=%s actual=%s", expected, actual)
+	if expected != actual {
+		report.fail(http.StatusServiceUnavailable, "ROLE_MISMATCH "+detail, "expected_role", detail)
+		return false
+	}
+
+	report.pass("expected_role", detail)
+	return true
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"strings"
+)
+
+// The role class tells serving nodes from warm standbys, replicas kept with
+// replica-priority 0 as backup sources only and never promoted
+const (
+	roleClassServingMaster  = "serving-master"
+	roleClassServingReplica = "serving-replica"
+	roleClassStandbyReplica = "standby-replica"
+	roleClassSentinel       = "sentinel"
+)
+
+// classifyRole returns the role class of the node and, for a replica,
+// whether it is eligible for failover. A replica whose replica-priority can't
+// be read, e.g. with CONFIG renamed, is a serving replica of unknown
+// eligibility.
+func classifyRole(probeCtx context.Context, role string) (string, *bool) {
+	switch role {
+	case "sentinel":
+		return roleClassSentinel, nil
+	case "master":
+		return roleClassServingMaster, nil
+	case "slave":
+	default:
+		return "", nil
+	}
+
+	reply, err := nodeClient(probeCtx).ConfigGet(probeCtx, "replica-priority").Result()
+	priority, ok := reply["replica-priority"]
+	if err != nil || !ok {
+		return roleClassServingReplica, nil
+	}
+
+	eligible := strings.TrimSpace(priority) != "0"
+	if !eligible {
+		return roleClassStandbyReplica, &eligible
+	}
+	return roleClassServingReplica, &eligible
+}
+
+// checkStandby fails a standby replica with STANDBY_READY=false, keeping it
+// out of rotation. It is only reported on standby replicas.
+func checkStandby(roleClass string, standbyReady bool) (string, string) {
+	switch {
+	case roleClass != roleClassStandbyReplica:
+		return "", ""
+	case standbyReady:
+		return "", "replica-priority=0"
+	}
+	return "STANDBY_REPLICA replica-priority=0", "standby replicas are kept out of rotation with STANDBY_READY=false"
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestRoleClass(t *testing.T) {
+	yes, no := true, false
+	tests := []struct {
+		name          string
+		info          string
+		configReply   any
+		env           map[string]string
+		code          int
+		body          string
+		wantClass     string
+		wantEligible  *bool
+		wantStandby   string // detail of the standby check, none when empty
+		wantStandbyOK bool
+	}{
+		{name: "master", info: masterInfo, configReply: []string{"replica-priority", "0"}, code: http.StatusOK, body: "OK", wantClass: roleClassServingMaster},
+		{name: "serving replica", info: replicaInfo, configReply: []string{"replica-priority", "100"}, code: http.StatusOK, body: "OK", wantClass: roleClassServingReplica, wantEligible: &yes},
+		{
+			name: "standby replica", info: replicaInfo, configReply: []string{"replica-priority", "0"}, code: http.StatusOK, body: "OK",
+			wantClass: roleClassStandbyReplica, wantEligible: &no, wantStandby: "replica-priority=0", wantStandbyOK: true,
+		},
+		{
+			name: "standby replica kept out of rotation", info: replicaInfo, configReply: []string{"replica-priority", "0"}, env: map[string]string{"STANDBY_READY": "false"},
+			code: http.StatusServiceUnavailable, body: "STANDBY_REPLICA replica-priority=0",
+			wantClass: roleClassStandbyReplica, wantEligible: &no, wantStandby: "standby replicas are kept out of rotation with STANDBY_READY=false",
+		},
+		{
+			name: "standby check not selected", info: replicaInfo, configReply: []string{"replica-priority", "0"}, env: map[string]string{"STANDBY_READY": "false", "HEALTH_CHECKS": "role,sync"},
+			code: http.StatusOK, body: "OK", wantClass: roleClassStandbyReplica, wantEligible: &no,
+		},
+		{name: "config key missing", info: replicaInfo, configReply: []string{}, env: map[string]string{"STANDBY_READY": "false"}, code: http.StatusOK, body: "OK", wantClass: roleClassServingReplica},
+		{
+			name: "config renamed", info: replicaInfo, configReply: replyError("ERR unknown command 'CONFIG'"), env: map[string]string{"STANDBY_READY": "false"},
+			code: http.StatusOK, body: "OK", wantClass: roleClassServingReplica, wantStandby: "skipped: command unavailable (CONFIG)", wantStandbyOK: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig(t, tt.env)
+			node := newFakeNode(tt.info)
+			node.reply("CONFIG GET", tt.configReply)
+			p := newTestProbes(t, cfg, node)
+
+			w := serve(t, cfg, p, http.MethodGet, "/readyz", nil)
+			if w.Code != tt.code || !strings.HasPrefix(w.Body.String(), tt.body) {
+				t.Errorf("GET /readyz = %d %q, want %d %q", w.Code, w.Body.String(), tt.code, tt.body)
+			}
+
+			w = serve(t, cfg, p, http.MethodGet, "/readyz", http.Header{"Accept": {"application/json"}})
+			var report healthReport
+			if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil {
+				t.Fatalf("invalid report %q: %v", w.Body.String(), err)
+			}
+			if report.RoleClass != tt.wantClass || (report.Promotable == nil) != (tt.wantEligible == nil) || (report.Promotable != nil && *report.Promotable != *tt.wantEligible) {
+				t.Errorf("role class = %q, eligible %v, want %q, %v", report.RoleClass, report.Promotable, tt.wantClass, tt.wantEligible)
+			}
+
+			var standby *checkResult
+			for i := range report.Checks {
+				if report.Checks[i].Name == "standby" {
+					standby = &report.Checks[i]
+				}
+			}
+			switch {
+			case tt.wantStandby == "" && standby != nil:
+				t.Errorf("standby check %+v, want none", *standby)
+			case tt.wantStandby != "" && (standby == nil || standby.Detail != tt.wantStandby || standby.OK != tt.wantStandbyOK):
+				t.Errorf("standby check %+v, want ok=%v %q", standby, tt.wantStandbyOK, tt.wantStandby)
+			}
+		})
+	}
+}
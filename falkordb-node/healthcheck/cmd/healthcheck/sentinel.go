@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"falkordb.cloud/main/internal/redisinfo"
+	"github.com/redis/go-redis/v9"
+)
+
+// isSentinel reports whether the probed process is a sentinel, either because
+// SENTINEL_MODE is set or because INFO says so.
+func isSentinel(info *redisinfo.Info, sentinelMode bool) bool {
+	if sentinelMode {
+		return true
+	}
+
+	role, _, _ := info.NodeRole()
+	return role == redisinfo.RoleSentinel
+}
+
+// checkSentinel verifies the sentinel monitors MASTER_NAME, that the master
+// isn't flagged down or otherwise unhealthy, that it has at least
+// EXPECTED_REPLICAS replicas and that enough peer sentinels are known. A
+// sentinel that lost its configuration fails with the names it does monitor.
+// It returns an empty string when healthy, or the reason otherwise.
+func checkSentinel(probeCtx context.Context, cfg *Config) (string, error) {
+	reply, err := nodeClient(probeCtx).Do(probeCtx, "SENTINEL", "MASTERS").Result()
+	if err != nil {
+		return "", err
+	}
+
+	masters := replyEntries(reply)
+	if len(masters) == 0 {
+		return "NO_MONITORED_MASTERS", nil
+	}
+
+	var master map[string]string
+	var monitored []string
+	for _, entry := range masters {
+		monitored = append(monitored, entry["name"])
+		if entry["name"] == cfg.MasterName {
+			master = entry
+		}
+	}
+
+	if master == nil {
+		return fmt.Sprintf("MASTER_NOT_MONITORED want=%s monitored=%s", cfg.MasterName, strings.Join(monitored, ",")), nil
+	}
+
+	flags := strings.Split(master["flags"], ",")
+	for _, flag := range flags {
+		if flag == "s_down" || flag == "o_down" {
+			return fmt.Sprintf("MASTER_DOWN master=%s flag=%s", cfg.MasterName, flag), nil
+		}
+	}
+	for _, flag := range flags {
+		if flag != "master" {
+			return fmt.Sprintf("MASTER_FLAGGED master=%s flags=%s", cfg.MasterName, master["flags"]), nil
+		}
+	}
+
+	replicas, _ := strconv.Atoi(master["num-slaves"])
+	if want := int(cfg.ExpectedReplicas); replicas < want {
+		return fmt.Sprintf("INSUFFICIENT_REPLICAS master=%s have=%d want=%d", cfg.MasterName, replicas, want), nil
+	}
+
+	others, _ := strconv.Atoi(master["num-other-sentinels"])
+	if want := int(cfg.SentinelMinOtherSentinels); others < want {
+		return fmt.Sprintf("INSUFFICIENT_SENTINELS master=%s have=%d want=%d", cfg.MasterName, others, want), nil
+	}
+
+	return "", nil
+}
+
+// checkQuorum verifies the sentinels could authorize a failover of the
+// monitored master. It is retried once since the master name can briefly be
+// unknown while a failover updates the configuration. It returns an empty
+// string when healthy, or the sentinel's reply otherwise.
+func checkQuorum(probeCtx context.Context, masterName string) (string, error) {
+	var err error
+	for attempt := 0; attempt < 2; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-probeCtx.Done():
+				return "", probeCtx.Err()
+			case <-time.After(200 * time.Millisecond):
+			}
+		}
+
+		err = nodeClient(probeCtx).Do(probeCtx, "SENTINEL", "CKQUORUM", masterName).Err()
+		if err == nil {
+			return "", nil
+		}
+		if !isRedisReply(err) {
+			return "", err
+		}
+	}
+
+	return "NO_QUORUM " + err.Error(), nil
+}
+
+// staleSentinelHello is how long a peer may go without a hello message, sent
+// every 2 seconds, before its entry counts as stale
+const staleSentinelHello = time.Minute
+
+// checkSentinelPeers reports peer sentinels that are flagged down or
+// disconnected, or that haven't said hello in a long time. Such entries still
+// count in num-other-sentinels, so they hide a shrunk sentinel set until
+// SENTINEL RESET is run. They are only reported, INSUFFICIENT_SENTINELS fails.
+func checkSentinelPeers(probeCtx context.Context, masterName string) (string, string, error) {
+	reply, err := nodeClient(probeCtx).Do(probeCtx, "SENTINEL", "SENTINELS", masterName).Result()
+	if err != nil {
+		return "", "", err
+	}
+
+	peers := replyEntries(reply)
+	var stale []string
+	for _, peer := range peers {
+		if reason := staleSentinel(peer); reason != "" {
+			stale = append(stale, net.JoinHostPort(peer["ip"], peer["port"])+" "+reason)
+		}
+	}
+
+	if len(stale) == 0 {
+		return "", fmt.Sprintf("peers=%d", len(peers)), nil
+	}
+
+	slog.Warn("stale sentinel entries, SENTINEL RESET may be needed", "master", masterName, "stale", stale)
+	return "", fmt.Sprintf("warning: peers=%d stale=%s", len(peers), strings.Join(stale, ",")), nil
+}
+
+// staleSentinel returns why a SENTINEL SENTINELS entry is stale, if it is
+func staleSentinel(peer map[string]string) string {
+	for _, flag := range strings.Split(peer["flags"], ",") {
+		if flag == "s_down" || flag == "o_down" || flag == "disconnected" {
+			return "flags=" + peer["flags"]
+		}
+	}
+
+	if hello, err := strconv.ParseInt(peer["last-hello-message"], 10, 64); err == nil && time.Duration(hello)*time.Millisecond > staleSentinelHello {
+		return fmt.Sprintf("last_hello_ms=%d", hello)
+	}
+	return ""
+}
+
+// newSentinelClient returns the client of SENTINEL_HOST, to cross-check the
+// role of masters against the sentinels' view.
+func newSentinelClient(cfg *Config) *redis.SentinelClient {
+	return sentinelClientFor(cfg, net.JoinHostPort(cfg.SentinelHost, cfg.SentinelPort))
+}
+
+func sentinelClientFor(cfg *Config, addr string) *redis.SentinelClient {
+	return redis.NewSentinelClient(&redis.Options{
+		Addr:         addr,
+		Password:     cfg.SentinelAuth,
+		DialTimeout:  cfg.SentinelTimeout,
+		ReadTimeout:  cfg.SentinelTimeout,
+		WriteTimeout: cfg.SentinelTimeout,
+		PoolSize:     1,
+		MaxRetries:   -1,
+		Protocol:     int(cfg.RedisProtocol),
+	})
+}
+
+// checkSentinelMaster catches split brain by failing a master the sentinels
+// don't consider the master anymore. An unreachable sentinel only logs a
+// warning, a sentinel outage must not take down every master.
+func checkSentinelMaster(probeCtx context.Context, cfg *Config) (string, string, error) {
+	sentinelClient := probesOf(probeCtx).sentinel
+	if sentinelClient == nil || probeTarget(probeCtx) != "" {
+		return "", "", nil
+	}
+
+	sentinelCtx, cancel := context.WithTimeout(probeCtx, cfg.SentinelTimeout)
+	defer cancel()
+
+	addr, err := sentinelClient.GetMasterAddrByName(sentinelCtx, cfg.MasterName).Result()
+	if err != nil || len(addr) != 2 {
+		slog.Warn("error asking sentinel for the master address, skipping", "sentinel", cfg.SentinelHost, "master", cfg.MasterName, "error", err)
+		return "", "sentinel unreachable, skipped", nil
+	}
+
+	actual, err := podIP(cfg)
+	if err != nil {
+		return "", "", err
+	}
+
+	sentinelSays := net.JoinHostPort(addr[0], addr[1])
+	hosts := []string{addr[0]}
+	if net.ParseIP(addr[0]) == nil {
+		if resolved, err := net.DefaultResolver.LookupHost(sentinelCtx, addr[0]); err == nil {
+			hosts = resolved
+		}
+	}
+
+	if !containsIP(hosts, actual) || (cfg.NodePort != "" && addr[1] != cfg.NodePort) {
+		return "NOT_SENTINEL_MASTER sentinel_says=" + sentinelSays, "sentinel_says=" + sentinelSays + " actual=" + net.JoinHostPort(actual, cfg.NodePort), nil
+	}
+	return "", "sentinel_says=" + sentinelSays, nil
+}
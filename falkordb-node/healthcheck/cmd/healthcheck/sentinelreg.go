@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// registrationSentinel is one of SENTINEL_ADDRS
+type registrationSentinel struct {
+	addr   string
+	client *redis.SentinelClient
+}
+
+// newRegistrationSentinels returns the SENTINEL_ADDRS queried with
+// REQUIRE_SENTINEL_REGISTRATION
+func newRegistrationSentinels(cfg *Config) []registrationSentinel {
+	sentinels := make([]registrationSentinel, 0, len(cfg.SentinelAddrs))
+	for _, addr := range cfg.SentinelAddrs {
+		sentinels = append(sentinels, registrationSentinel{addr: addr, client: sentinelClientFor(cfg, addr)})
+	}
+	return sentinels
+}
+
+// checkSentinelRegistration keeps a node out of rotation until the sentinels
+// know it, so clients discovering it through DNS find it through sentinel
+// discovery too. A replica must be listed by SENTINEL REPLICAS under its
+// announced address, and a master must be the address returned by
+// get-master-addr-by-name. SENTINEL_ADDRS are tried in order, each within
+// SENTINEL_TIMEOUT_MS, until one answers, a node no sentinel can vouch for
+// fails with NOT_REGISTERED_WITH_SENTINEL.
+func checkSentinelRegistration(probeCtx context.Context, cfg *Config, role string) (string, string, error) {
+	// The announced address is only known for the local node
+	registrationSentinels := probesOf(probeCtx).registration
+	if len(registrationSentinels) == 0 || probeTarget(probeCtx) != "" {
+		return "", "", nil
+	}
+
+	host, port, err := nodeAnnouncedAddr(probeCtx, cfg)
+	if err != nil {
+		return "", "", err
+	}
+	self := net.JoinHostPort(host, port)
+
+	var errs []string
+	for _, sentinel := range registrationSentinels {
+		if probeCtx.Err() != nil {
+			break
+		}
+		registered, known, err := askSentinel(probeCtx, cfg, sentinel.client, role, host, port)
+		if err != nil {
+			errs = append(errs, sentinel.addr+": "+err.Error())
+			continue
+		}
+
+		detail := "addr=" + self + " sentinel=" + sentinel.addr
+		if !registered {
+			if role == "master" {
+				detail += " sentinel_says=" + known
+			}
+			return "NOT_REGISTERED_WITH_SENTINEL addr=" + self, detail, nil
+		}
+		return "", detail, nil
+	}
+
+	if len(errs) == 0 {
+		errs = append(errs, "probe timeout")
+	}
+	return "NOT_REGISTERED_WITH_SENTINEL addr=" + self, "no sentinel answered: " + strings.Join(errs, "; "), nil
+}
+
+// askSentinel tells whether the sentinel knows the node at host:port in the
+// role, and for a master the address the sentinel has instead
+func askSentinel(probeCtx context.Context, cfg *Config, client *redis.SentinelClient, role string, host string, port string) (bool, string, error) {
+	sentinelCtx, cancel := context.WithTimeout(probeCtx, cfg.SentinelTimeout)
+	defer cancel()
+
+	if role == "master" {
+		addr, err := client.GetMasterAddrByName(sentinelCtx, cfg.MasterName).Result()
+		if err != nil {
+			return false, "", err
+		}
+		if len(addr) != 2 {
+			return false, "", errors.New("unexpected get-master-addr-by-name reply")
+		}
+		return addr[1] == port && sameHost(sentinelCtx, addr[0], host), net.JoinHostPort(addr[0], addr[1]), nil
+	}
+
+	replicas, err := client.Replicas(sentinelCtx, cfg.MasterName).Result()
+	if err != nil {
+		return false, "", err
+	}
+	for _, replica := range replicas {
+		if replica["port"] == port && sameHost(sentinelCtx, replica["ip"], host) {
+			return true, "", nil
+		}
+	}
+	return false, "", nil
+}
+
+// sameHost compares two hosts, resolving the hostnames among them
+func sameHost(resolveCtx context.Context, a string, b string) bool {
+	if strings.EqualFold(a, b) {
+		return true
+	}
+
+	resolve := func(host string) []string {
+		if net.ParseIP(host) != nil {
+			return []string{host}
+		}
+		addrs, _ := net.DefaultResolver.LookupHost(resolveCtx, host)
+		return addrs
+	}
+	resolved := resolve(b)
+	for _, addr := range resolve(a) {
+		if containsIP(resolved, addr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// slowlogWindow is the shortest interval the growth is measured over, so
+// frequent probes don't extrapolate a single slow query to a high rate
+const slowlogWindow = 10 * time.Second
+
+// slowlogSample is the SLOWLOG LEN that started the current window, and the
+// growth measured over the previous one
+var slowlogSample = struct {
+	mu       sync.Mutex
+	length   int64
+	at       time.Time
+	growth   float64
+	measured bool
+}{}
+
+// checkSlowlogGrowth flags a slowlog filling up quickly, which tends to
+// precede customer visible latency. Growth above SLOWLOG_GROWTH_PER_MINUTE
+// is only reported, growth above SLOWLOG_FAIL_THRESHOLD per minute fails
+// readiness. Both are disabled when not positive.
+func checkSlowlogGrowth(probeCtx context.Context, warnPerMinute int64, failPerMinute int64) (string, string, error) {
+	if warnPerMinute <= 0 && failPerMinute <= 0 {
+		return "", "", nil
+	}
+	// The previous sample describes the local node only
+	if probeTarget(probeCtx) != "" {
+		return "", "", nil
+	}
+
+	length, err := nodeClient(probeCtx).Do(probeCtx, "SLOWLOG", "LEN").Int64()
+	if err != nil {
+		return "", "", err
+	}
+
+	growth, ok := slowlogGrowthPerMinute(length)
+	if !ok {
+		return "", fmt.Sprintf("len=%d", length), nil
+	}
+	slowlogGrowthGauge.Set(growth)
+
+	detail := fmt.Sprintf("len=%d growth_per_minute=%.1f", length, growth)
+	switch {
+	case failPerMinute > 0 && growth > float64(failPerMinute):
+		return fmt.Sprintf("SLOWLOG_GROWTH growth_per_minute=%.1f", growth), fmt.Sprintf("%s max=%d", detail, failPerMinute), nil
+	case warnPerMinute > 0 && growth > float64(warnPerMinute):
+		return "", "warning: " + detail, nil
+	}
+	return "", detail, nil
+}
+
+// slowlogGrowthPerMinute returns the growth over the last full window,
+// starting a new one with length once slowlogWindow passed. After SLOWLOG
+// RESET the entries since the reset count as the growth. It returns false
+// until a first window completed.
+func slowlogGrowthPerMinute(length int64) (float64, bool) {
+	slowlogSample.mu.Lock()
+	defer slowlogSample.mu.Unlock()
+
+	now := time.Now()
+	if slowlogSample.at.IsZero() {
+		slowlogSample.length, slowlogSample.at = length, now
+		return 0, false
+	}
+
+	if elapsed := now.Sub(slowlogSample.at); elapsed >= slowlogWindow {
+		added := length - slowlogSample.length
+		if added < 0 {
+			added = length
+		}
+		slowlogSample.growth = float64(added) / elapsed.Minutes()
+		slowlogSample.measured = true
+		slowlogSample.length, slowlogSample.at = length, now
+	}
+	return slowlogSample.growth, slowlogSample.measured
+}
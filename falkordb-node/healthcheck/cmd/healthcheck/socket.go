@@ -0,0 +1,49 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"time"
+)
+
+// listenUnix listens on HEALTH_CHECK_SOCKET_PATH with HEALTH_CHECK_SOCKET_MODE
+// permissions, for co-located containers sharing the volume. A socket file
+// left behind by a crash is removed first, unless another process still
+// answers on it. The listener unlinks the file when closed, so on graceful
+// shutdown.
+func listenUnix(cfg *Config) (net.Listener, error) {
+	if err := removeStaleSocket(cfg.SocketPath); err != nil {
+		return nil, err
+	}
+
+	listener, err := net.Listen("unix", cfg.SocketPath)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(cfg.SocketPath, cfg.SocketMode); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	return listener, nil
+}
+
+func removeStaleSocket(path string) error {
+	stat, err := os.Lstat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if stat.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("%s exists and is not a socket", path)
+	}
+
+	if conn, err := net.DialTimeout("unix", path, time.Second); err == nil {
+		conn.Close()
+		return fmt.Errorf("%s is in use by another process", path)
+	}
+	return os.Remove(path)
+}
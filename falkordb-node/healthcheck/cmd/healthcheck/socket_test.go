@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestProbeOverUnixSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "healthcheck.sock")
+	cfg := testConfig(t, map[string]string{"HEALTH_CHECK_SOCKET_PATH": path, "HEALTH_CHECK_SOCKET_MODE": "0600"})
+	if !cfg.SocketOnly {
+		t.Error("SocketOnly = false without a port set")
+	}
+	p := newTestProbes(t, cfg, newFakeNode(masterInfo))
+
+	listener, err := listenUnix(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stat, err := os.Stat(path)
+	if err != nil || stat.Mode().Perm() != 0o600 {
+		t.Errorf("socket mode = %v, %v, want 0600", stat.Mode().Perm(), err)
+	}
+
+	server := &http.Server{Handler: newHealthCheckHandler(cfg, p)}
+	go server.Serve(listener)
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, network string, addr string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, "unix", path)
+		},
+	}}
+	resp, err := client.Get("http://healthcheck/readyz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(string(body), "OK") {
+		t.Errorf("GET /readyz over the socket = %d %q, want 200 OK", resp.StatusCode, body)
+	}
+
+	// Closing the server unlinks the socket
+	server.Close()
+	if _, err := os.Lstat(path); !os.IsNotExist(err) {
+		t.Errorf("socket left behind on shutdown: %v", err)
+	}
+}
+
+func TestListenUnixStaleSocket(t *testing.T) {
+	dir := t.TempDir()
+
+	t.Run("left by a crash", func(t *testing.T) {
+		path := filepath.Join(dir, "stale.sock")
+		stale, err := net.ListenUnix("unix", &net.UnixAddr{Name: path, Net: "unix"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		stale.SetUnlinkOnClose(false)
+		stale.Close()
+
+		listener, err := listenUnix(testConfig(t, map[string]string{"HEALTH_CHECK_SOCKET_PATH": path}))
+		if err != nil {
+			t.Fatalf("listenUnix() over a stale socket: %v", err)
+		}
+		listener.Close()
+	})
+
+	t.Run("in use", func(t *testing.T) {
+		path := filepath.Join(dir, "live.sock")
+		live, err := net.Listen("unix", path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer live.Close()
+
+		if listener, err := listenUnix(testConfig(t, map[string]string{"HEALTH_CHECK_SOCKET_PATH": path})); err == nil {
+			listener.Close()
+			t.Error("listenUnix() took over a socket in use")
+		}
+	})
+
+	t.Run("not a socket", func(t *testing.T) {
+		path := filepath.Join(dir, "file")
+		if err := os.WriteFile(path, nil, 0o600); err != nil {
+			t.Fatal(err)
+		}
+
+		if listener, err := listenUnix(testConfig(t, map[string]string{"HEALTH_CHECK_SOCKET_PATH": path})); err == nil {
+			listener.Close()
+			t.Error("listenUnix() removed a regular file")
+		}
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("regular file removed: %v", err)
+		}
+	})
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// processStart is when the healthcheck started, along with redis-server in
+// the same pod
+var processStart = time.Now()
+
+// startingError is a connection refused by a node still booting, within
+// STARTUP_GRACE_SECONDS
+type startingError struct {
+	err error
+}
+
+func (e *startingError) Error() string {
+	return e.err.Error()
+}
+
+func (e *startingError) Unwrap() error {
+	return e.err
+}
+
+// startingIn returns err as a startingError when it is a connection refused
+// within the startup grace of the probes of probeCtx
+func startingIn(probeCtx context.Context, err error) error {
+	if isConnRefused(err) && probesOf(probeCtx).inStartupGrace() {
+		return &startingError{err: err}
+	}
+	return err
+}
+
+// isStartingError reports whether err was refused while the node boots
+func isStartingError(err error) bool {
+	var starting *startingError
+	return errors.As(err, &starting)
+}
+
+// isLoadingError reports whether the node is still loading its dataset
+func isLoadingError(err error) bool {
+	return replyHasPrefix(err, "LOADING")
+}
+
+// isBusyError reports whether a script or module operation keeps the node
+// from serving commands
+func isBusyError(err error) bool {
+	return replyHasPrefix(err, "BUSY")
+}
+
+// replyHasPrefix reports whether the error reply err wraps starts with
+// prefix, however the error was annotated on the way
+func replyHasPrefix(err error, prefix string) bool {
+	var reply redis.Error
+	return errors.As(err, &reply) && strings.HasPrefix(reply.Error(), prefix)
+}
+
+// isConnRefused reports whether nothing listens on the node address yet, or,
+// over NODE_SOCKET, the socket wasn't created yet
+func isConnRefused(err error) bool {
+	return errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ENOENT)
+}
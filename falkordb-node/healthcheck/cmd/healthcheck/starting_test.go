@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// redisReply is an error reply of the node, as go-redis returns it
+type redisReply string
+
+func (e redisReply) Error() string { return string(e) }
+
+func (redisReply) RedisError() {}
+
+func TestClassifyRedisError(t *testing.T) {
+	refused := &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}
+	tests := []struct {
+		name   string
+		err    error
+		code   int
+		reason string
+	}{
+		{name: "loading", err: redisReply("LOADING Redis is loading the dataset in memory"), code: http.StatusServiceUnavailable, reason: "STARTING"},
+		{name: "busy script", err: redisReply("BUSY Redis is busy running a script. You can only call SCRIPT KILL or SHUTDOWN NOSAVE."), code: http.StatusServiceUnavailable, reason: "BUSY"},
+		{name: "busy module", err: redisReply("BUSY Redis is busy running a module command."), code: http.StatusServiceUnavailable, reason: "BUSY"},
+		{name: "wrapped loading", err: fmt.Errorf("info: %w", redisReply("LOADING Redis is loading the dataset in memory")), code: http.StatusServiceUnavailable, reason: "STARTING"},
+		{name: "refused while starting", err: &startingError{err: refused}, code: http.StatusServiceUnavailable, reason: "STARTING"},
+		{name: "refused", err: refused, code: http.StatusBadGateway, reason: "REDIS_UNREACHABLE"},
+		{name: "wrong password", err: redisReply("WRONGPASS invalid username-password pair or user is disabled."), code: http.StatusServiceUnavailable, reason: "AUTH_FAILED"},
+		{name: "no permission", err: redisReply("NOPERM this user has no permissions to run the 'info' command"), code: http.StatusServiceUnavailable, reason: "AUTH_FAILED"},
+		{name: "timeout", err: context.DeadlineExceeded, code: http.StatusServiceUnavailable, reason: "TIMEOUT"},
+		{name: "other error reply", err: redisReply("ERR syntax error"), code: http.StatusServiceUnavailable, reason: "COMMAND_FAILED"},
+		{name: "a reply only mentioning loading", err: redisReply("ERR LOADING is not a command"), code: http.StatusServiceUnavailable, reason: "COMMAND_FAILED"},
+		{name: "not a reply", err: errors.New("LOADING"), code: http.StatusBadGateway, reason: "REDIS_UNREACHABLE"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code, reason := classifyRedisError(tt.err); code != tt.code || reason != tt.reason {
+				t.Errorf("classifyRedisError(%v) = %d %s, want %d %s", tt.err, code, reason, tt.code, tt.reason)
+			}
+		})
+	}
+}
+
+func TestLoadingAndBusyNode(t *testing.T) {
+	tests := []struct {
+		reply  string
+		reason string
+	}{
+		{reply: "LOADING Redis is loading the dataset in memory", reason: "STARTING"},
+		{reply: "BUSY Redis is busy running a script. You can only call SCRIPT KILL or SHUTDOWN NOSAVE.", reason: "BUSY"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.reason, func(t *testing.T) {
+			cfg := testConfig(t, nil)
+			node := newFakeNode(masterInfo)
+			node.reply("INFO", replyError(tt.reply))
+			node.reply("PING", replyError(tt.reply))
+			p := newTestProbes(t, cfg, node)
+
+			w := serve(t, cfg, p, http.MethodGet, "/readyz", nil)
+			if w.Code != http.StatusServiceUnavailable || !strings.HasPrefix(w.Body.String(), tt.reason) {
+				t.Errorf("GET /readyz = %d %q, want 503 %s", w.Code, w.Body.String(), tt.reason)
+			}
+			if got := w.Header().Get("X-Health-Reason"); got != tt.reason {
+				t.Errorf("X-Health-Reason = %q, want %s", got, tt.reason)
+			}
+
+			// Restarting the node would only start it over
+			if w := serve(t, cfg, p, http.MethodGet, "/livez", nil); w.Code != http.StatusOK {
+				t.Errorf("GET /livez = %d %q, want 200", w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestConnectionRefusedWhileStarting(t *testing.T) {
+	// A port nothing listens on anymore refuses connections
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+	_, refused := net.Dial("tcp", addr)
+	if !isConnRefused(refused) {
+		t.Fatalf("dialing a closed port = %v, want a connection refused", refused)
+	}
+
+	cfg := testConfig(t, map[string]string{"STARTUP_GRACE_SECONDS": "60"})
+	node := newFakeNode(masterInfo)
+	node.dialErr = refused
+	p := newTestProbes(t, cfg, node)
+
+	w := serve(t, cfg, p, http.MethodGet, "/readyz", nil)
+	if w.Code != http.StatusServiceUnavailable || !strings.HasPrefix(w.Body.String(), "STARTING") {
+		t.Errorf("GET /readyz within the startup grace = %d %q, want 503 STARTING", w.Code, w.Body.String())
+	}
+	if w := serve(t, cfg, p, http.MethodGet, "/livez", nil); w.Code != http.StatusOK {
+		t.Errorf("GET /livez within the startup grace = %d %q, want 200", w.Code, w.Body.String())
+	}
+
+	// Past the grace the node is down rather than starting
+	p.startupUntil = time.Now().Add(-time.Second)
+	w = serve(t, cfg, p, http.MethodGet, "/readyz", nil)
+	if w.Code != http.StatusBadGateway || !strings.HasPrefix(w.Body.String(), "REDIS_UNREACHABLE") {
+		t.Errorf("GET /readyz past the startup grace = %d %q, want 502 REDIS_UNREACHABLE", w.Code, w.Body.String())
+	}
+	if w := serve(t, cfg, p, http.MethodGet, "/livez", nil); w.Code == http.StatusOK {
+		t.Errorf("GET /livez past the startup grace = %d %q, want a failure", w.Code, w.Body.String())
+	}
+}
+
+func TestStartingIn(t *testing.T) {
+	cfg := testConfig(t, nil)
+	p := newTestProbes(t, cfg, newFakeNode(masterInfo))
+	probeCtx := withProbes(context.Background(), p)
+	refused := &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}
+	noSocket := &net.OpError{Op: "dial", Net: "unix", Err: os.NewSyscallError("connect", syscall.ENOENT)}
+
+	for _, err := range []error{refused, noSocket} {
+		if got := startingIn(probeCtx, err); !isStartingError(got) || !errors.Is(got, err) {
+			t.Errorf("startingIn(%v) = %v, want a startingError wrapping it", err, got)
+		}
+	}
+	if got := startingIn(probeCtx, redisReply("ERR syntax error")); isStartingError(got) {
+		t.Errorf("startingIn(ERR) = %v, want it unchanged", got)
+	}
+
+	p.startupUntil = time.Now().Add(-time.Second)
+	if got := startingIn(probeCtx, refused); isStartingError(got) {
+		t.Errorf("startingIn past the grace = %v, want it unchanged", got)
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// statusDocument is the content of HEALTH_STATUS_FILE
+type statusDocument struct {
+	Timestamp time.Time     `json:"timestamp"`
+	Report    *healthReport `json:"report"`
+}
+
+// statusFileMu keeps concurrent evaluations from renaming over each other
+var statusFileMu sync.Mutex
+
+// writeStatusFile replaces HEALTH_STATUS_FILE with the readiness report, for
+// runtimes that can only run exec probes. The document is written to a
+// temporary file renamed over the previous one, so readers never see a
+// partial write. The directory is created when missing.
+func writeStatusFile(cfg *Config, report *healthReport) {
+	if cfg.StatusFile == "" {
+		return
+	}
+
+	statusFileMu.Lock()
+	defer statusFileMu.Unlock()
+
+	if err := replaceStatusFile(cfg.StatusFile, statusDocument{Timestamp: time.Now().UTC(), Report: report}); err != nil {
+		slog.Warn("error writing the status file", "path", cfg.StatusFile, "error", err)
+	}
+}
+
+func replaceStatusFile(path string, doc statusDocument) error {
+	body, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(body); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0o644); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// runCheckFile is the -check-file mode: it reads the status file, prints the
+// reason to stdout and returns exitHealthy only for a passing report written
+// within maxAge. Missing, unparsable and stale files are unhealthy.
+func runCheckFile(path string, maxAge time.Duration) int {
+	body, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Println("STATUS_FILE_UNREADABLE", err)
+		return exitUnhealthy
+	}
+
+	var doc statusDocument
+	if err := json.Unmarshal(body, &doc); err != nil || doc.Report == nil || doc.Timestamp.IsZero() {
+		fmt.Println("STATUS_FILE_INVALID", path)
+		return exitUnhealthy
+	}
+
+	if age := time.Since(doc.Timestamp); age > maxAge {
+		fmt.Printf("STATUS_FILE_STALE age=%s max=%s\n", age.Round(time.Second), maxAge)
+		return exitUnhealthy
+	}
+
+	if doc.Report.Status != "pass" {
+		fmt.Println(doc.Report.ReasonCode, doc.Report.Detail)
+		return exitUnhealthy
+	}
+	fmt.Println("OK")
+	return exitHealthy
+}
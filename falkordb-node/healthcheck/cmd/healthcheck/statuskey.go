@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"time"
+)
+
+// inbandStatus is the value of PUBLISH_STATUS_KEY, kept to a few dozen bytes
+type inbandStatus struct {
+	Status     string `json:"status"`
+	ReasonCode string `json:"reason_code,omitempty"`
+	Role       string `json:"role,omitempty"`
+	Previous   string `json:"previous,omitempty"` // transition messages only
+	Timestamp  int64  `json:"ts"`
+}
+
+// startStatusPublisher writes the readiness of a master, from the readiness
+// broadcaster, to PUBLISH_STATUS_KEY every PUBLISH_STATUS_INTERVAL_MS for
+// tools that only have a connection to the node, and publishes transitions
+// on the key followed by :events as they happen. The
+// key expires after one and a half intervals, so a dead healthcheck shows as
+// a missing key. Replicas are read-only and are skipped, as is cluster mode,
+// where the key would hash to another node's slot.
+//
+// Keys under the __falkordb: prefix, as in __falkordb:health, are reserved
+// for the healthcheck. The one key is a few dozen bytes: it is counted by
+// INFO keyspace and MAX_KEYS but makes no difference to the memory
+// thresholds.
+func startStatusPublisher(serverCtx context.Context, cfg *Config, p *probes) func() {
+	if cfg.StatusKey == "" {
+		return func() {}
+	}
+	if cfg.ClusterMode {
+		slog.Warn("PUBLISH_STATUS_KEY is ignored in cluster mode")
+		return func() {}
+	}
+
+	publishCtx, cancel := context.WithCancel(withProbes(serverCtx, p))
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		publishStatuses(publishCtx, cfg)
+	}()
+
+	slog.Info("publishing the status in-band", "key", cfg.StatusKey, "interval", cfg.StatusKeyInterval)
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+func publishStatuses(publishCtx context.Context, cfg *Config) {
+	streams := probesOf(publishCtx).streams
+	reports, ok := streams.subscribe(publishCtx, cfg, cfg.StatusKeyInterval)
+	if !ok {
+		return
+	}
+	defer streams.unsubscribe(reports)
+
+	ticker := time.NewTicker(cfg.StatusKeyInterval)
+	defer ticker.Stop()
+
+	var report *healthReport
+	previous := ""
+	failing := false
+	for {
+		select {
+		case <-publishCtx.Done():
+			return
+		case latest, ok := <-reports:
+			if !ok {
+				return
+			}
+			report = latest
+		case <-ticker.C:
+			// The broadcaster only publishes changes, the key is refreshed
+			// before it expires
+			if report == nil {
+				continue
+			}
+		}
+
+		if report.Role == "master" {
+			// Failing to publish says nothing about the probes, only logged
+			// once until it works again
+			if err := publishStatus(publishCtx, cfg, report, previous); err != nil && !failing {
+				slog.Warn("error publishing the status in-band", "key", cfg.StatusKey, "error", err)
+				failing = true
+			} else if err == nil {
+				failing = false
+			}
+		}
+		previous = report.Status
+	}
+}
+
+// publishStatus sets the key, and publishes the status when it differs from
+// previous. The first status after a start only sets the baseline.
+func publishStatus(publishCtx context.Context, cfg *Config, report *healthReport, previous string) error {
+	status := inbandStatus{Status: report.Status, ReasonCode: report.ReasonCode, Role: report.Role, Timestamp: time.Now().Unix()}
+	value, err := json.Marshal(status)
+	if err != nil {
+		return err
+	}
+
+	writeCtx, cancel := context.WithTimeout(publishCtx, probesOf(publishCtx).probeTimeout)
+	defer cancel()
+
+	pipe := probesOf(publishCtx).client.Pipeline()
+	pipe.Set(writeCtx, cfg.StatusKey, value, cfg.StatusKeyInterval*3/2)
+	if previous != "" && previous != report.Status {
+		status.Previous = previous
+		message, err := json.Marshal(status)
+		if err != nil {
+			return err
+		}
+		pipe.Publish(writeCtx, cfg.StatusKey+":events", message)
+	}
+	_, err = pipe.Exec(writeCtx)
+	return err
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestPublishStatuses(t *testing.T) {
+	cfg := testConfig(t, map[string]string{"PUBLISH_STATUS_KEY": "__falkordb:health", "PUBLISH_STATUS_INTERVAL_MS": "10"})
+	node := newFakeNode(masterInfo)
+
+	var mu sync.Mutex
+	var sets []inbandStatus
+	var published []inbandStatus
+	record := func(to *[]inbandStatus, key string, value string) {
+		var status inbandStatus
+		if err := json.Unmarshal([]byte(value), &status); err != nil {
+			t.Errorf("invalid status %q on %s: %v", value, key, err)
+		}
+		mu.Lock()
+		*to = append(*to, status)
+		mu.Unlock()
+	}
+	node.reply("SET", func(args []string) any {
+		if args[1] != "__falkordb:health" || len(args) != 5 || args[3] != "px" || args[4] != "15" {
+			t.Errorf("SET %q, want the key expiring after one and a half intervals", args[1:])
+		}
+		record(&sets, args[1], args[2])
+		return status("OK")
+	})
+	node.reply("PUBLISH", func(args []string) any {
+		if args[1] != "__falkordb:health:events" {
+			t.Errorf("PUBLISH on %q", args[1])
+		}
+		record(&published, args[1], args[2])
+		return 1
+	})
+	observed := func() ([]inbandStatus, []inbandStatus) {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]inbandStatus(nil), sets...), append([]inbandStatus(nil), published...)
+	}
+	p := newTestProbes(t, cfg, node)
+
+	publishCtx, cancel := context.WithCancel(withProbes(context.Background(), p))
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		publishStatuses(publishCtx, cfg)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	// The key is refreshed on the interval although the status is unchanged,
+	// the first status only sets the baseline of the transitions
+	eventually(t, "the key refreshed", func() bool { keys, _ := observed(); return len(keys) >= 3 })
+	if _, events := observed(); len(events) != 0 {
+		t.Fatalf("published %+v without a transition", events)
+	}
+
+	node.setInfo(strings.Replace(masterInfo, "master_repl_offset:100", "master_repl_offset:100\nmaster_failover_state:waiting-for-sync", 1))
+	eventually(t, "the transition published", func() bool { _, events := observed(); return len(events) == 1 })
+	keys, events := observed()
+	if event := events[0]; event.Status != "fail" || event.Previous != "pass" || event.Role != "master" || event.ReasonCode != "FAILOVER_IN_PROGRESS" {
+		t.Errorf("transition = %+v, want master from pass to fail in a failover", event)
+	}
+	if last := keys[len(keys)-1]; last.Status != "fail" || last.Previous != "" {
+		t.Errorf("key = %+v, want the failing status", last)
+	}
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const streamKeepalive = 15 * time.Second
+
+// healthBroadcaster runs a single readiness poller while at least one
+// subscriber is left and fans its reports out, so the number of streams and
+// background notifiers never changes the load on Redis. It polls on the
+// shortest interval subscribed.
+type healthBroadcaster struct {
+	mu          sync.Mutex
+	subscribers map[chan *healthReport]time.Duration
+	last        *healthReport
+	stop        context.CancelFunc
+	closed      bool
+	probes      *probes
+}
+
+func newHealthBroadcaster(p *probes) *healthBroadcaster {
+	return &healthBroadcaster{subscribers: map[chan *healthReport]time.Duration{}, probes: p}
+}
+
+// subscribe returns a channel receiving the current report, if any, and every
+// report that changes the status, evaluated at least every interval. The
+// channel is closed on shutdown. The poller outlives the subscriber starting
+// it, it only keeps the values of parent.
+func (b *healthBroadcaster) subscribe(parent context.Context, cfg *Config, interval time.Duration) (chan *healthReport, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return nil, false
+	}
+
+	ch := make(chan *healthReport, 1)
+	if b.last != nil {
+		ch <- b.last
+	}
+	b.subscribers[ch] = interval
+
+	if b.stop == nil {
+		pollCtx, cancel := context.WithCancel(withProbes(context.WithoutCancel(parent), b.probes))
+		b.stop = cancel
+		go b.poll(pollCtx, cfg)
+	}
+	return ch, true
+}
+
+func (b *healthBroadcaster) unsubscribe(ch chan *healthReport) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.subscribers[ch]; !ok {
+		return
+	}
+	delete(b.subscribers, ch)
+
+	if len(b.subscribers) == 0 && b.stop != nil {
+		b.stop()
+		b.stop = nil
+		b.last = nil
+	}
+}
+
+// closeAll ends every stream, since streams never go idle and would otherwise
+// hold up the server shutdown.
+func (b *healthBroadcaster) closeAll() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	for ch := range b.subscribers {
+		delete(b.subscribers, ch)
+		close(ch)
+	}
+	if b.stop != nil {
+		b.stop()
+		b.stop = nil
+	}
+}
+
+// interval is the shortest subscribed interval. A shorter one subscribed
+// meanwhile applies after the current wait.
+func (b *healthBroadcaster) interval() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	shortest := time.Duration(0)
+	for _, interval := range b.subscribers {
+		if shortest == 0 || interval < shortest {
+			shortest = interval
+		}
+	}
+	return shortest
+}
+
+func (b *healthBroadcaster) poll(pollCtx context.Context, cfg *Config) {
+	for {
+		probeCtx, cancel := context.WithTimeout(withConfiguredRole(pollCtx, cfg), b.probes.probeTimeout)
+		report := evaluateRecorded("stream", evaluateReadiness, probeCtx, cfg)
+		cancel()
+
+		if pollCtx.Err() == nil {
+			b.publish(report)
+		}
+
+		interval := b.interval()
+		if interval == 0 {
+			return
+		}
+		wait := time.NewTimer(interval)
+		select {
+		case <-pollCtx.Done():
+			wait.Stop()
+			return
+		case <-wait.C:
+		}
+	}
+}
+
+func (b *healthBroadcaster) publish(report *healthReport) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.last != nil && b.last.Status == report.Status && b.last.body == report.body {
+		return
+	}
+	b.last = report
+
+	for ch := range b.subscribers {
+		// Slow readers only ever get the latest report
+		select {
+		case <-ch:
+		default:
+		}
+		ch <- report
+	}
+}
+
+// streamHandler serves the readiness reports as server-sent events, one
+// event carrying the JSON report per status change, with a keepalive comment
+// every 15s.
+func streamHandler(cfg *Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		streams := probesOf(r.Context()).streams
+		ch, ok := streams.subscribe(r.Context(), cfg, cfg.StreamInterval)
+		if !ok {
+			setNotReadyHeaders(w, "SHUTTING_DOWN", nil)
+			writeError(w, r, http.StatusServiceUnavailable, "SHUTTING_DOWN", "")
+			return
+		}
+		defer streams.unsubscribe(ch)
+
+		// The server write timeout is sized for probes, not streams
+		controller := http.NewResponseController(w)
+		if err := controller.SetWriteDeadline(time.Time{}); err != nil {
+			slog.Warn("error disabling write deadline for stream", "error", err)
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Connection", "keep-alive")
+		w.WriteHeader(http.StatusOK)
+		controller.Flush()
+
+		keepalive := time.NewTicker(streamKeepalive)
+		defer keepalive.Stop()
+
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-keepalive.C:
+				fmt.Fprint(w, ": keepalive\n\n")
+			case report, ok := <-ch:
+				if !ok {
+					return
+				}
+				data, err := json.Marshal(report)
+				if err != nil {
+					return
+				}
+				fmt.Fprintf(w, "event: health\ndata: %s\n\n", data)
+			}
+
+			if err := controller.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
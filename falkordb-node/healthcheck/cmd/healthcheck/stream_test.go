@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestHealthBroadcasterInterval(t *testing.T) {
+	cfg := testConfig(t, nil)
+	p := newTestProbes(t, cfg, newFakeNode(masterInfo))
+	streams := p.streams
+
+	slow, _ := streams.subscribe(context.Background(), cfg, time.Hour)
+	fast, _ := streams.subscribe(context.Background(), cfg, time.Minute)
+	if got := streams.interval(); got != time.Minute {
+		t.Errorf("interval() = %v with both subscribed, want the shortest", got)
+	}
+
+	// Every subscriber gets the first report of the one poller
+	for _, ch := range []chan *healthReport{slow, fast} {
+		select {
+		case report := <-ch:
+			if !report.ok() {
+				t.Errorf("report = %q, want pass", report.body)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for the first report")
+		}
+	}
+
+	streams.unsubscribe(fast)
+	if got := streams.interval(); got != time.Hour {
+		t.Errorf("interval() = %v after the shortest left, want %v", got, time.Hour)
+	}
+	streams.unsubscribe(slow)
+	if got := streams.interval(); got != 0 {
+		t.Errorf("interval() = %v without subscribers, want 0", got)
+	}
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"falkordb.cloud/main/internal/redisinfo"
+)
+
+const (
+	maxSyncSamples = 16
+	// syncSampleWindow is how far back the throughput is measured, so it
+	// follows the current transfer rate rather than the average since start
+	syncSampleWindow = time.Minute
+)
+
+type syncSample struct {
+	at   time.Time
+	left int64
+}
+
+// syncHistory keeps the bytes left to transfer seen by the last probes of the
+// current sync, used to estimate how long it still takes.
+type syncHistory struct {
+	mu      sync.Mutex
+	total   int64
+	samples []syncSample
+	// movedAt is when bytes left last went down
+	movedAt time.Time
+	warned  bool
+}
+
+// syncProgress is the body of /sync-progress and the sync field of the JSON
+// status. The throughput and ETA are null until two samples are known, and
+// the ETA stays null while the transfer is stalled.
+type syncProgress struct {
+	InProgress            bool     `json:"in_progress"`
+	Percent               int64    `json:"percent,omitempty"`
+	TotalBytes            int64    `json:"total_bytes,omitempty"`
+	LeftBytes             int64    `json:"left_bytes,omitempty"`
+	ThroughputBytesPerSec *float64 `json:"throughput_bytes_per_sec"`
+	ETASeconds            *float64 `json:"eta_seconds"`
+	StalledSeconds        int64    `json:"stalled_seconds,omitempty"`
+	Warning               string   `json:"warning,omitempty"`
+}
+
+// checkSync fails a replica still syncing with its master. On Redis 7+ the
+// reason carries the transfer progress, e.g. SYNC_IN_PROGRESS 73% left=1.2GiB,
+// and a sync with no I/O for longer than MAX_SYNC_STALL_SECONDS fails with
+// SYNC_STALLED instead. The progress of the local node is returned for the
+// JSON status, nil for remote targets.
+func checkSync(probeCtx context.Context, info *redisinfo.Info, maxStallSeconds, stallWarnSeconds int64) (string, string, *syncProgress) {
+	// Remote targets would mix their progress with the local node's
+	local := probeTarget(probeCtx) == ""
+	var progress *syncProgress
+	if local {
+		progress = probesOf(probeCtx).sync.observe(info, stallWarnSeconds)
+	}
+
+	syncing, err := info.MasterSyncInProgress()
+	if err != nil {
+		return "SYNC_STATUS_UNKNOWN", err.Error(), progress
+	}
+	if !syncing {
+		return "", "master_sync_in_progress=0", progress
+	}
+
+	if lastIO, err := info.Int("master_sync_last_io_seconds_ago"); err == nil && maxStallSeconds > 0 && lastIO > maxStallSeconds {
+		detail := fmt.Sprintf("master_sync_last_io_seconds_ago=%d max=%d", lastIO, maxStallSeconds)
+		return "SYNC_STALLED " + detail, detail, progress
+	}
+
+	total, errTotal := info.Int("master_sync_total_bytes")
+	left, errLeft := info.Int("master_sync_left_bytes")
+	// Older servers don't report the sizes, and diskless syncs don't know the total
+	if errTotal != nil || errLeft != nil || total <= 0 || left < 0 {
+		return "SYNC_IN_PROGRESS", "master_sync_in_progress=1", progress
+	}
+
+	percent := (total - left) * 100 / total
+	parts := []string{fmt.Sprintf("%d%%", percent), "left=" + formatBytes(left)}
+	if progress != nil && progress.ETASeconds != nil {
+		eta := time.Duration(*progress.ETASeconds * float64(time.Second)).Round(time.Second)
+		parts = append(parts, "eta="+eta.String())
+	}
+	if progress != nil && progress.Warning != "" {
+		parts = append(parts, fmt.Sprintf("stalled=%ds", progress.StalledSeconds))
+	}
+
+	detail := "master_sync_in_progress=1 progress=" + strings.Join(parts, " ")
+	return "SYNC_IN_PROGRESS " + strings.Join(parts, " "), detail, progress
+}
+
+// observe records the bytes left of the local sync and estimates its
+// throughput over the last syncSampleWindow. The history restarts when the
+// sync does, seen as bytes left going back up or a new total, and is cleared
+// once the sync completes. With no progress for stallWarnSeconds the ETA is
+// dropped and the progress carries a SYNC_STALLED warning, logged once per
+// stall.
+func (h *syncHistory) observe(info *redisinfo.Info, stallWarnSeconds int64) *syncProgress {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	syncing, _ := info.MasterSyncInProgress()
+	total, errTotal := info.Int("master_sync_total_bytes")
+	left, errLeft := info.Int("master_sync_left_bytes")
+	if !syncing || errTotal != nil || errLeft != nil || total <= 0 || left < 0 {
+		h.total, h.samples, h.warned = 0, nil, false
+		return &syncProgress{InProgress: syncing}
+	}
+
+	now := time.Now()
+	samples := h.samples
+	if total != h.total || (len(samples) > 0 && left > samples[len(samples)-1].left) {
+		samples = nil
+	}
+	if len(samples) == 0 || left < samples[len(samples)-1].left {
+		h.movedAt, h.warned = now, false
+	}
+	samples = append(samples, syncSample{at: now, left: left})
+	for len(samples) > maxSyncSamples || (len(samples) > 2 && now.Sub(samples[1].at) > syncSampleWindow) {
+		samples = samples[1:]
+	}
+	h.total, h.samples = total, samples
+
+	progress := &syncProgress{InProgress: true, Percent: (total - left) * 100 / total, TotalBytes: total, LeftBytes: left}
+	first := samples[0]
+	if elapsed := now.Sub(first.at).Seconds(); len(samples) > 1 && elapsed > 0 {
+		throughput := float64(first.left-left) / elapsed
+		progress.ThroughputBytesPerSec = &throughput
+		if throughput > 0 {
+			eta := float64(left) / throughput
+			progress.ETASeconds = &eta
+		}
+	}
+
+	stalled := now.Sub(h.movedAt)
+	if stallWarnSeconds > 0 && stalled > time.Duration(stallWarnSeconds)*time.Second {
+		progress.StalledSeconds = int64(stalled.Seconds())
+		progress.Warning = "SYNC_STALLED"
+		// The rate over the window still counts the transfer before the stall
+		progress.ETASeconds = nil
+		if !h.warned {
+			slog.Warn("replica sync made no progress", "stalled", stalled.Round(time.Second), "left", left, "total", total)
+			h.warned = true
+		}
+	}
+	return progress
+}
+
+// syncProgressHandler reports the sync of the local node with its master
+func syncProgressHandler(cfg *Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		probeCtx, cancel := probeContext(r)
+		defer cancel()
+
+		info, err := fetchInfo(probeCtx)
+		if err != nil {
+			writeRedisError(w, r, err)
+			return
+		}
+		writeJSON(w, r, http.StatusOK, probesOf(probeCtx).sync.observe(info, cfg.SyncStallWarnSeconds))
+	}
+}
+
+// formatBytes renders a size with binary units, e.g. 1.2GiB
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+
+	value, exp := float64(n)/unit, 0
+	for value >= unit && exp < 4 {
+		value /= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%ciB", value, "KMGTP"[exp])
+}
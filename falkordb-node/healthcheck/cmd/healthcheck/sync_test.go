@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"falkordb.cloud/main/internal/redisinfo"
+)
+
+// syncingInfo is a replica's INFO during a sync of total bytes with left to go
+func syncingInfo(total, left int64) string {
+	return strings.NewReplacer(
+		"master_link_status:up", "master_link_status:down",
+		"master_sync_in_progress:0", fmt.Sprintf("master_sync_in_progress:1\nmaster_sync_total_bytes:%d\nmaster_sync_left_bytes:%d\nmaster_sync_last_io_seconds_ago:0", total, left),
+	).Replace(replicaInfo)
+}
+
+// ageSyncSamples moves the samples of the sync history back by d, as if the
+// probes had been that far apart
+func ageSyncSamples(h *syncHistory, d time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i := range h.samples {
+		h.samples[i].at = h.samples[i].at.Add(-d)
+	}
+	h.movedAt = h.movedAt.Add(-d)
+}
+
+func TestObserveSync(t *testing.T) {
+	h := &syncHistory{}
+	observe := func(total, left int64) *syncProgress {
+		return h.observe(redisinfo.Parse(syncingInfo(total, left)), 0)
+	}
+
+	// A single sample gives no throughput
+	if progress := observe(1000, 800); progress.Percent != 20 || progress.ThroughputBytesPerSec != nil || progress.ETASeconds != nil {
+		t.Errorf("first probe = %+v, want 20%% without throughput or ETA", progress)
+	}
+
+	ageSyncSamples(h, 10*time.Second)
+	progress := observe(1000, 600)
+	if progress.ThroughputBytesPerSec == nil || progress.ETASeconds == nil {
+		t.Fatalf("second probe = %+v, want the throughput and ETA", progress)
+	}
+	if throughput, eta := *progress.ThroughputBytesPerSec, *progress.ETASeconds; throughput < 19 || throughput > 20 || eta < 30 || eta > 31 {
+		t.Errorf("second probe = %.2fB/s eta=%.2fs, want 20B/s eta=30s", throughput, eta)
+	}
+
+	// A sync restarted from scratch starts the history over
+	h = &syncHistory{}
+	observe(1000, 800)
+	ageSyncSamples(h, 10*time.Second)
+	if progress := observe(1000, 900); progress.ThroughputBytesPerSec != nil || progress.ETASeconds != nil {
+		t.Errorf("restarted sync = %+v, want no throughput from the previous one", progress)
+	}
+
+	// No bytes moved, no ETA
+	h = &syncHistory{}
+	observe(1000, 800)
+	ageSyncSamples(h, 10*time.Second)
+	progress = observe(1000, 800)
+	if progress.ThroughputBytesPerSec == nil || *progress.ThroughputBytesPerSec != 0 || progress.ETASeconds != nil {
+		t.Errorf("stuck sync = %+v, want zero throughput without an ETA", progress)
+	}
+
+	if progress := h.observe(redisinfo.Parse(replicaInfo), 0); progress.InProgress || len(h.samples) != 0 {
+		t.Errorf("completed sync = %+v, want the history cleared", progress)
+	}
+}
+
+func TestSyncRetryAfter(t *testing.T) {
+	cfg := testConfig(t, nil)
+	node := newFakeNode(syncingInfo(1000, 800))
+	p := newTestProbes(t, cfg, node)
+
+	notReady := func(wantRetryAfter string) {
+		t.Helper()
+
+		w := serve(t, cfg, p, http.MethodGet, "/readyz?nocache=1", nil)
+		if w.Code != http.StatusServiceUnavailable || !strings.HasPrefix(w.Body.String(), "SYNC_IN_PROGRESS") {
+			t.Fatalf("GET /readyz = %d %q, want 503 SYNC_IN_PROGRESS", w.Code, w.Body.String())
+		}
+		if got := w.Header().Get("X-Health-Reason"); got != "SYNC_IN_PROGRESS" {
+			t.Errorf("X-Health-Reason = %q, want SYNC_IN_PROGRESS", got)
+		}
+		if got := w.Header().Get("Retry-After"); got != wantRetryAfter {
+			t.Errorf("Retry-After = %q for %q, want %s", got, w.Body.String(), wantRetryAfter)
+		}
+	}
+
+	// The first probe has no throughput to estimate from
+	notReady("5")
+
+	// 100 bytes in 10s leave 70s for the 700 left, past the cap
+	ageSyncSamples(p.sync, 10*time.Second)
+	node.setInfo(syncingInfo(1000, 700))
+	notReady("30")
+
+	// Nothing moved over the window, the estimate is gone
+	p.sync = &syncHistory{}
+	node.setInfo(syncingInfo(1000, 600))
+	notReady("5")
+	ageSyncSamples(p.sync, 10*time.Second)
+	notReady("5")
+
+	// 500 bytes in 10s leave 2s for the 100 left
+	node.setInfo(syncingInfo(1000, 100))
+	notReady("2")
+}
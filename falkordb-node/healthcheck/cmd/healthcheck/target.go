@@ -0,0 +1,136 @@
+package main
+
+import (
+	"container/list"
+	"context"
+	"net"
+	"net/http"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+)
+
+type targetKey struct{}
+
+// maxTargetClients bounds the clients kept for remote targets, the least
+// recently probed one is closed first
+const maxTargetClients = 64
+
+// targetClients holds one client per remote target so probing a node doesn't
+// open a new connection per request.
+type targetClients struct {
+	mu   sync.Mutex
+	base *redis.Options
+	// The clients of the TARGETS besides the local node, with their
+	// credentials, are kept for good
+	configured  map[string]*redis.Client
+	credentials []*nodeCredentials
+	clients     map[string]*list.Element
+	recent      *list.List // of *targetClient, most recently probed first
+}
+
+type targetClient struct {
+	addr   string
+	client *redis.Client
+}
+
+func newTargetClients() *targetClients {
+	return &targetClients{configured: map[string]*redis.Client{}, clients: map[string]*list.Element{}, recent: list.New()}
+}
+
+// withTarget resolves the node probed by the request from the target query
+// parameter. Targets are only accepted with ALLOW_REMOTE_TARGETS and must
+// match REMOTE_TARGET_PATTERN, so the endpoint can't be used as an open
+// proxy. It returns false for rejected targets.
+func withTarget(probeCtx context.Context, r *http.Request, cfg *Config) (context.Context, bool) {
+	target := r.URL.Query().Get("target")
+	if target == "" {
+		return probeCtx, true
+	}
+
+	if !cfg.AllowRemoteTargets || !cfg.RemoteTargetPattern.MatchString(target) {
+		return probeCtx, false
+	}
+
+	if _, _, err := net.SplitHostPort(target); err != nil {
+		return probeCtx, false
+	}
+
+	// The shared INFO cache only ever holds the local node
+	return context.WithValue(withNoCache(probeCtx), targetKey{}, target), true
+}
+
+func writeInvalidTarget(w http.ResponseWriter, r *http.Request) {
+	writeError(w, r, http.StatusBadRequest, "INVALID_TARGET", "")
+}
+
+func probeTarget(probeCtx context.Context) string {
+	target, _ := probeCtx.Value(targetKey{}).(string)
+	return target
+}
+
+// nodeClient returns the client for the node probed within probeCtx, the
+// local node unless the request named a remote target.
+func nodeClient(probeCtx context.Context) redis.UniversalClient {
+	target := probeTarget(probeCtx)
+	if target == "" {
+		// The listener that answered first with CHECK_BOTH_LISTENERS
+		if client, ok := probeCtx.Value(listenerKey{}).(redis.UniversalClient); ok {
+			return client
+		}
+		return probesOf(probeCtx).client
+	}
+	return probesOf(probeCtx).targets.client(target)
+}
+
+// client returns the client for the node at addr, authenticating like the
+// local node
+func (t *targetClients) client(addr string) *redis.Client {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if client, ok := t.configured[addr]; ok {
+		return client
+	}
+	if element, ok := t.clients[addr]; ok {
+		t.recent.MoveToFront(element)
+		return element.Value.(*targetClient).client
+	}
+	if t.recent.Len() >= maxTargetClients {
+		oldest := t.recent.Remove(t.recent.Back()).(*targetClient)
+		delete(t.clients, oldest.addr)
+		oldest.client.Close()
+	}
+
+	options := *t.base
+	options.Network = "tcp"
+	options.Addr = addr
+	options.MinIdleConns = 0
+
+	// Verify the certificate against the target, not localhost
+	if options.TLSConfig != nil {
+		host, _, _ := net.SplitHostPort(addr)
+		options.TLSConfig = options.TLSConfig.Clone()
+		options.TLSConfig.ServerName = host
+	}
+
+	client := redis.NewClient(&options)
+	client.AddHook(timingHook{})
+	t.clients[addr] = t.recent.PushFront(&targetClient{addr: addr, client: client})
+	return client
+}
+
+// close closes every client
+func (t *targetClients) close() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, client := range t.configured {
+		client.Close()
+	}
+	for element := t.recent.Front(); element != nil; element = element.Next() {
+		element.Value.(*targetClient).client.Close()
+	}
+	t.configured, t.clients = map[string]*redis.Client{}, map[string]*list.Element{}
+	t.recent.Init()
+}
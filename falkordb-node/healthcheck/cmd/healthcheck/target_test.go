@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func TestTargetClientsEviction(t *testing.T) {
+	cfg := testConfig(t, nil)
+	p := newTestProbes(t, cfg, newFakeNode(masterInfo))
+	closed := func(client *redis.Client) bool {
+		return errors.Is(client.Ping(context.Background()).Err(), redis.ErrClosed)
+	}
+
+	clients := make([]*redis.Client, maxTargetClients)
+	for i := range clients {
+		clients[i] = p.targets.client(fmt.Sprintf("10.0.0.%d:6379", i))
+	}
+	// The first target probed again isn't the least recent anymore
+	if client := p.targets.client("10.0.0.0:6379"); client != clients[0] {
+		t.Fatal("a cached target got a new client")
+	}
+
+	extra := p.targets.client("10.0.1.0:6379")
+	if !closed(clients[1]) {
+		t.Error("the least recently probed target wasn't closed past the cap")
+	}
+	if closed(clients[0]) || closed(extra) {
+		t.Error("a recently probed target was closed")
+	}
+	if len(p.targets.clients) != maxTargetClients {
+		t.Errorf("%d target clients kept, want %d", len(p.targets.clients), maxTargetClients)
+	}
+
+	p.targets.close()
+	if !closed(clients[0]) || !closed(extra) {
+		t.Error("closing the probes left target clients open")
+	}
+}
+
+func TestProbesCloseRegistrationSentinels(t *testing.T) {
+	cfg := testConfig(t, map[string]string{
+		"REQUIRE_SENTINEL_REGISTRATION": "true",
+		"SENTINEL_ADDRS":                "10.0.0.1:26379,10.0.0.2:26379",
+	})
+	p := newTestProbes(t, cfg, newFakeNode(masterInfo))
+	if len(p.registration) != 2 {
+		t.Fatalf("%d registration sentinels, want 2", len(p.registration))
+	}
+
+	p.close()
+	for _, sentinel := range p.registration {
+		if err := sentinel.client.Ping(context.Background()).Err(); !errors.Is(err, redis.ErrClosed) {
+			t.Errorf("sentinel %s left open after closing the probes: %v", sentinel.addr, err)
+		}
+	}
+}
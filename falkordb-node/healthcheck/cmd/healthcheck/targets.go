@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/sync/errgroup"
+)
+
+// setupTargets registers a client per TARGETS entry besides the local
+// node, each with its own credentials and TLS settings, so probes reach them
+// through nodeClient like remote targets.
+func setupTargets(cfg *Config, p *probes) error {
+	if len(cfg.Targets) < 2 {
+		return nil
+	}
+
+	p.targets.mu.Lock()
+	defer p.targets.mu.Unlock()
+
+	for _, target := range cfg.Targets[1:] {
+		credentials := newNodeCredentials(target.Config)
+		options, err := clientOptions(target.Config, credentials)
+		if err != nil {
+			return fmt.Errorf("error configuring redis client for target %s: %w", target.Name, err)
+		}
+
+		addr := targetAddr(target)
+		if _, ok := p.targets.configured[addr]; ok {
+			return fmt.Errorf("target %s: %s is already probed by another target", target.Name, addr)
+		}
+		p.targets.credentials = append(p.targets.credentials, credentials)
+		client := redis.NewClient(options)
+		client.AddHook(timingHook{})
+		p.targets.configured[addr] = client
+	}
+	return nil
+}
+
+func targetAddr(target Target) string {
+	return net.JoinHostPort(target.Config.NodeHost, target.Config.NodePort)
+}
+
+// targetContext returns the context probing target, which is the local node
+// for the first one
+func targetContext(probeCtx context.Context, cfg *Config, target Target) context.Context {
+	if target.Config == cfg {
+		return probeCtx
+	}
+	// The shared INFO cache only ever holds the local node
+	return context.WithValue(withNoCache(probeCtx), targetKey{}, targetAddr(target))
+}
+
+// targetHandler serves the readiness of one target with its own check set,
+// e.g. the sentinel checks for /healthcheck/sentinel.
+func targetHandler(cfg *Config, target Target) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		probeCtx, cancel := probeContext(r)
+		defer cancel()
+
+		evaluate := func(probeCtx context.Context, _ *Config) *healthReport {
+			return evaluateReadiness(targetContext(probeCtx, cfg, target), target.Config)
+		}
+		writeReport(w, r, evaluateRequest(r, "healthcheck/"+target.Name, evaluate, probeCtx, cfg))
+	}
+}
+
+// targetsHandler serves /healthcheck when TARGETS is set, OK only when every
+// target is ready. The body is that of the first failing target.
+func targetsHandler(cfg *Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		probeCtx, cancel := probeContext(r)
+		defer cancel()
+
+		writeReport(w, r, evaluateRequest(r, "healthcheck", evaluateTargets, probeCtx, cfg))
+	}
+}
+
+// evaluateTargets evaluates the readiness of every target concurrently and
+// reports one check per target
+func evaluateTargets(probeCtx context.Context, cfg *Config) *healthReport {
+	reports := make([]*healthReport, len(cfg.Targets))
+
+	group := errgroup.Group{}
+	for i, target := range cfg.Targets {
+		i, target := i, target
+		group.Go(func() error {
+			reports[i] = evaluateReadiness(targetContext(probeCtx, cfg, target), target.Config)
+			return nil
+		})
+	}
+	group.Wait()
+
+	report := newHealthReport()
+	for i, target := range cfg.Targets {
+		targetReport := reports[i]
+		if targetReport.ok() {
+			report.pass(target.Name, "")
+			continue
+		}
+
+		if report.ok() {
+			report.err = targetReport.err
+		}
+		check, _ := targetReport.failedCheck()
+		report.fail(targetReport.code, targetReport.body, target.Name, check.Name+": "+check.Detail)
+	}
+	return report
+}
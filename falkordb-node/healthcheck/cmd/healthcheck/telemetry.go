@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer stays a no-op unless startTelemetry installed a provider
+var tracer = otel.Tracer("falkordb.cloud/healthcheck")
+
+// startTelemetry exports traces and metrics over OTLP to
+// OTEL_EXPORTER_OTLP_ENDPOINT. Without it nothing is set up, no exporter
+// runs and the handler isn't instrumented. The other OTEL_* variables are
+// read by the exporters themselves. The returned function flushes and stops
+// the exporters.
+func startTelemetry(serverCtx context.Context, cfg *Config) (func(context.Context), error) {
+	if !cfg.Telemetry {
+		return func(context.Context) {}, nil
+	}
+
+	info := currentBuildInfo()
+	res, err := resource.New(serverCtx,
+		resource.WithAttributes(
+			attribute.String("service.name", "falkordb-healthcheck"),
+			attribute.String("service.version", info.Version),
+		),
+		// OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES take precedence
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	traceExporter, err := otlptracehttp.New(serverCtx)
+	if err != nil {
+		return nil, err
+	}
+	metricExporter, err := otlpmetrichttp.New(serverCtx)
+	if err != nil {
+		return nil, err
+	}
+
+	tracerProvider := sdktrace.NewTracerProvider(sdktrace.WithBatcher(traceExporter), sdktrace.WithResource(res))
+	meterProvider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(sdkmetric.NewPeriodicReader(metricExporter)), sdkmetric.WithResource(res))
+	otel.SetTracerProvider(tracerProvider)
+	otel.SetMeterProvider(meterProvider)
+
+	slog.Info("exporting telemetry over OTLP")
+	return func(shutdownCtx context.Context) {
+		if err := errors.Join(tracerProvider.Shutdown(shutdownCtx), meterProvider.Shutdown(shutdownCtx)); err != nil {
+			slog.Warn("error flushing telemetry", "error", err)
+		}
+	}, nil
+}
+
+// instrumentHandler adds the handler span and the standard HTTP server
+// metrics when telemetry is enabled.
+func instrumentHandler(cfg *Config, handler http.Handler) http.Handler {
+	if !cfg.Telemetry {
+		return handler
+	}
+	return otelhttp.NewHandler(handler, "healthcheck")
+}
+
+// endReportSpan records the outcome of an evaluation on its span. The reason
+// code is the first word of the body, e.g. SYNC_IN_PROGRESS.
+func endReportSpan(span trace.Span, report *healthReport) {
+	attrs := []attribute.KeyValue{attribute.String("healthcheck.status", report.Status)}
+	if report.Role != "" {
+		attrs = append(attrs, attribute.String("falkordb.role", report.Role))
+	}
+	if !report.ok() {
+		reason, _, _ := strings.Cut(report.body, " ")
+		attrs = append(attrs, attribute.String("healthcheck.reason", reason))
+		span.SetStatus(codes.Error, reason)
+	}
+	span.SetAttributes(attrs...)
+	span.End()
+}
+
+// endCheckSpan records the outcome of one check on its span
+func endCheckSpan(span trace.Span, role string, outcome checkOutcome) {
+	if role != "" {
+		span.SetAttributes(attribute.String("falkordb.role", role))
+	}
+
+	switch {
+	case outcome.err != nil:
+		_, reason := classifyRedisError(outcome.err)
+		span.SetAttributes(attribute.String("healthcheck.reason", reason))
+		span.RecordError(outcome.err)
+		span.SetStatus(codes.Error, reason)
+	case outcome.reason != "":
+		reason, _, _ := strings.Cut(outcome.reason, " ")
+		span.SetAttributes(attribute.String("healthcheck.reason", reason))
+		span.SetStatus(codes.Error, reason)
+	}
+	span.End()
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// terminationLogLimit is the most Kubernetes reads from the termination log
+const terminationLogLimit = 4096
+
+// terminationStatus is the compact status document of the termination log.
+// Fields are in order of importance and the tail is dropped first to fit
+// terminationLogLimit.
+type terminationStatus struct {
+	ReasonCode      string        `json:"reason_code,omitempty"`
+	Role            string        `json:"role,omitempty"`
+	FailingCheck    string        `json:"failing_check,omitempty"`
+	Endpoint        string        `json:"endpoint"`
+	Event           string        `json:"event"` // unhealthy or shutdown
+	Timestamp       time.Time     `json:"timestamp"`
+	EvaluatedAt     time.Time     `json:"evaluated_at"`
+	Status          string        `json:"status"`
+	Detail          string        `json:"detail,omitempty"`
+	FailingChecks   []checkResult `json:"failing_checks,omitempty"`
+	DetailTruncated bool          `json:"detail_truncated,omitempty"`
+}
+
+// terminationReports keeps the last local report of each endpoint, written
+// out on shutdown
+var terminationReports = struct {
+	mu      sync.Mutex
+	reports map[string]terminationReport
+}{reports: map[string]terminationReport{}}
+
+type terminationReport struct {
+	report *healthReport
+	at     time.Time
+}
+
+// terminationLogFailed is set after the first failed write, logged once
+var terminationLogFailed atomic.Bool
+
+// rememberTerminationReport keeps the report of an endpoint and, when the
+// endpoint just turned unhealthy, writes it to the termination log so the
+// reason of a probe-triggered restart outlives the container.
+func rememberTerminationReport(cfg *Config, endpoint string, report *healthReport, transitioned bool) {
+	now := time.Now().UTC()
+	terminationReports.mu.Lock()
+	terminationReports.reports[endpoint] = terminationReport{report: report, at: now}
+	terminationReports.mu.Unlock()
+
+	if transitioned && report.Status != "pass" {
+		writeTerminationLog(cfg, compactStatus(endpoint, "unhealthy", report, now))
+	}
+}
+
+// writeShutdownTerminationLog writes the most telling last report on
+// graceful shutdown: a failing liveness, readiness or startup report first.
+func writeShutdownTerminationLog(cfg *Config) {
+	terminationReports.mu.Lock()
+	chosen := ""
+	for _, endpoint := range []string{"livez", "readyz", "startupz"} {
+		last, ok := terminationReports.reports[endpoint]
+		if !ok {
+			continue
+		}
+		if chosen == "" {
+			chosen = endpoint
+		}
+		if last.report.Status != "pass" {
+			chosen = endpoint
+			break
+		}
+	}
+	last, ok := terminationReports.reports[chosen]
+	terminationReports.mu.Unlock()
+
+	if ok {
+		writeTerminationLog(cfg, compactStatus(chosen, "shutdown", last.report, last.at))
+	}
+}
+
+func compactStatus(endpoint string, event string, report *healthReport, evaluatedAt time.Time) terminationStatus {
+	status := terminationStatus{
+		ReasonCode:  report.ReasonCode,
+		Role:        report.Role,
+		Endpoint:    endpoint,
+		Event:       event,
+		Timestamp:   time.Now().UTC(),
+		EvaluatedAt: evaluatedAt,
+		Status:      report.Status,
+		Detail:      report.Detail,
+	}
+	for _, result := range report.Checks {
+		if !result.OK {
+			if status.FailingCheck == "" {
+				status.FailingCheck = result.Name
+			}
+			status.FailingChecks = append(status.FailingChecks, result)
+		}
+	}
+	return status
+}
+
+// marshalTerminationStatus encodes status within limit bytes, dropping the
+// failing checks from the last one and then shortening the detail
+func marshalTerminationStatus(status terminationStatus, limit int) []byte {
+	for {
+		body, err := json.Marshal(status)
+		if err != nil || len(body) <= limit {
+			return body
+		}
+
+		switch {
+		case len(status.FailingChecks) > 0:
+			status.FailingChecks = status.FailingChecks[:len(status.FailingChecks)-1]
+		case status.Detail != "":
+			// The escaping makes the encoded detail at least as long as the
+			// text, cut what overflows and retry
+			over := len(body) - limit
+			status.DetailTruncated = true
+			if over >= len(status.Detail) {
+				status.Detail = ""
+			} else {
+				status.Detail = truncateUTF8(status.Detail, len(status.Detail)-over)
+			}
+		default:
+			return body[:0]
+		}
+	}
+}
+
+// truncateUTF8 cuts s to at most n bytes without splitting a character
+func truncateUTF8(s string, n int) string {
+	if n >= len(s) {
+		return s
+	}
+	for n > 0 && s[n]&0xc0 == 0x80 {
+		n--
+	}
+	return s[:n]
+}
+
+// writeTerminationLog overwrites TERMINATION_LOG_PATH in place: Kubernetes
+// mounts the file itself, it can't be replaced by a rename. A failure is
+// logged once, outside Kubernetes the path usually doesn't exist.
+func writeTerminationLog(cfg *Config, status terminationStatus) {
+	body := marshalTerminationStatus(status, terminationLogLimit)
+	if len(body) == 0 {
+		return
+	}
+	if err := os.WriteFile(cfg.TerminationLog, body, 0o644); err != nil {
+		if !terminationLogFailed.Swap(true) {
+			slog.Warn("error writing the termination log", "path", cfg.TerminationLog, "error", err)
+		}
+		return
+	}
+	slog.Debug("wrote the termination log", "path", cfg.TerminationLog, "event", status.Event, "reason_code", status.ReasonCode)
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func resetTerminationReports() {
+	terminationReports.mu.Lock()
+	terminationReports.reports = map[string]terminationReport{}
+	terminationReports.mu.Unlock()
+}
+
+func TestMarshalTerminationStatusTruncation(t *testing.T) {
+	checks := []checkResult{}
+	for i := 0; i < 50; i++ {
+		checks = append(checks, checkResult{Name: "check", Detail: strings.Repeat("x", 100)})
+	}
+	status := terminationStatus{ReasonCode: "MASTER_LINK_DOWN", Endpoint: "readyz", Event: "unhealthy", Status: "fail", FailingChecks: checks}
+
+	t.Run("failing checks dropped first", func(t *testing.T) {
+		body := marshalTerminationStatus(status, terminationLogLimit)
+		var decoded terminationStatus
+		if err := json.Unmarshal(body, &decoded); err != nil || len(body) > terminationLogLimit {
+			t.Fatalf("%d bytes, %v", len(body), err)
+		}
+		if len(decoded.FailingChecks) == 0 || len(decoded.FailingChecks) == len(checks) || decoded.ReasonCode != "MASTER_LINK_DOWN" {
+			t.Errorf("kept %d failing checks of %d, reason %q", len(decoded.FailingChecks), len(checks), decoded.ReasonCode)
+		}
+		if decoded.DetailTruncated {
+			t.Error("detail truncated while failing checks could be dropped")
+		}
+	})
+
+	t.Run("detail shortened last", func(t *testing.T) {
+		long := status
+		long.FailingChecks = nil
+		// Multibyte runes must not be split, and escaped quotes grow the body
+		long.Detail = strings.Repeat("é\"", 3000)
+		body := marshalTerminationStatus(long, terminationLogLimit)
+		var decoded terminationStatus
+		if err := json.Unmarshal(body, &decoded); err != nil || len(body) > terminationLogLimit {
+			t.Fatalf("%d bytes, %v", len(body), err)
+		}
+		if !decoded.DetailTruncated || decoded.Detail == "" || !utf8.ValidString(decoded.Detail) || !strings.HasPrefix(long.Detail, decoded.Detail) {
+			t.Errorf("detail %d bytes, truncated=%v", len(decoded.Detail), decoded.DetailTruncated)
+		}
+	})
+
+	t.Run("fits as is", func(t *testing.T) {
+		short := status
+		short.FailingChecks = checks[:1]
+		body := marshalTerminationStatus(short, terminationLogLimit)
+		if want, _ := json.Marshal(short); string(body) != string(want) {
+			t.Errorf("marshalTerminationStatus() = %s, want %s", body, want)
+		}
+	})
+}
+
+func TestTruncateUTF8(t *testing.T) {
+	tests := []struct {
+		s    string
+		n    int
+		want string
+	}{
+		{s: "abc", n: 2, want: "ab"},
+		{s: "abc", n: 5, want: "abc"},
+		{s: "aé", n: 2, want: "a"},
+		{s: "aé", n: 3, want: "aé"},
+		{s: "€", n: 2, want: ""},
+	}
+	for _, tt := range tests {
+		if got := truncateUTF8(tt.s, tt.n); got != tt.want {
+			t.Errorf("truncateUTF8(%q, %d) = %q, want %q", tt.s, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestRememberTerminationReport(t *testing.T) {
+	resetTerminationReports()
+	path := filepath.Join(t.TempDir(), "termination-log")
+	cfg := testConfig(t, map[string]string{"TERMINATION_LOG_PATH": path})
+
+	readTerminationLog := func() *terminationStatus {
+		body, err := os.ReadFile(path)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		var status terminationStatus
+		if err := json.Unmarshal(body, &status); err != nil {
+			t.Fatalf("invalid termination log %q: %v", body, err)
+		}
+		os.Remove(path)
+		return &status
+	}
+
+	passing := newHealthReport()
+	passing.pass("loading", "")
+	rememberTerminationReport(cfg, "readyz", passing, true)
+	if status := readTerminationLog(); status != nil {
+		t.Fatalf("termination log written for a passing report: %+v", status)
+	}
+
+	failing := newHealthReport()
+	failing.pass("role", "master")
+	failing.fail(http.StatusServiceUnavailable, "LOADING", "loading", "loading=1")
+	rememberTerminationReport(cfg, "readyz", failing, false)
+	if status := readTerminationLog(); status != nil {
+		t.Fatalf("termination log written without a transition: %+v", status)
+	}
+
+	rememberTerminationReport(cfg, "readyz", failing, true)
+	status := readTerminationLog()
+	if status == nil || status.Event != "unhealthy" || status.Endpoint != "readyz" || status.ReasonCode != "LOADING" || status.FailingCheck != "loading" {
+		t.Fatalf("termination log = %+v, want the unhealthy readyz report", status)
+	}
+
+	// On shutdown a failing report wins over a passing one
+	rememberTerminationReport(cfg, "livez", passing, false)
+	writeShutdownTerminationLog(cfg)
+	if status := readTerminationLog(); status == nil || status.Event != "shutdown" || status.Endpoint != "readyz" || status.Status != "fail" {
+		t.Errorf("shutdown termination log = %+v, want the failing readyz report", status)
+	}
+}
+
+func TestTerminationLogOnTransition(t *testing.T) {
+	resetTerminationReports()
+	path := filepath.Join(t.TempDir(), "termination-log")
+	cfg := testConfig(t, map[string]string{"TERMINATION_LOG_PATH": path})
+	node := newFakeNode(masterInfo)
+	p := newTestProbes(t, cfg, node)
+
+	readTerminationLog := func() *terminationStatus {
+		body, err := os.ReadFile(path)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		var status terminationStatus
+		if err := json.Unmarshal(body, &status); err != nil {
+			t.Fatalf("invalid termination log %q: %v", body, err)
+		}
+		os.Remove(path)
+		return &status
+	}
+
+	serve(t, cfg, p, http.MethodGet, "/readyz", nil)
+	if status := readTerminationLog(); status != nil {
+		t.Fatalf("termination log written for a passing node: %+v", status)
+	}
+
+	// Asserting another role isn't the node turning unhealthy
+	if w := serve(t, cfg, p, http.MethodGet, "/readyz?expect_role=slave", nil); w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("GET /readyz?expect_role=slave = %d", w.Code)
+	}
+	if status := readTerminationLog(); status != nil {
+		t.Fatalf("termination log written for an expect_role probe: %+v", status)
+	}
+	writeShutdownTerminationLog(cfg)
+	if status := readTerminationLog(); status == nil || status.Event != "shutdown" || status.Status != "pass" {
+		t.Fatalf("shutdown termination log = %+v, want the passing readyz report", status)
+	}
+
+	node.setInfo(strings.Replace(masterInfo, "loading:0", "loading:1", 1))
+	serve(t, cfg, p, http.MethodGet, "/readyz?nocache=1", nil)
+	if status := readTerminationLog(); status != nil {
+		t.Fatalf("termination log written for a nocache probe: %+v", status)
+	}
+
+	p.info.invalidate()
+	serve(t, cfg, p, http.MethodGet, "/readyz", nil)
+	status := readTerminationLog()
+	if status == nil || status.Event != "unhealthy" || status.Endpoint != "readyz" || status.Status != "fail" || status.FailingCheck != "loading" {
+		t.Fatalf("termination log = %+v, want the unhealthy readyz report", status)
+	}
+
+	// Only the transition is written
+	serve(t, cfg, p, http.MethodGet, "/readyz", nil)
+	if status := readTerminationLog(); status != nil {
+		t.Errorf("termination log written again without a transition: %+v", status)
+	}
+}
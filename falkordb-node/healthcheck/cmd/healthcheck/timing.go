@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+type checkTimingKey struct{}
+
+// checkTiming is the time the commands of one check spent in the Redis round
+// trip, the rest of its duration being the healthcheck's own, parsing
+// included
+type checkTiming struct {
+	redis atomic.Int64
+}
+
+// withCheckTiming returns a context whose commands add their round trip to
+// the returned timing
+func withCheckTiming(ctx context.Context) (context.Context, *checkTiming) {
+	timing := &checkTiming{}
+	return context.WithValue(ctx, checkTimingKey{}, timing), timing
+}
+
+func (t *checkTiming) redisTime() time.Duration {
+	return time.Duration(t.redis.Load())
+}
+
+// timingHook adds the round trip of every command to the checkTiming of its
+// context, if any. Concurrent commands of one check add up.
+type timingHook struct{}
+
+func (timingHook) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (timingHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(cmdCtx context.Context, cmd redis.Cmder) error {
+		start := time.Now()
+		err := next(cmdCtx, cmd)
+		addRedisTime(cmdCtx, time.Since(start))
+		return err
+	}
+}
+
+func (timingHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(cmdCtx context.Context, cmds []redis.Cmder) error {
+		start := time.Now()
+		err := next(cmdCtx, cmds)
+		addRedisTime(cmdCtx, time.Since(start))
+		return err
+	}
+}
+
+func addRedisTime(cmdCtx context.Context, elapsed time.Duration) {
+	if timing, ok := cmdCtx.Value(checkTimingKey{}).(*checkTiming); ok {
+		timing.redis.Add(int64(elapsed))
+	}
+}
+
+// milliseconds returns d in fractional milliseconds, as reported in JSON
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// warnSlowEvaluation warns when an evaluation took more than
+// SLOW_EVALUATION_WARN_FRACTION of the probe timeout, naming its slowest
+// check so an intermittent probe timeout can be traced to it
+func warnSlowEvaluation(cfg *Config, source string, report *healthReport, elapsed time.Duration) {
+	if cfg.SlowEvalFraction <= 0 || elapsed <= time.Duration(cfg.SlowEvalFraction*float64(cfg.ProbeTimeout)) {
+		return
+	}
+
+	attrs := []any{"source", source, "duration_ms", milliseconds(elapsed), "probe_timeout_ms", cfg.ProbeTimeout.Milliseconds()}
+	var slowest *checkResult
+	for i := range report.Checks {
+		if slowest == nil || report.Checks[i].DurationMs > slowest.DurationMs {
+			slowest = &report.Checks[i]
+		}
+	}
+	if slowest != nil {
+		attrs = append(attrs, "slowest_check", slowest.Name, "slowest_check_ms", slowest.DurationMs, "slowest_check_redis_ms", slowest.RedisMs)
+	}
+	slog.Warn("slow health evaluation", attrs...)
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestRunChecksTiming(t *testing.T) {
+	cfg := testConfig(t, nil)
+	node := newFakeNode(masterInfo)
+	node.delay("PING", 20*time.Millisecond)
+	p := newTestProbes(t, cfg, node)
+
+	checkDurationHistogram.DeleteLabelValues("own")
+	checkDurationHistogram.DeleteLabelValues("round_trip")
+	before := testutil.CollectAndCount(checkDurationHistogram)
+	report := newHealthReport()
+	runChecks(withProbes(context.Background(), p), report, []check{
+		{name: "own", run: func(ctx context.Context) (string, string, error) {
+			time.Sleep(30 * time.Millisecond)
+			return "", "parsed", nil
+		}},
+		{name: "round_trip", run: func(ctx context.Context) (string, string, error) {
+			return "", "pong", nodeClient(ctx).Ping(ctx).Err()
+		}},
+	})
+
+	if len(report.Checks) != 2 {
+		t.Fatalf("checks = %+v, want own and round_trip", report.Checks)
+	}
+	own, roundTrip := report.Checks[0], report.Checks[1]
+	if own.DurationMs < 30 || own.RedisMs != 0 {
+		t.Errorf("own = %+v, want at least 30ms of its own and no Redis time", own)
+	}
+	if roundTrip.RedisMs < 20 || roundTrip.DurationMs < roundTrip.RedisMs {
+		t.Errorf("round_trip = %+v, want at least 20ms in Redis, within its duration", roundTrip)
+	}
+	if after := testutil.CollectAndCount(checkDurationHistogram); after < before+2 {
+		t.Errorf("check duration series = %d, want the two checks observed on top of %d", after, before)
+	}
+}
+
+func TestSlowEvaluationWarning(t *testing.T) {
+	var logs bytes.Buffer
+	defer slog.SetDefault(slog.Default())
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&logs, nil)))
+
+	cfg := testConfig(t, map[string]string{"HEALTH_CHECK_TIMEOUT_MS": "500", "SLOW_EVALUATION_WARN_FRACTION": "0.1"})
+	node := newFakeNode(masterInfo)
+	p := newTestProbes(t, cfg, node)
+
+	serve(t, cfg, p, http.MethodGet, "/readyz", nil)
+	if strings.Contains(logs.String(), "slow health evaluation") {
+		t.Errorf("a fast evaluation warned: %s", logs.String())
+	}
+
+	// Two PINGs of 40ms each take ping_latency beyond 50ms
+	node.delay("PING", 40*time.Millisecond)
+	w := serve(t, cfg, p, http.MethodGet, "/readyz?nocache=1", http.Header{"Accept": {"application/json"}})
+	var report healthReport
+	if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil {
+		t.Fatalf("invalid report %q: %v", w.Body.String(), err)
+	}
+	if report.DurationMs < 80 {
+		t.Errorf("duration_ms = %v, want the slow PINGs included", report.DurationMs)
+	}
+
+	for _, line := range strings.Split(strings.TrimSpace(logs.String()), "\n") {
+		var entry struct {
+			Msg          string  `json:"msg"`
+			SlowestCheck string  `json:"slowest_check"`
+			SlowestMs    float64 `json:"slowest_check_ms"`
+			SlowestRedis float64 `json:"slowest_check_redis_ms"`
+		}
+		if json.Unmarshal([]byte(line), &entry) != nil || entry.Msg != "slow health evaluation" {
+			continue
+		}
+		if entry.SlowestCheck != "ping_latency" || entry.SlowestMs < 80 || entry.SlowestRedis < 80 {
+			t.Errorf("slow evaluation warning = %s, want ping_latency with its Redis time", line)
+		}
+		return
+	}
+	t.Errorf("no slow evaluation warning in %s", logs.String())
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// redisTLSConfig builds the TLS configuration used for rediss:// connections.
+// Certificate files are loaded eagerly so a bad mount fails at startup rather
+// than on the first probe.
+func redisTLSConfig(cfg *Config, serverName string) (*tls.Config, error) {
+	config := &tls.Config{
+		MinVersion:   cfg.TLSMinVersion,
+		CipherSuites: cfg.TLSCipherSuites,
+		ServerName:   serverName,
+	}
+
+	if cfg.RedisTLSServerName != "" {
+		config.ServerName = cfg.RedisTLSServerName
+	}
+
+	if cfg.RedisTLSInsecureSkipVerify {
+		config.InsecureSkipVerify = true
+	}
+
+	if caFile := cfg.RedisTLSCAFile; caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading REDIS_TLS_CA_FILE: %w", err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in REDIS_TLS_CA_FILE %s", caFile)
+		}
+		config.RootCAs = pool
+	}
+
+	if cfg.RedisTLSCertFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.RedisTLSCertFile, cfg.RedisTLSKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("error loading redis client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	return config, nil
+}
+
+// isTLSHandshakeError reports whether err is a failed TLS handshake with the
+// node, e.g. when it doesn't support REDIS_TLS_MIN_VERSION or any of the
+// REDIS_TLS_CIPHER_SUITES.
+func isTLSHandshakeError(err error) bool {
+	var recordHeader tls.RecordHeaderError
+	var verification *tls.CertificateVerificationError
+	if errors.As(err, &recordHeader) || errors.As(err, &verification) {
+		return true
+	}
+
+	// Alerts and most handshake errors aren't exported by crypto/tls
+	return strings.Contains(err.Error(), "tls: ")
+}
+
+// certReloader serves the healthcheck listener certificate and reloads it
+// whenever the files change on disk, since certificates rotate regularly.
+type certReloader struct {
+	certFile string
+	keyFile  string
+
+	mu      sync.Mutex
+	cert    *tls.Certificate
+	modTime time.Time
+}
+
+func newCertReloader(certFile string, keyFile string) (*certReloader, error) {
+	reloader := &certReloader{certFile: certFile, keyFile: keyFile}
+	if err := reloader.reload(); err != nil {
+		return nil, err
+	}
+	return reloader, nil
+}
+
+func (c *certReloader) reload() error {
+	stat, err := os.Stat(c.certFile)
+	if err != nil {
+		return err
+	}
+
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return err
+	}
+
+	c.cert = &cert
+	c.modTime = stat.ModTime()
+	return nil
+}
+
+func (c *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if stat, err := os.Stat(c.certFile); err == nil && !stat.ModTime().Equal(c.modTime) {
+		// Keep serving the previous certificate if the new pair is not loadable
+		// yet, e.g. while the key is still being written
+		if err := c.reload(); err != nil {
+			slog.Warn("error reloading healthcheck certificate", "error", err)
+		} else {
+			slog.Info("reloaded healthcheck certificate", "file", c.certFile)
+		}
+	}
+
+	return c.cert, nil
+}
+
+// serverTLSConfig builds the TLS configuration for the healthcheck listener
+// when HEALTH_CHECK_TLS is enabled, or returns nil otherwise.
+func serverTLSConfig(cfg *Config) (*tls.Config, error) {
+	if !cfg.ServerTLS {
+		return nil, nil
+	}
+
+	reloader, err := newCertReloader(cfg.ServerTLSCertFile, cfg.ServerTLSKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("error loading healthcheck certificate: %w", err)
+	}
+
+	config := &tls.Config{
+		MinVersion:     cfg.TLSMinVersion,
+		CipherSuites:   cfg.TLSCipherSuites,
+		GetCertificate: reloader.GetCertificate,
+	}
+
+	if caFile := cfg.ServerTLSClientCAFile; caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading HEALTH_CHECK_TLS_CLIENT_CA_FILE: %w", err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in HEALTH_CHECK_TLS_CLIENT_CA_FILE %s", caFile)
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return config, nil
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+
+	"falkordb.cloud/main/internal/redisinfo"
+)
+
+// nodeTopology is the body of /topology
+type nodeTopology struct {
+	Role       string            `json:"role"`
+	RoleClass  string            `json:"role_class,omitempty"`
+	Promotable *bool             `json:"failover_eligible,omitempty"` // replicas only
+	Master     *topologyMaster   `json:"master,omitempty"`
+	Replicas   []topologyReplica `json:"replicas,omitempty"`
+	Cluster    *topologyCluster  `json:"cluster,omitempty"`
+}
+
+// topologyMaster is the master a replica replicates from
+type topologyMaster struct {
+	Host       string `json:"host"`
+	Port       int64  `json:"port"`
+	LinkStatus string `json:"link_status"`
+	// LastIOSecondsAgo is -1 while the link is down
+	LastIOSecondsAgo int64 `json:"last_io_seconds_ago"`
+}
+
+// topologyReplica is one of the replicas connected to a master
+type topologyReplica struct {
+	Addr   string `json:"addr"`
+	State  string `json:"state"`
+	Online bool   `json:"online"`
+	Offset int64  `json:"offset"`
+	Lag    int64  `json:"lag"`
+}
+
+// topologyCluster is the place of the node in the cluster, from CLUSTER
+// SHARDS
+type topologyCluster struct {
+	NodeID string         `json:"node_id"`
+	Slots  []string       `json:"slots"`
+	Peers  []topologyPeer `json:"peers"`
+}
+
+type topologyPeer struct {
+	ID     string   `json:"id"`
+	Addr   string   `json:"addr"`
+	Role   string   `json:"role"`
+	Health string   `json:"health"`
+	Offset int64    `json:"replication_offset"`
+	Slots  []string `json:"slots,omitempty"`
+}
+
+// topologyHandler tells who the node replicates from and who replicates from
+// it, and in cluster mode its shard and peers, so nobody has to read raw
+// INFO.
+func topologyHandler(cfg *Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		probeCtx, cancel := probeContext(r)
+		defer cancel()
+
+		info, err := fetchInfo(probeCtx)
+		if err != nil {
+			writeRedisError(w, r, err)
+			return
+		}
+
+		topology := replicationTopology(info)
+		role := topology.Role
+		if isSentinel(info, cfg.SentinelMode) {
+			role = "sentinel"
+		}
+		topology.RoleClass, topology.Promotable = classifyRole(probeCtx, role)
+		if cfg.ClusterMode {
+			if topology.Cluster, err = clusterTopology(probeCtx); err != nil {
+				writeRedisError(w, r, err)
+				return
+			}
+		}
+		writeJSON(w, r, http.StatusOK, topology)
+	}
+}
+
+// replicationTopology reads the master of a replica, or the slaveN entries
+// of a master, from INFO replication
+func replicationTopology(info *redisinfo.Info) *nodeTopology {
+	role, _ := info.Role()
+	topology := &nodeTopology{Role: role}
+
+	if role == "slave" {
+		master := &topologyMaster{LastIOSecondsAgo: -1}
+		master.Host, _ = info.String("master_host")
+		master.Port, _ = info.Int("master_port")
+		master.LinkStatus, _ = info.String("master_link_status")
+		if seconds, err := info.Int("master_last_io_seconds_ago"); err == nil {
+			master.LastIOSecondsAgo = seconds
+		}
+		topology.Master = master
+	}
+
+	for _, replica := range info.Replicas() {
+		topology.Replicas = append(topology.Replicas, topologyReplica{
+			Addr:   net.JoinHostPort(replica.IP, strconv.FormatInt(replica.Port, 10)),
+			State:  replica.State,
+			Online: replica.State == "online",
+			Offset: replica.Offset,
+			Lag:    replica.Lag,
+		})
+	}
+	return topology
+}
+
+// clusterTopology returns the ID and slots of the node and every other node
+// of the cluster. CLUSTER SHARDS needs Redis 7.
+func clusterTopology(probeCtx context.Context) (*topologyCluster, error) {
+	myID, err := nodeClient(probeCtx).Do(probeCtx, "CLUSTER", "MYID").Text()
+	if err != nil {
+		return nil, err
+	}
+	shards, err := nodeClient(probeCtx).ClusterShards(probeCtx).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	cluster := &topologyCluster{NodeID: myID, Slots: []string{}, Peers: []topologyPeer{}}
+	for _, shard := range shards {
+		var slots []string
+		for _, slot := range shard.Slots {
+			if slot.Start == slot.End {
+				slots = append(slots, strconv.FormatInt(slot.Start, 10))
+			} else {
+				slots = append(slots, fmt.Sprintf("%d-%d", slot.Start, slot.End))
+			}
+		}
+
+		for _, node := range shard.Nodes {
+			if node.ID == myID {
+				cluster.Slots = append(cluster.Slots, slots...)
+				continue
+			}
+
+			port := node.Port
+			if port == 0 {
+				port = node.TLSPort
+			}
+			cluster.Peers = append(cluster.Peers, topologyPeer{
+				ID:     node.ID,
+				Addr:   net.JoinHostPort(node.Endpoint, strconv.FormatInt(port, 10)),
+				Role:   node.Role,
+				Health: node.Health,
+				Offset: node.ReplicationOffset,
+				Slots:  slots,
+			})
+		}
+	}
+	return cluster, nil
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"runtime"
+	"runtime/debug"
+)
+
+// Set at build time with
+//
+//	-ldflags "-X main.version=... -X main.commit=... -X main.buildDate=..."
+var (
+	version   = ""
+	commit    = ""
+	buildDate = ""
+)
+
+type buildInfo struct {
+	Version         string `json:"version"`
+	Commit          string `json:"commit"`
+	BuildDate       string `json:"build_date"`
+	GoVersion       string `json:"go_version"`
+	FalkorDBVersion string `json:"falkordb_version,omitempty"`
+}
+
+// currentBuildInfo returns the values embedded with -ldflags, falling back
+// to the module and VCS information recorded by the Go toolchain.
+func currentBuildInfo() buildInfo {
+	info := buildInfo{
+		Version:         version,
+		Commit:          commit,
+		BuildDate:       buildDate,
+		GoVersion:       runtime.Version(),
+		FalkorDBVersion: os.Getenv("FALKORDB_VERSION"),
+	}
+
+	if build, ok := debug.ReadBuildInfo(); ok {
+		if info.Version == "" && build.Main.Version != "(devel)" {
+			info.Version = build.Main.Version
+		}
+		for _, setting := range build.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				if info.Commit == "" {
+					info.Commit = setting.Value
+				}
+			case "vcs.time":
+				if info.BuildDate == "" {
+					info.BuildDate = setting.Value
+				}
+			}
+		}
+	}
+
+	if info.Version == "" {
+		info.Version = "dev"
+	}
+	if info.Commit == "" {
+		info.Commit = "unknown"
+	}
+	if info.BuildDate == "" {
+		info.BuildDate = "unknown"
+	}
+	return info
+}
+
+func versionHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, r, http.StatusOK, currentBuildInfo())
+}
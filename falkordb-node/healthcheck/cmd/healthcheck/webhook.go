@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+)
+
+var webhookBackoff = []time.Duration{time.Second, 2 * time.Second, 4 * time.Second}
+
+// healthEvent is POSTed to HEALTH_WEBHOOK_URL when the readiness status changes
+type healthEvent struct {
+	Node         string    `json:"node"`
+	OldStatus    string    `json:"old_status"`
+	NewStatus    string    `json:"new_status"`
+	FailingCheck string    `json:"failing_check,omitempty"`
+	Reason       string    `json:"reason,omitempty"`
+	Timestamp    time.Time `json:"timestamp"`
+}
+
+// nodeName identifies the node in notifications, POD_NAME or the hostname
+func nodeName(cfg *Config) string {
+	if cfg.PodName != "" {
+		return cfg.PodName
+	}
+	hostname, _ := os.Hostname()
+	return hostname
+}
+
+// startWebhookWatcher follows the readiness broadcaster, evaluated at least
+// every HEALTH_WEBHOOK_INTERVAL_MS, and notifies HEALTH_WEBHOOK_URL of status
+// changes. It runs independently of the HTTP probes. It returns a function
+// stopping the watcher.
+func startWebhookWatcher(serverCtx context.Context, cfg *Config, p *probes) func() {
+	if cfg.WebhookURL == "" {
+		return func() {}
+	}
+
+	watchCtx, cancel := context.WithCancel(withProbes(serverCtx, p))
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		watchHealth(watchCtx, cfg)
+	}()
+
+	slog.Info("starting health webhook watcher", "interval", cfg.WebhookInterval)
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+func watchHealth(watchCtx context.Context, cfg *Config) {
+	streams := probesOf(watchCtx).streams
+	reports, ok := streams.subscribe(watchCtx, cfg, cfg.WebhookInterval)
+	if !ok {
+		return
+	}
+	defer streams.unsubscribe(reports)
+
+	ticker := time.NewTicker(cfg.WebhookInterval)
+	defer ticker.Stop()
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	node := nodeName(cfg)
+
+	// sent is the last status the webhook was told about. Flapping back to it
+	// before an event went out sends nothing.
+	var sent string
+	var sentAt time.Time
+
+	var report *healthReport
+	for {
+		select {
+		case <-watchCtx.Done():
+			return
+		case latest, ok := <-reports:
+			if !ok {
+				return
+			}
+			report = latest
+		case <-ticker.C:
+			// A change held back by HEALTH_WEBHOOK_MIN_INTERVAL_MS goes out
+			// once it elapsed
+		}
+		if report == nil {
+			continue
+		}
+
+		switch {
+		case sent == "":
+			// The first evaluation only sets the baseline
+			sent = report.Status
+		case report.Status != sent && time.Since(sentAt) >= cfg.WebhookMinInterval:
+			event := healthEvent{
+				Node:      node,
+				OldStatus: sent,
+				NewStatus: report.Status,
+				Timestamp: time.Now().UTC(),
+			}
+			if check, failed := report.failedCheck(); failed {
+				event.FailingCheck = check.Name
+				event.Reason = report.body
+			}
+
+			if err := deliverWebhook(watchCtx, client, cfg.WebhookURL, event); err != nil {
+				slog.Warn("error delivering health webhook", "error", err)
+			}
+			// Not retried past the backoff, the next change is reported anyway
+			sent = report.Status
+			sentAt = time.Now()
+		}
+	}
+}
+
+// deliverWebhook POSTs the event, retrying with backoff until it is accepted
+func deliverWebhook(watchCtx context.Context, client *http.Client, url string, event healthEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	for attempt := 0; ; attempt++ {
+		err = postWebhook(watchCtx, client, url, body)
+		if err == nil {
+			slog.Info("delivered health webhook", "old_status", event.OldStatus, "new_status", event.NewStatus)
+			return nil
+		}
+
+		if attempt >= len(webhookBackoff) {
+			return fmt.Errorf("%w (after %d attempts)", err, attempt+1)
+		}
+
+		select {
+		case <-watchCtx.Done():
+			return watchCtx.Err()
+		case <-time.After(webhookBackoff[attempt]):
+		}
+	}
+}
+
+func postWebhook(watchCtx context.Context, client *http.Client, url string, body []byte) error {
+	req, err := http.NewRequestWithContext(watchCtx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook answered %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWatchHealth(t *testing.T) {
+	var mu sync.Mutex
+	var events []healthEvent
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event healthEvent
+		if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+			t.Errorf("invalid webhook body: %v", err)
+		}
+		mu.Lock()
+		events = append(events, event)
+		mu.Unlock()
+	}))
+	defer webhook.Close()
+	delivered := func() []healthEvent {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]healthEvent(nil), events...)
+	}
+
+	cfg := testConfig(t, map[string]string{
+		"HEALTH_WEBHOOK_URL":             webhook.URL,
+		"HEALTH_WEBHOOK_INTERVAL_MS":     "10",
+		"HEALTH_WEBHOOK_MIN_INTERVAL_MS": "1",
+		"POD_NAME":                       "node-0",
+	})
+	node := newFakeNode(masterInfo)
+	p := newTestProbes(t, cfg, node)
+
+	watchCtx, cancel := context.WithCancel(withProbes(context.Background(), p))
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		watchHealth(watchCtx, cfg)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	// The first report only sets the baseline
+	eventually(t, "the baseline evaluation", func() bool { return node.called("INFO") > 0 })
+	node.setInfo(strings.Replace(masterInfo, "loading:0", "loading:1", 1))
+	eventually(t, "the unhealthy event", func() bool { return len(delivered()) == 1 })
+
+	event := delivered()[0]
+	if event.Node != "node-0" || event.OldStatus != "pass" || event.NewStatus != "fail" || event.FailingCheck != "loading" || event.Reason == "" {
+		t.Errorf("event = %+v, want node-0 going from pass to fail on loading", event)
+	}
+
+	node.setInfo(masterInfo)
+	eventually(t, "the healthy event", func() bool { return len(delivered()) == 2 })
+	if event := delivered()[1]; event.OldStatus != "fail" || event.NewStatus != "pass" || event.FailingCheck != "" {
+		t.Errorf("event = %+v, want fail to pass", event)
+	}
+}
+
+func TestWatchHealthMinInterval(t *testing.T) {
+	var mu sync.Mutex
+	delivered := 0
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		delivered++
+		mu.Unlock()
+	}))
+	defer webhook.Close()
+
+	cfg := testConfig(t, map[string]string{
+		"HEALTH_WEBHOOK_URL":             webhook.URL,
+		"HEALTH_WEBHOOK_INTERVAL_MS":     "10",
+		"HEALTH_WEBHOOK_MIN_INTERVAL_MS": "100",
+	})
+	node := newFakeNode(masterInfo)
+	p := newTestProbes(t, cfg, node)
+
+	watchCtx, cancel := context.WithCancel(withProbes(context.Background(), p))
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		watchHealth(watchCtx, cfg)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	count := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return delivered
+	}
+
+	eventually(t, "the baseline evaluation", func() bool { return node.called("INFO") > 0 })
+	node.setInfo(strings.Replace(masterInfo, "loading:0", "loading:1", 1))
+	eventually(t, "the unhealthy event", func() bool { return count() == 1 })
+	failedAt := time.Now()
+
+	// The broadcaster publishes the recovery once, within the minimum
+	// interval. The watcher still sends it once the interval elapsed.
+	node.setInfo(masterInfo)
+	eventually(t, "the held back healthy event", func() bool { return count() == 2 })
+	if elapsed := time.Since(failedAt); elapsed < 90*time.Millisecond {
+		t.Errorf("healthy event sent %v after the unhealthy one, want the minimum interval", elapsed)
+	}
+}
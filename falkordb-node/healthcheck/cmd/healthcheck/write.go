@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"falkordb.cloud/main/internal/redisinfo"
+)
+
+// writeProbeKey is SET by the write probe. The prefix is reserved for the
+// healthcheck and the key expires after writeProbeTTL.
+const writeProbeKey = "__healthcheck:probe"
+
+const writeProbeTTL = 5 * time.Second
+
+// checkWriteProbe proves a master accepts writes, which it can refuse while
+// still reporting role:master, e.g. with NOREPLICAS under
+// min-replicas-to-write or OOM at maxmemory with noeviction. A rejected
+// write fails with the error as returned by the node. Skipped during a
+// failover, and in cluster mode where the key's slot may live elsewhere.
+func checkWriteProbe(probeCtx context.Context, info *redisinfo.Info, clusterMode bool) (string, string, error) {
+	if clusterMode {
+		return "", "skipped in cluster mode", nil
+	}
+	if state, err := info.String("master_failover_state"); err == nil && state != "no-failover" {
+		return "", "skipped master_failover_state=" + state, nil
+	}
+
+	start := time.Now()
+	err := nodeClient(probeCtx).Set(probeCtx, writeProbeKey, strconv.FormatInt(start.UnixMilli(), 10), writeProbeTTL).Err()
+	elapsed := time.Since(start)
+
+	// A healthcheck user not allowed to SET is an ACL problem, not the node's
+	if isRedisReply(err) && !isNoPermError(err) {
+		return "WRITE_REJECTED " + err.Error(), err.Error(), nil
+	}
+	if err != nil {
+		return "", "", err
+	}
+
+	observeWriteProbeLatency(elapsed)
+	return "", fmt.Sprintf("latency_ms=%.2f", float64(elapsed.Microseconds())/1000), nil
+}
@@ -0,0 +1,68 @@
+// Package checks names the readiness checks of the healthcheck and selects
+// those that apply to each deployment topology, so the node, cluster and
+// sentinel images run the same binary.
+package checks
+
+// Topologies are the values TOPOLOGY accepts
+var Topologies = []string{"standalone", "replication", "cluster", "sentinel"}
+
+// Readiness are the checks HEALTH_CHECKS selects from, in the order they
+// are listed. Only those that apply to the node run, sync only runs on
+// replicas and the cluster checks need CLUSTER_MODE.
+var Readiness = []string{
+	"loading", "role", "ping_latency", "protocol", "module", "module_version",
+	"failover", "sentinel_master", "connected_replicas", "repl_backlog", "replica_lag", "write_probe",
+	"sync", "master_link", "replica_stale", "replica_config", "standby",
+	"memory", "fragmentation", "cpu", "disk", "clients", "persistence", "persistence_mode", "persistence_activity", "keyspace", "rdb_age", "graph_query", "read_probe", "graph_config", "expected_graphs", "slowlog", "latency_events", "network", "sentinel_registration", "external_address", "acl_users",
+	"cluster", "cluster_nodes", "cluster_master", "slot_migrations", "announce", "slots",
+	"sentinel", "quorum", "sentinel_peers", "e2e_sentinel",
+}
+
+// clusterOnly are the checks that only run in cluster mode
+var clusterOnly = map[string]bool{"cluster": true, "cluster_nodes": true, "cluster_master": true, "slot_migrations": true, "announce": true, "slots": true}
+
+// restricted lists the checks of the topologies that don't run every check
+// applying to the node
+var restricted = map[string]map[string]bool{
+	"standalone": {
+		"loading": true, "role": true, "ping_latency": true, "protocol": true, "module": true, "module_version": true, "write_probe": true,
+		"memory": true, "fragmentation": true, "cpu": true, "disk": true, "clients": true, "persistence": true, "persistence_mode": true, "persistence_activity": true, "keyspace": true, "rdb_age": true,
+		"graph_query": true, "read_probe": true, "graph_config": true, "expected_graphs": true, "slowlog": true, "latency_events": true, "network": true, "external_address": true, "acl_users": true,
+	},
+	"sentinel": {
+		"loading": true, "role": true, "ping_latency": true, "protocol": true,
+		"sentinel": true, "quorum": true, "sentinel_peers": true, "e2e_sentinel": true,
+	},
+}
+
+// Known reports whether name is one of the Readiness checks
+func Known(name string) bool {
+	for _, known := range Readiness {
+		if known == name {
+			return true
+		}
+	}
+	return false
+}
+
+// InTopology reports whether the named check may run under topology. The
+// cluster checks only run in a cluster, and the standalone and sentinel
+// topologies leave out the checks that don't apply to them.
+func InTopology(topology string, name string) bool {
+	if allowed, ok := restricted[topology]; ok && !allowed[name] {
+		return false
+	}
+	return topology == "cluster" || !clusterOnly[name]
+}
+
+// ForTopology returns the Readiness checks that may run under topology, in
+// order
+func ForTopology(topology string) []string {
+	var names []string
+	for _, name := range Readiness {
+		if InTopology(topology, name) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
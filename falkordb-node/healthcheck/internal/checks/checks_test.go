@@ -0,0 +1,90 @@
+package checks
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestForTopology(t *testing.T) {
+	tests := []struct {
+		topology string
+		includes []string
+		excludes []string
+	}{
+		{
+			topology: "standalone",
+			includes: []string{"loading", "role", "module", "memory", "persistence"},
+			excludes: []string{"sync", "master_link", "connected_replicas", "failover", "cluster", "sentinel", "quorum"},
+		},
+		{
+			topology: "replication",
+			includes: []string{"loading", "module", "sync", "master_link", "replica_lag", "connected_replicas", "sentinel_master", "sentinel"},
+			excludes: []string{"cluster", "cluster_nodes", "slots", "announce"},
+		},
+		{
+			topology: "cluster",
+			includes: []string{"loading", "sync", "cluster", "cluster_nodes", "slot_migrations", "announce", "slots"},
+		},
+		{
+			topology: "sentinel",
+			includes: []string{"loading", "role", "ping_latency", "sentinel", "quorum", "sentinel_peers"},
+			excludes: []string{"module", "memory", "sync", "cluster"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.topology, func(t *testing.T) {
+			selected := ForTopology(tt.topology)
+			for _, name := range tt.includes {
+				if !slices.Contains(selected, name) {
+					t.Errorf("ForTopology(%q) leaves out %s", tt.topology, name)
+				}
+			}
+			for _, name := range tt.excludes {
+				if slices.Contains(selected, name) {
+					t.Errorf("ForTopology(%q) selects %s", tt.topology, name)
+				}
+			}
+		})
+	}
+}
+
+func TestForTopologyOrder(t *testing.T) {
+	// Every topology runs its checks in the order of Readiness
+	for _, topology := range Topologies {
+		selected := ForTopology(topology)
+		last := -1
+		for _, name := range selected {
+			index := slices.Index(Readiness, name)
+			if index <= last {
+				t.Errorf("ForTopology(%q) = %v, not in the order of Readiness", topology, selected)
+				break
+			}
+			last = index
+		}
+	}
+}
+
+func TestClusterIsEveryCheck(t *testing.T) {
+	if selected := ForTopology("cluster"); !slices.Equal(selected, Readiness) {
+		t.Errorf("ForTopology(cluster) = %v, want every check", selected)
+	}
+}
+
+func TestRestrictedChecksAreKnown(t *testing.T) {
+	for topology, allowed := range restricted {
+		for name := range allowed {
+			if !Known(name) {
+				t.Errorf("topology %s allows unknown check %s", topology, name)
+			}
+		}
+	}
+	for name := range clusterOnly {
+		if !Known(name) {
+			t.Errorf("unknown cluster check %s", name)
+		}
+	}
+	if Known("nope") {
+		t.Error("Known(nope) = true")
+	}
+}
@@ -0,0 +1,307 @@
+// Package redisinfo parses the output of the Redis INFO command into typed
+// fields, so checks never have to scrape the raw text.
+package redisinfo
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ErrMissingField is returned when a requested field is not present
+var ErrMissingField = errors.New("info field not found")
+
+// Info is a parsed INFO reply. Keys are unique across sections, so fields can
+// be looked up without knowing which section they belong to.
+type Info struct {
+	sections map[string]map[string]string
+	fields   map[string]string
+	order    []string
+}
+
+// Replica is one of the slaveN entries reported by a master
+type Replica struct {
+	Name   string
+	IP     string
+	Port   int64
+	State  string
+	Offset int64
+	Lag    int64
+}
+
+// KeyspaceDB is one of the dbN entries of the keyspace section. AvgTTL is in
+// milliseconds.
+type KeyspaceDB struct {
+	Keys    int64
+	Expires int64
+	AvgTTL  int64
+}
+
+// Parse parses an INFO reply. Lines are split on CRLF or LF, "# Section"
+// headers start a new section and lines without a colon are ignored.
+func Parse(raw string) *Info {
+	info := &Info{
+		sections: map[string]map[string]string{},
+		fields:   map[string]string{},
+	}
+
+	section := ""
+	for _, line := range strings.Split(raw, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if line == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, "#") {
+			section = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(line, "#")))
+			if _, ok := info.sections[section]; !ok {
+				info.sections[section] = map[string]string{}
+				info.order = append(info.order, section)
+			}
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+
+		if _, ok := info.sections[section]; !ok {
+			info.sections[section] = map[string]string{}
+			info.order = append(info.order, section)
+		}
+		info.sections[section][key] = value
+		info.fields[key] = value
+	}
+
+	return info
+}
+
+// Sections returns the section names in the order they appeared
+func (i *Info) Sections() []string {
+	return append([]string(nil), i.order...)
+}
+
+// Section returns a copy of the fields of a section, or nil if absent
+func (i *Info) Section(name string) map[string]string {
+	fields, ok := i.sections[strings.ToLower(name)]
+	if !ok {
+		return nil
+	}
+
+	copied := make(map[string]string, len(fields))
+	for key, value := range fields {
+		copied[key] = value
+	}
+	return copied
+}
+
+// Keys returns every field name in sorted order
+func (i *Info) Keys() []string {
+	keys := make([]string, 0, len(i.fields))
+	for key := range i.fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Has reports whether the field is present
+func (i *Info) Has(key string) bool {
+	_, ok := i.fields[key]
+	return ok
+}
+
+// String returns the raw value of a field
+func (i *Info) String(key string) (string, error) {
+	value, ok := i.fields[key]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrMissingField, key)
+	}
+	return value, nil
+}
+
+// Int returns the value of a field parsed as an integer
+func (i *Info) Int(key string) (int64, error) {
+	value, err := i.String(key)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("info field %s is not an integer: %q", key, value)
+	}
+	return n, nil
+}
+
+// Float returns the value of a field parsed as a float
+func (i *Info) Float(key string) (float64, error) {
+	value, err := i.String(key)
+	if err != nil {
+		return 0, err
+	}
+
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("info field %s is not a number: %q", key, value)
+	}
+	return f, nil
+}
+
+// Bool returns the value of a 0/1 flag field
+func (i *Info) Bool(key string) (bool, error) {
+	value, err := i.String(key)
+	if err != nil {
+		return false, err
+	}
+
+	switch value {
+	case "0":
+		return false, nil
+	case "1":
+		return true, nil
+	}
+	return false, fmt.Errorf("info field %s is not a flag: %q", key, value)
+}
+
+// NodeRole is the role of a node
+type NodeRole int
+
+const (
+	RoleUnknown NodeRole = iota
+	RoleMaster
+	RoleReplica
+	RoleSentinel
+)
+
+// String returns the role as INFO names it, slave for replicas, and an
+// empty string for RoleUnknown
+func (r NodeRole) String() string {
+	switch r {
+	case RoleMaster:
+		return "master"
+	case RoleReplica:
+		return "slave"
+	case RoleSentinel:
+		return "sentinel"
+	}
+	return ""
+}
+
+// ParseRole maps a role value to its NodeRole, replica being an alias of
+// slave. Any other value is RoleUnknown.
+func ParseRole(value string) NodeRole {
+	switch value {
+	case "master":
+		return RoleMaster
+	case "slave", "replica":
+		return RoleReplica
+	case "sentinel":
+		return RoleSentinel
+	}
+	return RoleUnknown
+}
+
+// NodeRole returns the role of the node from the role field of the
+// replication section, and the value as reported: the String of a known role
+// or the raw value of an unknown one. Sentinels have no replication section,
+// they are recognised by redis_mode.
+func (i *Info) NodeRole() (NodeRole, string, error) {
+	if i.sections["server"]["redis_mode"] == "sentinel" {
+		return RoleSentinel, RoleSentinel.String(), nil
+	}
+
+	value, ok := i.sections["replication"]["role"]
+	if !ok {
+		return RoleUnknown, "", fmt.Errorf("%w: role in the replication section", ErrMissingField)
+	}
+	role := ParseRole(strings.TrimSpace(value))
+	if role == RoleUnknown {
+		return RoleUnknown, value, nil
+	}
+	return role, role.String(), nil
+}
+
+// Role returns the value of NodeRole, e.g. master, slave or sentinel
+func (i *Info) Role() (string, error) {
+	_, value, err := i.NodeRole()
+	return value, err
+}
+
+// MasterSyncInProgress reports whether a replica is syncing with its master
+func (i *Info) MasterSyncInProgress() (bool, error) {
+	return i.Bool("master_sync_in_progress")
+}
+
+// Loading reports whether the node is still loading its dataset
+func (i *Info) Loading() (bool, error) {
+	return i.Bool("loading")
+}
+
+// Replicas returns the slaveN entries reported by a master, ordered by index
+func (i *Info) Replicas() []Replica {
+	var replicas []Replica
+	for n := 0; ; n++ {
+		name := "slave" + strconv.Itoa(n)
+		value, ok := i.fields[name]
+		if !ok {
+			break
+		}
+
+		replica := Replica{Name: name}
+		for _, pair := range strings.Split(value, ",") {
+			key, val, ok := strings.Cut(pair, "=")
+			if !ok {
+				continue
+			}
+
+			switch key {
+			case "ip":
+				replica.IP = val
+			case "port":
+				replica.Port, _ = strconv.ParseInt(val, 10, 64)
+			case "state":
+				replica.State = val
+			case "offset":
+				replica.Offset, _ = strconv.ParseInt(val, 10, 64)
+			case "lag":
+				replica.Lag, _ = strconv.ParseInt(val, 10, 64)
+			}
+		}
+		replicas = append(replicas, replica)
+	}
+	return replicas
+}
+
+// Keyspace returns the dbN entries of the keyspace section by database name.
+// Redis leaves out empty databases, so an empty keyspace has none.
+func (i *Info) Keyspace() map[string]KeyspaceDB {
+	dbs := map[string]KeyspaceDB{}
+	for name, value := range i.sections["keyspace"] {
+		if !strings.HasPrefix(name, "db") || strings.TrimLeft(name[len("db"):], "0123456789") != "" {
+			continue
+		}
+
+		var db KeyspaceDB
+		for _, pair := range strings.Split(value, ",") {
+			key, val, ok := strings.Cut(pair, "=")
+			if !ok {
+				continue
+			}
+
+			switch key {
+			case "keys":
+				db.Keys, _ = strconv.ParseInt(val, 10, 64)
+			case "expires":
+				db.Expires, _ = strconv.ParseInt(val, 10, 64)
+			case "avg_ttl":
+				db.AvgTTL, _ = strconv.ParseInt(val, 10, 64)
+			}
+		}
+		dbs[name] = db
+	}
+	return dbs
+}
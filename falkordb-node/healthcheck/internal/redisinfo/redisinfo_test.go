@@ -0,0 +1,290 @@
+package redisinfo
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const masterInfo = `# Server
+redis_version:7.2.4
+redis_mode:standalone
+
+# Replication
+role:master
+connected_slaves:2
+slave0:ip=10.0.0.2,port=6379,state=online,offset=120,lag=0
+slave1:ip=10.0.0.3,port=6380,state=wait_bgsave,offset=0,lag=3
+master_repl_offset:120
+
+# Persistence
+loading:0
+
+# Keyspace
+db0:keys=10,expires=2,avg_ttl=5000
+`
+
+const replicaInSyncInfo = `# Server
+redis_version:7.2.4
+redis_mode:standalone
+
+# Replication
+role:slave
+master_host:10.0.0.1
+master_port:6379
+master_link_status:up
+master_sync_in_progress:0
+slave_repl_offset:120
+
+# Persistence
+loading:0
+`
+
+const replicaSyncingInfo = `# Replication
+role:slave
+master_link_status:down
+master_sync_in_progress:1
+master_sync_total_bytes:1024
+`
+
+const sentinelInfo = `# Server
+redis_version:7.2.4
+redis_mode:sentinel
+
+# Sentinel
+sentinel_masters:1
+master0:name=mymaster,status=ok,address=10.0.0.1:6379,slaves=2,sentinels=3
+`
+
+const loadingInfo = `# Replication
+role:master
+
+# Persistence
+loading:1
+async_loading:0
+`
+
+func TestParseRole(t *testing.T) {
+	tests := []struct {
+		name     string
+		raw      string
+		role     NodeRole
+		value    string
+		syncing  bool
+		loading  bool
+		replicas int
+	}{
+		{name: "master", raw: masterInfo, role: RoleMaster, value: "master", replicas: 2},
+		{name: "replica in sync", raw: replicaInSyncInfo, role: RoleReplica, value: "slave"},
+		{name: "replica syncing", raw: replicaSyncingInfo, role: RoleReplica, value: "slave", syncing: true},
+		{name: "sentinel", raw: sentinelInfo, role: RoleSentinel, value: "sentinel"},
+		{name: "loading", raw: loadingInfo, role: RoleMaster, value: "master", loading: true},
+		{name: "replica alias", raw: "# Replication\nrole:replica\n", role: RoleReplica, value: "slave"},
+		{name: "unknown role", raw: "# Replication\nrole:arbiter\n", role: RoleUnknown, value: "arbiter"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info := Parse(tt.raw)
+
+			role, value, err := info.NodeRole()
+			if err != nil || role != tt.role || value != tt.value {
+				t.Errorf("NodeRole() = %v, %q, %v, want %v, %q", role, value, err, tt.role, tt.value)
+			}
+			if syncing, _ := info.MasterSyncInProgress(); syncing != tt.syncing {
+				t.Errorf("MasterSyncInProgress() = %v, want %v", syncing, tt.syncing)
+			}
+			if loading, _ := info.Loading(); loading != tt.loading {
+				t.Errorf("Loading() = %v, want %v", loading, tt.loading)
+			}
+			if replicas := info.Replicas(); len(replicas) != tt.replicas {
+				t.Errorf("Replicas() = %v, want %d replicas", replicas, tt.replicas)
+			}
+		})
+	}
+}
+
+func TestParseMissingRole(t *testing.T) {
+	info := Parse("# Server\nredis_version:7.2.4\n")
+
+	if _, _, err := info.NodeRole(); !errors.Is(err, ErrMissingField) {
+		t.Errorf("NodeRole() error = %v, want ErrMissingField", err)
+	}
+	if _, err := info.MasterSyncInProgress(); !errors.Is(err, ErrMissingField) {
+		t.Errorf("MasterSyncInProgress() error = %v, want ErrMissingField", err)
+	}
+}
+
+func TestParseCRLF(t *testing.T) {
+	lf := Parse(masterInfo)
+	crlf := Parse(strings.ReplaceAll(masterInfo, "\n", "\r\n"))
+
+	if !reflect.DeepEqual(lf.Sections(), crlf.Sections()) {
+		t.Errorf("Sections() = %v over CRLF, want %v", crlf.Sections(), lf.Sections())
+	}
+	for _, key := range lf.Keys() {
+		want, _ := lf.String(key)
+		if got, err := crlf.String(key); err != nil || got != want {
+			t.Errorf("String(%q) = %q, %v over CRLF, want %q", key, got, err, want)
+		}
+	}
+	if role, _ := crlf.Role(); role != "master" {
+		t.Errorf("Role() = %q over CRLF, want master", role)
+	}
+}
+
+func TestParseMalformed(t *testing.T) {
+	raw := "garbage before any section\r\n# Replication\r\nrole:master\r\nno colon here\r\n:no key\r\nempty_value:\r\nurl:redis://host:6379\r\n#\r\n"
+	info := Parse(raw)
+
+	if role, err := info.Role(); err != nil || role != "master" {
+		t.Errorf("Role() = %q, %v, want master", role, err)
+	}
+	if info.Has("no colon here") || info.Has("garbage before any section") {
+		t.Errorf("lines without a colon were parsed as fields, keys %v", info.Keys())
+	}
+	if value, err := info.String("empty_value"); err != nil || value != "" {
+		t.Errorf("String(empty_value) = %q, %v, want an empty value", value, err)
+	}
+	// Only the first colon separates the key
+	if value, _ := info.String("url"); value != "redis://host:6379" {
+		t.Errorf("String(url) = %q, want redis://host:6379", value)
+	}
+}
+
+func TestTypedFields(t *testing.T) {
+	info := Parse("# Stats\nint:42\nfloat:1.5\nflag:1\nword:yes\n")
+
+	tests := []struct {
+		name    string
+		get     func() (any, error)
+		want    any
+		wantErr bool
+	}{
+		{name: "int", get: func() (any, error) { return info.Int("int") }, want: int64(42)},
+		{name: "float", get: func() (any, error) { return info.Float("float") }, want: 1.5},
+		{name: "bool", get: func() (any, error) { return info.Bool("flag") }, want: true},
+		{name: "not an integer", get: func() (any, error) { return info.Int("word") }, want: int64(0), wantErr: true},
+		{name: "not a number", get: func() (any, error) { return info.Float("word") }, want: 0.0, wantErr: true},
+		{name: "not a flag", get: func() (any, error) { return info.Bool("word") }, want: false, wantErr: true},
+		{name: "missing", get: func() (any, error) { return info.Int("missing") }, want: int64(0), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.get()
+			if got != tt.want || (err != nil) != tt.wantErr {
+				t.Errorf("got %v, %v, want %v, error %v", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestReplicas(t *testing.T) {
+	want := []Replica{
+		{Name: "slave0", IP: "10.0.0.2", Port: 6379, State: "online", Offset: 120, Lag: 0},
+		{Name: "slave1", IP: "10.0.0.3", Port: 6380, State: "wait_bgsave", Offset: 0, Lag: 3},
+	}
+	if got := Parse(masterInfo).Replicas(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Replicas() = %+v, want %+v", got, want)
+	}
+}
+
+func TestKeyspace(t *testing.T) {
+	want := map[string]KeyspaceDB{"db0": {Keys: 10, Expires: 2, AvgTTL: 5000}}
+	if got := Parse(masterInfo).Keyspace(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Keyspace() = %+v, want %+v", got, want)
+	}
+}
+
+// TestParseDumps parses the full INFO replies of the master, replica and
+// sentinel nodes of the Redis version the image ships, as sent over the wire
+func TestParseDumps(t *testing.T) {
+	tests := []struct {
+		file     string
+		role     NodeRole
+		value    string
+		replicas []Replica
+		keyspace map[string]KeyspaceDB
+		sections int
+	}{
+		{
+			file:     "master.txt",
+			role:     RoleMaster,
+			value:    "master",
+			replicas: []Replica{{Name: "slave0", IP: "10.0.1.12", Port: 6379, State: "online", Offset: 9044856, Lag: 1}},
+			keyspace: map[string]KeyspaceDB{"db0": {Keys: 128}},
+			sections: 11,
+		},
+		{
+			file:     "replica.txt",
+			role:     RoleReplica,
+			value:    "slave",
+			keyspace: map[string]KeyspaceDB{"db0": {Keys: 128}},
+			sections: 11,
+		},
+		{
+			file:     "sentinel.txt",
+			role:     RoleSentinel,
+			value:    "sentinel",
+			keyspace: map[string]KeyspaceDB{},
+			sections: 5,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			raw, err := os.ReadFile(filepath.Join("testdata", tt.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			info := Parse(strings.ReplaceAll(string(raw), "\n", "\r\n"))
+
+			role, value, err := info.NodeRole()
+			if err != nil || role != tt.role || value != tt.value {
+				t.Errorf("NodeRole() = %v, %q, %v, want %v, %q", role, value, err, tt.role, tt.value)
+			}
+			if got := info.Replicas(); !reflect.DeepEqual(got, tt.replicas) {
+				t.Errorf("Replicas() = %+v, want %+v", got, tt.replicas)
+			}
+			if got := info.Keyspace(); !reflect.DeepEqual(got, tt.keyspace) {
+				t.Errorf("Keyspace() = %+v, want %+v", got, tt.keyspace)
+			}
+			if got := info.Sections(); len(got) != tt.sections {
+				t.Errorf("Sections() = %v, want %d sections", got, tt.sections)
+			}
+			if version, _ := info.String("redis_version"); version != "7.2.4" {
+				t.Errorf("redis_version = %q, want 7.2.4", version)
+			}
+			// No value keeps the CR of the line ending
+			for _, key := range info.Keys() {
+				if value, _ := info.String(key); strings.HasSuffix(value, "\r") {
+					t.Errorf("%s = %q, want the CR trimmed", key, value)
+				}
+			}
+		})
+	}
+}
+
+// TestParseDumpRoleField takes the role from the role key of the replication
+// section only, whatever other lines mention it
+func TestParseDumpRoleField(t *testing.T) {
+	raw, err := os.ReadFile(filepath.Join("testdata", "replica.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dump := strings.Replace(string(raw), "# Server\n", "# Server\nexecutable_role:master\nconfig_file:/etc/role:master.conf\n", 1)
+	info := Parse(dump)
+
+	if role, value, err := info.NodeRole(); err != nil || role != RoleReplica || value != "slave" {
+		t.Errorf("NodeRole() = %v, %q, %v, want the replica", role, value, err)
+	}
+	if readOnly, err := info.Bool("slave_read_only"); err != nil || !readOnly {
+		t.Errorf("slave_read_only = %v, %v, want 1", readOnly, err)
+	}
+	if status, _ := info.String("master_link_status"); status != "up" {
+		t.Errorf("master_link_status = %q, want up", status)
+	}
+}
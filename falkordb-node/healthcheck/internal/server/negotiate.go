@@ -0,0 +1,114 @@
+// Package server holds the HTTP plumbing of the healthcheck endpoints that
+// doesn't depend on the node: content negotiation, compression and paging.
+package server
+
+import (
+	"compress/gzip"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Format is the representation negotiated for a response
+type Format int
+
+const (
+	FormatText Format = iota
+	FormatJSON
+)
+
+// NegotiateFormat picks JSON or plain text for the response. ?format=json or
+// ?format=text wins over the Accept header, in which the highest q-value
+// among application/json and text/plain wins, and at equal q-values an exact
+// type beats text/* and then */*. Anything else, including no Accept header,
+// falls back to plain text so probes keep their body.
+func NegotiateFormat(r *http.Request) Format {
+	switch r.URL.Query().Get("format") {
+	case "json":
+		return FormatJSON
+	case "text":
+		return FormatText
+	}
+
+	best, bestQ, bestExact := FormatText, 0.0, -1
+	for _, accepted := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, q := ParseAccepted(accepted)
+		format, exact := FormatText, 0
+		switch mediaType {
+		case "application/json":
+			format, exact = FormatJSON, 2
+		case "text/plain":
+			exact = 2
+		case "text/*":
+			exact = 1
+		case "*/*":
+		default:
+			continue
+		}
+		// Ties otherwise keep the earlier entry
+		if q > 0 && (q > bestQ || (q == bestQ && exact > bestExact)) {
+			best, bestQ, bestExact = format, q, exact
+		}
+	}
+	return best
+}
+
+// ParseAccepted returns the media type or coding of one Accept or
+// Accept-Encoding entry and its q-value, 1 when not given and 0 when
+// malformed
+func ParseAccepted(accepted string) (string, float64) {
+	mediaType, params, _ := strings.Cut(accepted, ";")
+	q := 1.0
+	for _, param := range strings.Split(params, ";") {
+		if value, ok := strings.CutPrefix(strings.TrimSpace(param), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil || parsed < 0 || parsed > 1 {
+				parsed = 0
+			}
+			q = parsed
+		}
+	}
+	return strings.ToLower(strings.TrimSpace(mediaType)), q
+}
+
+// AcceptsGzip reports whether the Accept-Encoding of the request allows gzip
+// with a q-value above 0, by name or else as *
+func AcceptsGzip(r *http.Request) bool {
+	gzipQ, anyQ := -1.0, -1.0
+	for _, header := range r.Header.Values("Accept-Encoding") {
+		for _, accepted := range strings.Split(header, ",") {
+			switch coding, q := ParseAccepted(accepted); coding {
+			case "gzip":
+				gzipQ = q
+			case "*":
+				anyQ = q
+			}
+		}
+	}
+	return gzipQ > 0 || (gzipQ < 0 && anyQ > 0)
+}
+
+// WriteJSON answers with the JSON body, gzipped when at least gzipMinBytes
+// long, never when 0, and the Accept-Encoding of the client allows it.
+func WriteJSON(w http.ResponseWriter, r *http.Request, code int, body []byte, gzipMinBytes int64) {
+	w.Header().Set("Content-Type", "application/json")
+	if gzipMinBytes <= 0 {
+		w.WriteHeader(code)
+		w.Write(body)
+		return
+	}
+
+	w.Header().Add("Vary", "Accept-Encoding")
+	if int64(len(body)) < gzipMinBytes || !AcceptsGzip(r) {
+		w.WriteHeader(code)
+		w.Write(body)
+		return
+	}
+
+	w.Header().Set("Content-Encoding", "gzip")
+	w.Header().Del("Content-Length")
+	w.WriteHeader(code)
+	compressed := gzip.NewWriter(w)
+	compressed.Write(body)
+	compressed.Close()
+}
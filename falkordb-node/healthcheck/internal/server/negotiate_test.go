@@ -0,0 +1,150 @@
+package server
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNegotiateFormat(t *testing.T) {
+	tests := []struct {
+		name   string
+		target string
+		accept string
+		want   Format
+	}{
+		{name: "no accept header", target: "/readyz", want: FormatText},
+		{name: "json", target: "/readyz", accept: "application/json", want: FormatJSON},
+		{name: "text", target: "/readyz", accept: "text/plain", want: FormatText},
+		{name: "any", target: "/readyz", accept: "*/*", want: FormatText},
+		{name: "unsupported", target: "/readyz", accept: "application/xml", want: FormatText},
+		{name: "json after any", target: "/readyz", accept: "*/*, application/json", want: FormatJSON},
+		{name: "json after text wildcard", target: "/readyz", accept: "text/*, application/json", want: FormatJSON},
+		{name: "json before any", target: "/readyz", accept: "application/json, */*", want: FormatJSON},
+		{name: "exact types tie on order", target: "/readyz", accept: "text/plain, application/json", want: FormatText},
+		{name: "exact types tie on order json first", target: "/readyz", accept: "application/json, text/plain", want: FormatJSON},
+		{name: "higher q wins", target: "/readyz", accept: "application/json;q=0.5, text/plain;q=0.9", want: FormatText},
+		{name: "wildcard with higher q wins", target: "/readyz", accept: "application/json;q=0.5, */*", want: FormatText},
+		{name: "json refused", target: "/readyz", accept: "application/json;q=0", want: FormatText},
+		{name: "malformed q", target: "/readyz", accept: "text/plain;q=2, application/json;q=0.1", want: FormatJSON},
+		{name: "case insensitive", target: "/readyz", accept: "Application/JSON", want: FormatJSON},
+		{name: "format query wins", target: "/readyz?format=json", accept: "text/plain", want: FormatJSON},
+		{name: "text format query wins", target: "/readyz?format=text", accept: "application/json", want: FormatText},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			if tt.accept != "" {
+				r.Header.Set("Accept", tt.accept)
+			}
+			if got := NegotiateFormat(r); got != tt.want {
+				t.Errorf("NegotiateFormat(%q, Accept %q) = %v, want %v", tt.target, tt.accept, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseAccepted(t *testing.T) {
+	tests := []struct {
+		accepted  string
+		mediaType string
+		q         float64
+	}{
+		{accepted: "application/json", mediaType: "application/json", q: 1},
+		{accepted: " Text/Plain ", mediaType: "text/plain", q: 1},
+		{accepted: "application/json;q=0.5", mediaType: "application/json", q: 0.5},
+		{accepted: "text/plain; charset=utf-8; q=0.8", mediaType: "text/plain", q: 0.8},
+		{accepted: "text/plain;q=0", mediaType: "text/plain", q: 0},
+		{accepted: "gzip;q=1.0", mediaType: "gzip", q: 1},
+		{accepted: "text/plain;q=2", mediaType: "text/plain", q: 0},
+		{accepted: "text/plain;q=-1", mediaType: "text/plain", q: 0},
+		{accepted: "text/plain;q=high", mediaType: "text/plain", q: 0},
+		{accepted: "", mediaType: "", q: 1},
+	}
+	for _, tt := range tests {
+		if mediaType, q := ParseAccepted(tt.accepted); mediaType != tt.mediaType || q != tt.q {
+			t.Errorf("ParseAccepted(%q) = %q %v, want %q %v", tt.accepted, mediaType, q, tt.mediaType, tt.q)
+		}
+	}
+}
+
+func TestAcceptsGzip(t *testing.T) {
+	tests := []struct {
+		accept string
+		want   bool
+	}{
+		{accept: "", want: false},
+		{accept: "gzip", want: true},
+		{accept: "deflate, gzip;q=0.5", want: true},
+		{accept: "GZIP", want: true},
+		{accept: "gzip;q=0", want: false},
+		{accept: "*", want: true},
+		{accept: "*;q=0", want: false},
+		{accept: "gzip;q=0, *", want: false},
+		{accept: "identity", want: false},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/readyz", nil)
+		if tt.accept != "" {
+			r.Header.Set("Accept-Encoding", tt.accept)
+		}
+		if got := AcceptsGzip(r); got != tt.want {
+			t.Errorf("AcceptsGzip(Accept-Encoding %q) = %v, want %v", tt.accept, got, tt.want)
+		}
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	body := []byte(`{"status":"healthy","detail":"` + strings.Repeat("x", 100) + `"}`)
+	tests := []struct {
+		name         string
+		accept       string
+		gzipMinBytes int64
+		gzipped      bool
+		vary         bool
+	}{
+		{name: "gzip off", accept: "gzip", gzipMinBytes: 0},
+		{name: "below the cutoff", accept: "gzip", gzipMinBytes: int64(len(body)) + 1, vary: true},
+		{name: "at the cutoff", accept: "gzip", gzipMinBytes: int64(len(body)), gzipped: true, vary: true},
+		{name: "not accepted", gzipMinBytes: 1, vary: true},
+		{name: "refused", accept: "gzip;q=0", gzipMinBytes: 1, vary: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/readyz", nil)
+			if tt.accept != "" {
+				r.Header.Set("Accept-Encoding", tt.accept)
+			}
+			w := httptest.NewRecorder()
+			WriteJSON(w, r, http.StatusServiceUnavailable, body, tt.gzipMinBytes)
+
+			if w.Code != http.StatusServiceUnavailable || w.Header().Get("Content-Type") != "application/json" {
+				t.Errorf("WriteJSON = %d %q, want 503 application/json", w.Code, w.Header().Get("Content-Type"))
+			}
+			if vary := w.Header().Get("Vary") == "Accept-Encoding"; vary != tt.vary {
+				t.Errorf("Vary = %q, want Accept-Encoding %v", w.Header().Get("Vary"), tt.vary)
+			}
+
+			got := w.Body.Bytes()
+			if encoding := w.Header().Get("Content-Encoding"); tt.gzipped != (encoding == "gzip") {
+				t.Fatalf("Content-Encoding = %q, want gzip %v", encoding, tt.gzipped)
+			}
+			if tt.gzipped {
+				reader, err := gzip.NewReader(w.Body)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got, err = io.ReadAll(reader); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if !bytes.Equal(got, body) {
+				t.Errorf("body = %q, want %q", got, body)
+			}
+		})
+	}
+}
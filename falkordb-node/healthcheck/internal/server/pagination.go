@@ -0,0 +1,78 @@
+package server
+
+import (
+	"encoding/base64"
+	"net/url"
+	"sort"
+	"strconv"
+)
+
+// Page is the page of a list endpoint asked for with ?limit= and ?cursor=
+type Page struct {
+	Limit int
+	// After is the key of the last item of the previous page
+	After string
+}
+
+// Truncation marks a page that isn't the whole list. The next page is asked
+// for with ?cursor= set to NextCursor.
+type Truncation struct {
+	Truncated  bool   `json:"truncated,omitempty"`
+	Total      int    `json:"total,omitempty"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// PageError is a malformed ?limit= or ?cursor=, its Reason INVALID_LIMIT or
+// INVALID_CURSOR
+type PageError struct {
+	Reason string
+	Detail string
+}
+
+func (e *PageError) Error() string {
+	return e.Reason + " " + e.Detail
+}
+
+// ParsePage reads ?limit=, capped to maxItems, and ?cursor=, the next_cursor
+// of the previous page, from query
+func ParsePage(query url.Values, maxItems int) (Page, error) {
+	page := Page{Limit: maxItems}
+
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit <= 0 {
+			return page, &PageError{Reason: "INVALID_LIMIT", Detail: "expected a positive integer"}
+		}
+		page.Limit = min(limit, maxItems)
+	}
+
+	if value := query.Get("cursor"); value != "" {
+		after, err := base64.RawURLEncoding.DecodeString(value)
+		if err != nil || len(after) == 0 {
+			return page, &PageError{Reason: "INVALID_CURSOR", Detail: "expected the next_cursor of the previous page"}
+		}
+		page.After = string(after)
+	}
+	return page, nil
+}
+
+// PageOf returns the page of items, sorted by their unique key. The cursor
+// is the key of the last item rather than an offset, so the pages don't
+// shift when items are added or removed in between.
+func PageOf[T any](items []T, key func(T) string, page Page) ([]T, Truncation) {
+	start := 0
+	if page.After != "" {
+		start = sort.Search(len(items), func(i int) bool { return key(items[i]) > page.After })
+	}
+	end := min(start+page.Limit, len(items))
+
+	var truncation Truncation
+	if end-start < len(items) {
+		truncation.Total = len(items)
+	}
+	if end < len(items) {
+		truncation.Truncated = true
+		truncation.NextCursor = base64.RawURLEncoding.EncodeToString([]byte(key(items[end-1])))
+	}
+	return items[start:end], truncation
+}
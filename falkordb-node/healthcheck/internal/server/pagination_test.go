@@ -0,0 +1,97 @@
+package server
+
+import (
+	"errors"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestParsePage(t *testing.T) {
+	tests := []struct {
+		query  string
+		want   Page
+		reason string
+	}{
+		{query: "", want: Page{Limit: 10}},
+		{query: "limit=3", want: Page{Limit: 3}},
+		{query: "limit=10", want: Page{Limit: 10}},
+		{query: "limit=11", want: Page{Limit: 10}},
+		{query: "limit=0", reason: "INVALID_LIMIT"},
+		{query: "limit=-1", reason: "INVALID_LIMIT"},
+		{query: "limit=ten", reason: "INVALID_LIMIT"},
+		{query: "cursor=Yg", want: Page{Limit: 10, After: "b"}},
+		{query: "limit=2&cursor=Yg", want: Page{Limit: 2, After: "b"}},
+		{query: "cursor=%21%21", reason: "INVALID_CURSOR"},
+		{query: "cursor=Yg==", reason: "INVALID_CURSOR"},
+	}
+	for _, tt := range tests {
+		query, err := url.ParseQuery(tt.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		page, err := ParsePage(query, 10)
+		var pageErr *PageError
+		switch {
+		case tt.reason != "":
+			if !errors.As(err, &pageErr) || pageErr.Reason != tt.reason {
+				t.Errorf("ParsePage(%q) error = %v, want %s", tt.query, err, tt.reason)
+			}
+		case err != nil:
+			t.Errorf("ParsePage(%q) error = %v", tt.query, err)
+		case page != tt.want:
+			t.Errorf("ParsePage(%q) = %+v, want %+v", tt.query, page, tt.want)
+		}
+	}
+}
+
+func TestPageOf(t *testing.T) {
+	items := []string{"a", "b", "c", "d", "e"}
+	key := func(item string) string { return item }
+
+	tests := []struct {
+		name  string
+		page  Page
+		want  []string
+		trunc Truncation
+	}{
+		{name: "whole list", page: Page{Limit: 5}, want: items},
+		{name: "limit above the list", page: Page{Limit: 10}, want: items},
+		{name: "first page", page: Page{Limit: 2}, want: []string{"a", "b"}, trunc: Truncation{Truncated: true, Total: 5, NextCursor: "Yg"}},
+		{name: "middle page", page: Page{Limit: 2, After: "b"}, want: []string{"c", "d"}, trunc: Truncation{Truncated: true, Total: 5, NextCursor: "ZA"}},
+		{name: "last page", page: Page{Limit: 2, After: "d"}, want: []string{"e"}, trunc: Truncation{Total: 5}},
+		{name: "page ending on the last item", page: Page{Limit: 3, After: "b"}, want: []string{"c", "d", "e"}, trunc: Truncation{Total: 5}},
+		{name: "after the last item", page: Page{Limit: 2, After: "e"}, want: []string{}, trunc: Truncation{Total: 5}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, trunc := PageOf(items, key, tt.page)
+			if !reflect.DeepEqual(got, tt.want) || trunc != tt.trunc {
+				t.Errorf("PageOf = %v %+v, want %v %+v", got, trunc, tt.want, tt.trunc)
+			}
+		})
+	}
+}
+
+// TestPageOfCursorStability walks the pages while items are added and
+// removed in between, which neither skips nor repeats the items kept
+func TestPageOfCursorStability(t *testing.T) {
+	key := func(item string) string { return item }
+
+	first, trunc := PageOf([]string{"b", "d", "f", "h"}, key, Page{Limit: 2})
+	if !reflect.DeepEqual(first, []string{"b", "d"}) || !trunc.Truncated {
+		t.Fatalf("first page = %v %+v", first, trunc)
+	}
+	query := url.Values{"cursor": {trunc.NextCursor}, "limit": {"2"}}
+	page, err := ParsePage(query, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// b and d, the cursor, are gone and a, c and e were added
+	second, trunc := PageOf([]string{"a", "c", "e", "f", "h"}, key, page)
+	if !reflect.DeepEqual(second, []string{"e", "f"}) || trunc.NextCursor == "" {
+		t.Errorf("second page = %v %+v, want e and f then more", second, trunc)
+	}
+}